@@ -0,0 +1,65 @@
+package banklink
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// New returns the bank link provider picked by the config. When it can't be set up an unavailable
+// provider is returned with the error, so the rest of the API keeps working without bank linking.
+func New(cfg *config.PlaidConfig) (model.BankLinkProvider, error) {
+	switch cfg.Provider {
+	case "fake":
+		if cfg.AlpacaEnv == "prod" {
+			return Unavailable{}, errors.New("the fake bank link provider can't be used in prod")
+		}
+		return NewFake(), nil
+	case "", "plaid":
+		p, err := NewPlaid(cfg)
+		if err != nil {
+			return Unavailable{}, err
+		}
+		return p, nil
+	}
+	return Unavailable{}, fmt.Errorf("unknown bank link provider %q", cfg.Provider)
+}
+
+var errUnavailable = apperr.New(http.StatusServiceUnavailable, "Bank linking is unavailable. Try again later.")
+
+// Unavailable is the provider used when bank linking isn't configured, every call fails
+type Unavailable struct{}
+
+// CreateLinkToken fails as bank linking is unavailable
+func (Unavailable) CreateLinkToken(clientUserID, accessToken string) (string, error) {
+	return "", errUnavailable
+}
+
+// ExchangePublicToken fails as bank linking is unavailable
+func (Unavailable) ExchangePublicToken(publicToken string) (*model.BankLinkItem, error) {
+	return nil, errUnavailable
+}
+
+// GetAuth fails as bank linking is unavailable
+func (Unavailable) GetAuth(accessToken, accountID string) (*model.BankAuth, error) {
+	return nil, errUnavailable
+}
+
+// GetIdentity fails as bank linking is unavailable
+func (Unavailable) GetIdentity(accessToken, accountID string) (*model.BankIdentity, error) {
+	return nil, errUnavailable
+}
+
+// RemoveItem fails as bank linking is unavailable
+func (Unavailable) RemoveItem(accessToken string) error {
+	return errUnavailable
+}
+
+// VerifyWebhook fails as bank linking is unavailable
+func (Unavailable) VerifyWebhook(signature string, body []byte) error {
+	return errUnavailable
+}
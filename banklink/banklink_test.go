@@ -0,0 +1,26 @@
+package banklink_test
+
+import (
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/banklink"
+	"github.com/alpacahq/ribbit-backend/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	p, err := banklink.New(&config.PlaidConfig{Provider: "fake", AlpacaEnv: "dev"})
+	assert.NoError(t, err)
+	assert.IsType(t, &banklink.Fake{}, p)
+
+	for _, cfg := range []*config.PlaidConfig{
+		{Provider: "fake", AlpacaEnv: "prod"},
+		{Provider: "unknown"},
+	} {
+		p, err := banklink.New(cfg)
+		assert.Error(t, err)
+		_, err = p.CreateLinkToken("1", "")
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,109 @@
+package banklink
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// NewFake creates an in-memory bank link provider, every public token links a made up bank
+func NewFake() *Fake {
+	return &Fake{items: map[string]string{}}
+}
+
+// Fake is an in-memory bank link provider for tests and local development
+type Fake struct {
+	// WebhookSecret is the signature VerifyWebhook accepts
+	WebhookSecret string
+
+	mu    sync.Mutex
+	next  int
+	items map[string]string
+}
+
+// CreateLinkToken returns a made up link token
+func (f *Fake) CreateLinkToken(clientUserID, accessToken string) (string, error) {
+	if accessToken != "" {
+		if _, err := f.itemID(accessToken); err != nil {
+			return "", err
+		}
+		return "link-fake-update-" + clientUserID, nil
+	}
+	return "link-fake-" + clientUserID, nil
+}
+
+// ExchangePublicToken accepts any public token and links a new item
+func (f *Fake) ExchangePublicToken(publicToken string) (*model.BankLinkItem, error) {
+	if publicToken == "" {
+		return nil, errors.New("public token is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	item := &model.BankLinkItem{
+		AccessToken: fmt.Sprintf("access-fake-%d", f.next),
+		ItemID:      fmt.Sprintf("item-fake-%d", f.next),
+	}
+	f.items[item.AccessToken] = item.ItemID
+	return item, nil
+}
+
+// GetAuth returns the numbers of a checking account at a made up bank
+func (f *Fake) GetAuth(accessToken, accountID string) (*model.BankAuth, error) {
+	if _, err := f.itemID(accessToken); err != nil {
+		return nil, err
+	}
+	return &model.BankAuth{
+		AccountNumber:   "1111222233330000",
+		RoutingNumber:   "021000021",
+		AccountType:     "CHECKING",
+		Mask:            "0000",
+		InstitutionName: "First Platypus Bank",
+	}, nil
+}
+
+// GetIdentity returns a made up account owner
+func (f *Fake) GetIdentity(accessToken, accountID string) (*model.BankIdentity, error) {
+	if _, err := f.itemID(accessToken); err != nil {
+		return nil, err
+	}
+	return &model.BankIdentity{AccountName: "Plaid Checking", OwnerName: "Alberta Bobbeth Charleson"}, nil
+}
+
+// RemoveItem forgets the item of accessToken
+func (f *Fake) RemoveItem(accessToken string) error {
+	if _, err := f.itemID(accessToken); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, accessToken)
+	return nil
+}
+
+// VerifyWebhook accepts webhooks signed with the WebhookSecret
+func (f *Fake) VerifyWebhook(signature string, body []byte) error {
+	if f.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(signature), []byte(f.WebhookSecret)) != 1 {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
+
+// Linked reports whether the item of accessToken is still linked
+func (f *Fake) Linked(accessToken string) bool {
+	_, err := f.itemID(accessToken)
+	return err == nil
+}
+
+func (f *Fake) itemID(accessToken string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	itemID, ok := f.items[accessToken]
+	if !ok {
+		return "", errors.New("unknown access token")
+	}
+	return itemID, nil
+}
@@ -0,0 +1,154 @@
+package banklink
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/plaid/plaid-go/plaid"
+)
+
+var environments = map[string]plaid.Environment{
+	"sandbox":     plaid.Sandbox,
+	"development": plaid.Development,
+	"production":  plaid.Production,
+}
+
+// NewPlaid creates a bank link provider backed by Plaid
+func NewPlaid(cfg *config.PlaidConfig) (*Plaid, error) {
+	name := cfg.Env
+	if name == "" {
+		name = "sandbox"
+	}
+	environment, ok := environments[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown plaid environment %q", cfg.Env)
+	}
+	client, err := plaid.NewClient(plaid.ClientOptions{
+		ClientID:    cfg.ClientID,
+		Secret:      cfg.Secret,
+		Environment: environment,
+		HTTPClient:  &http.Client{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &Plaid{client: client, config: cfg}
+	p.verifier = NewWebhookVerifier(func(keyID string) (*plaid.WebhookVerificationKey, error) {
+		resp, err := client.GetWebhookVerificationKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+		return &resp.Key, nil
+	})
+	return p, nil
+}
+
+// Plaid links bank accounts through Plaid Link
+type Plaid struct {
+	client   *plaid.Client
+	config   *config.PlaidConfig
+	verifier *WebhookVerifier
+}
+
+// CreateLinkToken creates a link token for a new item, or for the item of accessToken in update mode
+func (p *Plaid) CreateLinkToken(clientUserID, accessToken string) (string, error) {
+	configs := plaid.LinkTokenConfigs{
+		User: &plaid.LinkTokenUser{
+			ClientUserID: clientUserID,
+		},
+		ClientName:   "Ribbit",
+		CountryCodes: p.config.CountryCodes,
+		Language:     "en",
+		Webhook:      p.config.WebhookURL,
+		RedirectUri:  p.config.RedirectURI,
+	}
+	// update mode is chosen by passing the access token, it must not list products
+	if accessToken != "" {
+		configs.AccessToken = accessToken
+	} else {
+		configs.Products = p.config.Products
+	}
+	resp, err := p.client.CreateLinkToken(configs)
+	if err != nil {
+		return "", err
+	}
+	return resp.LinkToken, nil
+}
+
+// ExchangePublicToken exchanges the public token of a finished Plaid Link for the item's access token
+func (p *Plaid) ExchangePublicToken(publicToken string) (*model.BankLinkItem, error) {
+	resp, err := p.client.ExchangePublicToken(publicToken)
+	if err != nil {
+		return nil, err
+	}
+	return &model.BankLinkItem{AccessToken: resp.AccessToken, ItemID: resp.ItemID}, nil
+}
+
+// GetAuth returns the ACH numbers of one account of the item
+func (p *Plaid) GetAuth(accessToken, accountID string) (*model.BankAuth, error) {
+	resp, err := p.client.GetAuth(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	auth := &model.BankAuth{AccountType: "CHECKING"}
+	for _, number := range resp.Numbers.ACH {
+		if number.AccountID == accountID {
+			auth.RoutingNumber = number.Routing
+			auth.AccountNumber = number.Account
+		}
+	}
+	if auth.RoutingNumber == "" || auth.AccountNumber == "" {
+		return nil, apperr.New(http.StatusBadRequest, "Bank routing/account number not found")
+	}
+	for _, account := range resp.Accounts {
+		if account.AccountID == accountID {
+			auth.Mask = account.Mask
+			if account.Subtype == "savings" {
+				auth.AccountType = "SAVINGS"
+			}
+		}
+	}
+	// the institution name is only informative, the link works without it
+	if resp.Item.InstitutionID != "" {
+		if inst, err := p.client.GetInstitutionByID(resp.Item.InstitutionID, p.config.CountryCodes); err == nil {
+			auth.InstitutionName = inst.Institution.Name
+		}
+	}
+	return auth, nil
+}
+
+// GetIdentity returns the name and owner of one account of the item
+func (p *Plaid) GetIdentity(accessToken, accountID string) (*model.BankIdentity, error) {
+	resp, err := p.client.GetIdentity(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	identity := new(model.BankIdentity)
+	for _, account := range resp.Accounts {
+		if account.AccountID != accountID {
+			continue
+		}
+		identity.AccountName = account.Name
+		for _, owner := range account.Owners {
+			if len(owner.Names) > 0 {
+				identity.OwnerName = owner.Names[0]
+			}
+		}
+	}
+	return identity, nil
+}
+
+// RemoveItem removes the item at Plaid, its access token stops working
+func (p *Plaid) RemoveItem(accessToken string) error {
+	_, err := p.client.RemoveItem(accessToken)
+	return err
+}
+
+// VerifyWebhook checks the Plaid-Verification header of a webhook
+func (p *Plaid) VerifyWebhook(signature string, body []byte) error {
+	return p.verifier.Verify(signature, body)
+}
@@ -0,0 +1,118 @@
+package banklink
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// webhookMaxAge is how old a signed webhook may be before we treat it as a replay
+const webhookMaxAge = 5 * time.Minute
+
+// WebhookKeyFunc fetches the Plaid webhook verification key with the given key id
+type WebhookKeyFunc func(keyID string) (*plaid.WebhookVerificationKey, error)
+
+// NewWebhookVerifier creates a verifier for the Plaid-Verification header, keys are cached by key id
+func NewWebhookVerifier(fetch WebhookKeyFunc) *WebhookVerifier {
+	return &WebhookVerifier{fetch: fetch, keys: map[string]*ecdsa.PublicKey{}}
+}
+
+// WebhookVerifier checks that a webhook was signed by Plaid and that the body wasn't altered
+type WebhookVerifier struct {
+	fetch WebhookKeyFunc
+	mu    sync.Mutex
+	keys  map[string]*ecdsa.PublicKey
+}
+
+// webhookClaims are the claims of the JWT in the Plaid-Verification header
+type webhookClaims struct {
+	IssuedAt          int64  `json:"iat"`
+	RequestBodySHA256 string `json:"request_body_sha256"`
+}
+
+// Valid implements jwt.Claims
+func (c *webhookClaims) Valid() error {
+	issued := time.Unix(c.IssuedAt, 0)
+	if time.Since(issued) > webhookMaxAge || time.Until(issued) > time.Minute {
+		return errors.New("webhook is too old")
+	}
+	return nil
+}
+
+// Verify checks the signed JWT sent in the Plaid-Verification header against the raw webhook body
+func (v *WebhookVerifier) Verify(signature string, body []byte) error {
+	if signature == "" {
+		return errors.New("missing Plaid-Verification header")
+	}
+	claims := new(webhookClaims)
+	_, err := jwt.ParseWithClaims(signature, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodES256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.key(kid)
+	})
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(claims.RequestBodySHA256)) != 1 {
+		return errors.New("webhook body doesn't match its signature")
+	}
+	return nil
+}
+
+// key returns the public key with the given id, fetching it from Plaid the first time it is seen
+func (v *WebhookVerifier) key(kid string) (*ecdsa.PublicKey, error) {
+	if kid == "" {
+		return nil, errors.New("missing key id")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	jwk, err := v.fetch(kid)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.ExpiredAt != 0 && time.Unix(jwk.ExpiredAt, 0).Before(time.Now()) {
+		return nil, errors.New("verification key expired")
+	}
+	key, err := ecdsaKey(jwk)
+	if err != nil {
+		return nil, err
+	}
+	v.keys[kid] = key
+	return key, nil
+}
+
+// ecdsaKey converts a P-256 JWK into a public key
+func ecdsaKey(jwk *plaid.WebhookVerificationKey) (*ecdsa.PublicKey, error) {
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return nil, errors.New("unsupported verification key")
+	}
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("invalid verification key")
+	}
+	return key, nil
+}
@@ -0,0 +1,73 @@
+package banklink_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/banklink"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/plaid/plaid-go/plaid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched := 0
+	v := banklink.NewWebhookVerifier(func(kid string) (*plaid.WebhookVerificationKey, error) {
+		fetched++
+		return &plaid.WebhookVerificationKey{
+			Kty: "EC",
+			Crv: "P-256",
+			Kid: kid,
+			X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+		}, nil
+	})
+	body := []byte(`{"webhook_type":"ITEM","webhook_code":"PENDING_EXPIRATION","item_id":"item-1"}`)
+	sign := func(method jwt.SigningMethod, signingKey interface{}, issued time.Time, signed []byte) string {
+		sum := sha256.Sum256(signed)
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"iat":                 issued.Unix(),
+			"request_body_sha256": hex.EncodeToString(sum[:]),
+		})
+		token.Header["kid"] = "key-1"
+		s, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	cases := []struct {
+		name      string
+		signature string
+		wantErr   bool
+	}{
+		{name: "valid", signature: sign(jwt.SigningMethodES256, key, time.Now(), body)},
+		{name: "missing header", signature: "", wantErr: true},
+		{name: "tampered body", signature: sign(jwt.SigningMethodES256, key, time.Now(), []byte(`{}`)), wantErr: true},
+		{name: "too old", signature: sign(jwt.SigningMethodES256, key, time.Now().Add(-10*time.Minute), body), wantErr: true},
+		{name: "wrong algorithm", signature: sign(jwt.SigningMethodHS256, []byte("secret"), time.Now(), body), wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(tt.signature, body)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+	assert.Equal(t, 1, fetched, "keys are cached by key id")
+}
@@ -0,0 +1,339 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+)
+
+// NewBroker creates a new broker service implementation
+func NewBroker(config *config.BrokerConfig) *Broker {
+	return &Broker{config, &http.Client{}}
+}
+
+// Broker provides a broker service implementation
+type Broker struct {
+	config *config.BrokerConfig
+	client *http.Client
+}
+
+// Document is an account document as accepted by the broker's documents API
+type Document struct {
+	DocumentType    string `json:"document_type"`
+	DocumentSubType string `json:"document_sub_type,omitempty"`
+	Content         string `json:"content" redact:"true"` // base64 encoded
+	MimeType        string `json:"mime_type"`
+}
+
+// Account is a brokerage account as returned by the broker's accounts API
+type Account struct {
+	ID            string `json:"id"`
+	AccountNumber string `json:"account_number"`
+	Status        string `json:"status"`
+	Currency      string `json:"currency"`
+	LastEquity    string `json:"last_equity"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// TrustedContact is the trusted contact attached to a brokerage account
+type TrustedContact struct {
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+	Email         string   `json:"email_address,omitempty"`
+	Phone         string   `json:"phone_number,omitempty"`
+	StreetAddress []string `json:"street_address,omitempty"`
+	City          string   `json:"city,omitempty"`
+	State         string   `json:"state,omitempty"`
+	PostalCode    string   `json:"postal_code,omitempty"`
+	Country       string   `json:"country,omitempty"`
+}
+
+// TradingAccount holds the balances of a brokerage account as returned by the trading API
+type TradingAccount struct {
+	ID               string `json:"id"`
+	Cash             string `json:"cash"`
+	CashWithdrawable string `json:"cash_withdrawable"`
+	BuyingPower      string `json:"buying_power"`
+}
+
+// ACHRelationship is a bank account linked to a brokerage account
+type ACHRelationship struct {
+	ID               string `json:"id"`
+	AccountID        string `json:"account_id"`
+	Status           string `json:"status"`
+	AccountOwnerName string `json:"account_owner_name"`
+	BankAccountType  string `json:"bank_account_type"`
+	Nickname         string `json:"nickname"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// ACHRelationshipRequest attaches a bank account to a brokerage account
+type ACHRelationshipRequest struct {
+	AccountOwnerName  string `json:"account_owner_name"`
+	BankAccountType   string `json:"bank_account_type"`
+	BankAccountNumber string `json:"bank_account_number" redact:"true"`
+	BankRoutingNumber string `json:"bank_routing_number"`
+	Nickname          string `json:"nickname,omitempty"`
+}
+
+// TransferRequest creates a new transfer for a brokerage account
+type TransferRequest struct {
+	TransferType   string `json:"transfer_type"`
+	RelationshipID string `json:"relationship_id"`
+	Amount         string `json:"amount"`
+	Direction      string `json:"direction"`
+}
+
+// Transfer is a transfer as returned by the broker's transfers API
+type Transfer struct {
+	ID             string `json:"id"`
+	RelationshipID string `json:"relationship_id"`
+	AccountID      string `json:"account_id"`
+	Type           string `json:"type"`
+	Status         string `json:"status"`
+	Amount         string `json:"amount"`
+	Direction      string `json:"direction"`
+	Reason         string `json:"reason"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// Journal entry types, JNLC moves cash and JNLS moves shares between accounts
+const (
+	JournalCash  = "JNLC"
+	JournalStock = "JNLS"
+)
+
+// JournalExecuted is the status of a journal which moved the cash or shares, a journal may still be
+// rejected, canceled or refused until then
+const JournalExecuted = "executed"
+
+// JournalRequest moves cash or shares from one account to another
+type JournalRequest struct {
+	FromAccount string `json:"from_account"`
+	ToAccount   string `json:"to_account"`
+	EntryType   string `json:"entry_type"`
+	Amount      string `json:"amount,omitempty"`
+	Symbol      string `json:"symbol,omitempty"`
+	Qty         string `json:"qty,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Journal is a movement of cash or shares between accounts
+type Journal struct {
+	ID          string `json:"id"`
+	EntryType   string `json:"entry_type"`
+	FromAccount string `json:"from_account"`
+	ToAccount   string `json:"to_account"`
+	Status      string `json:"status"`
+	NetAmount   string `json:"net_amount"`
+	Symbol      string `json:"symbol"`
+	Qty         string `json:"qty"`
+}
+
+// Asset is a tradable instrument as returned by the broker's assets API
+type Asset struct {
+	ID           string `json:"id"`
+	Class        string `json:"class"`
+	Symbol       string `json:"symbol"`
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	Tradable     bool   `json:"tradable"`
+	Fractionable bool   `json:"fractionable"`
+}
+
+// OrderRequest places an order for an account, either Qty or Notional, a dollar amount, is set
+type OrderRequest struct {
+	Symbol        string `json:"symbol"`
+	Qty           string `json:"qty,omitempty"`
+	Notional      string `json:"notional,omitempty"`
+	Side          string `json:"side"`
+	Type          string `json:"type"`
+	TimeInForce   string `json:"time_in_force"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+}
+
+// Order is an order as returned by the broker's trading API
+type Order struct {
+	ID            string `json:"id"`
+	ClientOrderID string `json:"client_order_id"`
+	Symbol        string `json:"symbol"`
+	Notional      string `json:"notional"`
+	Qty           string `json:"qty"`
+	Side          string `json:"side"`
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+}
+
+// errorBody is the error payload returned by the broker
+type errorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// UploadDocuments forwards documents to the broker for the given account
+func (b *Broker) UploadDocuments(accountID string, docs []Document) error {
+	_, err := b.send("POST", "/v1/accounts/"+accountID+"/documents/upload", docs, nil)
+	return err
+}
+
+// GetAccount returns the broker account with the given id
+func (b *Broker) GetAccount(accountID string) (*Account, error) {
+	account := new(Account)
+	if _, err := b.send("GET", "/v1/accounts/"+accountID, nil, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// UpdateAccount patches an existing broker account, only the keys present in patch are changed
+func (b *Broker) UpdateAccount(accountID string, patch map[string]interface{}) error {
+	_, err := b.send("PATCH", "/v1/accounts/"+accountID, patch, nil)
+	return err
+}
+
+// GetTradingAccount returns the balances of the given account
+func (b *Broker) GetTradingAccount(accountID string) (*TradingAccount, error) {
+	account := new(TradingAccount)
+	if _, err := b.send("GET", "/v1/trading/accounts/"+accountID+"/account", nil, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ListACHRelationships returns the banks linked to the given account
+func (b *Broker) ListACHRelationships(accountID string) ([]ACHRelationship, error) {
+	var relationships []ACHRelationship
+	if _, err := b.send("GET", "/v1/accounts/"+accountID+"/ach_relationships", nil, &relationships); err != nil {
+		return nil, err
+	}
+	return relationships, nil
+}
+
+// CreateACHRelationship attaches a bank account to the given account
+func (b *Broker) CreateACHRelationship(accountID string, r *ACHRelationshipRequest) (*ACHRelationship, error) {
+	relationship := new(ACHRelationship)
+	if _, err := b.send("POST", "/v1/accounts/"+accountID+"/ach_relationships", r, relationship); err != nil {
+		return nil, err
+	}
+	return relationship, nil
+}
+
+// DeleteACHRelationship detaches a bank account from the given account
+func (b *Broker) DeleteACHRelationship(accountID, relationshipID string) error {
+	_, err := b.send("DELETE", "/v1/accounts/"+accountID+"/ach_relationships/"+relationshipID, nil, nil)
+	return err
+}
+
+// CreateTransfer requests a new transfer for the given account
+func (b *Broker) CreateTransfer(accountID string, t *TransferRequest) (*Transfer, error) {
+	transfer := new(Transfer)
+	if _, err := b.send("POST", "/v1/accounts/"+accountID+"/transfers", t, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// ListTransfers returns a page of at most limit transfers of the given account, newest first, skipping the first offset
+func (b *Broker) ListTransfers(accountID string, limit, offset int) ([]Transfer, error) {
+	var transfers []Transfer
+	path := "/v1/accounts/" + accountID + "/transfers?limit=" + strconv.Itoa(limit) + "&offset=" + strconv.Itoa(offset)
+	if _, err := b.send("GET", path, nil, &transfers); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// DeleteTransfer cancels a transfer of the given account which hasn't been sent to clearing yet
+// and returns the broker's response as is
+func (b *Broker) DeleteTransfer(accountID, transferID string) (interface{}, error) {
+	var out interface{}
+	if _, err := b.send("DELETE", "/v1/accounts/"+accountID+"/transfers/"+transferID, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateJournal moves cash or shares between two accounts
+func (b *Broker) CreateJournal(j *JournalRequest) (*Journal, error) {
+	journal := new(Journal)
+	if _, err := b.send("POST", "/v1/journals", j, journal); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// GetJournal returns a journal, its status tells whether the movement went through
+func (b *Broker) GetJournal(journalID string) (*Journal, error) {
+	journal := new(Journal)
+	if _, err := b.send("GET", "/v1/journals/"+journalID, nil, journal); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// GetAsset returns the asset with the given symbol or id
+func (b *Broker) GetAsset(symbol string) (*Asset, error) {
+	asset := new(Asset)
+	if _, err := b.send("GET", "/v1/assets/"+symbol, nil, asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// CreateOrder places an order for the given account
+func (b *Broker) CreateOrder(accountID string, o *OrderRequest) (*Order, error) {
+	order := new(Order)
+	if _, err := b.send("POST", "/v1/trading/accounts/"+accountID+"/orders", o, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// send performs a request against the broker API, decoding a successful response into out when it is not nil
+func (b *Broker) send(method, path string, body interface{}, out interface{}) (int, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, b.config.APIBase+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Add("Authorization", b.config.Token)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, apperr.New(http.StatusBadGateway, "Something went wrong. Try again later.")
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, apperr.New(http.StatusBadGateway, "Something went wrong. Try again later.")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := errorBody{}
+		json.Unmarshal(data, &e)
+		if e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return resp.StatusCode, apperr.New(resp.StatusCode, e.Message)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,19 @@
+package broker
+
+// Service is the interface to the Alpaca Broker API
+type Service interface {
+	UploadDocuments(accountID string, docs []Document) error
+	GetAccount(accountID string) (*Account, error)
+	UpdateAccount(accountID string, patch map[string]interface{}) error
+	GetTradingAccount(accountID string) (*TradingAccount, error)
+	ListACHRelationships(accountID string) ([]ACHRelationship, error)
+	CreateACHRelationship(accountID string, r *ACHRelationshipRequest) (*ACHRelationship, error)
+	DeleteACHRelationship(accountID, relationshipID string) error
+	CreateTransfer(accountID string, t *TransferRequest) (*Transfer, error)
+	ListTransfers(accountID string, limit, offset int) ([]Transfer, error)
+	DeleteTransfer(accountID, transferID string) (interface{}, error)
+	CreateJournal(j *JournalRequest) (*Journal, error)
+	GetJournal(journalID string) (*Journal, error)
+	GetAsset(symbol string) (*Asset, error)
+	CreateOrder(accountID string, o *OrderRequest) (*Order, error)
+}
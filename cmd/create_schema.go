@@ -2,14 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
 	"github.com/alpacahq/ribbit-backend/manager"
 	"github.com/alpacahq/ribbit-backend/repository"
 	"github.com/alpacahq/ribbit-backend/secret"
 
 	"github.com/spf13/cobra"
-	"go.uber.org/zap"
 )
 
 // createschemaCmd represents the createschema command
@@ -21,7 +22,7 @@ var createSchemaCmd = &cobra.Command{
 		fmt.Println("createschema called")
 
 		db := config.GetConnection()
-		log, _ := zap.NewDevelopment()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
 		defer log.Sync()
 		accountRepo := repository.NewAccountRepo(db, log, secret.New())
 		roleRepo := repository.NewRoleRepo(db, log)
@@ -2,15 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 
 	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
 	"github.com/alpacahq/ribbit-backend/manager"
 	"github.com/alpacahq/ribbit-backend/repository"
 	"github.com/alpacahq/ribbit-backend/secret"
 
 	"github.com/spf13/cobra"
-	"go.uber.org/zap"
 )
 
 var email string
@@ -35,7 +36,7 @@ var createSuperAdminCmd = &cobra.Command{
 		}
 
 		db := config.GetConnection()
-		log, _ := zap.NewDevelopment()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
 		defer log.Sync()
 		accountRepo := repository.NewAccountRepo(db, log, secret.New())
 		roleRepo := repository.NewRoleRepo(db, log)
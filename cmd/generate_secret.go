@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/alpacahq/ribbit-backend/secret"
 
@@ -20,6 +22,12 @@ var generateSecretCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		fmt.Printf("\nJWT_SECRET=%s\n\n", s)
+
+		k, err := secret.GenerateRandomBytes(32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("ENCRYPTION_KEYS=%s:%s\n\n", time.Now().Format("20060102"), base64.StdEncoding.EncodeToString(k))
 	},
 }
 
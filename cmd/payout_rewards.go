@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
+	"github.com/alpacahq/ribbit-backend/repository"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/spf13/cobra"
+)
+
+var payoutDryRun bool
+
+// payoutRewardsCmd represents the payout_rewards command
+var payoutRewardsCmd = &cobra.Command{
+	Use:   "payout_rewards",
+	Short: "payout_rewards journals every referral reward still owed",
+	Long:  `payout_rewards checks the journals of paid referral rewards with the broker, reports the failed and dead-lettered rewards and their total liability, then journals them from the sweep account unless --dry-run is given`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := config.GetConnection()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
+		defer log.Sync()
+
+		userRepo := repository.NewUserRepo(db, log)
+		svc := reward.NewRewardService(
+			userRepo,
+			repository.NewRewardRepo(db, log),
+			repository.NewBankAccountRepo(db, log),
+			repository.NewRBACService(userRepo),
+			broker.NewBroker(config.GetBrokerConfig()),
+			config.GetRewardConfig(),
+			log,
+		)
+		if !payoutDryRun {
+			failed, err := svc.Reconcile()
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Printf("%d paid reward(s) whose journal was rejected are owed again\n", failed)
+		}
+		rewards, total, err := svc.Outstanding()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		for _, r := range rewards {
+			fmt.Printf("reward %d\tuser %d\t%s\t$%.2f\t%s\t%d attempt(s)\t%s\n", r.ID, r.UserID, r.RewardType, r.RewardValue, r.Status, r.Attempts, r.ErrorResponse)
+		}
+		fmt.Printf("%d reward(s) outstanding, total liability $%.2f\n", len(rewards), total)
+		if payoutDryRun || len(rewards) == 0 {
+			return
+		}
+
+		paid, err := svc.Payout()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("%d reward(s) paid, %d still outstanding\n", paid, len(rewards)-paid)
+	},
+}
+
+func init() {
+	payoutRewardsCmd.Flags().BoolVar(&payoutDryRun, "dry-run", false, "only report the outstanding rewards and their total")
+	rootCmd.AddCommand(payoutRewardsCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
+	"github.com/alpacahq/ribbit-backend/repository"
+	"github.com/alpacahq/ribbit-backend/repository/document"
+	"github.com/alpacahq/ribbit-backend/secret"
+	"github.com/spf13/cobra"
+)
+
+// encryptedColumns lists the columns stored as secret.EncryptedString
+var encryptedColumns = []struct{ table, column string }{
+	{"users", "tax_id"},
+	{"users", "dob"},
+	{"users", "mfa_secret"},
+	{"bank_accounts", "access_token"},
+}
+
+// rotateKeysCmd represents the rotate_keys command
+var rotateKeysCmd = &cobra.Command{
+	Use:   "rotate_keys",
+	Short: "rotate_keys re-encrypts sensitive data with the primary encryption key",
+	Long: `rotate_keys re-encrypts encrypted columns and stored documents with the key set in ENCRYPTION_KEY_ID.
+Plaintext values written before encryption was enabled are encrypted as well. Keep the old keys in
+ENCRYPTION_KEYS until the command has finished, and keep the key named in HASH_KEY_ID for good.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := config.GetConnection()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
+		defer log.Sync()
+
+		storage := config.GetStorageConfig()
+		keyring, err := storage.Keyring()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		secret.UseFieldKeyring(keyring)
+
+		encryptionRepo := repository.NewEncryptionRepo(db, log)
+		for _, c := range encryptedColumns {
+			n, err := encryptionRepo.RotateColumn(c.table, c.column, keyring)
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Printf("%s.%s: %d value(s) re-encrypted with key %s\n", c.table, c.column, n, keyring.PrimaryID())
+		}
+
+		documentService := document.NewDocumentService(nil, repository.NewDocumentRepo(db, log), nil, keyring, storage.DocumentPath, log)
+		n, err := documentService.Reencrypt()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("documents: %d file(s) re-encrypted with key %s\n", n, keyring.PrimaryID())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateKeysCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
+	"github.com/alpacahq/ribbit-backend/mail"
+	"github.com/alpacahq/ribbit-backend/mobile"
+	"github.com/alpacahq/ribbit-backend/repository"
+	"github.com/alpacahq/ribbit-backend/repository/accountsync"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/spf13/cobra"
+)
+
+// syncAccountsCmd represents the sync_accounts command
+var syncAccountsCmd = &cobra.Command{
+	Use:   "sync_accounts",
+	Short: "sync_accounts syncs the status of all broker accounts",
+	Long:  `sync_accounts fetches the broker account status of every user with an account, stores changes and notifies the users`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := config.GetConnection()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
+		defer log.Sync()
+
+		b := broker.NewBroker(config.GetBrokerConfig())
+		userRepo := repository.NewUserRepo(db, log)
+		svc := accountsync.NewAccountSyncService(
+			repository.NewAccountStatusRepo(db, log),
+			b,
+			mail.NewMail(config.GetMailConfig(), config.GetSiteConfig()),
+			mobile.NewMobile(config.GetTwilioConfig(), log),
+			reward.NewRewardService(userRepo, repository.NewRewardRepo(db, log), repository.NewBankAccountRepo(db, log), repository.NewRBACService(userRepo), b, config.GetRewardConfig(), log),
+			log,
+		)
+		changed, err := svc.SyncAll()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("%d account(s) changed status\n", changed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncAccountsCmd)
+}
@@ -8,11 +8,11 @@ import (
 	"os"
 
 	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository"
 	"github.com/alpacahq/ribbit-backend/secret"
 	"github.com/spf13/cobra"
-	"go.uber.org/zap"
 )
 
 // syncAssetsCmd represents the syncAssets command
@@ -23,7 +23,7 @@ var syncAssetsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("syncAssets called")
 		db := config.GetConnection()
-		log, _ := zap.NewDevelopment()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
 		defer log.Sync()
 		assetRepo := repository.NewAssetRepo(db, log, secret.New())
 
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
+	"github.com/alpacahq/ribbit-backend/mail"
+	"github.com/alpacahq/ribbit-backend/mobile"
+	"github.com/alpacahq/ribbit-backend/repository"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/alpacahq/ribbit-backend/repository/transfersync"
+	"github.com/spf13/cobra"
+)
+
+// syncTransfersCmd represents the sync_transfers command
+var syncTransfersCmd = &cobra.Command{
+	Use:   "sync_transfers",
+	Short: "sync_transfers syncs the status of all pending transfers",
+	Long:  `sync_transfers fetches the broker status of every pending transfer, records changes on its timeline and notifies the users`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := config.GetConnection()
+		log := logger.New(os.Getenv("ALPACA_ENV"))
+		defer log.Sync()
+
+		b := broker.NewBroker(config.GetBrokerConfig())
+		userRepo := repository.NewUserRepo(db, log)
+		svc := transfersync.NewTransferSyncService(
+			repository.NewTransferRepo(db, log),
+			userRepo,
+			b,
+			mail.NewMail(config.GetMailConfig(), config.GetSiteConfig()),
+			mobile.NewMobile(config.GetTwilioConfig(), log),
+			reward.NewRewardService(userRepo, repository.NewRewardRepo(db, log), repository.NewBankAccountRepo(db, log), repository.NewRBACService(userRepo), b, config.GetRewardConfig(), log),
+			log,
+		)
+		changed, err := svc.SyncAll()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("%d transfer(s) changed status\n", changed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncTransfersCmd)
+}
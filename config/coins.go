@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// CoinsConfig persists the config for redeeming coins
+type CoinsConfig struct {
+	// USDRate is the dollar value of a single coin
+	USDRate float64 `env:"COINS_USD_RATE" envDefault:"0.01"`
+	// FirmAccountID is the firm account the cash of redeemed coins is journaled from
+	FirmAccountID string `env:"COINS_FIRM_ACCOUNT"`
+	// MinRedemption is the smallest dollar value coins can be redeemed for, the broker's minimum notional order
+	MinRedemption float64 `env:"COINS_MIN_REDEMPTION" envDefault:"1"`
+}
+
+// GetCoinsConfig returns a CoinsConfig pointer with the correct Coins Config values
+func GetCoinsConfig() *CoinsConfig {
+	c := CoinsConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
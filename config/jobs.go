@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// JobsConfig persists the config for our periodic background jobs
+type JobsConfig struct {
+	AccountSyncInterval      time.Duration `env:"ACCOUNT_SYNC_INTERVAL" envDefault:"15m"`
+	TransferSyncInterval     time.Duration `env:"TRANSFER_SYNC_INTERVAL" envDefault:"10m"`
+	RecurringDepositInterval time.Duration `env:"RECURRING_DEPOSIT_INTERVAL" envDefault:"1h"`
+	RewardRetryInterval      time.Duration `env:"REWARD_RETRY_INTERVAL" envDefault:"5m"`
+	LeaderboardInterval      time.Duration `env:"REFERRAL_LEADERBOARD_INTERVAL" envDefault:"1h"`
+	PasskeyPurgeInterval     time.Duration `env:"PASSKEY_CHALLENGE_PURGE_INTERVAL" envDefault:"1h"`
+}
+
+// GetJobsConfig returns a JobsConfig pointer with the correct Jobs Config values
+func GetJobsConfig() *JobsConfig {
+	c := JobsConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
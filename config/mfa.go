@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// MFAConfig persists the config for TOTP two-factor authentication
+type MFAConfig struct {
+	// Issuer names the app in the user's authenticator
+	Issuer string `env:"MFA_ISSUER" envDefault:"Ribbit"`
+	// ChallengeTTL is how long after the password step the second factor can be entered
+	ChallengeTTL time.Duration `env:"MFA_CHALLENGE_TTL" envDefault:"5m"`
+	// ChallengeAttempts is how many wrong codes a login challenge takes before it is used up
+	ChallengeAttempts int `env:"MFA_CHALLENGE_ATTEMPTS" envDefault:"5"`
+	// FreshWindow is how long a second factor counts as fresh for withdrawals and bank linking, 0 doesn't require one
+	FreshWindow time.Duration `env:"MFA_FRESH_WINDOW" envDefault:"5m"`
+	// LockoutAttempts is how many wrong codes in a row lock a user's second factor for Lockout, 0 never locks it
+	LockoutAttempts int           `env:"MFA_LOCKOUT_ATTEMPTS" envDefault:"10"`
+	Lockout         time.Duration `env:"MFA_LOCKOUT" envDefault:"15m"`
+	// RecoveryCodes is how many single use recovery codes are issued on enrollment
+	RecoveryCodes int `env:"MFA_RECOVERY_CODES" envDefault:"10"`
+}
+
+// GetMFAConfig returns a MFAConfig pointer with the correct MFA Config values
+func GetMFAConfig() *MFAConfig {
+	c := MFAConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
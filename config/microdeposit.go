@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// MicroDepositConfig persists the config for verifying manually entered bank accounts
+type MicroDepositConfig struct {
+	// Provider picks the micro-deposit provider. No real provider is integrated yet, "fake" logs the
+	// amounts instead of sending them and is refused when Env is prod
+	Provider string `env:"MICRO_DEPOSIT_PROVIDER"`
+	Env      string `env:"ALPACA_ENV"`
+}
+
+// GetMicroDepositConfig returns a MicroDepositConfig pointer with the correct MicroDeposit Config values
+func GetMicroDepositConfig() *MicroDepositConfig {
+	c := MicroDepositConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// PasskeyConfig persists the config for WebAuthn passkeys
+type PasskeyConfig struct {
+	// RPID is the domain passkeys are scoped to, it can't change without invalidating all of them
+	RPID string `env:"PASSKEY_RP_ID" envDefault:"localhost"`
+	// RPName names the app in the passkey prompt
+	RPName string `env:"PASSKEY_RP_NAME" envDefault:"Ribbit"`
+	// Origins are the web origins and native app origins passkey ceremonies may run on
+	Origins []string `env:"PASSKEY_ORIGINS" envSeparator:"," envDefault:"http://localhost:8080"`
+	// UserVerification requires a PIN or biometrics on the authenticator, not just the user's presence
+	UserVerification bool `env:"PASSKEY_USER_VERIFICATION" envDefault:"true"`
+	// ChallengeTTL is how long the user has to complete a ceremony
+	ChallengeTTL time.Duration `env:"PASSKEY_CHALLENGE_TTL" envDefault:"5m"`
+	// LoginRateLimit is how many passkey logins one client IP may start within LoginRateWindow, 0 doesn't limit them
+	LoginRateLimit  int           `env:"PASSKEY_LOGIN_RATE_LIMIT" envDefault:"10"`
+	LoginRateWindow time.Duration `env:"PASSKEY_LOGIN_RATE_WINDOW" envDefault:"1m"`
+}
+
+// GetPasskeyConfig returns a PasskeyConfig pointer with the correct Passkey Config values
+func GetPasskeyConfig() *PasskeyConfig {
+	c := PasskeyConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// PlaidConfig persists the config for linking bank accounts
+type PlaidConfig struct {
+	// Provider picks the bank link provider, "plaid" or "fake" for an in-memory stand-in during local development.
+	// The fake is refused when AlpacaEnv is prod
+	Provider     string   `env:"BANK_LINK_PROVIDER" envDefault:"plaid"`
+	AlpacaEnv    string   `env:"ALPACA_ENV"`
+	ClientID     string   `env:"PLAID_CLIENT_ID"`
+	Secret       string   `env:"PLAID_SECRET"`
+	Env          string   `env:"PLAID_ENV" envDefault:"sandbox"`
+	Products     []string `env:"PLAID_PRODUCTS" envSeparator:","`
+	CountryCodes []string `env:"PLAID_COUNTRY_CODES" envSeparator:","`
+	RedirectURI  string   `env:"PLAID_REDIRECT_URI"`
+	WebhookURL   string   `env:"PLAID_WEBHOOK_URL"`
+}
+
+// GetPlaidConfig returns a PlaidConfig pointer with the correct Plaid Config values
+func GetPlaidConfig() *PlaidConfig {
+	c := PlaidConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
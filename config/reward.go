@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// RewardConfig persists the config for referral rewards
+type RewardConfig struct {
+	// SweepAccountID is the firm account rewards are journaled from
+	SweepAccountID string `env:"REWARD_SWEEP_ACCOUNT"`
+	// RetryBackoff is the delay before the first retry of a failed journal, it doubles after every attempt
+	RetryBackoff time.Duration `env:"REWARD_RETRY_BACKOFF" envDefault:"15m"`
+	// MaxAttempts is the number of failed attempts after which a reward is dead-lettered
+	MaxAttempts int `env:"REWARD_MAX_ATTEMPTS" envDefault:"6"`
+	// StuckAfter is how long a reward may stay pending or processing before its payout counts as interrupted
+	StuckAfter time.Duration `env:"REWARD_STUCK_AFTER" envDefault:"1h"`
+	// FraudThreshold is the fraud score from which a referral's rewards are held for manual review
+	FraudThreshold int `env:"REFERRAL_FRAUD_THRESHOLD" envDefault:"50"`
+	// VelocityMax is the most signups with a referral code within VelocityWindow before they look suspicious
+	VelocityMax    int           `env:"REFERRAL_VELOCITY_MAX" envDefault:"5"`
+	VelocityWindow time.Duration `env:"REFERRAL_VELOCITY_WINDOW" envDefault:"24h"`
+	// DisposableDomains are the email domains of throwaway mailbox services
+	DisposableDomains []string `env:"REFERRAL_DISPOSABLE_DOMAINS" envSeparator:"," envDefault:"mailinator.com,guerrillamail.com,sharklasers.com,10minutemail.com,tempmail.com,temp-mail.org,yopmail.com,trashmail.com,getnada.com,maildrop.cc,dispostable.com,throwawaymail.com"`
+	// LinkBase is prefixed to a user's referral code to build their referral link
+	LinkBase string `env:"REFERRAL_LINK_BASE" envDefault:"https://alpa.ca/"`
+	// LeaderboardSize is the number of referrers published on the leaderboard
+	LeaderboardSize int `env:"REFERRAL_LEADERBOARD_SIZE" envDefault:"10"`
+}
+
+// GetRewardConfig returns a RewardConfig pointer with the correct Reward Config values
+func GetRewardConfig() *RewardConfig {
+	c := RewardConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// SessionConfig persists the config for login sessions and their refresh tokens
+type SessionConfig struct {
+	// IdleTimeout is how long a session may go without a refresh before its refresh token stops working
+	IdleTimeout time.Duration `env:"SESSION_IDLE_TIMEOUT" envDefault:"720h"`
+}
+
+// GetSessionConfig returns a SessionConfig pointer with the correct Session Config values
+func GetSessionConfig() *SessionConfig {
+	c := SessionConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
@@ -13,6 +13,8 @@ import (
 // SiteConfig persists global configs needed for our application
 type SiteConfig struct {
 	ExternalURL string `env:"EXTERNAL_URL"  envDefault:"http://localhost:8080"`
+	// TrustedProxies are the CIDRs of the proxies allowed to set the client IP, with none the remote address is used
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
 }
 
 // GetSiteConfig returns a SiteConfig pointer with the correct Site Config values
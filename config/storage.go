@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// StorageConfig persists the config for files we keep on disk
+type StorageConfig struct {
+	DocumentPath    string `env:"DOCUMENT_STORAGE_PATH" envDefault:"storage/documents"`
+	EncryptionKey   string `env:"ENCRYPTION_KEY"`    // base64 encoded 32 byte AES-256 key, kept as key id "default"
+	EncryptionKeys  string `env:"ENCRYPTION_KEYS"`   // comma separated id:base64key pairs
+	EncryptionKeyID string `env:"ENCRYPTION_KEY_ID"` // id of the key new data is encrypted with
+	HashKeyID       string `env:"HASH_KEY_ID"`       // id of the key lookup hashes are keyed with, required with several keys
+	Env             string `env:"ALPACA_ENV"`        // the server refuses to start without keys in prod
+}
+
+// Keyring returns the encryption keyring built from the configured keys
+func (c *StorageConfig) Keyring() (*secret.Keyring, error) {
+	k, err := secret.ParseKeyring(c.EncryptionKeys, c.EncryptionKeyID, c.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if c.HashKeyID == "" {
+		if k.HashKeyID() == "" {
+			return nil, errors.New("HASH_KEY_ID must name the key hashes are keyed with when several encryption keys are configured")
+		}
+		return k, nil
+	}
+	if err := k.UseHashKey(c.HashKeyID); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// GetStorageConfig returns a StorageConfig pointer with the correct Storage Config values
+func GetStorageConfig() *StorageConfig {
+	c := StorageConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
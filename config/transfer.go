@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+)
+
+// TransferConfig persists the config for ACH deposits and withdrawals
+type TransferConfig struct {
+	// WithdrawalCoolingOff is how long a newly linked bank has to wait before it can receive withdrawals
+	WithdrawalCoolingOff time.Duration `env:"WITHDRAWAL_COOLING_OFF" envDefault:"72h"`
+	// WithdrawalReauthWindow is how long after a login a withdrawal is allowed without an OTP
+	WithdrawalReauthWindow time.Duration `env:"WITHDRAWAL_REAUTH_WINDOW" envDefault:"5m"`
+	// WithdrawalOTPTTL is how long an emailed withdrawal OTP is accepted
+	WithdrawalOTPTTL time.Duration `env:"WITHDRAWAL_OTP_TTL" envDefault:"10m"`
+	// WithdrawalOTPMaxAttempts is how many wrong guesses an OTP takes before a new one has to be requested
+	WithdrawalOTPMaxAttempts int `env:"WITHDRAWAL_OTP_MAX_ATTEMPTS" envDefault:"5"`
+
+	// default caps per user, in USD, an admin can override them per user. 0 means no limit
+	DepositDailyLimit      float64 `env:"DEPOSIT_DAILY_LIMIT" envDefault:"50000"`
+	DepositWeeklyLimit     float64 `env:"DEPOSIT_WEEKLY_LIMIT" envDefault:"100000"`
+	DepositMonthlyLimit    float64 `env:"DEPOSIT_MONTHLY_LIMIT" envDefault:"250000"`
+	WithdrawalDailyLimit   float64 `env:"WITHDRAWAL_DAILY_LIMIT" envDefault:"50000"`
+	WithdrawalWeeklyLimit  float64 `env:"WITHDRAWAL_WEEKLY_LIMIT" envDefault:"100000"`
+	WithdrawalMonthlyLimit float64 `env:"WITHDRAWAL_MONTHLY_LIMIT" envDefault:"250000"`
+
+	// RecurringDepositMaxReturns is how many returned deposits suspend a recurring deposit
+	RecurringDepositMaxReturns int `env:"RECURRING_DEPOSIT_MAX_RETURNS" envDefault:"2"`
+}
+
+// GetTransferConfig returns a TransferConfig pointer with the correct Transfer Config values
+func GetTransferConfig() *TransferConfig {
+	c := TransferConfig{}
+
+	_, b, _, _ := runtime.Caller(0)
+	d := path.Join(path.Dir(b))
+	projectRoot := filepath.Dir(d)
+	dotenvPath := path.Join(projectRoot, ".env")
+	_ = godotenv.Load(dotenvPath)
+
+	if err := env.Parse(&c); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	return &c
+}
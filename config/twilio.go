@@ -16,6 +16,7 @@ type TwilioConfig struct {
 	Token      string `env:"TWILIO_TOKEN"`
 	VerifyName string `env:"TWILIO_VERIFY_NAME"`
 	Verify     string `env:"TWILIO_VERIFY"`
+	From       string `env:"TWILIO_FROM"`
 }
 
 // GetTwilioConfig returns a TwilioConfig pointer with the correct Mail Config values
@@ -119,7 +119,7 @@ func (suite *E2ETestSuite) SetupSuite() {
 	}
 
 	// setup routes
-	rs := route.NewServices(suite.db, log, jwt, m, mobile, r)
+	rs := route.NewServices(suite.db, log, jwt, m, mobile, &mock.Magic{}, &mock.Broker{}, r)
 	rs.SetupV1Routes()
 
 	// we can now test our routes in an end-to-end fashion by making http calls
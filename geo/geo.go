@@ -0,0 +1,82 @@
+package geo
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Country is a country identified by its ISO 3166-1 alpha-3 code
+type Country struct {
+	Name      string `json:"name"`
+	ShortCode string `json:"short_code"`
+}
+
+// Registry holds the country data we validate user input against, it is loaded once at startup
+type Registry struct {
+	countries []Country
+	byCode    map[string]Country
+	us        *usCities
+}
+
+// New returns an empty registry
+func New() *Registry {
+	return &Registry{byCode: map[string]Country{}}
+}
+
+// LoadCountriesFile reads the countries csv at path into the registry
+func (r *Registry) LoadCountriesFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.LoadCountries(f)
+}
+
+// LoadCountries reads "short_code","name" csv records, the header row is skipped
+func (r *Registry) LoadCountries(in io.Reader) error {
+	lines, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return err
+	}
+	for i, line := range lines {
+		if i == 0 || len(line) < 2 {
+			continue
+		}
+		country := Country{ShortCode: strings.ToUpper(strings.TrimSpace(line[0])), Name: strings.TrimSpace(line[1])}
+		if _, ok := r.byCode[country.ShortCode]; ok {
+			continue
+		}
+		r.byCode[country.ShortCode] = country
+		r.countries = append(r.countries, country)
+	}
+	sort.SliceStable(r.countries, func(i, j int) bool {
+		// keep the United States on top as most of our users are there
+		if r.countries[i].ShortCode == "USA" || r.countries[j].ShortCode == "USA" {
+			return r.countries[i].ShortCode == "USA"
+		}
+		return r.countries[i].Name < r.countries[j].Name
+	})
+	return nil
+}
+
+// Countries returns the countries whose name contains q, all of them when q is empty
+func (r *Registry) Countries(q string) []Country {
+	q = strings.ToLower(q)
+	countries := []Country{}
+	for _, c := range r.countries {
+		if q == "" || strings.Contains(strings.ToLower(c.Name), q) {
+			countries = append(countries, c)
+		}
+	}
+	return countries
+}
+
+// ValidCountry reports whether code is a known ISO3 country code
+func (r *Registry) ValidCountry(code string) bool {
+	_, ok := r.byCode[code]
+	return ok
+}
@@ -0,0 +1,59 @@
+package geo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/geo"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const countriesCSV = `"short_code","name"
+"USA","United States"
+"AFG","Afghanistan"
+"GBR","United Kingdom"
+"GBR","United Kingdom"
+`
+
+func TestCountries(t *testing.T) {
+	r := geo.New()
+	assert.Nil(t, r.LoadCountries(strings.NewReader(countriesCSV)))
+
+	assert.Equal(t, []geo.Country{
+		{ShortCode: "USA", Name: "United States"},
+		{ShortCode: "AFG", Name: "Afghanistan"},
+		{ShortCode: "GBR", Name: "United Kingdom"},
+	}, r.Countries(""))
+	assert.Equal(t, []geo.Country{{ShortCode: "GBR", Name: "United Kingdom"}}, r.Countries("kingdom"))
+	assert.True(t, r.ValidCountry("GBR"))
+	assert.False(t, r.ValidCountry("short_code"))
+	assert.False(t, r.ValidCountry("XXX"))
+}
+
+func TestValidateTaxID(t *testing.T) {
+	cases := []struct {
+		name      string
+		country   string
+		taxIDType string
+		taxID     string
+		wantErr   bool
+	}{
+		{"us ssn", "USA", "USA_SSN", "123-45-6789", false},
+		{"us ssn without dashes", "USA", "USA_SSN", "123456789", false},
+		{"us malformed ssn", "USA", "USA_SSN", "12-345-678", true},
+		{"us not specified", "USA", "NOT_SPECIFIED", "", true},
+		{"non us not specified", "AFG", "NOT_SPECIFIED", "", false},
+		{"non us with ssn", "GBR", "USA_SSN", "123-45-6789", true},
+		{"matching country type", "GBR", "GBR_NINO", "QQ123456C", false},
+		{"matching type without id", "GBR", "GBR_NINO", "", true},
+		{"type of another country", "GBR", "DEU_TAX_ID", "12345678901", true},
+		{"unknown type", "GBR", "GBR_PASSPORT", "123", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := geo.ValidateTaxID(tt.country, tt.taxIDType, tt.taxID)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package geo
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Tax ID types accepted by the broker, see the broker API identity object
+const (
+	TaxIDTypeUSASSN       = "USA_SSN"
+	TaxIDTypeNotSpecified = "NOT_SPECIFIED"
+)
+
+// taxIDTypes lists the non US tax id types the broker accepts, each is prefixed with the ISO3 code of its country
+var taxIDTypes = map[string]bool{
+	"ARG_AR_CUIT": true,
+	"AUS_TFN":     true,
+	"AUS_ABN":     true,
+	"BOL_NIT":     true,
+	"BRA_CPF":     true,
+	"CHL_RUT":     true,
+	"COL_NIT":     true,
+	"CRI_NITE":    true,
+	"DEU_TAX_ID":  true,
+	"DOM_RNC":     true,
+	"ECU_RUC":     true,
+	"FRA_SPI":     true,
+	"GBR_UTR":     true,
+	"GBR_NINO":    true,
+	"GTM_NIT":     true,
+	"HND_RTN":     true,
+	"HUN_TIN":     true,
+	"IDN_KTP":     true,
+	"IND_PAN":     true,
+	"ISR_TAX_ID":  true,
+	"ITA_TAX_ID":  true,
+	"JPN_TAX_ID":  true,
+	"MEX_RFC":     true,
+	"NIC_RUC":     true,
+	"NLD_TIN":     true,
+	"PAN_RUC":     true,
+	"PER_RUC":     true,
+	"PRY_RUC":     true,
+	"SGP_NRIC":    true,
+	"SGP_FIN":     true,
+	"SGP_ASGD":    true,
+	"SGP_ITR":     true,
+	"SLV_NIT":     true,
+	"SWE_TAX_ID":  true,
+	"URY_RUT":     true,
+	"VEN_RIF":     true,
+}
+
+var ssn = regexp.MustCompile(`^\d{3}-?\d{2}-?\d{4}$`)
+
+// ValidateTaxID checks that the tax id type fits the country of tax residence, US tax residents need an SSN
+// while everyone else may give a tax id of their own country or none at all
+func ValidateTaxID(countryOfTaxResidence, taxIDType, taxID string) error {
+	if countryOfTaxResidence == "USA" {
+		if taxIDType != TaxIDTypeUSASSN {
+			return errors.New("US tax residents must provide a USA_SSN tax id")
+		}
+		if !ssn.MatchString(taxID) {
+			return errors.New("tax id is not a valid SSN")
+		}
+		return nil
+	}
+	switch {
+	case taxIDType == TaxIDTypeNotSpecified:
+		return nil
+	case taxIDType == TaxIDTypeUSASSN:
+		return errors.New("USA_SSN is only valid for US tax residents")
+	case !taxIDTypes[taxIDType]:
+		return errors.New("unknown tax id type")
+	case !strings.HasPrefix(taxIDType, countryOfTaxResidence+"_"):
+		return errors.New("tax id type does not match the country of tax residence")
+	case strings.TrimSpace(taxID) == "":
+		return errors.New("tax id is required")
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package geo
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// State is a US state
+type State struct {
+	Name      string `json:"name"`
+	ShortCode string `json:"short_code"`
+}
+
+// City is a US city
+type City struct {
+	Name  string `json:"name"`
+	ASCII string `json:"ascii"`
+	LAT   string `json:"lat"`
+	LNG   string `json:"lng"`
+}
+
+// Place is a city within a state, as returned by zip lookups
+type Place struct {
+	City      string `json:"city"`
+	State     string `json:"state"`
+	StateName string `json:"state_name"`
+}
+
+// usCity is a city with the zip codes it covers
+type usCity struct {
+	City
+	state string
+	zips  []string
+}
+
+// usCities holds the uscities.csv data with its lookup indexes
+type usCities struct {
+	states      []State
+	stateByCode map[string]State
+	stateByName map[string]State
+	cities      map[string][]*usCity            // state code -> cities in file order
+	cityByName  map[string]map[string][]*usCity // state code -> lower case name -> cities
+	zips        map[string][]*usCity
+}
+
+// uscities.csv columns we read, the file header is used when present
+var usCityColumns = map[string]int{
+	"city":       0,
+	"city_ascii": 1,
+	"state_id":   2,
+	"state_name": 3,
+	"lat":        6,
+	"lng":        7,
+	"zips":       15,
+}
+
+// LoadUSCitiesFile reads the US cities csv at path into the registry
+func (r *Registry) LoadUSCitiesFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.LoadUSCities(f)
+}
+
+// LoadUSCities reads the simplemaps uscities csv and indexes it by state, city and zip
+func (r *Registry) LoadUSCities(in io.Reader) error {
+	lines, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.New("uscities csv is empty")
+	}
+	cols := map[string]int{}
+	for name, i := range usCityColumns {
+		cols[name] = i
+	}
+	for i, name := range lines[0] {
+		if _, ok := cols[name]; ok {
+			cols[name] = i
+		}
+	}
+	get := func(line []string, name string) string {
+		if cols[name] < len(line) {
+			return strings.TrimSpace(line[cols[name]])
+		}
+		return ""
+	}
+
+	us := &usCities{
+		stateByCode: map[string]State{},
+		stateByName: map[string]State{},
+		cities:      map[string][]*usCity{},
+		cityByName:  map[string]map[string][]*usCity{},
+		zips:        map[string][]*usCity{},
+	}
+	for _, line := range lines[1:] {
+		state := State{ShortCode: get(line, "state_id"), Name: get(line, "state_name")}
+		if state.ShortCode == "" {
+			continue
+		}
+		if _, ok := us.stateByCode[state.ShortCode]; !ok {
+			us.stateByCode[state.ShortCode] = state
+			us.stateByName[strings.ToLower(state.Name)] = state
+			us.states = append(us.states, state)
+			us.cityByName[state.ShortCode] = map[string][]*usCity{}
+		}
+		city := &usCity{
+			City: City{
+				Name:  get(line, "city"),
+				ASCII: get(line, "city_ascii"),
+				LAT:   get(line, "lat"),
+				LNG:   get(line, "lng"),
+			},
+			state: state.ShortCode,
+			zips:  strings.Fields(get(line, "zips")),
+		}
+		us.cities[state.ShortCode] = append(us.cities[state.ShortCode], city)
+		names := us.cityByName[state.ShortCode]
+		names[strings.ToLower(city.Name)] = append(names[strings.ToLower(city.Name)], city)
+		if ascii := strings.ToLower(city.ASCII); ascii != "" && ascii != strings.ToLower(city.Name) {
+			names[ascii] = append(names[ascii], city)
+		}
+		for _, zip := range city.zips {
+			us.zips[zip] = append(us.zips[zip], city)
+		}
+	}
+	sort.Slice(us.states, func(i, j int) bool {
+		return us.states[i].Name < us.states[j].Name
+	})
+	r.us = us
+	return nil
+}
+
+// HasUSCities reports whether US city data was loaded
+func (r *Registry) HasUSCities() bool {
+	return r.us != nil
+}
+
+// States returns the US states whose name contains q
+func (r *Registry) States(q string) []State {
+	states := []State{}
+	if r.us == nil {
+		return states
+	}
+	q = strings.ToLower(q)
+	for _, s := range r.us.states {
+		if q == "" || strings.Contains(strings.ToLower(s.Name), q) {
+			states = append(states, s)
+		}
+	}
+	return states
+}
+
+// Cities returns the cities of a US state whose name contains q
+func (r *Registry) Cities(state, q string) []City {
+	cities := []City{}
+	if r.us == nil {
+		return cities
+	}
+	q = strings.ToLower(q)
+	for _, c := range r.us.cities[state] {
+		if q == "" || strings.Contains(strings.ToLower(c.Name), q) {
+			cities = append(cities, c.City)
+		}
+	}
+	return cities
+}
+
+// LookupZip returns the places a US zip code belongs to, most populous first
+func (r *Registry) LookupZip(zip string) []Place {
+	places := []Place{}
+	if r.us == nil {
+		return places
+	}
+	for _, c := range r.us.zips[zip5(zip)] {
+		places = append(places, Place{City: c.Name, State: c.state, StateName: r.us.stateByCode[c.state].Name})
+	}
+	return places
+}
+
+// Address is a US address to validate
+type Address struct {
+	City  string
+	State string
+	Zip   string
+}
+
+// NormalizeAddress checks that the city, state and zip of a US address agree with each other and returns it
+// with the state as its two letter code and the city spelled as in our data, empty fields are not checked
+func (r *Registry) NormalizeAddress(a Address) (Address, error) {
+	if r.us == nil {
+		return a, nil
+	}
+	if a.State != "" {
+		state, ok := r.us.stateByCode[strings.ToUpper(a.State)]
+		if !ok {
+			state, ok = r.us.stateByName[strings.ToLower(a.State)]
+		}
+		if !ok {
+			return a, errors.New("unknown state")
+		}
+		a.State = state.ShortCode
+	}
+	var cities []*usCity
+	if a.City != "" && a.State != "" {
+		cities = r.us.cityByName[a.State][strings.ToLower(strings.TrimSpace(a.City))]
+		if len(cities) == 0 {
+			return a, errors.New("city is not in the given state")
+		}
+		a.City = cities[0].Name
+	}
+	if a.Zip != "" {
+		zipCities, ok := r.us.zips[zip5(a.Zip)]
+		if !ok {
+			return a, errors.New("unknown zip code")
+		}
+		if !zipMatches(zipCities, a.State, cities) {
+			return a, errors.New("zip code does not match the city and state")
+		}
+	}
+	return a, nil
+}
+
+// zipMatches reports whether one of the cities of a zip code is in the state and, when given, one of the cities
+func zipMatches(zipCities []*usCity, state string, cities []*usCity) bool {
+	for _, z := range zipCities {
+		if state != "" && z.state != state {
+			continue
+		}
+		if len(cities) == 0 {
+			return true
+		}
+		for _, c := range cities {
+			if c == z {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// zip5 strips the +4 extension of a zip code
+func zip5(zip string) string {
+	zip = strings.TrimSpace(zip)
+	if i := strings.IndexByte(zip, '-'); i >= 0 {
+		return zip[:i]
+	}
+	return zip
+}
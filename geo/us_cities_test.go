@@ -0,0 +1,70 @@
+package geo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/geo"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const usCitiesCSV = `"city","city_ascii","state_id","state_name","county_fips","county_name","lat","lng","population","density","source","military","incorporated","timezone","ranking","zips","id"
+"San Francisco","San Francisco","CA","California","06075","San Francisco","37.7562","-122.4430","3592294","7256","polygon","FALSE","TRUE","America/Los_Angeles","1","94130 94131 94102","1840021543"
+"Cañon City","Canon City","CO","Colorado","08043","Fremont","38.4420","-105.2275","16419","609","polygon","FALSE","TRUE","America/Denver","3","81212 81215","1840018851"
+"Springfield","Springfield","IL","Illinois","17167","Sangamon","39.7710","-89.6537","150251","950","polygon","FALSE","TRUE","America/Chicago","2","62701 62702","1840009517"
+"Springfield","Springfield","IL","Illinois","17031","Cook","41.0000","-88.0000","100","10","polygon","FALSE","FALSE","America/Chicago","3","60000","1840000000"
+`
+
+func loadUSCities(t *testing.T) *geo.Registry {
+	r := geo.New()
+	assert.Nil(t, r.LoadUSCities(strings.NewReader(usCitiesCSV)))
+	return r
+}
+
+func TestStatesAndCities(t *testing.T) {
+	r := loadUSCities(t)
+
+	assert.Equal(t, []geo.State{
+		{Name: "California", ShortCode: "CA"},
+		{Name: "Colorado", ShortCode: "CO"},
+		{Name: "Illinois", ShortCode: "IL"},
+	}, r.States(""))
+	assert.Equal(t, []geo.State{{Name: "Colorado", ShortCode: "CO"}}, r.States("colo"))
+	assert.Len(t, r.Cities("IL", "spring"), 2)
+	assert.Empty(t, r.Cities("CA", "spring"))
+}
+
+func TestLookupZip(t *testing.T) {
+	r := loadUSCities(t)
+
+	assert.Equal(t, []geo.Place{{City: "San Francisco", State: "CA", StateName: "California"}}, r.LookupZip("94102-1234"))
+	assert.Empty(t, r.LookupZip("00000"))
+	assert.Empty(t, geo.New().LookupZip("94102"))
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	r := loadUSCities(t)
+
+	a, err := r.NormalizeAddress(geo.Address{City: "canon city", State: "Colorado", Zip: "81212"})
+	assert.Nil(t, err)
+	assert.Equal(t, geo.Address{City: "Cañon City", State: "CO", Zip: "81212"}, a)
+
+	// the zip belongs to the second Springfield in the state
+	_, err = r.NormalizeAddress(geo.Address{City: "Springfield", State: "IL", Zip: "60000"})
+	assert.Nil(t, err)
+
+	_, err = r.NormalizeAddress(geo.Address{State: "ZZ"})
+	assert.NotNil(t, err)
+	_, err = r.NormalizeAddress(geo.Address{City: "Springfield", State: "CA"})
+	assert.NotNil(t, err)
+	_, err = r.NormalizeAddress(geo.Address{City: "San Francisco", State: "CA", Zip: "62701"})
+	assert.NotNil(t, err)
+	_, err = r.NormalizeAddress(geo.Address{State: "CA", Zip: "99999"})
+	assert.NotNil(t, err)
+
+	// without data addresses are accepted as is
+	a, err = geo.New().NormalizeAddress(geo.Address{City: "Nowhere", State: "ZZ"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Nowhere", a.City)
+}
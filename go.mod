@@ -4,7 +4,6 @@ go 1.13
 
 require (
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
-	github.com/bradfitz/slice v0.0.0-20180809154707-2b758aa73013
 	github.com/caarlos0/env/v6 v6.5.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/fergusstrange/embedded-postgres v1.4.0
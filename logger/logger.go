@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns the application logger, a development logger unless env is prod.
+// Everything it writes goes through the redaction layer.
+func New(env string) *zap.Logger {
+	cfg := zap.NewDevelopmentConfig()
+	if env == "prod" {
+		cfg = zap.NewProductionConfig()
+	}
+	log, err := cfg.Build(zap.WrapCore(NewRedactingCore))
+	if err != nil {
+		// the default configs only fail on broken sinks, don't take the app down for logging
+		return zap.NewNop()
+	}
+	return log
+}
+
+// NewRedactingCore wraps core so sensitive fields are masked before they are encoded
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{core}
+}
+
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, redactFields(fields))
+}
@@ -0,0 +1,97 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/logger"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	ssn         = "123-45-6789"
+	dob         = "1990-04-01"
+	accessToken = "access-sandbox-5f1d2c3b"
+	bankAccount = "000123456789"
+)
+
+var pii = []string{ssn, dob, accessToken, bankAccount}
+
+func newTestLogger() (*zap.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel)
+	return zap.New(logger.NewRedactingCore(core)), &buf
+}
+
+func assertNoPII(t *testing.T, out string) {
+	for _, v := range pii {
+		assert.NotContains(t, out, v)
+	}
+}
+
+func TestRedactsUser(t *testing.T) {
+	log, buf := newTestLogger()
+	u := &model.User{ID: 7, Email: "jane@example.com", DOB: dob, TaxID: ssn, TaxIDType: "USA_SSN"}
+
+	log.Info("User signed up", zap.Any("user", u))
+
+	out := buf.String()
+	assertNoPII(t, out)
+	assert.Contains(t, out, "jane@example.com")
+	assert.Contains(t, out, `"tax_id":"[REDACTED]"`)
+	assert.Contains(t, out, `"dob":"[REDACTED]"`)
+	assert.Contains(t, out, `"tax_id_type":"USA_SSN"`)
+}
+
+func TestRedactsBankAccount(t *testing.T) {
+	log, buf := newTestLogger()
+
+	log.Info("Bank linked", zap.Any("bank", model.BankAccount{ID: 1, AccessToken: accessToken, BankName: "Chase"}))
+
+	out := buf.String()
+	assertNoPII(t, out)
+	assert.Contains(t, out, "Chase")
+}
+
+func TestRedactsBrokerPayloads(t *testing.T) {
+	log, buf := newTestLogger()
+	payload := map[string]interface{}{
+		"identity": map[string]interface{}{
+			"given_name":    "Jane",
+			"date_of_birth": dob,
+			"tax_id":        ssn,
+		},
+		"ach": []interface{}{
+			map[string]interface{}{"bank_account_number": bankAccount, "bank_routing_number": "121000358"},
+		},
+	}
+	body, _ := json.Marshal(payload)
+
+	log.Debug("Broker account request", zap.Any("account", payload))
+	log.Debug("Broker account response", zap.ByteString("body", body))
+	log.Debug("Broker account response", zap.String("body", string(body)))
+	log.With(zap.Any("account", payload)).Warn("Broker error", zap.Error(errors.New("422")))
+
+	out := buf.String()
+	assertNoPII(t, out)
+	assert.Contains(t, out, "Jane")
+	assert.Contains(t, out, "121000358")
+}
+
+func TestRedactsSensitiveKeys(t *testing.T) {
+	log, buf := newTestLogger()
+
+	log.Info("Plaid exchange", zap.String("access_token", accessToken), zap.String("tax_id", ssn))
+	log.Info("Not json", zap.String("body", "plain text"))
+
+	out := buf.String()
+	assertNoPII(t, out)
+	assert.Contains(t, out, "plain text")
+}
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces sensitive values in the logs
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are field and json keys that are always masked, struct fields can opt in with a redact:"true" tag
+var sensitiveKeys = map[string]bool{
+	"tax_id":              true,
+	"date_of_birth":       true,
+	"dob":                 true,
+	"bank_account_number": true,
+	"account_number_full": true,
+	"access_token":        true,
+	"public_token":        true,
+	"refresh_token":       true,
+	"token":               true,
+	"password":            true,
+	"ssn":                 true,
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+func isSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = redactField(f)
+	}
+	return out
+}
+
+func redactField(f zapcore.Field) zapcore.Field {
+	if isSensitive(f.Key) {
+		return zap.String(f.Key, Redacted)
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = RedactJSON(f.String)
+	case zapcore.ByteStringType:
+		f = zap.String(f.Key, RedactJSON(string(f.Interface.([]byte))))
+	case zapcore.ReflectType:
+		f.Interface = Redact(f.Interface)
+	}
+	return f
+}
+
+// RedactJSON masks sensitive keys of a JSON document, anything that isn't JSON is returned unchanged
+func RedactJSON(s string) string {
+	t := strings.TrimSpace(s)
+	if t == "" || (t[0] != '{' && t[0] != '[') {
+		return s
+	}
+	var v interface{}
+	d := json.NewDecoder(strings.NewReader(t))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return s
+	}
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(Redact(v)); err != nil {
+		return s
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Redact returns a copy of v fit for logging: structs become maps keyed by their json names with
+// fields tagged redact:"true" or named after a sensitive key masked, maps are masked by key
+func Redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redactValue(reflect.ValueOf(v))
+}
+
+func redactValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType || v.Type().Implements(jsonMarshalerType) {
+			return v.Interface()
+		}
+		out := map[string]interface{}{}
+		redactStruct(v, out)
+		return out
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if isSensitive(key) {
+				out[key] = Redacted
+				continue
+			}
+			out[key] = redactValue(iter.Value())
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(v.Index(i))
+		}
+		return out
+	case reflect.Invalid:
+		return nil
+	}
+	return v.Interface()
+}
+
+func redactStruct(v reflect.Value, out map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fv := v.Field(i)
+		if field.Anonymous && fv.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			redactStruct(fv, out)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Tag.Get("redact") == "true" || isSensitive(name) {
+			out[name] = Redacted
+			continue
+		}
+		out[name] = redactValue(fv)
+	}
+}
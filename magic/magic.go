@@ -1,7 +1,6 @@
 package magic
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
@@ -25,7 +24,6 @@ type Magic struct {
 // IsValidToken validates a token with magic link
 func (m *Magic) IsValidToken(tkn string) (*token.Token, error) {
 	authBearer := "Bearer"
-	fmt.Printf("%s", authBearer)
 	if tkn == "" {
 		return nil, apperr.New(http.StatusUnauthorized, "Bearer token is required")
 	}
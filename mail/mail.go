@@ -1,6 +1,8 @@
 package mail
 
 import (
+	"fmt"
+	"html"
 	"os"
 	"strings"
 
@@ -85,3 +87,11 @@ func (m *Mail) SendForgotVerificationEmail(toEmail string, v *model.Verification
 	}
 	return nil
 }
+
+// SendWithdrawalOTPEmail sends the otp confirming a withdrawal, it names the amount and the destination bank
+// so that a user who didn't ask for the withdrawal can tell
+func (m *Mail) SendWithdrawalOTPEmail(toEmail, code string, amount float64, bank string) error {
+	content := fmt.Sprintf("Here is your otp to withdraw $%.2f to %s: %s. If you didn't request this withdrawal, please contact support immediately.", amount, bank, code)
+	HTMLContent := "<p>" + html.EscapeString(content) + "</p>"
+	return m.SendWithDefaults("Confirm your withdrawal", toEmail, content, HTMLContent)
+}
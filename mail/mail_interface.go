@@ -8,4 +8,5 @@ type Service interface {
 	SendWithDefaults(subject, toEmail, content string, HTMLContent string) error
 	SendVerificationEmail(toEmail string, v *model.Verification) error
 	SendForgotVerificationEmail(toEmail string, v *model.Verification) error
+	SendWithdrawalOTPEmail(toEmail, code string, amount float64, bank string) error
 }
@@ -0,0 +1,35 @@
+package microdeposit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alpacahq/ribbit-backend/config"
+
+	"go.uber.org/zap"
+)
+
+// New returns the micro-deposit provider picked by the config. When none is configured an unavailable
+// provider is returned with the error, manually entered bank accounts are then refused.
+func New(cfg *config.MicroDepositConfig, log *zap.Logger) (Service, error) {
+	switch cfg.Provider {
+	case "fake":
+		if cfg.Env == "prod" {
+			return Unavailable{}, errors.New("the fake micro-deposit provider can't be used in prod")
+		}
+		return NewFake(log), nil
+	case "":
+		return Unavailable{}, errors.New("no micro-deposit provider is configured")
+	}
+	return Unavailable{}, fmt.Errorf("unknown micro-deposit provider %q", cfg.Provider)
+}
+
+var errUnavailable = errors.New("micro-deposits are unavailable")
+
+// Unavailable is the provider used when micro-deposits aren't configured, every call fails
+type Unavailable struct{}
+
+// Send fails as micro-deposits are unavailable
+func (Unavailable) Send(routingNumber, accountNumber, accountType string, amounts []int) (string, error) {
+	return "", errUnavailable
+}
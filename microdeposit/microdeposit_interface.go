@@ -0,0 +1,7 @@
+package microdeposit
+
+// Service is the interface to the provider sending the micro-deposits which verify a manually entered bank account
+type Service interface {
+	// Send credits each of amounts, in cents, to the bank account and returns the provider's reference
+	Send(routingNumber, accountNumber, accountType string, amounts []int) (string, error)
+}
@@ -0,0 +1,28 @@
+package microdeposit_test
+
+import (
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/microdeposit"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNew(t *testing.T) {
+	p, err := microdeposit.New(&config.MicroDepositConfig{Provider: "fake", Env: "dev"}, zap.NewNop())
+	assert.NoError(t, err)
+	assert.IsType(t, &microdeposit.Fake{}, p)
+
+	for _, cfg := range []*config.MicroDepositConfig{
+		{Provider: "fake", Env: "prod"},
+		{Provider: ""},
+		{Provider: "unknown"},
+	} {
+		p, err := microdeposit.New(cfg, zap.NewNop())
+		assert.Error(t, err)
+		_, err = p.Send("021000021", "123456789", "CHECKING", []int{12, 34})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,47 @@
+package migration
+
+import (
+	"fmt"
+
+	migrations "github.com/go-pg/migrations/v7"
+)
+
+// adds the countries, signup IP and MFA columns of users
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding countries, signup_ip and mfa columns to users")
+		return exec(db,
+			`ALTER TABLE users
+				ADD COLUMN IF NOT EXISTS country_of_citizenship text,
+				ADD COLUMN IF NOT EXISTS country_of_birth text,
+				ADD COLUMN IF NOT EXISTS country_of_tax_residence text,
+				ADD COLUMN IF NOT EXISTS signup_ip text,
+				ADD COLUMN IF NOT EXISTS mfa_enabled boolean,
+				ADD COLUMN IF NOT EXISTS mfa_secret text,
+				ADD COLUMN IF NOT EXISTS mfa_recovery_hashes text[],
+				ADD COLUMN IF NOT EXISTS mfa_last_step bigint,
+				ADD COLUMN IF NOT EXISTS mfa_verified_at timestamptz,
+				ADD COLUMN IF NOT EXISTS mfa_failed_attempts bigint,
+				ADD COLUMN IF NOT EXISTS mfa_locked_until timestamptz`,
+			// referrals are looked up by the code the referee signed up with
+			`CREATE INDEX IF NOT EXISTS users_referred_by_idx ON users (referred_by)`,
+		)
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping countries, signup_ip and mfa columns from users")
+		return exec(db,
+			`DROP INDEX IF EXISTS users_referred_by_idx`,
+			`ALTER TABLE users
+				DROP COLUMN IF EXISTS country_of_citizenship,
+				DROP COLUMN IF EXISTS country_of_birth,
+				DROP COLUMN IF EXISTS country_of_tax_residence,
+				DROP COLUMN IF EXISTS signup_ip,
+				DROP COLUMN IF EXISTS mfa_enabled,
+				DROP COLUMN IF EXISTS mfa_secret,
+				DROP COLUMN IF EXISTS mfa_recovery_hashes,
+				DROP COLUMN IF EXISTS mfa_last_step,
+				DROP COLUMN IF EXISTS mfa_verified_at,
+				DROP COLUMN IF EXISTS mfa_failed_attempts,
+				DROP COLUMN IF EXISTS mfa_locked_until`,
+		)
+	})
+}
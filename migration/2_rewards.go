@@ -0,0 +1,76 @@
+package migration
+
+import (
+	"fmt"
+
+	migrations "github.com/go-pg/migrations/v7"
+)
+
+// adds the program fields of rewards and the payout status of user_rewards
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding program columns to rewards and payout columns to user_rewards")
+		return exec(db,
+			`ALTER TABLE rewards
+				ADD COLUMN IF NOT EXISTS name text,
+				ADD COLUMN IF NOT EXISTS version bigint,
+				ADD COLUMN IF NOT EXISTS previous_id bigint,
+				ADD COLUMN IF NOT EXISTS starts_at timestamptz,
+				ADD COLUMN IF NOT EXISTS ends_at timestamptz,
+				ADD COLUMN IF NOT EXISTS type text,
+				ADD COLUMN IF NOT EXISTS stocks jsonb,
+				ADD COLUMN IF NOT EXISTS budget double precision`,
+			// the programs created before versioning paid cash without a budget
+			`UPDATE rewards SET version = 1, type = 'cash' WHERE version IS NULL`,
+			`CREATE INDEX IF NOT EXISTS rewards_previous_id_idx ON rewards (previous_id)`,
+
+			`ALTER TABLE user_rewards
+				ADD COLUMN IF NOT EXISTS referee_id bigint,
+				ADD COLUMN IF NOT EXISTS program_id bigint,
+				ADD COLUMN IF NOT EXISTS journal_status text,
+				ADD COLUMN IF NOT EXISTS symbol text,
+				ADD COLUMN IF NOT EXISTS order_id text,
+				ADD COLUMN IF NOT EXISTS status text,
+				ADD COLUMN IF NOT EXISTS attempts bigint,
+				ADD COLUMN IF NOT EXISTS next_attempt_at timestamptz,
+				ADD COLUMN IF NOT EXISTS fraud_score bigint,
+				ADD COLUMN IF NOT EXISTS fraud_reasons text[]`,
+			// rewards paid before the status existed only recorded whether the journal went through, the
+			// others show up as failed so they can be retried or voided
+			`UPDATE user_rewards SET status = CASE WHEN reward_transfer_status THEN 'PAID' ELSE 'FAILED' END, attempts = 0
+				WHERE status IS NULL`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS user_rewards_user_id_referee_id_reward_type_key
+				ON user_rewards (user_id, referee_id, reward_type)`,
+			`CREATE INDEX IF NOT EXISTS user_rewards_status_next_attempt_at_idx ON user_rewards (status, next_attempt_at)`,
+			`CREATE INDEX IF NOT EXISTS user_rewards_program_id_idx ON user_rewards (program_id)`,
+		)
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping program columns from rewards and payout columns from user_rewards")
+		return exec(db,
+			`DROP INDEX IF EXISTS user_rewards_program_id_idx`,
+			`DROP INDEX IF EXISTS user_rewards_status_next_attempt_at_idx`,
+			`DROP INDEX IF EXISTS user_rewards_user_id_referee_id_reward_type_key`,
+			`ALTER TABLE user_rewards
+				DROP COLUMN IF EXISTS referee_id,
+				DROP COLUMN IF EXISTS program_id,
+				DROP COLUMN IF EXISTS journal_status,
+				DROP COLUMN IF EXISTS symbol,
+				DROP COLUMN IF EXISTS order_id,
+				DROP COLUMN IF EXISTS status,
+				DROP COLUMN IF EXISTS attempts,
+				DROP COLUMN IF EXISTS next_attempt_at,
+				DROP COLUMN IF EXISTS fraud_score,
+				DROP COLUMN IF EXISTS fraud_reasons`,
+			`DROP INDEX IF EXISTS rewards_previous_id_idx`,
+			`ALTER TABLE rewards
+				DROP COLUMN IF EXISTS name,
+				DROP COLUMN IF EXISTS version,
+				DROP COLUMN IF EXISTS previous_id,
+				DROP COLUMN IF EXISTS starts_at,
+				DROP COLUMN IF EXISTS ends_at,
+				DROP COLUMN IF EXISTS type,
+				DROP COLUMN IF EXISTS stocks,
+				DROP COLUMN IF EXISTS budget`,
+		)
+	})
+}
@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"fmt"
+
+	migrations "github.com/go-pg/migrations/v7"
+)
+
+// makes the reason of a coin statement unique per user so an earning is only credited once
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding unique user_reason index to coin_statements")
+		return exec(db,
+			`CREATE UNIQUE INDEX IF NOT EXISTS coin_statements_user_id_reason_key ON coin_statements (user_id, reason)`,
+		)
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping unique user_reason index from coin_statements")
+		return exec(db,
+			`DROP INDEX IF EXISTS coin_statements_user_id_reason_key`,
+		)
+	})
+}
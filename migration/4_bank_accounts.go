@@ -0,0 +1,48 @@
+package migration
+
+import (
+	"fmt"
+
+	migrations "github.com/go-pg/migrations/v7"
+)
+
+// adds the plaid item, relationship and verification columns of bank_accounts and turns status into the broker status
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding item, relationship and verification columns to bank_accounts")
+		return exec(db,
+			`ALTER TABLE bank_accounts
+				ADD COLUMN IF NOT EXISTS item_id text,
+				ADD COLUMN IF NOT EXISTS plaid_account_id text,
+				ADD COLUMN IF NOT EXISTS relationship_id text,
+				ADD COLUMN IF NOT EXISTS mask text,
+				ADD COLUMN IF NOT EXISTS item_status text,
+				ADD COLUMN IF NOT EXISTS verification text,
+				ADD COLUMN IF NOT EXISTS numbers_hash text`,
+			`DO $$ BEGIN
+				IF EXISTS (SELECT 1 FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = 'bank_accounts' AND column_name = 'status' AND data_type = 'boolean') THEN
+					ALTER TABLE bank_accounts ALTER COLUMN status TYPE text
+						USING CASE WHEN status THEN 'APPROVED' ELSE 'CANCELED' END;
+				END IF;
+			END $$`,
+			`CREATE INDEX IF NOT EXISTS bank_accounts_user_id_idx ON bank_accounts (user_id)`,
+			`CREATE INDEX IF NOT EXISTS bank_accounts_item_id_idx ON bank_accounts (item_id)`,
+		)
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping item, relationship and verification columns from bank_accounts")
+		return exec(db,
+			`DROP INDEX IF EXISTS bank_accounts_item_id_idx`,
+			`DROP INDEX IF EXISTS bank_accounts_user_id_idx`,
+			`ALTER TABLE bank_accounts ALTER COLUMN status TYPE boolean USING status = 'APPROVED'`,
+			`ALTER TABLE bank_accounts
+				DROP COLUMN IF EXISTS item_id,
+				DROP COLUMN IF EXISTS plaid_account_id,
+				DROP COLUMN IF EXISTS relationship_id,
+				DROP COLUMN IF EXISTS mask,
+				DROP COLUMN IF EXISTS item_status,
+				DROP COLUMN IF EXISTS verification,
+				DROP COLUMN IF EXISTS numbers_hash`,
+		)
+	})
+}
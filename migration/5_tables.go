@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"fmt"
+
+	migrations "github.com/go-pg/migrations/v7"
+)
+
+// creates the tables of the account, funding, security and referral features. Tables made by an earlier
+// create_schema are kept, the columns added to them afterwards are added here.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("creating account, funding, security and referral tables")
+		return exec(db,
+			`CREATE TABLE IF NOT EXISTS account_status_changes ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "account_id" text, "from_status" text, "to_status" text, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS account_status_changes_user_id_idx ON account_status_changes (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS trusted_contacts ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "given_name" text, "family_name" text, "email" text, "phone" text,
+				"street_address" text, "city" text, "state" text, "postal_code" text, "country" text, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS trusted_contacts_user_id_idx ON trusted_contacts (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS documents ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "document_type" text, "document_sub_type" text, "file_name" text, "mime_type" text,
+				"size" bigint, "path" text, "status" text, "error_response" text, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS documents_user_id_idx ON documents (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS sessions ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "token_hash" text UNIQUE, "rotated_hashes" text[], "device_id" text, "ip" text,
+				"user_agent" text, "last_used_at" timestamptz, "revoked_at" timestamptz, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS mfa_challenges ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "token_hash" text UNIQUE, "expires_at" timestamptz, "attempts" bigint, PRIMARY KEY ("id"))`,
+
+			`CREATE TABLE IF NOT EXISTS passkeys ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "name" text, "credential_id" text UNIQUE, "public_key" bytea, "sign_count" bigint,
+				"aaguid" text, "last_used_at" timestamptz, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS passkeys_user_id_idx ON passkeys (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS passkey_challenges ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "challenge" text UNIQUE, "ceremony" text, "expires_at" timestamptz, PRIMARY KEY ("id"))`,
+			`ALTER TABLE passkey_challenges ADD COLUMN IF NOT EXISTS client_ip text`,
+			// login challenges are counted per client to rate limit them, and expired ones are swept
+			`CREATE INDEX IF NOT EXISTS passkey_challenges_client_ip_idx ON passkey_challenges (client_ip, ceremony, created_at)`,
+			`CREATE INDEX IF NOT EXISTS passkey_challenges_expires_at_idx ON passkey_challenges (expires_at)`,
+
+			`CREATE TABLE IF NOT EXISTS micro_deposits ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "bank_account_id" bigint UNIQUE, "reference" text, "amounts" bigint[], "attempts" bigint,
+				"verified_at" timestamptz, PRIMARY KEY ("id"))`,
+
+			`CREATE TABLE IF NOT EXISTS transfers ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "account_id" text, "transfer_id" text, "relationship_id" text, "direction" text,
+				"amount" double precision, "status" text, PRIMARY KEY ("id"))`,
+			`ALTER TABLE transfers ADD COLUMN IF NOT EXISTS reason text`,
+			// the limits sum the transfers of a user in one direction over a window
+			`CREATE INDEX IF NOT EXISTS transfers_user_id_direction_created_at_idx ON transfers (user_id, direction, created_at)`,
+			`CREATE INDEX IF NOT EXISTS transfers_transfer_id_idx ON transfers (transfer_id)`,
+
+			`CREATE TABLE IF NOT EXISTS transfer_events ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "transfer_id" bigint, "from_status" text, "to_status" text, "reason" text, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS transfer_events_transfer_id_idx ON transfer_events (transfer_id)`,
+
+			`CREATE TABLE IF NOT EXISTS transfer_limits ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint UNIQUE, "deposit_daily" double precision, "deposit_weekly" double precision,
+				"deposit_monthly" double precision, "withdrawal_daily" double precision, "withdrawal_weekly" double precision,
+				"withdrawal_monthly" double precision, PRIMARY KEY ("id"))`,
+
+			`CREATE TABLE IF NOT EXISTS withdrawal_otps ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "code_hash" text, "expires_at" timestamptz, "attempts" bigint, "used_at" timestamptz,
+				PRIMARY KEY ("id"))`,
+			`ALTER TABLE withdrawal_otps
+				ADD COLUMN IF NOT EXISTS relationship_id text,
+				ADD COLUMN IF NOT EXISTS amount double precision`,
+			`CREATE INDEX IF NOT EXISTS withdrawal_otps_user_id_idx ON withdrawal_otps (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS recurring_deposits ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "relationship_id" text, "amount" double precision, "frequency" text, "day" bigint,
+				"next_date" timestamptz, "last_run_at" timestamptz, "status" text, "suspended_reason" text, "active_since" timestamptz,
+				PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS recurring_deposits_user_id_idx ON recurring_deposits (user_id)`,
+			`CREATE INDEX IF NOT EXISTS recurring_deposits_status_next_date_idx ON recurring_deposits (status, next_date)`,
+
+			`CREATE TABLE IF NOT EXISTS recurring_deposit_runs ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "recurring_deposit_id" bigint, "user_id" bigint, "scheduled_for" timestamptz, "transfer_id" text,
+				"status" text, "error" text, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS recurring_deposit_runs_recurring_deposit_id_idx ON recurring_deposit_runs (recurring_deposit_id)`,
+
+			`CREATE TABLE IF NOT EXISTS coin_redemptions ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "user_id" bigint, "coins" bigint, "amount" double precision, "symbol" text, "status" text,
+				"journal_id" text, "order_id" text, "error" text, PRIMARY KEY ("id"))`,
+			`CREATE INDEX IF NOT EXISTS coin_redemptions_user_id_idx ON coin_redemptions (user_id)`,
+
+			`CREATE TABLE IF NOT EXISTS leaderboard_entries ("created_at" timestamptz, "updated_at" timestamptz, "deleted_at" timestamptz,
+				"id" bigserial, "rank" bigint, "user_id" bigint, "name" text, "referrals" bigint, PRIMARY KEY ("id"))`,
+		)
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping account, funding, security and referral tables")
+		return exec(db,
+			`DROP TABLE IF EXISTS leaderboard_entries`,
+			`DROP TABLE IF EXISTS coin_redemptions`,
+			`DROP TABLE IF EXISTS recurring_deposit_runs`,
+			`DROP TABLE IF EXISTS recurring_deposits`,
+			`DROP TABLE IF EXISTS withdrawal_otps`,
+			`DROP TABLE IF EXISTS transfer_limits`,
+			`DROP TABLE IF EXISTS transfer_events`,
+			`DROP TABLE IF EXISTS transfers`,
+			`DROP TABLE IF EXISTS micro_deposits`,
+			`DROP TABLE IF EXISTS passkey_challenges`,
+			`DROP TABLE IF EXISTS passkeys`,
+			`DROP TABLE IF EXISTS mfa_challenges`,
+			`DROP TABLE IF EXISTS sessions`,
+			`DROP TABLE IF EXISTS documents`,
+			`DROP TABLE IF EXISTS trusted_contacts`,
+			`DROP TABLE IF EXISTS account_status_changes`,
+		)
+	})
+}
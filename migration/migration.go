@@ -119,3 +119,13 @@ func createSchema(db *pg.DB, models ...interface{}) {
 		}
 	}
 }
+
+// exec runs the statements of a migration in order and stops at the first error
+func exec(db migrations.DB, statements ...string) error {
+	for _, s := range statements {
+		if _, err := db.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
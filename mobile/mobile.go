@@ -3,23 +3,25 @@ package mobile
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/alpacahq/ribbit-backend/config"
+
+	"go.uber.org/zap"
 )
 
 // NewMobile creates a new mobile service implementation
-func NewMobile(config *config.TwilioConfig) *Mobile {
-	return &Mobile{config}
+func NewMobile(config *config.TwilioConfig, log *zap.Logger) *Mobile {
+	return &Mobile{config, log}
 }
 
 // Mobile provides a mobile service implementation
 type Mobile struct {
 	config *config.TwilioConfig
+	log    *zap.Logger
 }
 
 // GenerateSMSToken sends an sms token to the mobile numer
@@ -33,13 +35,13 @@ func (m *Mobile) GenerateSMSToken(countryCode, mobile string) error {
 		return err
 	}
 
+	defer resp.Body.Close()
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	bodyString := string(bodyBytes)
-	fmt.Println(bodyString)
-	return err
+	m.log.Debug("Twilio verification response", zap.Int("status", resp.StatusCode), zap.ByteString("body", bodyBytes))
+	return nil
 }
 
 // CheckCode verifies if the user-provided code is approved
@@ -53,15 +55,30 @@ func (m *Mobile) CheckCode(countryCode, mobile, code string) error {
 		return err
 	}
 
-	// take a look at our response
-	fmt.Println(resp.StatusCode)
-	fmt.Println(resp.Body)
+	defer resp.Body.Close()
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	m.log.Debug("Twilio verification check response", zap.Int("status", resp.StatusCode), zap.ByteString("body", bodyBytes))
+	return nil
+}
+
+// SendSMS sends a plain text message to the mobile number
+func (m *Mobile) SendSMS(countryCode, mobile, body string) error {
+	apiURL := "https://api.twilio.com/2010-04-01/Accounts/" + m.config.Account + "/Messages.json"
+	data := url.Values{}
+	data.Set("To", countryCode+mobile)
+	data.Set("From", m.config.From)
+	data.Set("Body", body)
+	resp, err := m.send(apiURL, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("twilio responded with status %d", resp.StatusCode)
 	}
-	bodyString := string(bodyBytes)
-	fmt.Println(bodyString)
 	return nil
 }
 
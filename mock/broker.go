@@ -0,0 +1,91 @@
+package mock
+
+import "github.com/alpacahq/ribbit-backend/broker"
+
+// Broker mock
+type Broker struct {
+	UploadDocumentsFn       func(string, []broker.Document) error
+	GetAccountFn            func(string) (*broker.Account, error)
+	UpdateAccountFn         func(string, map[string]interface{}) error
+	GetTradingAccountFn     func(string) (*broker.TradingAccount, error)
+	ListACHRelationshipsFn  func(string) ([]broker.ACHRelationship, error)
+	CreateTransferFn        func(string, *broker.TransferRequest) (*broker.Transfer, error)
+	CreateACHRelationshipFn func(string, *broker.ACHRelationshipRequest) (*broker.ACHRelationship, error)
+	DeleteACHRelationshipFn func(string, string) error
+	ListTransfersFn         func(string, int, int) ([]broker.Transfer, error)
+	DeleteTransferFn        func(string, string) (interface{}, error)
+	CreateJournalFn         func(*broker.JournalRequest) (*broker.Journal, error)
+	GetJournalFn            func(string) (*broker.Journal, error)
+	GetAssetFn              func(string) (*broker.Asset, error)
+	CreateOrderFn           func(string, *broker.OrderRequest) (*broker.Order, error)
+}
+
+// UploadDocuments mock
+func (b *Broker) UploadDocuments(accountID string, docs []broker.Document) error {
+	return b.UploadDocumentsFn(accountID, docs)
+}
+
+// GetAccount mock
+func (b *Broker) GetAccount(accountID string) (*broker.Account, error) {
+	return b.GetAccountFn(accountID)
+}
+
+// UpdateAccount mock
+func (b *Broker) UpdateAccount(accountID string, patch map[string]interface{}) error {
+	return b.UpdateAccountFn(accountID, patch)
+}
+
+// GetTradingAccount mock
+func (b *Broker) GetTradingAccount(accountID string) (*broker.TradingAccount, error) {
+	return b.GetTradingAccountFn(accountID)
+}
+
+// ListACHRelationships mock
+func (b *Broker) ListACHRelationships(accountID string) ([]broker.ACHRelationship, error) {
+	return b.ListACHRelationshipsFn(accountID)
+}
+
+// CreateTransfer mock
+func (b *Broker) CreateTransfer(accountID string, t *broker.TransferRequest) (*broker.Transfer, error) {
+	return b.CreateTransferFn(accountID, t)
+}
+
+// CreateACHRelationship mock
+func (b *Broker) CreateACHRelationship(accountID string, r *broker.ACHRelationshipRequest) (*broker.ACHRelationship, error) {
+	return b.CreateACHRelationshipFn(accountID, r)
+}
+
+// DeleteACHRelationship mock
+func (b *Broker) DeleteACHRelationship(accountID, relationshipID string) error {
+	return b.DeleteACHRelationshipFn(accountID, relationshipID)
+}
+
+// ListTransfers mock
+func (b *Broker) ListTransfers(accountID string, limit, offset int) ([]broker.Transfer, error) {
+	return b.ListTransfersFn(accountID, limit, offset)
+}
+
+// DeleteTransfer mock
+func (b *Broker) DeleteTransfer(accountID, transferID string) (interface{}, error) {
+	return b.DeleteTransferFn(accountID, transferID)
+}
+
+// CreateJournal mock
+func (b *Broker) CreateJournal(j *broker.JournalRequest) (*broker.Journal, error) {
+	return b.CreateJournalFn(j)
+}
+
+// GetJournal mock
+func (b *Broker) GetJournal(journalID string) (*broker.Journal, error) {
+	return b.GetJournalFn(journalID)
+}
+
+// GetAsset mock
+func (b *Broker) GetAsset(symbol string) (*broker.Asset, error) {
+	return b.GetAssetFn(symbol)
+}
+
+// CreateOrder mock
+func (b *Broker) CreateOrder(accountID string, o *broker.OrderRequest) (*broker.Order, error) {
+	return b.CreateOrderFn(accountID, o)
+}
@@ -9,6 +9,7 @@ type Mail struct {
 	SendWithDefaultsFn            func(string, string, string, string) error
 	SendVerificationEmailFn       func(string, *model.Verification) error
 	SendForgotVerificationEmailFn func(string, *model.Verification) error
+	SendWithdrawalOTPEmailFn      func(string, string, float64, string) error
 }
 
 // Send mock
@@ -29,3 +30,8 @@ func (m *Mail) SendVerificationEmail(toEmail string, v *model.Verification) erro
 func (m *Mail) SendForgotVerificationEmail(toEmail string, v *model.Verification) error {
 	return m.SendForgotVerificationEmailFn(toEmail, v)
 }
+
+// SendWithdrawalOTPEmail mock
+func (m *Mail) SendWithdrawalOTPEmail(toEmail, code string, amount float64, bank string) error {
+	return m.SendWithdrawalOTPEmailFn(toEmail, code, amount, bank)
+}
@@ -0,0 +1,33 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// MFA mock
+type MFA struct {
+	ChallengeFn func(*model.User) (string, time.Time, error)
+	RedeemFn    func(string, string) (*model.User, error)
+}
+
+// Challenge mock
+func (m *MFA) Challenge(u *model.User) (string, time.Time, error) {
+	return m.ChallengeFn(u)
+}
+
+// Redeem mock
+func (m *MFA) Redeem(token, code string) (*model.User, error) {
+	return m.RedeemFn(token, code)
+}
+
+// SecondFactor mock
+type SecondFactor struct {
+	RequireFreshFn func(int) error
+}
+
+// RequireFresh mock
+func (s *SecondFactor) RequireFresh(userID int) error {
+	return s.RequireFreshFn(userID)
+}
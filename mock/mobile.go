@@ -4,6 +4,7 @@ package mock
 type Mobile struct {
 	GenerateSMSTokenFn func(string, string) error
 	CheckCodeFn        func(string, string, string) error
+	SendSMSFn          func(string, string, string) error
 }
 
 // GenerateSMSToken mock
@@ -15,3 +16,8 @@ func (m *Mobile) GenerateSMSToken(countryCode, mobile string) error {
 func (m *Mobile) CheckCode(countryCode, mobile, code string) error {
 	return m.CheckCodeFn(countryCode, mobile, code)
 }
+
+// SendSMS mock
+func (m *Mobile) SendSMS(countryCode, mobile, body string) error {
+	return m.SendSMSFn(countryCode, mobile, body)
+}
@@ -0,0 +1,21 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// AccountStatus database mock
+type AccountStatus struct {
+	ListWithAccountFn func() ([]model.User, error)
+	TransitionFn      func(*model.User, string) (*model.AccountStatusChange, error)
+}
+
+// ListWithAccount mock
+func (a *AccountStatus) ListWithAccount() ([]model.User, error) {
+	return a.ListWithAccountFn()
+}
+
+// Transition mock
+func (a *AccountStatus) Transition(u *model.User, status string) (*model.AccountStatusChange, error) {
+	return a.TransitionFn(u, status)
+}
@@ -0,0 +1,91 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// BankAccount database mock
+type BankAccount struct {
+	CreateFn             func(*model.BankAccount) (*model.BankAccount, error)
+	ListByUserFn         func(int) ([]model.BankAccount, error)
+	FindByRelationshipFn func(int, string) (*model.BankAccount, error)
+	CountByItemFn        func(string) (int, error)
+	ListByItemFn         func(string) ([]model.BankAccount, error)
+	UpdateItemStatusFn   func(string, string) error
+	UpdateStatusFn       func(*model.BankAccount) error
+	UpdateVerificationFn func(*model.BankAccount) error
+	DeleteFn             func(*model.BankAccount) error
+}
+
+// Create mock
+func (b *BankAccount) Create(account *model.BankAccount) (*model.BankAccount, error) {
+	return b.CreateFn(account)
+}
+
+// ListByUser mock
+func (b *BankAccount) ListByUser(userID int) ([]model.BankAccount, error) {
+	return b.ListByUserFn(userID)
+}
+
+// FindByRelationship mock
+func (b *BankAccount) FindByRelationship(userID int, relationshipID string) (*model.BankAccount, error) {
+	return b.FindByRelationshipFn(userID, relationshipID)
+}
+
+// CountByItem mock
+func (b *BankAccount) CountByItem(itemID string) (int, error) {
+	return b.CountByItemFn(itemID)
+}
+
+// ListByItem mock
+func (b *BankAccount) ListByItem(itemID string) ([]model.BankAccount, error) {
+	return b.ListByItemFn(itemID)
+}
+
+// UpdateItemStatus mock
+func (b *BankAccount) UpdateItemStatus(itemID, status string) error {
+	return b.UpdateItemStatusFn(itemID, status)
+}
+
+// UpdateStatus mock
+func (b *BankAccount) UpdateStatus(account *model.BankAccount) error {
+	return b.UpdateStatusFn(account)
+}
+
+// Delete mock
+func (b *BankAccount) Delete(account *model.BankAccount) error {
+	return b.DeleteFn(account)
+}
+
+// UpdateVerification mock
+func (b *BankAccount) UpdateVerification(account *model.BankAccount) error {
+	return b.UpdateVerificationFn(account)
+}
+
+// MicroDeposit database mock
+type MicroDeposit struct {
+	CreateFn            func(*model.MicroDeposit) (*model.MicroDeposit, error)
+	FindByBankAccountFn func(int) (*model.MicroDeposit, error)
+	UpdateFn            func(*model.MicroDeposit) error
+	AddAttemptFn        func(*model.MicroDeposit, int) error
+}
+
+// Create mock
+func (m *MicroDeposit) Create(deposit *model.MicroDeposit) (*model.MicroDeposit, error) {
+	return m.CreateFn(deposit)
+}
+
+// FindByBankAccount mock
+func (m *MicroDeposit) FindByBankAccount(bankAccountID int) (*model.MicroDeposit, error) {
+	return m.FindByBankAccountFn(bankAccountID)
+}
+
+// Update mock
+func (m *MicroDeposit) Update(deposit *model.MicroDeposit) error {
+	return m.UpdateFn(deposit)
+}
+
+// AddAttempt mock
+func (m *MicroDeposit) AddAttempt(deposit *model.MicroDeposit, maxAttempts int) error {
+	return m.AddAttemptFn(deposit, maxAttempts)
+}
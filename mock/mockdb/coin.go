@@ -0,0 +1,27 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Coin database mock
+type Coin struct {
+	AppendFn  func(*model.CoinStatement) (*model.CoinStatement, error)
+	BalanceFn func(int) (int, error)
+	HistoryFn func(int, *model.Pagination) ([]model.CoinStatement, error)
+}
+
+// Append mock
+func (c *Coin) Append(entry *model.CoinStatement) (*model.CoinStatement, error) {
+	return c.AppendFn(entry)
+}
+
+// Balance mock
+func (c *Coin) Balance(userID int) (int, error) {
+	return c.BalanceFn(userID)
+}
+
+// History mock
+func (c *Coin) History(userID int, p *model.Pagination) ([]model.CoinStatement, error) {
+	return c.HistoryFn(userID, p)
+}
@@ -0,0 +1,21 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// CoinRedemption database mock
+type CoinRedemption struct {
+	CreateFn func(*model.CoinRedemption) (*model.CoinRedemption, error)
+	UpdateFn func(*model.CoinRedemption) error
+}
+
+// Create mock
+func (c *CoinRedemption) Create(r *model.CoinRedemption) (*model.CoinRedemption, error) {
+	return c.CreateFn(r)
+}
+
+// Update mock
+func (c *CoinRedemption) Update(r *model.CoinRedemption) error {
+	return c.UpdateFn(r)
+}
@@ -0,0 +1,39 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Document database mock
+type Document struct {
+	CreateFn       func(*model.Document) (*model.Document, error)
+	ViewFn         func(int) (*model.Document, error)
+	ListByUserFn   func(int) ([]model.Document, error)
+	ListAllFn      func() ([]model.Document, error)
+	UpdateStatusFn func(*model.Document) error
+}
+
+// Create mock
+func (d *Document) Create(doc *model.Document) (*model.Document, error) {
+	return d.CreateFn(doc)
+}
+
+// View mock
+func (d *Document) View(id int) (*model.Document, error) {
+	return d.ViewFn(id)
+}
+
+// ListByUser mock
+func (d *Document) ListByUser(userID int) ([]model.Document, error) {
+	return d.ListByUserFn(userID)
+}
+
+// ListAll mock
+func (d *Document) ListAll() ([]model.Document, error) {
+	return d.ListAllFn()
+}
+
+// UpdateStatus mock
+func (d *Document) UpdateStatus(doc *model.Document) error {
+	return d.UpdateStatusFn(doc)
+}
@@ -0,0 +1,59 @@
+package mockdb
+
+import (
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// MFA database mock
+type MFA struct {
+	UpdateFactorFn     func(*model.User) error
+	UseStepFn          func(*model.User, int64, time.Time) error
+	UseRecoveryCodeFn  func(*model.User, string, time.Time) error
+	AddFailedAttemptFn func(*model.User, int, time.Time) error
+	CreateChallengeFn  func(*model.MFAChallenge) (*model.MFAChallenge, error)
+	FindChallengeFn    func(string) (*model.MFAChallenge, error)
+	UpdateChallengeFn  func(*model.MFAChallenge) error
+	DeleteChallengeFn  func(*model.MFAChallenge) error
+}
+
+// UpdateFactor mock
+func (m *MFA) UpdateFactor(u *model.User) error {
+	return m.UpdateFactorFn(u)
+}
+
+// UseStep mock
+func (m *MFA) UseStep(u *model.User, step int64, at time.Time) error {
+	return m.UseStepFn(u, step, at)
+}
+
+// UseRecoveryCode mock
+func (m *MFA) UseRecoveryCode(u *model.User, hash string, at time.Time) error {
+	return m.UseRecoveryCodeFn(u, hash, at)
+}
+
+// AddFailedAttempt mock
+func (m *MFA) AddFailedAttempt(u *model.User, maxAttempts int, lockedUntil time.Time) error {
+	return m.AddFailedAttemptFn(u, maxAttempts, lockedUntil)
+}
+
+// CreateChallenge mock
+func (m *MFA) CreateChallenge(c *model.MFAChallenge) (*model.MFAChallenge, error) {
+	return m.CreateChallengeFn(c)
+}
+
+// FindChallenge mock
+func (m *MFA) FindChallenge(tokenHash string) (*model.MFAChallenge, error) {
+	return m.FindChallengeFn(tokenHash)
+}
+
+// UpdateChallenge mock
+func (m *MFA) UpdateChallenge(c *model.MFAChallenge) error {
+	return m.UpdateChallengeFn(c)
+}
+
+// DeleteChallenge mock
+func (m *MFA) DeleteChallenge(c *model.MFAChallenge) error {
+	return m.DeleteChallengeFn(c)
+}
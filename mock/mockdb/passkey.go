@@ -0,0 +1,77 @@
+package mockdb
+
+import (
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Passkey database mock
+type Passkey struct {
+	CreateFn           func(*model.Passkey) (*model.Passkey, error)
+	FindByCredentialFn func(string) (*model.Passkey, error)
+	ListByUserFn       func(int) ([]model.Passkey, error)
+	ViewFn             func(int, int) (*model.Passkey, error)
+	UpdateSignCountFn  func(*model.Passkey) error
+	DeleteFn           func(*model.Passkey) error
+	CreateChallengeFn  func(*model.PasskeyChallenge) (*model.PasskeyChallenge, error)
+	FindChallengeFn    func(string) (*model.PasskeyChallenge, error)
+	DeleteChallengeFn  func(*model.PasskeyChallenge) error
+	CountChallengesFn  func(string, time.Time) (int, error)
+	PurgeChallengesFn  func(time.Time) (int, error)
+}
+
+// Create mock
+func (p *Passkey) Create(passkey *model.Passkey) (*model.Passkey, error) {
+	return p.CreateFn(passkey)
+}
+
+// FindByCredential mock
+func (p *Passkey) FindByCredential(credentialID string) (*model.Passkey, error) {
+	return p.FindByCredentialFn(credentialID)
+}
+
+// ListByUser mock
+func (p *Passkey) ListByUser(userID int) ([]model.Passkey, error) {
+	return p.ListByUserFn(userID)
+}
+
+// View mock
+func (p *Passkey) View(userID, id int) (*model.Passkey, error) {
+	return p.ViewFn(userID, id)
+}
+
+// UpdateSignCount mock
+func (p *Passkey) UpdateSignCount(passkey *model.Passkey) error {
+	return p.UpdateSignCountFn(passkey)
+}
+
+// Delete mock
+func (p *Passkey) Delete(passkey *model.Passkey) error {
+	return p.DeleteFn(passkey)
+}
+
+// CreateChallenge mock
+func (p *Passkey) CreateChallenge(c *model.PasskeyChallenge) (*model.PasskeyChallenge, error) {
+	return p.CreateChallengeFn(c)
+}
+
+// FindChallenge mock
+func (p *Passkey) FindChallenge(challenge string) (*model.PasskeyChallenge, error) {
+	return p.FindChallengeFn(challenge)
+}
+
+// DeleteChallenge mock
+func (p *Passkey) DeleteChallenge(c *model.PasskeyChallenge) error {
+	return p.DeleteChallengeFn(c)
+}
+
+// CountChallenges mock
+func (p *Passkey) CountChallenges(clientIP string, since time.Time) (int, error) {
+	return p.CountChallengesFn(clientIP, since)
+}
+
+// PurgeChallenges mock
+func (p *Passkey) PurgeChallenges(before time.Time) (int, error) {
+	return p.PurgeChallengesFn(before)
+}
@@ -0,0 +1,33 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Referral database mock
+type Referral struct {
+	ListInviteesFn       func(*model.User) ([]model.Invitee, error)
+	RankingsFn           func(int) ([]model.LeaderboardEntry, error)
+	ReplaceLeaderboardFn func([]model.LeaderboardEntry) error
+	LeaderboardFn        func() ([]model.LeaderboardEntry, error)
+}
+
+// ListInvitees mock
+func (r *Referral) ListInvitees(referrer *model.User) ([]model.Invitee, error) {
+	return r.ListInviteesFn(referrer)
+}
+
+// Rankings mock
+func (r *Referral) Rankings(limit int) ([]model.LeaderboardEntry, error) {
+	return r.RankingsFn(limit)
+}
+
+// ReplaceLeaderboard mock
+func (r *Referral) ReplaceLeaderboard(entries []model.LeaderboardEntry) error {
+	return r.ReplaceLeaderboardFn(entries)
+}
+
+// Leaderboard mock
+func (r *Referral) Leaderboard() ([]model.LeaderboardEntry, error) {
+	return r.LeaderboardFn()
+}
@@ -0,0 +1,128 @@
+package mockdb
+
+import (
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Reward database mock
+type Reward struct {
+	InEffectFn               func(time.Time) (*model.Reward, error)
+	ListProgramsFn           func() ([]model.Reward, error)
+	ViewProgramFn            func(int) (*model.Reward, error)
+	CreateProgramFn          func(*model.Reward, *model.Reward) (*model.Reward, error)
+	EndProgramFn             func(*model.Reward) error
+	SpentBudgetFn            func(int) (float64, error)
+	FindReferrerFn           func(string) (*model.User, error)
+	CountSignupsFn           func(string, time.Time, time.Time, int) (int, error)
+	FindUserRewardFn         func(int, int, string) (*model.UserReward, error)
+	CountRefereesFn          func(int, int, int) (int, error)
+	CreateUserRewardFn       func(*model.UserReward) (*model.UserReward, error)
+	UpdateUserRewardFn       func(*model.UserReward, ...string) error
+	ClaimUserRewardFn        func(*model.UserReward) error
+	ViewUserRewardFn         func(int) (*model.UserReward, error)
+	ListDueRewardsFn         func(time.Time) ([]model.UserReward, error)
+	ListOutstandingRewardsFn func() ([]model.UserReward, error)
+	ListRewardsInReviewFn    func() ([]model.UserReward, error)
+	ListUnsettledRewardsFn   func() ([]model.UserReward, error)
+	ListStuckRewardsFn       func(time.Time) ([]model.UserReward, error)
+}
+
+// InEffect mock
+func (r *Reward) InEffect(at time.Time) (*model.Reward, error) {
+	return r.InEffectFn(at)
+}
+
+// ListPrograms mock
+func (r *Reward) ListPrograms() ([]model.Reward, error) {
+	return r.ListProgramsFn()
+}
+
+// ViewProgram mock
+func (r *Reward) ViewProgram(id int) (*model.Reward, error) {
+	return r.ViewProgramFn(id)
+}
+
+// CreateProgram mock
+func (r *Reward) CreateProgram(program, previous *model.Reward) (*model.Reward, error) {
+	return r.CreateProgramFn(program, previous)
+}
+
+// EndProgram mock
+func (r *Reward) EndProgram(program *model.Reward) error {
+	return r.EndProgramFn(program)
+}
+
+// SpentBudget mock
+func (r *Reward) SpentBudget(programID int) (float64, error) {
+	return r.SpentBudgetFn(programID)
+}
+
+// FindReferrer mock
+func (r *Reward) FindReferrer(referralCode string) (*model.User, error) {
+	return r.FindReferrerFn(referralCode)
+}
+
+// CountSignups mock
+func (r *Reward) CountSignups(referralCode string, from, to time.Time, exceptUserID int) (int, error) {
+	return r.CountSignupsFn(referralCode, from, to, exceptUserID)
+}
+
+// FindUserReward mock
+func (r *Reward) FindUserReward(userID, refereeID int, rewardType string) (*model.UserReward, error) {
+	return r.FindUserRewardFn(userID, refereeID, rewardType)
+}
+
+// CountReferees mock
+func (r *Reward) CountReferees(programID, referrerID, exceptRefereeID int) (int, error) {
+	return r.CountRefereesFn(programID, referrerID, exceptRefereeID)
+}
+
+// CreateUserReward mock, check reads the usage of the program from the mock itself
+func (r *Reward) CreateUserReward(reward *model.UserReward, check func(model.RewardUsage) error) (*model.UserReward, error) {
+	if err := check(r); err != nil {
+		return nil, err
+	}
+	return r.CreateUserRewardFn(reward)
+}
+
+// UpdateUserReward mock
+func (r *Reward) UpdateUserReward(reward *model.UserReward, from ...string) error {
+	return r.UpdateUserRewardFn(reward, from...)
+}
+
+// ClaimUserReward mock
+func (r *Reward) ClaimUserReward(reward *model.UserReward) error {
+	return r.ClaimUserRewardFn(reward)
+}
+
+// ViewUserReward mock
+func (r *Reward) ViewUserReward(id int) (*model.UserReward, error) {
+	return r.ViewUserRewardFn(id)
+}
+
+// ListDueRewards mock
+func (r *Reward) ListDueRewards(now time.Time) ([]model.UserReward, error) {
+	return r.ListDueRewardsFn(now)
+}
+
+// ListOutstandingRewards mock
+func (r *Reward) ListOutstandingRewards() ([]model.UserReward, error) {
+	return r.ListOutstandingRewardsFn()
+}
+
+// ListRewardsInReview mock
+func (r *Reward) ListRewardsInReview() ([]model.UserReward, error) {
+	return r.ListRewardsInReviewFn()
+}
+
+// ListUnsettledRewards mock
+func (r *Reward) ListUnsettledRewards() ([]model.UserReward, error) {
+	return r.ListUnsettledRewardsFn()
+}
+
+// ListStuckRewards mock
+func (r *Reward) ListStuckRewards(before time.Time) ([]model.UserReward, error) {
+	return r.ListStuckRewardsFn(before)
+}
@@ -0,0 +1,57 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Session database mock
+type Session struct {
+	CreateFn             func(*model.Session) (*model.Session, error)
+	FindByTokenFn        func(string) (*model.Session, error)
+	FindByRotatedTokenFn func(string) (*model.Session, error)
+	RotateFn             func(*model.Session, string) error
+	ListByUserFn         func(int) ([]model.Session, error)
+	ViewFn               func(int, int) (*model.Session, error)
+	RevokeFn             func(*model.Session) error
+	RevokeAllFn          func(int) error
+}
+
+// Create mock
+func (s *Session) Create(session *model.Session) (*model.Session, error) {
+	return s.CreateFn(session)
+}
+
+// FindByToken mock
+func (s *Session) FindByToken(tokenHash string) (*model.Session, error) {
+	return s.FindByTokenFn(tokenHash)
+}
+
+// FindByRotatedToken mock
+func (s *Session) FindByRotatedToken(tokenHash string) (*model.Session, error) {
+	return s.FindByRotatedTokenFn(tokenHash)
+}
+
+// Rotate mock
+func (s *Session) Rotate(session *model.Session, previousHash string) error {
+	return s.RotateFn(session, previousHash)
+}
+
+// ListByUser mock
+func (s *Session) ListByUser(userID int) ([]model.Session, error) {
+	return s.ListByUserFn(userID)
+}
+
+// View mock
+func (s *Session) View(userID, id int) (*model.Session, error) {
+	return s.ViewFn(userID, id)
+}
+
+// Revoke mock
+func (s *Session) Revoke(session *model.Session) error {
+	return s.RevokeFn(session)
+}
+
+// RevokeAll mock
+func (s *Session) RevokeAll(userID int) error {
+	return s.RevokeAllFn(userID)
+}
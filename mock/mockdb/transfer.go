@@ -0,0 +1,151 @@
+package mockdb
+
+import (
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Transfer database mock
+type Transfer struct {
+	CreateFn           func(*model.Transfer) (*model.Transfer, error)
+	ViewFn             func(int, int) (*model.Transfer, error)
+	FindByTransferIDFn func(int, string) (*model.Transfer, error)
+	ListPendingFn      func() ([]model.Transfer, error)
+	UpdateStatusFn     func(*model.Transfer, string, string) (*model.TransferEvent, error)
+	ListEventsFn       func(int) ([]model.TransferEvent, error)
+	SumSinceFn         func(int, string, time.Time) (float64, error)
+	ReserveFn          func(*model.Transfer, func(model.TransferSum) error) error
+	AcceptFn           func(*model.Transfer, string, string) error
+	DiscardFn          func(*model.Transfer) error
+}
+
+// Create mock
+func (t *Transfer) Create(transfer *model.Transfer) (*model.Transfer, error) {
+	return t.CreateFn(transfer)
+}
+
+// View mock
+func (t *Transfer) View(userID, id int) (*model.Transfer, error) {
+	return t.ViewFn(userID, id)
+}
+
+// FindByTransferID mock
+func (t *Transfer) FindByTransferID(userID int, transferID string) (*model.Transfer, error) {
+	return t.FindByTransferIDFn(userID, transferID)
+}
+
+// ListPending mock
+func (t *Transfer) ListPending() ([]model.Transfer, error) {
+	return t.ListPendingFn()
+}
+
+// UpdateStatus mock
+func (t *Transfer) UpdateStatus(transfer *model.Transfer, status, reason string) (*model.TransferEvent, error) {
+	return t.UpdateStatusFn(transfer, status, reason)
+}
+
+// ListEvents mock
+func (t *Transfer) ListEvents(transferID int) ([]model.TransferEvent, error) {
+	return t.ListEventsFn(transferID)
+}
+
+// SumSince mock
+func (t *Transfer) SumSince(userID int, direction string, since time.Time) (float64, error) {
+	return t.SumSinceFn(userID, direction, since)
+}
+
+// Reserve mock
+func (t *Transfer) Reserve(transfer *model.Transfer, check func(model.TransferSum) error) error {
+	return t.ReserveFn(transfer, check)
+}
+
+// Accept mock
+func (t *Transfer) Accept(transfer *model.Transfer, transferID, status string) error {
+	return t.AcceptFn(transfer, transferID, status)
+}
+
+// Discard mock
+func (t *Transfer) Discard(transfer *model.Transfer) error {
+	return t.DiscardFn(transfer)
+}
+
+// TransferLimit database mock
+type TransferLimit struct {
+	FindByUserFn func(int) (*model.TransferLimit, error)
+	SaveFn       func(*model.TransferLimit, *model.User) (*model.TransferLimit, error)
+}
+
+// FindByUser mock
+func (t *TransferLimit) FindByUser(userID int) (*model.TransferLimit, error) {
+	return t.FindByUserFn(userID)
+}
+
+// Save mock
+func (t *TransferLimit) Save(limit *model.TransferLimit, u *model.User) (*model.TransferLimit, error) {
+	return t.SaveFn(limit, u)
+}
+
+// RecurringDeposit database mock
+type RecurringDeposit struct {
+	CreateFn       func(*model.RecurringDeposit) (*model.RecurringDeposit, error)
+	ViewFn         func(int, int) (*model.RecurringDeposit, error)
+	ListByUserFn   func(int) ([]model.RecurringDeposit, error)
+	ListDueFn      func(time.Time) ([]model.RecurringDeposit, error)
+	UpdateFn       func(*model.RecurringDeposit) error
+	ClaimFn        func(*model.RecurringDeposit, time.Time) error
+	DeleteFn       func(*model.RecurringDeposit) error
+	CreateRunFn    func(*model.RecurringDepositRun) error
+	ListRunsFn     func(int) ([]model.RecurringDepositRun, error)
+	CountReturnsFn func(int, time.Time) (int, error)
+}
+
+// Create mock
+func (r *RecurringDeposit) Create(deposit *model.RecurringDeposit) (*model.RecurringDeposit, error) {
+	return r.CreateFn(deposit)
+}
+
+// View mock
+func (r *RecurringDeposit) View(userID, id int) (*model.RecurringDeposit, error) {
+	return r.ViewFn(userID, id)
+}
+
+// ListByUser mock
+func (r *RecurringDeposit) ListByUser(userID int) ([]model.RecurringDeposit, error) {
+	return r.ListByUserFn(userID)
+}
+
+// ListDue mock
+func (r *RecurringDeposit) ListDue(date time.Time) ([]model.RecurringDeposit, error) {
+	return r.ListDueFn(date)
+}
+
+// Update mock
+func (r *RecurringDeposit) Update(deposit *model.RecurringDeposit) error {
+	return r.UpdateFn(deposit)
+}
+
+// Claim mock
+func (r *RecurringDeposit) Claim(deposit *model.RecurringDeposit, next time.Time) error {
+	return r.ClaimFn(deposit, next)
+}
+
+// Delete mock
+func (r *RecurringDeposit) Delete(deposit *model.RecurringDeposit) error {
+	return r.DeleteFn(deposit)
+}
+
+// CreateRun mock
+func (r *RecurringDeposit) CreateRun(run *model.RecurringDepositRun) error {
+	return r.CreateRunFn(run)
+}
+
+// ListRuns mock
+func (r *RecurringDeposit) ListRuns(recurringDepositID int) ([]model.RecurringDepositRun, error) {
+	return r.ListRunsFn(recurringDepositID)
+}
+
+// CountReturns mock
+func (r *RecurringDeposit) CountReturns(recurringDepositID int, since time.Time) (int, error) {
+	return r.CountReturnsFn(recurringDepositID, since)
+}
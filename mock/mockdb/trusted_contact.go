@@ -0,0 +1,33 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// TrustedContact database mock
+type TrustedContact struct {
+	FindByUserFn func(int) (*model.TrustedContact, error)
+	CreateFn     func(*model.TrustedContact) (*model.TrustedContact, error)
+	UpdateFn     func(*model.TrustedContact) (*model.TrustedContact, error)
+	DeleteFn     func(*model.TrustedContact) error
+}
+
+// FindByUser mock
+func (t *TrustedContact) FindByUser(userID int) (*model.TrustedContact, error) {
+	return t.FindByUserFn(userID)
+}
+
+// Create mock
+func (t *TrustedContact) Create(contact *model.TrustedContact) (*model.TrustedContact, error) {
+	return t.CreateFn(contact)
+}
+
+// Update mock
+func (t *TrustedContact) Update(contact *model.TrustedContact) (*model.TrustedContact, error) {
+	return t.UpdateFn(contact)
+}
+
+// Delete mock
+func (t *TrustedContact) Delete(contact *model.TrustedContact) error {
+	return t.DeleteFn(contact)
+}
@@ -6,16 +6,28 @@ import (
 
 // User database mock
 type User struct {
-	ViewFn               func(int) (*model.User, error)
-	FindByReferralCodeFn func(string) (*model.ReferralCodeVerifyResponse, error)
-	FindByUsernameFn     func(string) (*model.User, error)
-	FindByEmailFn        func(string) (*model.User, error)
-	FindByMobileFn       func(string, string) (*model.User, error)
-	FindByTokenFn        func(string) (*model.User, error)
-	UpdateLoginFn        func(*model.User) error
-	ListFn               func(*model.ListQuery, *model.Pagination) ([]model.User, error)
-	DeleteFn             func(*model.User) error
-	UpdateFn             func(*model.User) (*model.User, error)
+	ViewFn                  func(int) (*model.User, error)
+	FindByReferralCodeFn    func(string) (*model.ReferralCodeVerifyResponse, error)
+	FindByUsernameFn        func(string) (*model.User, error)
+	FindByEmailFn           func(string) (*model.User, error)
+	FindByMobileFn          func(string, string) (*model.User, error)
+	FindByTokenFn           func(string) (*model.User, error)
+	UpdateLoginFn           func(*model.User) error
+	ListFn                  func(*model.ListQuery, *model.Pagination) ([]model.User, error)
+	DeleteFn                func(*model.User) error
+	UpdateFn                func(*model.User) (*model.User, error)
+	CreatePostFn            func(*model.Post) (*model.Post, error)
+	ListPostFn              func(*model.ListQuery, *model.Pagination) ([]model.Post, error)
+	ViewPostFn              func(int) (*model.Post, error)
+	ListPrivatePostFn       func(*model.ListQuery, *model.Pagination) ([]model.Post, error)
+	ListPostCommentsCountFn func([]int) ([]model.CommentCount, error)
+	UpdatePostFn            func(*model.Post) (*model.Post, error)
+	DeletePostFn            func(*model.Post) error
+	CreateCommentFn         func(*model.Post) (*model.Post, error)
+	ListPostCommentsFn      func(*model.ListQuery, *model.Pagination, int) ([]model.Post, error)
+	ViewPostCommentFn       func(int) (*model.Post, error)
+	UpdatePostCommentFn     func(*model.Post) (*model.Post, error)
+	DeletePostCommentFn     func(*model.Post) error
 }
 
 // View mock
@@ -67,3 +79,63 @@ func (u *User) Delete(usr *model.User) error {
 func (u *User) Update(usr *model.User) (*model.User, error) {
 	return u.UpdateFn(usr)
 }
+
+// CreatePost mock
+func (u *User) CreatePost(post *model.Post) (*model.Post, error) {
+	return u.CreatePostFn(post)
+}
+
+// ListPost mock
+func (u *User) ListPost(lq *model.ListQuery, p *model.Pagination) ([]model.Post, error) {
+	return u.ListPostFn(lq, p)
+}
+
+// ViewPost mock
+func (u *User) ViewPost(id int) (*model.Post, error) {
+	return u.ViewPostFn(id)
+}
+
+// ListPrivatePost mock
+func (u *User) ListPrivatePost(lq *model.ListQuery, p *model.Pagination) ([]model.Post, error) {
+	return u.ListPrivatePostFn(lq, p)
+}
+
+// ListPostCommentsCount mock
+func (u *User) ListPostCommentsCount(ids []int) ([]model.CommentCount, error) {
+	return u.ListPostCommentsCountFn(ids)
+}
+
+// UpdatePost mock
+func (u *User) UpdatePost(post *model.Post) (*model.Post, error) {
+	return u.UpdatePostFn(post)
+}
+
+// DeletePost mock
+func (u *User) DeletePost(post *model.Post) error {
+	return u.DeletePostFn(post)
+}
+
+// CreateComment mock
+func (u *User) CreateComment(post *model.Post) (*model.Post, error) {
+	return u.CreateCommentFn(post)
+}
+
+// ListPostComments mock
+func (u *User) ListPostComments(lq *model.ListQuery, p *model.Pagination, postID int) ([]model.Post, error) {
+	return u.ListPostCommentsFn(lq, p, postID)
+}
+
+// ViewPostComment mock
+func (u *User) ViewPostComment(id int) (*model.Post, error) {
+	return u.ViewPostCommentFn(id)
+}
+
+// UpdatePostComment mock
+func (u *User) UpdatePostComment(post *model.Post) (*model.Post, error) {
+	return u.UpdatePostCommentFn(post)
+}
+
+// DeletePostComment mock
+func (u *User) DeletePostComment(post *model.Post) error {
+	return u.DeletePostCommentFn(post)
+}
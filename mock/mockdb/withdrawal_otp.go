@@ -0,0 +1,33 @@
+package mockdb
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// WithdrawalOTP database mock
+type WithdrawalOTP struct {
+	CreateFn     func(*model.WithdrawalOTP) (*model.WithdrawalOTP, error)
+	FindLatestFn func(int) (*model.WithdrawalOTP, error)
+	AddAttemptFn func(*model.WithdrawalOTP, int) error
+	UseFn        func(*model.WithdrawalOTP) error
+}
+
+// Create mock
+func (w *WithdrawalOTP) Create(otp *model.WithdrawalOTP) (*model.WithdrawalOTP, error) {
+	return w.CreateFn(otp)
+}
+
+// FindLatest mock
+func (w *WithdrawalOTP) FindLatest(userID int) (*model.WithdrawalOTP, error) {
+	return w.FindLatestFn(userID)
+}
+
+// AddAttempt mock
+func (w *WithdrawalOTP) AddAttempt(otp *model.WithdrawalOTP, maxAttempts int) error {
+	return w.AddAttemptFn(otp, maxAttempts)
+}
+
+// Use mock
+func (w *WithdrawalOTP) Use(otp *model.WithdrawalOTP) error {
+	return w.UseFn(otp)
+}
@@ -0,0 +1,23 @@
+package mock
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/request"
+	"github.com/alpacahq/ribbit-backend/webauthn"
+)
+
+// Passkeys mock
+type Passkeys struct {
+	BeginLoginFn  func(string) (*webauthn.RequestOptions, error)
+	FinishLoginFn func(*request.PasskeyAssertion) (*model.User, error)
+}
+
+// BeginLogin mock
+func (p *Passkeys) BeginLogin(clientIP string) (*webauthn.RequestOptions, error) {
+	return p.BeginLoginFn(clientIP)
+}
+
+// FinishLogin mock
+func (p *Passkeys) FinishLogin(r *request.PasskeyAssertion) (*model.User, error) {
+	return p.FinishLoginFn(r)
+}
@@ -0,0 +1,19 @@
+package mock
+
+import "github.com/alpacahq/ribbit-backend/model"
+
+// Rewards mock
+type Rewards struct {
+	OnApprovedFn func(*model.User) error
+	OnFundedFn   func(*model.User) error
+}
+
+// OnApproved mock
+func (r *Rewards) OnApproved(u *model.User) error {
+	return r.OnApprovedFn(u)
+}
+
+// OnFunded mock
+func (r *Rewards) OnFunded(u *model.User) error {
+	return r.OnFundedFn(u)
+}
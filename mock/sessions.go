@@ -0,0 +1,29 @@
+package mock
+
+import (
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sessions mock
+type Sessions struct {
+	StartFn     func(*gin.Context, *model.User) (string, error)
+	RotateFn    func(*gin.Context, string) (int, string, error)
+	RevokeAllFn func(*model.User) error
+}
+
+// Start mock
+func (s *Sessions) Start(c *gin.Context, u *model.User) (string, error) {
+	return s.StartFn(c, u)
+}
+
+// Rotate mock
+func (s *Sessions) Rotate(c *gin.Context, token string) (int, string, error) {
+	return s.RotateFn(c, token)
+}
+
+// RevokeAll mock
+func (s *Sessions) RevokeAll(u *model.User) error {
+	return s.RevokeAllFn(u)
+}
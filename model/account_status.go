@@ -0,0 +1,30 @@
+package model
+
+func init() {
+	Register(&AccountStatusChange{})
+}
+
+// Broker account statuses we act on
+const (
+	AccountStatusSubmitted      = "SUBMITTED"
+	AccountStatusActionRequired = "ACTION_REQUIRED"
+	AccountStatusApproved       = "APPROVED"
+	AccountStatusRejected       = "REJECTED"
+	AccountStatusActive         = "ACTIVE"
+)
+
+// AccountStatusChange records a transition of a user's broker account status
+type AccountStatusChange struct {
+	Base
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	AccountID  string `json:"account_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+}
+
+// AccountStatusRepo represents account status database interface (the repository)
+type AccountStatusRepo interface {
+	ListWithAccount() ([]User, error)
+	Transition(*User, string) (*AccountStatusChange, error)
+}
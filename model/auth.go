@@ -9,20 +9,26 @@ type AuthToken struct {
 	Token        string `json:"token"`
 	Expires      string `json:"expires"`
 	RefreshToken string `json:"refresh_token"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
 }
 
-// LoginResponseWithToken holds authentication token details with refresh token
+// LoginResponseWithToken holds authentication token details with refresh token. For a user with two-factor
+// authentication the password step only returns MFAToken, valid until Expires, to exchange at /login/mfa
 type LoginResponseWithToken struct {
-	Token        string `json:"token"`
+	Token        string `json:"token,omitempty"`
 	Expires      string `json:"expires"`
-	RefreshToken string `json:"refresh_token"`
-	User         User   `json:"user"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	User         *User  `json:"user,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
 }
 
-// RefreshToken holds authentication token details
+// RefreshToken holds authentication token details with the refresh token replacing the one used
 type RefreshToken struct {
-	Token   string `json:"token"`
-	Expires string `json:"expires"`
+	Token        string `json:"token"`
+	Expires      string `json:"expires"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 // AuthService represents authentication service interface
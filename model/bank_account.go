@@ -1,17 +1,74 @@
 package model
 
+import (
+	"github.com/alpacahq/ribbit-backend/secret"
+)
+
 func init() {
 	Register(&BankAccount{})
 }
 
-// Verification stores randomly generated tokens that can be redeemed
+// Bank account statuses, these mirror the statuses of the broker's ACH relationships
+const (
+	BankAccountQueued   = "QUEUED"
+	BankAccountApproved = "APPROVED"
+	BankAccountPending  = "PENDING"
+	BankAccountCanceled = "CANCELED"
+)
+
+// Plaid item statuses of a bank account, an empty status means the link works
+const (
+	ItemLoginRequired     = "LOGIN_REQUIRED"
+	ItemPendingExpiration = "PENDING_EXPIRATION"
+	ItemPermissionRevoked = "PERMISSION_REVOKED"
+)
+
+// Verification statuses of a manually entered bank account, accounts linked through Plaid need no verification
+const (
+	VerificationPending  = "PENDING"
+	VerificationVerified = "VERIFIED"
+	VerificationFailed   = "FAILED"
+)
+
+// BankAccount is a bank account linked through Plaid or entered manually, attached to the user's brokerage account as an ACH relationship
 type BankAccount struct {
 	Base
-	ID          int    `json:"id"`
-	UserID      int    `json:"user_id"`
-	AccessToken string `json:"access_token"`
-	AccountID   string `json:"account_id"`
-	BankName    string `json:"bank_name"`
-	AccountName string `json:"account_name"`
-	Status      bool   `json:"status"`
+	ID             int                    `json:"id"`
+	UserID         int                    `json:"user_id"`
+	AccessToken    secret.EncryptedString `json:"-" redact:"true"`
+	ItemID         string                 `json:"-"`
+	PlaidAccountID string                 `json:"plaid_account_id"`
+	AccountID      string                 `json:"account_id"`
+	RelationshipID string                 `json:"relationship_id"`
+	BankName       string                 `json:"bank_name"`
+	AccountName    string                 `json:"account_name"`
+	Mask           string                 `json:"mask"`
+	Status         string                 `json:"status"`
+	ItemStatus     string                 `json:"item_status"`
+	Verification   string                 `json:"verification,omitempty"`
+	NumbersHash    string                 `json:"-"`
+}
+
+// HashBankNumbers returns the hash stored with a bank account to tell when two users link the same account,
+// it is keyed with the field keyring so the numbers can't be recovered by hashing every possible account
+func HashBankNumbers(routingNumber, accountNumber string) string {
+	return secret.Hash(routingNumber + ":" + accountNumber)
+}
+
+// Usable reports whether the bank account may be used for transfers, a manually entered one only once its micro-deposits are confirmed
+func (b *BankAccount) Usable() bool {
+	return b.Verification == "" || b.Verification == VerificationVerified
+}
+
+// BankAccountRepo represents the bank account database interface (the repository)
+type BankAccountRepo interface {
+	Create(*BankAccount) (*BankAccount, error)
+	ListByUser(userID int) ([]BankAccount, error)
+	FindByRelationship(userID int, relationshipID string) (*BankAccount, error)
+	CountByItem(itemID string) (int, error)
+	ListByItem(itemID string) ([]BankAccount, error)
+	UpdateItemStatus(itemID, status string) error
+	UpdateStatus(*BankAccount) error
+	UpdateVerification(*BankAccount) error
+	Delete(*BankAccount) error
 }
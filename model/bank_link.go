@@ -0,0 +1,33 @@
+package model
+
+// BankLinkProvider links a user's bank accounts and reads the details needed to attach them for ACH transfers
+type BankLinkProvider interface {
+	// CreateLinkToken starts linking a new item, or repairs the item of accessToken when it is set
+	CreateLinkToken(clientUserID, accessToken string) (string, error)
+	ExchangePublicToken(publicToken string) (*BankLinkItem, error)
+	GetAuth(accessToken, accountID string) (*BankAuth, error)
+	GetIdentity(accessToken, accountID string) (*BankIdentity, error)
+	RemoveItem(accessToken string) error
+	VerifyWebhook(signature string, body []byte) error
+}
+
+// BankLinkItem is a login at a bank, it may hold several accounts
+type BankLinkItem struct {
+	AccessToken string `redact:"true"`
+	ItemID      string
+}
+
+// BankAuth holds the ACH numbers of a linked account
+type BankAuth struct {
+	AccountNumber   string `redact:"true"`
+	RoutingNumber   string
+	AccountType     string
+	Mask            string
+	InstitutionName string
+}
+
+// BankIdentity holds the name and owner of a linked account
+type BankIdentity struct {
+	AccountName string
+	OwnerName   string
+}
@@ -0,0 +1,37 @@
+package model
+
+func init() {
+	Register(&CoinRedemption{})
+}
+
+// Coin redemption statuses. A redemption is pending while its steps run and complete once the order is
+// placed. When a step fails the earlier ones are undone and it is reversed, if undoing fails as well it
+// needs review by support. A redemption the ledger refused to debit is rejected
+const (
+	RedemptionPending     = "PENDING"
+	RedemptionComplete    = "COMPLETE"
+	RedemptionReversed    = "REVERSED"
+	RedemptionRejected    = "REJECTED"
+	RedemptionNeedsReview = "NEEDS_REVIEW"
+)
+
+// CoinRedemption is coins redeemed for a notional buy of a fractionable asset. It records how far the
+// redemption got: the ledger debit, the cash journaled to the user's account and the order placed
+type CoinRedemption struct {
+	Base
+	ID        int     `json:"id"`
+	UserID    int     `json:"user_id"`
+	Coins     int     `json:"coins"`
+	Amount    float64 `json:"amount"`
+	Symbol    string  `json:"symbol"`
+	Status    string  `json:"status"`
+	JournalID string  `json:"journal_id"`
+	OrderID   string  `json:"order_id"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// CoinRedemptionRepo represents the coin redemption database interface (the repository)
+type CoinRedemptionRepo interface {
+	Create(*CoinRedemption) (*CoinRedemption, error)
+	Update(*CoinRedemption) error
+}
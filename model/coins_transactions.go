@@ -4,13 +4,30 @@ func init() {
 	Register(&CoinStatement{})
 }
 
-// Verification stores randomly generated tokens that can be redeemed
+// Coin statement types, earned coins are positive and spent or expired ones negative. An adjustment goes either way
+const (
+	CoinEarn   = "earn"
+	CoinSpend  = "spend"
+	CoinExpire = "expire"
+	CoinAdjust = "adjust"
+)
+
+// CoinStatement is an entry of a user's coins ledger. Entries are never updated, a correction is a new
+// adjust entry. Reason identifies what the entry is for and is unique per user, so recording the same
+// reason twice leaves a single entry
 type CoinStatement struct {
 	Base
 	ID     int    `json:"id"`
-	UserID int    `json:"user_id"`
+	UserID int    `json:"user_id" pg:"unique:user_reason"`
 	Coins  int    `json:"coins"`
 	Type   string `json:"type"`
-	Reason string `json:"reason"`
+	Reason string `json:"reason" pg:"unique:user_reason"`
 	Status bool   `json:"status"`
 }
+
+// CoinRepo represents the coins ledger database interface (the repository)
+type CoinRepo interface {
+	Append(*CoinStatement) (*CoinStatement, error)
+	Balance(userID int) (int, error)
+	History(userID int, p *Pagination) ([]CoinStatement, error)
+}
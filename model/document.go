@@ -0,0 +1,38 @@
+package model
+
+func init() {
+	Register(&Document{})
+}
+
+const (
+	// DocumentPending is a document stored locally that has not reached the broker yet
+	DocumentPending = "PENDING"
+	// DocumentUploaded is a document accepted by the broker
+	DocumentUploaded = "UPLOADED"
+	// DocumentFailed is a document the broker rejected or we failed to forward
+	DocumentFailed = "FAILED"
+)
+
+// Document is an identity document uploaded by a user for KYC
+type Document struct {
+	Base
+	ID              int    `json:"id"`
+	UserID          int    `json:"user_id"`
+	DocumentType    string `json:"document_type"`
+	DocumentSubType string `json:"document_sub_type"`
+	FileName        string `json:"file_name"`
+	MimeType        string `json:"mime_type"`
+	Size            int64  `json:"size"`
+	Path            string `json:"-"`
+	Status          string `json:"status"`
+	ErrorResponse   string `json:"error_response,omitempty"`
+}
+
+// DocumentRepo represents document database interface (the repository)
+type DocumentRepo interface {
+	Create(*Document) (*Document, error)
+	View(int) (*Document, error)
+	ListByUser(int) ([]Document, error)
+	ListAll() ([]Document, error)
+	UpdateStatus(*Document) error
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&MFAChallenge{})
+}
+
+// MFAChallenge is the second step of a password login for a user with two-factor authentication, it is
+// exchanged for a JWT together with a code from the user's authenticator app or a recovery code
+type MFAChallenge struct {
+	Base
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	TokenHash string    `json:"-" pg:",unique"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int       `json:"attempts" pg:",use_zero"`
+}
+
+// MFARepo represents the two-factor authentication database interface (the repository)
+type MFARepo interface {
+	UpdateFactor(*User) error
+	UseStep(u *User, step int64, at time.Time) error
+	UseRecoveryCode(u *User, hash string, at time.Time) error
+	AddFailedAttempt(u *User, maxAttempts int, lockedUntil time.Time) error
+	CreateChallenge(*MFAChallenge) (*MFAChallenge, error)
+	FindChallenge(tokenHash string) (*MFAChallenge, error)
+	UpdateChallenge(*MFAChallenge) error
+	DeleteChallenge(*MFAChallenge) error
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&MicroDeposit{})
+}
+
+// MicroDeposit holds the two small deposits sent to a manually entered bank account,
+// the account is verified once the user confirms their amounts
+type MicroDeposit struct {
+	Base
+	ID            int        `json:"id"`
+	BankAccountID int        `json:"bank_account_id" pg:",unique"`
+	Reference     string     `json:"reference"`
+	Amounts       []int      `json:"-" pg:",array" redact:"true"`
+	Attempts      int        `json:"attempts" pg:",use_zero"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+}
+
+// MicroDepositRepo represents the micro-deposit database interface (the repository)
+type MicroDepositRepo interface {
+	Create(*MicroDeposit) (*MicroDeposit, error)
+	FindByBankAccount(bankAccountID int) (*MicroDeposit, error)
+	Update(*MicroDeposit) error
+	AddAttempt(d *MicroDeposit, maxAttempts int) error
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&Passkey{})
+	Register(&PasskeyChallenge{})
+}
+
+// Passkey ceremonies
+const (
+	PasskeyRegistration = "REGISTRATION"
+	PasskeyLogin        = "LOGIN"
+)
+
+// Passkey is a WebAuthn credential a user signs in with. SignCount is the last signature counter the
+// authenticator reported, a counter which doesn't go up means the credential was cloned
+type Passkey struct {
+	Base
+	ID           int        `json:"id"`
+	UserID       int        `json:"-"`
+	Name         string     `json:"name"`
+	CredentialID string     `json:"-" pg:",unique"`
+	PublicKey    []byte     `json:"-"`
+	SignCount    int64      `json:"-" pg:",use_zero"`
+	AAGUID       string     `json:"-"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// PasskeyChallenge is the challenge of a pending passkey registration or login, it is used once. Logins
+// aren't tied to a user until the passkey is known, ClientIP limits how many one client can start
+type PasskeyChallenge struct {
+	Base
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Challenge string    `json:"-" pg:",unique"`
+	Ceremony  string    `json:"ceremony"`
+	ClientIP  string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasskeyRepo represents the passkeys database interface (the repository)
+type PasskeyRepo interface {
+	Create(*Passkey) (*Passkey, error)
+	FindByCredential(credentialID string) (*Passkey, error)
+	ListByUser(userID int) ([]Passkey, error)
+	View(userID, id int) (*Passkey, error)
+	UpdateSignCount(*Passkey) error
+	Delete(*Passkey) error
+	CreateChallenge(*PasskeyChallenge) (*PasskeyChallenge, error)
+	FindChallenge(challenge string) (*PasskeyChallenge, error)
+	DeleteChallenge(*PasskeyChallenge) error
+	CountChallenges(clientIP string, since time.Time) (int, error)
+	PurgeChallenges(before time.Time) (int, error)
+}
@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&RecurringDeposit{})
+	Register(&RecurringDepositRun{})
+}
+
+// Frequencies of a recurring deposit
+const (
+	RecurringWeekly   = "weekly"
+	RecurringBiweekly = "biweekly"
+	RecurringMonthly  = "monthly"
+)
+
+// Recurring deposit statuses, a suspended schedule stops until the user resumes it
+const (
+	RecurringActive    = "ACTIVE"
+	RecurringSuspended = "SUSPENDED"
+)
+
+// Outcomes of a single recurring deposit run
+const (
+	RecurringRunSubmitted = "SUBMITTED"
+	RecurringRunFailed    = "FAILED"
+	RecurringRunSkipped   = "SKIPPED"
+)
+
+// RecurringDeposit is a deposit the scheduler repeats from one of the user's linked bank accounts.
+// Day is the weekday (0 is Sunday) for weekly and biweekly schedules and the day of the month for monthly ones.
+// NextDate is the scheduled date of the next run, the deposit is made on the first business day on or after it.
+type RecurringDeposit struct {
+	Base
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	RelationshipID  string     `json:"relationship_id"`
+	Amount          float64    `json:"amount"`
+	Frequency       string     `json:"frequency"`
+	Day             int        `json:"day" pg:",use_zero"`
+	NextDate        time.Time  `json:"next_date"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	Status          string     `json:"status"`
+	SuspendedReason string     `json:"suspended_reason,omitempty"`
+	ActiveSince     time.Time  `json:"-"`
+}
+
+// RecurringDepositRun records one execution of a recurring deposit
+type RecurringDepositRun struct {
+	Base
+	ID                 int       `json:"id"`
+	RecurringDepositID int       `json:"recurring_deposit_id"`
+	UserID             int       `json:"user_id"`
+	ScheduledFor       time.Time `json:"scheduled_for"`
+	TransferID         string    `json:"transfer_id,omitempty"`
+	Status             string    `json:"status"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// RecurringDepositRepo represents the recurring deposit database interface (the repository)
+type RecurringDepositRepo interface {
+	Create(*RecurringDeposit) (*RecurringDeposit, error)
+	View(userID, id int) (*RecurringDeposit, error)
+	ListByUser(userID int) ([]RecurringDeposit, error)
+	ListDue(date time.Time) ([]RecurringDeposit, error)
+	Update(*RecurringDeposit) error
+	Claim(d *RecurringDeposit, next time.Time) error
+	Delete(*RecurringDeposit) error
+	CreateRun(*RecurringDepositRun) error
+	ListRuns(recurringDepositID int) ([]RecurringDepositRun, error)
+	CountReturns(recurringDepositID int, since time.Time) (int, error)
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+func init() {
+	Register(&LeaderboardEntry{})
+}
+
+// Funnel stages of a referred user, each stage implies the ones before it
+const (
+	ReferralSignedUp = "SIGNED_UP"
+	ReferralApproved = "KYC_APPROVED"
+	ReferralFunded   = "FUNDED"
+)
+
+// Invitee is a user who signed up with a referral code, with the rewards their referrer earned for them.
+// Paid rewards were journaled, pending ones are still owed or held for review
+type Invitee struct {
+	FirstName     string    `json:"-"`
+	LastName      string    `json:"-"`
+	Name          string    `json:"name"`
+	AccountStatus string    `json:"-"`
+	Funded        bool      `json:"-"`
+	Stage         string    `json:"stage"`
+	SignedUpAt    time.Time `json:"signed_up_at"`
+	Pending       float64   `json:"pending"`
+	Paid          float64   `json:"paid"`
+}
+
+// SetStage sets the funnel stage the invitee reached
+func (i *Invitee) SetStage() {
+	switch {
+	case i.Funded:
+		i.Stage = ReferralFunded
+	case i.AccountStatus == AccountStatusApproved || i.AccountStatus == AccountStatusActive:
+		i.Stage = ReferralApproved
+	default:
+		i.Stage = ReferralSignedUp
+	}
+}
+
+// LeaderboardEntry is a row of the public referral leaderboard. Only a display name and the number of
+// referred users who funded their account are published
+type LeaderboardEntry struct {
+	Base
+	ID        int    `json:"-"`
+	Rank      int    `json:"rank"`
+	UserID    int    `json:"-"`
+	Name      string `json:"name"`
+	Referrals int    `json:"referrals"`
+}
+
+// DisplayName returns a first name and last initial, the most of a user's name shown to other users
+func DisplayName(firstName, lastName string) string {
+	name := strings.TrimSpace(firstName)
+	if name == "" {
+		return "Anonymous"
+	}
+	if last := strings.TrimSpace(lastName); last != "" {
+		name += " " + strings.ToUpper(string([]rune(last)[0])) + "."
+	}
+	return name
+}
+
+// ReferralRepo represents the referrals database interface (the repository)
+type ReferralRepo interface {
+	ListInvitees(referrer *User) ([]Invitee, error)
+	Rankings(limit int) ([]LeaderboardEntry, error)
+	ReplaceLeaderboard([]LeaderboardEntry) error
+	Leaderboard() ([]LeaderboardEntry, error)
+}
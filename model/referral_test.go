@@ -0,0 +1,22 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+func TestDisplayName(t *testing.T) {
+	cases := map[string][2]string{
+		"Alice S.":  {"Alice", "smith"},
+		"José Ö.":   {" José ", "östberg"},
+		"Mei 王.":    {"Mei", "王芳"},
+		"Bob":       {"Bob", " "},
+		"Anonymous": {"", "Jones"},
+	}
+	for want, name := range cases {
+		if got := model.DisplayName(name[0], name[1]); got != want {
+			t.Errorf("DisplayName(%q, %q) = %q, want %q", name[0], name[1], got, want)
+		}
+	}
+}
@@ -1,14 +1,77 @@
 package model
 
+import "time"
+
 func init() {
 	Register(&Reward{})
 }
 
+// Reward program types, a cash reward is journaled to the user and a stock reward is spent on a notional
+// buy of a stock picked at random from the program's weighted list
+const (
+	ProgramCash  = "cash"
+	ProgramStock = "stock"
+)
+
+// Reward is a version of a referral program. A referred user is rewarded under the program in effect when
+// they signed up, between StartsAt and EndsAt. ReferralKycReward and ReferralSignupReward go to the referrer
+// when the referred user is approved and first funds their account, ReferreKycReward goes to the referred
+// user on approval. A referrer is rewarded for at most PerAccountLimit referred users and the rewards of
+// the program add up to at most Budget, 0 means no limit. Editing a program adds a new version which
+// ends the previous one
 type Reward struct {
 	Base
-	ID                   int     `json:"id"`
-	PerAccountLimit      int     `json:"per_account_limit"`
-	ReferralKycReward    float64 `json:"referral_kyc_reward"`
-	ReferralSignupReward float64 `json:"referral_signup_reward"`
-	ReferreKycReward     float64 `json:"referre_Kyc_reward"`
+	ID                   int           `json:"id"`
+	Name                 string        `json:"name"`
+	Version              int           `json:"version"`
+	PreviousID           int           `json:"previous_id,omitempty"`
+	StartsAt             *time.Time    `json:"starts_at"`
+	EndsAt               *time.Time    `json:"ends_at"`
+	Type                 string        `json:"type"`
+	Stocks               []RewardStock `json:"stocks,omitempty"`
+	Budget               float64       `json:"budget"`
+	PerAccountLimit      int           `json:"per_account_limit"`
+	ReferralKycReward    float64       `json:"referral_kyc_reward"`
+	ReferralSignupReward float64       `json:"referral_signup_reward"`
+	ReferreKycReward     float64       `json:"referre_Kyc_reward"`
+}
+
+// RewardStock is a stock a stock reward may buy, picked with a chance proportional to Weight
+type RewardStock struct {
+	Symbol string `json:"symbol"`
+	Weight int    `json:"weight"`
+}
+
+// InEffect reports whether the program applies to users signing up at t
+func (r *Reward) InEffect(t time.Time) bool {
+	return (r.StartsAt == nil || !t.Before(*r.StartsAt)) && (r.EndsAt == nil || t.Before(*r.EndsAt))
+}
+
+// RewardRepo represents the referral reward database interface (the repository)
+type RewardRepo interface {
+	InEffect(at time.Time) (*Reward, error)
+	ListPrograms() ([]Reward, error)
+	ViewProgram(id int) (*Reward, error)
+	CreateProgram(program *Reward, previous *Reward) (*Reward, error)
+	EndProgram(*Reward) error
+	SpentBudget(programID int) (float64, error)
+	FindReferrer(referralCode string) (*User, error)
+	CountSignups(referralCode string, from, to time.Time, exceptUserID int) (int, error)
+	FindUserReward(userID, refereeID int, rewardType string) (*UserReward, error)
+	CountReferees(programID, referrerID, exceptRefereeID int) (int, error)
+	CreateUserReward(r *UserReward, check func(RewardUsage) error) (*UserReward, error)
+	UpdateUserReward(r *UserReward, from ...string) error
+	ClaimUserReward(*UserReward) error
+	ViewUserReward(id int) (*UserReward, error)
+	ListDueRewards(now time.Time) ([]UserReward, error)
+	ListOutstandingRewards() ([]UserReward, error)
+	ListRewardsInReview() ([]UserReward, error)
+	ListUnsettledRewards() ([]UserReward, error)
+	ListStuckRewards(before time.Time) ([]UserReward, error)
+}
+
+// RewardUsage tells how much of a program was used up, see RewardRepo
+type RewardUsage interface {
+	SpentBudget(programID int) (float64, error)
+	CountReferees(programID, referrerID, exceptRefereeID int) (int, error)
 }
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&Session{})
+}
+
+// Session is a user signed in on a device. Its refresh token is stored hashed and rotated on every
+// refresh, the hashes it replaced are kept so that a stolen token used again revokes the whole session
+type Session struct {
+	Base
+	ID            int        `json:"id"`
+	UserID        int        `json:"-"`
+	TokenHash     string     `json:"-" pg:",unique"`
+	RotatedHashes []string   `json:"-" pg:",array"`
+	DeviceID      string     `json:"device_id"`
+	IP            string     `json:"ip"`
+	UserAgent     string     `json:"user_agent"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	RevokedAt     *time.Time `json:"-"`
+}
+
+// SessionRepo represents the sessions database interface (the repository)
+type SessionRepo interface {
+	Create(*Session) (*Session, error)
+	FindByToken(tokenHash string) (*Session, error)
+	FindByRotatedToken(tokenHash string) (*Session, error)
+	Rotate(session *Session, previousHash string) error
+	ListByUser(userID int) ([]Session, error)
+	View(userID, id int) (*Session, error)
+	Revoke(*Session) error
+	RevokeAll(userID int) error
+}
@@ -0,0 +1,77 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&Transfer{})
+	Register(&TransferEvent{})
+}
+
+// Transfer directions as used by the broker's transfers API
+const (
+	TransferIncoming = "INCOMING"
+	TransferOutgoing = "OUTGOING"
+)
+
+// Transfer statuses as used by the broker, a transfer usually goes QUEUED, SENT_TO_CLEARING, then COMPLETE or RETURNED
+const (
+	// TransferStatusPending is ours, it holds a transfer in the ledger while the broker is asked to create it
+	TransferStatusPending        = "PENDING"
+	TransferStatusQueued         = "QUEUED"
+	TransferStatusSentToClearing = "SENT_TO_CLEARING"
+	TransferStatusComplete       = "COMPLETE"
+	// rejected, canceled and returned transfers never move money and so don't count towards transfer limits
+	TransferStatusRejected = "REJECTED"
+	TransferStatusCanceled = "CANCELED"
+	TransferStatusReturned = "RETURNED"
+)
+
+// TransferFinal reports whether a transfer in the status won't change anymore
+func TransferFinal(status string) bool {
+	switch status {
+	case TransferStatusComplete, TransferStatusReturned, TransferStatusCanceled, TransferStatusRejected:
+		return true
+	}
+	return false
+}
+
+// Transfer is our local ledger entry for an ACH transfer requested through the broker
+type Transfer struct {
+	Base
+	ID             int     `json:"id"`
+	UserID         int     `json:"user_id"`
+	AccountID      string  `json:"account_id"`
+	TransferID     string  `json:"transfer_id"`
+	RelationshipID string  `json:"relationship_id"`
+	Direction      string  `json:"direction"`
+	Amount         float64 `json:"amount"`
+	Status         string  `json:"status"`
+	Reason         string  `json:"reason,omitempty"`
+}
+
+// TransferEvent is a status change of a transfer, together they make up its timeline
+type TransferEvent struct {
+	Base
+	ID         int    `json:"id"`
+	TransferID int    `json:"transfer_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// TransferRepo represents the transfer ledger database interface (the repository)
+type TransferRepo interface {
+	Create(*Transfer) (*Transfer, error)
+	View(userID, id int) (*Transfer, error)
+	FindByTransferID(userID int, transferID string) (*Transfer, error)
+	ListPending() ([]Transfer, error)
+	UpdateStatus(t *Transfer, status, reason string) (*TransferEvent, error)
+	ListEvents(transferID int) ([]TransferEvent, error)
+	SumSince(userID int, direction string, since time.Time) (float64, error)
+	Reserve(t *Transfer, check func(TransferSum) error) error
+	Accept(t *Transfer, transferID, status string) error
+	Discard(t *Transfer) error
+}
+
+// TransferSum returns the amount a user transferred in direction since the given time, see TransferRepo.SumSince
+type TransferSum func(userID int, direction string, since time.Time) (float64, error)
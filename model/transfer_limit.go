@@ -0,0 +1,25 @@
+package model
+
+func init() {
+	Register(&TransferLimit{})
+}
+
+// TransferLimit holds an admin's overrides of the global transfer caps for one user.
+// A nil cap falls back to the global default, a cap of 0 means no limit.
+type TransferLimit struct {
+	Base
+	ID                int      `json:"id"`
+	UserID            int      `json:"user_id" pg:",unique"`
+	DepositDaily      *float64 `json:"deposit_daily"`
+	DepositWeekly     *float64 `json:"deposit_weekly"`
+	DepositMonthly    *float64 `json:"deposit_monthly"`
+	WithdrawalDaily   *float64 `json:"withdrawal_daily"`
+	WithdrawalWeekly  *float64 `json:"withdrawal_weekly"`
+	WithdrawalMonthly *float64 `json:"withdrawal_monthly"`
+}
+
+// TransferLimitRepo represents the transfer limit database interface (the repository)
+type TransferLimitRepo interface {
+	FindByUser(userID int) (*TransferLimit, error)
+	Save(*TransferLimit, *User) (*TransferLimit, error)
+}
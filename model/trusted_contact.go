@@ -0,0 +1,29 @@
+package model
+
+func init() {
+	Register(&TrustedContact{})
+}
+
+// TrustedContact is the person the broker may contact about a user's account
+type TrustedContact struct {
+	Base
+	ID            int    `json:"id"`
+	UserID        int    `json:"user_id"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	StreetAddress string `json:"street_address"`
+	City          string `json:"city"`
+	State         string `json:"state"`
+	PostalCode    string `json:"postal_code"`
+	Country       string `json:"country"`
+}
+
+// TrustedContactRepo represents trusted contact database interface (the repository)
+type TrustedContactRepo interface {
+	FindByUser(int) (*TrustedContact, error)
+	Create(*TrustedContact) (*TrustedContact, error)
+	Update(*TrustedContact) (*TrustedContact, error)
+	Delete(*TrustedContact) error
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/alpacahq/ribbit-backend/secret"
 )
 
 func init() {
@@ -11,57 +13,68 @@ func init() {
 // User represents user domain model
 type User struct {
 	Base
-	ID                                int        `json:"id"`
-	FirstName                         string     `json:"first_name"`
-	LastName                          string     `json:"last_name"`
-	Username                          string     `json:"username"`
-	Password                          string     `json:"-"`
-	Email                             string     `json:"email"`
-	Mobile                            string     `json:"mobile"`
-	CountryCode                       string     `json:"country_code"`
-	Address                           string     `json:"address"`
-	LastLogin                         *time.Time `json:"last_login,omitempty"`
-	Verified                          bool       `json:"verified"`
-	Active                            bool       `json:"active"`
-	Token                             string     `json:"-"`
-	Role                              *Role      `json:"role,omitempty"`
-	RoleID                            int        `json:"-"`
-	AccountID                         string     `json:"account_id"`
-	AccountNumber                     string     `json:"account_number"`
-	AccountCurrency                   string     `json:"account_currency"`
-	AccountStatus                     string     `json:"account_status"`
-	DOB                               string     `json:"dob"`
-	City                              string     `json:"city"`
-	State                             string     `json:"state"`
-	Country                           string     `json:"country"`
-	TaxIDType                         string     `json:"tax_id_type"`
-	TaxID                             string     `json:"tax_id"`
-	FundingSource                     string     `json:"funding_source"`
-	EmploymentStatus                  string     `json:"employment_status"`
-	InvestingExperience               string     `json:"investing_experience"`
-	PublicShareholder                 string     `json:"public_shareholder"`
-	AnotherBrokerage                  string     `json:"another_brokerage"`
-	DeviceID                          string     `json:"device_id"`
-	ProfileCompletion                 string     `json:"profile_completion"`
-	BIO                               string     `json:"bio"`
-	FacebookURL                       string     `json:"facebook_url"`
-	TwitterURL                        string     `json:"twitter_url"`
-	InstagramURL                      string     `json:"instagram_url"`
-	PublicPortfolio                   string     `json:"public_portfolio"`
-	EmployerName                      string     `json:"employer_name"`
-	Occupation                        string     `json:"occupation"`
-	UnitApt                           string     `json:"unit_apt"`
-	ZipCode                           string     `json:"zip_code"`
-	StockSymbol                       string     `json:"stock_symbol"`
-	BrokerageFirmName                 string     `json:"brokerage_firm_name"`
-	BrokerageFirmEmployeeName         string     `json:"brokerage_firm_employee_name"`
-	BrokerageFirmEmployeeRelationship string     `json:"brokerage_firm_employee_relationship"`
-	ShareholderCompanyName            string     `json:"shareholder_company_name"`
-	Avatar                            string     `json:"avatar"`
-	ReferredBy                        string     `json:"referred_by"`
-	ReferralCode                      string     `json:"referral_code"`
-	WatchlistID                       string     `json:"watchlist_id"`
-	PerAccountLimit                   float64    `json:"per_account_limit"`
+	ID                                int                    `json:"id"`
+	FirstName                         string                 `json:"first_name"`
+	LastName                          string                 `json:"last_name"`
+	Username                          string                 `json:"username"`
+	Password                          string                 `json:"-"`
+	Email                             string                 `json:"email"`
+	Mobile                            string                 `json:"mobile"`
+	CountryCode                       string                 `json:"country_code"`
+	Address                           string                 `json:"address"`
+	LastLogin                         *time.Time             `json:"last_login,omitempty"`
+	Verified                          bool                   `json:"verified"`
+	Active                            bool                   `json:"active"`
+	Token                             string                 `json:"-"`
+	Role                              *Role                  `json:"role,omitempty"`
+	RoleID                            int                    `json:"-"`
+	AccountID                         string                 `json:"account_id"`
+	AccountNumber                     string                 `json:"account_number"`
+	AccountCurrency                   string                 `json:"account_currency"`
+	AccountStatus                     string                 `json:"account_status"`
+	DOB                               secret.EncryptedString `json:"dob" redact:"true"`
+	City                              string                 `json:"city"`
+	State                             string                 `json:"state"`
+	Country                           string                 `json:"country"`
+	CountryOfCitizenship              string                 `json:"country_of_citizenship"`
+	CountryOfBirth                    string                 `json:"country_of_birth"`
+	CountryOfTaxResidence             string                 `json:"country_of_tax_residence"`
+	TaxIDType                         string                 `json:"tax_id_type"`
+	TaxID                             secret.EncryptedString `json:"tax_id" redact:"true"`
+	FundingSource                     string                 `json:"funding_source"`
+	EmploymentStatus                  string                 `json:"employment_status"`
+	InvestingExperience               string                 `json:"investing_experience"`
+	PublicShareholder                 string                 `json:"public_shareholder"`
+	AnotherBrokerage                  string                 `json:"another_brokerage"`
+	DeviceID                          string                 `json:"device_id"`
+	SignupIP                          string                 `json:"-"`
+	ProfileCompletion                 string                 `json:"profile_completion"`
+	BIO                               string                 `json:"bio"`
+	FacebookURL                       string                 `json:"facebook_url"`
+	TwitterURL                        string                 `json:"twitter_url"`
+	InstagramURL                      string                 `json:"instagram_url"`
+	PublicPortfolio                   string                 `json:"public_portfolio"`
+	EmployerName                      string                 `json:"employer_name"`
+	Occupation                        string                 `json:"occupation"`
+	UnitApt                           string                 `json:"unit_apt"`
+	ZipCode                           string                 `json:"zip_code"`
+	StockSymbol                       string                 `json:"stock_symbol"`
+	BrokerageFirmName                 string                 `json:"brokerage_firm_name"`
+	BrokerageFirmEmployeeName         string                 `json:"brokerage_firm_employee_name"`
+	BrokerageFirmEmployeeRelationship string                 `json:"brokerage_firm_employee_relationship"`
+	ShareholderCompanyName            string                 `json:"shareholder_company_name"`
+	Avatar                            string                 `json:"avatar"`
+	ReferredBy                        string                 `json:"referred_by"`
+	ReferralCode                      string                 `json:"referral_code"`
+	WatchlistID                       string                 `json:"watchlist_id"`
+	PerAccountLimit                   float64                `json:"per_account_limit"`
+	MFAEnabled                        bool                   `json:"mfa_enabled"`
+	MFASecret                         secret.EncryptedString `json:"-" redact:"true"`
+	MFARecoveryHashes                 []string               `json:"-" pg:",array"`
+	MFALastStep                       int64                  `json:"-"`
+	MFAVerifiedAt                     *time.Time             `json:"-"`
+	MFAFailedAttempts                 int                    `json:"-" pg:",use_zero"`
+	MFALockedUntil                    *time.Time             `json:"-"`
 }
 
 type Post struct {
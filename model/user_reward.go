@@ -1,17 +1,63 @@
 package model
 
+import "time"
+
 func init() {
 	Register(&UserReward{})
 }
 
+// Reward types of a UserReward
+const (
+	RewardReferralKyc    = "referral_kyc"
+	RewardReferralSignup = "referral_signup"
+	RewardReferreKyc     = "referre_kyc"
+)
+
+// Payout statuses of a UserReward. A reward is recorded pending before it is journaled, so that a
+// concurrent payout of the same reward fails on the unique constraint instead of paying twice, and a
+// retry claims it as processing for the same reason. A paid reward whose journal the broker rejects
+// afterwards fails again, one left pending or processing by an interrupted payout is dead-lettered for
+// an admin to check. A failed reward is retried with exponential backoff until it runs
+// out of attempts and is dead-lettered, an admin may retry or void it from either state. A reward over
+// the referrer's per account limit is recorded as skipped. A reward flagged by the fraud rules waits
+// in review until an admin approves or voids it
+const (
+	RewardPending    = "PENDING"
+	RewardProcessing = "PROCESSING"
+	RewardPaid       = "PAID"
+	RewardFailed     = "FAILED"
+	RewardDeadLetter = "DEAD_LETTER"
+	RewardVoided     = "VOIDED"
+	RewardSkipped    = "SKIPPED"
+	RewardInReview   = "IN_REVIEW"
+)
+
+// UserReward is one referral reward paid, or attempted, to UserID for referring or being RefereeID under
+// ProgramID. ReferredBy is the referrer of the pair. A stock reward buys Symbol once its cash is journaled.
+// RewardTransferStatus is set once the reward was paid, otherwise ErrorResponse says why it wasn't.
 type UserReward struct {
 	Base
-	ID                   int     `json:"id"`
-	UserID               int     `json:"user_id"`
-	JournalID            string  `json:"journal_id"`
-	ReferredBy           int     `json:"referred_by"`
-	RewardValue          float32 `json:"reward_value"`
-	RewardType           string  `json:"reward_type"`
-	RewardTransferStatus bool    `json:"reward_transfer_status"`
-	ErrorResponse        string  `json:"error_response"`
+	ID                   int        `json:"id"`
+	UserID               int        `json:"user_id" pg:",unique:payout"`
+	RefereeID            int        `json:"referee_id" pg:",unique:payout"`
+	ProgramID            int        `json:"program_id"`
+	JournalID            string     `json:"journal_id"`
+	JournalStatus        string     `json:"journal_status,omitempty"`
+	Symbol               string     `json:"symbol,omitempty"`
+	OrderID              string     `json:"order_id,omitempty"`
+	ReferredBy           int        `json:"referred_by"`
+	RewardValue          float32    `json:"reward_value"`
+	RewardType           string     `json:"reward_type" pg:",unique:payout"`
+	RewardTransferStatus bool       `json:"reward_transfer_status"`
+	ErrorResponse        string     `json:"error_response"`
+	Status               string     `json:"status"`
+	Attempts             int        `json:"attempts" pg:",use_zero"`
+	NextAttemptAt        *time.Time `json:"next_attempt_at,omitempty"`
+	FraudScore           int        `json:"fraud_score"`
+	FraudReasons         []string   `json:"fraud_reasons,omitempty" pg:",array"`
+}
+
+// Outstanding reports whether the reward is still owed to the user
+func (r *UserReward) Outstanding() bool {
+	return r.Status == RewardFailed || r.Status == RewardDeadLetter
 }
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+func init() {
+	Register(&WithdrawalOTP{})
+}
+
+// WithdrawalOTP is a one time password emailed to a user to confirm a withdrawal of an amount to a bank.
+// Only its hash is stored, it expires and stops being accepted after too many wrong guesses
+type WithdrawalOTP struct {
+	Base
+	ID             int        `json:"id"`
+	UserID         int        `json:"-"`
+	RelationshipID string     `json:"-"`
+	Amount         float64    `json:"-"`
+	CodeHash       string     `json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	Attempts       int        `json:"-" pg:",use_zero"`
+	UsedAt         *time.Time `json:"-"`
+}
+
+// WithdrawalOTPRepo represents the withdrawal OTP database interface (the repository)
+type WithdrawalOTPRepo interface {
+	Create(*WithdrawalOTP) (*WithdrawalOTP, error)
+	FindLatest(userID int) (*WithdrawalOTP, error)
+	AddAttempt(otp *WithdrawalOTP, maxAttempts int) error
+	Use(*WithdrawalOTP) error
+}
@@ -3,7 +3,6 @@ package repository
 import (
 	"crypto/rand"
 	"io"
-	"log"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -81,10 +80,7 @@ func (a *AccountRepo) CreateAndVerify(u *model.User) (*model.Verification, error
 		return nil, apperr.DB
 	}
 
-	re, err := regexp.Compile(`[^a-z0-9]`)
-	if err != nil {
-		log.Fatal(err)
-	}
+	re := regexp.MustCompile(`[^a-z0-9]`)
 	u.ReferralCode = strings.ToUpper(re.ReplaceAllString(strings.Split(u.Email, "@")[0]+strconv.Itoa(u.ID), ""))
 	if err := a.db.Update(u); err != nil {
 		a.log.Warn("AccountRepo error: ", zap.Error(err))
@@ -147,10 +143,7 @@ func (a *AccountRepo) CreateWithMobile(u *model.User) error {
 		a.log.Warn("AccountRepo error: ", zap.Error(err))
 		return apperr.DB
 	}
-	re, err := regexp.Compile(`[^a-z0-9]`)
-	if err != nil {
-		log.Fatal(err)
-	}
+	re := regexp.MustCompile(`[^a-z0-9]`)
 	u.ReferralCode = strings.ToUpper(re.ReplaceAllString(strings.Split(u.Email, "@")[0]+strconv.Itoa(u.ID), ""))
 
 	if err := a.db.Update(u); err != nil {
@@ -184,10 +177,7 @@ func (a *AccountRepo) CreateWithMagic(u *model.User) (int, error) {
 		a.log.Warn("AccountRepo error: ", zap.Error(err))
 		return 0, apperr.DB
 	}
-	re, err := regexp.Compile(`[^a-z0-9]`)
-	if err != nil {
-		log.Fatal(err)
-	}
+	re := regexp.MustCompile(`[^a-z0-9]`)
 	u.ReferralCode = strings.ToUpper(re.ReplaceAllString(strings.Split(u.Email, "@")[0]+strconv.Itoa(u.ID), ""))
 	if err := a.db.Update(u); err != nil {
 		a.log.Warn("AccountRepo error: ", zap.Error(err))
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/geo"
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository/platform/structs"
 	"github.com/alpacahq/ribbit-backend/request"
@@ -18,15 +19,24 @@ type Service struct {
 	userRepo    model.UserRepo
 	rbac        model.RBACService
 	secret      secret.Service
+	geo         *geo.Registry
+	sessions    Sessions
+}
+
+// Sessions signs users out of their devices
+type Sessions interface {
+	RevokeAll(*model.User) error
 }
 
 // NewAccountService creates a new account application service
-func NewAccountService(userRepo model.UserRepo, accountRepo model.AccountRepo, rbac model.RBACService, secret secret.Service) *Service {
+func NewAccountService(userRepo model.UserRepo, accountRepo model.AccountRepo, rbac model.RBACService, secret secret.Service, geo *geo.Registry, sessions Sessions) *Service {
 	return &Service{
 		accountRepo: accountRepo,
 		userRepo:    userRepo,
 		rbac:        rbac,
 		secret:      secret,
+		geo:         geo,
+		sessions:    sessions,
 	}
 }
 
@@ -40,7 +50,7 @@ func (s *Service) Create(c *gin.Context, u *model.User) error {
 	return err
 }
 
-// ChangePassword changes user's password
+// ChangePassword changes user's password and signs them out of every device
 func (s *Service) ChangePassword(c *gin.Context, oldPass, newPass string, id int) error {
 	if !s.rbac.EnforceUser(c, id) {
 		return apperr.New(http.StatusForbidden, "Forbidden")
@@ -53,7 +63,10 @@ func (s *Service) ChangePassword(c *gin.Context, oldPass, newPass string, id int
 		return apperr.New(http.StatusBadGateway, "old password is not correct")
 	}
 	u.Password = s.secret.HashPassword(newPass)
-	return s.accountRepo.ChangePassword(u)
+	if err := s.accountRepo.ChangePassword(u); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAll(u)
 }
 
 // UpdateAvatar changes user's avatar
@@ -92,5 +105,79 @@ func (s *Service) UpdateProfile(c *gin.Context, update *request.Update) (*model.
 		return nil, err
 	}
 	structs.Merge(u, update)
+	if err := s.validateCountries(u, update); err != nil {
+		return nil, err
+	}
+	if update.City != nil || update.State != nil || update.ZipCode != nil {
+		if err := s.NormalizeAddress(u); err != nil {
+			return nil, err
+		}
+	}
 	return s.userRepo.Update(u)
 }
+
+// Countries returns the countries matching q
+func (s *Service) Countries(q string) []geo.Country {
+	return s.geo.Countries(q)
+}
+
+// States returns the US states matching q
+func (s *Service) States(q string) []geo.State {
+	return s.geo.States(q)
+}
+
+// Cities returns the cities of a US state matching q
+func (s *Service) Cities(state, q string) []geo.City {
+	return s.geo.Cities(state, q)
+}
+
+// LookupZip returns the cities and states of a US zip code
+func (s *Service) LookupZip(zip string) []geo.Place {
+	return s.geo.LookupZip(zip)
+}
+
+// NormalizeAddress checks that the city, state and zip code of a US user agree and normalizes their spelling
+func (s *Service) NormalizeAddress(u *model.User) error {
+	if u.Country != "" && u.Country != "USA" {
+		return nil
+	}
+	a, err := s.geo.NormalizeAddress(geo.Address{City: u.City, State: u.State, Zip: u.ZipCode})
+	if err != nil {
+		return apperr.New(http.StatusBadRequest, "Invalid address: "+err.Error()+".")
+	}
+	u.City, u.State = a.City, a.State
+	return nil
+}
+
+// ValidateIdentity checks the countries and tax id the user onboards with
+func (s *Service) ValidateIdentity(u *model.User) error {
+	for _, code := range []string{u.CountryOfCitizenship, u.CountryOfBirth, u.CountryOfTaxResidence} {
+		if !s.geo.ValidCountry(code) {
+			return apperr.New(http.StatusBadRequest, "Unknown country "+code+".")
+		}
+	}
+	if err := geo.ValidateTaxID(u.CountryOfTaxResidence, u.TaxIDType, string(u.TaxID)); err != nil {
+		return apperr.New(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// validateCountries checks the country fields present in the update, the tax id is checked
+// once both its type and the country of tax residence are known
+func (s *Service) validateCountries(u *model.User, update *request.Update) error {
+	for _, code := range []*string{update.Country, update.CountryOfCitizenship, update.CountryOfBirth, update.CountryOfTaxResidence} {
+		if code != nil && *code != "" && !s.geo.ValidCountry(*code) {
+			return apperr.New(http.StatusBadRequest, "Unknown country "+*code+".")
+		}
+	}
+	if update.TaxID == nil && update.TaxIDType == nil && update.CountryOfTaxResidence == nil {
+		return nil
+	}
+	if u.TaxIDType == "" || u.CountryOfTaxResidence == "" {
+		return nil
+	}
+	if err := geo.ValidateTaxID(u.CountryOfTaxResidence, u.TaxIDType, string(u.TaxID)); err != nil {
+		return apperr.New(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewAccountStatusRepo returns a new AccountStatusRepo instance
+func NewAccountStatusRepo(db orm.DB, log *zap.Logger) *AccountStatusRepo {
+	return &AccountStatusRepo{db, log}
+}
+
+// AccountStatusRepo is the client for broker account statuses and their history
+type AccountStatusRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// ListWithAccount returns all users that have a broker account
+func (a *AccountStatusRepo) ListWithAccount() ([]model.User, error) {
+	var users []model.User
+	err := a.db.Model(&users).
+		Column("id", "email", "country_code", "mobile", "first_name", "account_id", "account_status").
+		Where("account_id is not null and account_id != ''").
+		Where(notDeleted).
+		Order("id asc").
+		Select()
+	if err != nil {
+		a.log.Warn("AccountStatusRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return users, nil
+}
+
+// errStatusChanged is returned when the status was changed by someone else since the user was loaded
+var errStatusChanged = apperr.New(http.StatusConflict, "Account status was already changed.")
+
+// Transition updates the user's account status and records the change, it fails when the
+// stored status is no longer the one the user was loaded with
+func (a *AccountStatusRepo) Transition(u *model.User, status string) (*model.AccountStatusChange, error) {
+	change := &model.AccountStatusChange{
+		UserID:     u.ID,
+		AccountID:  u.AccountID,
+		FromStatus: u.AccountStatus,
+		ToStatus:   status,
+	}
+	err := inTx(a.db, func(db orm.DB) error {
+		res, err := db.Model((*model.User)(nil)).
+			Set("account_status = ?", status).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", u.ID).
+			Where("coalesce(account_status, '') = ?", u.AccountStatus).
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errStatusChanged
+		}
+		return db.Insert(change)
+	})
+	if err == errStatusChanged {
+		return nil, err
+	}
+	if err != nil {
+		a.log.Warn("AccountStatusRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	u.AccountStatus = status
+	return change, nil
+}
@@ -0,0 +1,112 @@
+package accountsync
+
+import (
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mail"
+	"github.com/alpacahq/ribbit-backend/mobile"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"go.uber.org/zap"
+)
+
+// notifications holds the message sent to the user when their account reaches a status
+var notifications = map[string]struct{ subject, body string }{
+	model.AccountStatusApproved: {
+		"Your account is approved",
+		"Good news! Your brokerage account has been approved. You can now fund your account and start investing.",
+	},
+	model.AccountStatusActionRequired: {
+		"Action required on your account",
+		"We need a little more information to open your brokerage account. Please open the app and upload the requested documents.",
+	},
+	model.AccountStatusRejected: {
+		"Your account application",
+		"Unfortunately we were unable to approve your brokerage account application. Please contact support for more details.",
+	},
+}
+
+// Rewards pays the referral rewards due when a referred user's account is approved
+type Rewards interface {
+	OnApproved(*model.User) error
+}
+
+// NewAccountSyncService creates new account sync service
+func NewAccountSyncService(statusRepo model.AccountStatusRepo, broker broker.Service, mail mail.Service, mobile mobile.Service, rewards Rewards, log *zap.Logger) *Service {
+	return &Service{statusRepo, broker, mail, mobile, rewards, log}
+}
+
+// Service represents the broker account status sync application service
+type Service struct {
+	statusRepo model.AccountStatusRepo
+	broker     broker.Service
+	mail       mail.Service
+	mobile     mobile.Service
+	rewards    Rewards
+	log        *zap.Logger
+}
+
+// SyncAll fetches the broker status of every user with an account and returns the number of accounts that changed
+func (s *Service) SyncAll() (int, error) {
+	users, err := s.statusRepo.ListWithAccount()
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for i := range users {
+		ok, err := s.Sync(&users[i])
+		if err != nil {
+			s.log.Warn("AccountSyncService Error", zap.Int("user_id", users[i].ID), zap.Error(err))
+			continue
+		}
+		if ok {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// Sync updates the account status of a single user and notifies them, it reports whether the status changed
+func (s *Service) Sync(u *model.User) (bool, error) {
+	account, err := s.broker.GetAccount(u.AccountID)
+	if err != nil {
+		return false, err
+	}
+	if account.Status == "" || account.Status == u.AccountStatus {
+		return false, nil
+	}
+	change, err := s.statusRepo.Transition(u, account.Status)
+	if err != nil {
+		return false, err
+	}
+	s.log.Info("Account status changed",
+		zap.Int("user_id", u.ID),
+		zap.String("from", change.FromStatus),
+		zap.String("to", change.ToStatus))
+	s.notify(u, change.ToStatus)
+	if change.ToStatus == model.AccountStatusApproved {
+		if err := s.rewards.OnApproved(u); err != nil {
+			s.log.Warn("AccountSyncService Error", zap.Int("user_id", u.ID), zap.Error(err))
+		}
+	}
+	return true, nil
+}
+
+// notify emails the user about the new status, falling back to sms when the email can't be sent
+func (s *Service) notify(u *model.User, status string) {
+	n, ok := notifications[status]
+	if !ok {
+		return
+	}
+	if u.Email != "" {
+		err := s.mail.SendWithDefaults(n.subject, u.Email, n.body, "<p>"+n.body+"</p>")
+		if err == nil {
+			return
+		}
+		s.log.Warn("AccountSyncService Error", zap.Int("user_id", u.ID), zap.Error(err))
+	}
+	if u.Mobile != "" {
+		if err := s.mobile.SendSMS(u.CountryCode, u.Mobile, n.body); err != nil {
+			s.log.Warn("AccountSyncService Error", zap.Int("user_id", u.ID), zap.Error(err))
+		}
+	}
+}
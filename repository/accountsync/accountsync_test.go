@@ -0,0 +1,112 @@
+package accountsync_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/accountsync"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSyncAll(t *testing.T) {
+	users := []model.User{
+		{ID: 1, Email: "a@example.com", AccountID: "acc-1", AccountStatus: model.AccountStatusSubmitted},
+		{ID: 2, Email: "b@example.com", AccountID: "acc-2", AccountStatus: model.AccountStatusApproved},
+		{ID: 3, CountryCode: "+1", Mobile: "5550100", AccountID: "acc-3", AccountStatus: model.AccountStatusSubmitted},
+		{ID: 4, Email: "d@example.com", AccountID: "acc-4", AccountStatus: model.AccountStatusSubmitted},
+	}
+	statuses := map[string]string{
+		"acc-1": model.AccountStatusApproved,
+		"acc-2": model.AccountStatusApproved,
+		"acc-3": model.AccountStatusActionRequired,
+	}
+	var transitions []string
+	var emails, sms []string
+	var approved []int
+
+	statusRepo := &mockdb.AccountStatus{
+		ListWithAccountFn: func() ([]model.User, error) {
+			return users, nil
+		},
+		TransitionFn: func(u *model.User, status string) (*model.AccountStatusChange, error) {
+			transitions = append(transitions, u.AccountID+":"+status)
+			return &model.AccountStatusChange{UserID: u.ID, FromStatus: u.AccountStatus, ToStatus: status}, nil
+		},
+	}
+	b := &mock.Broker{
+		GetAccountFn: func(id string) (*broker.Account, error) {
+			status, ok := statuses[id]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return &broker.Account{ID: id, Status: status}, nil
+		},
+	}
+	m := &mock.Mail{
+		SendWithDefaultsFn: func(subject, toEmail, content, html string) error {
+			emails = append(emails, toEmail)
+			return nil
+		},
+	}
+	mob := &mock.Mobile{
+		SendSMSFn: func(countryCode, mobile, body string) error {
+			sms = append(sms, countryCode+mobile)
+			return nil
+		},
+	}
+	rewards := &mock.Rewards{
+		OnApprovedFn: func(u *model.User) error {
+			approved = append(approved, u.ID)
+			return nil
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	svc := accountsync.NewAccountSyncService(statusRepo, b, m, mob, rewards, log)
+
+	changed, err := svc.SyncAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, changed)
+	assert.Equal(t, []string{"acc-1:APPROVED", "acc-3:ACTION_REQUIRED"}, transitions)
+	assert.Equal(t, []string{"a@example.com"}, emails)
+	assert.Equal(t, []string{"+15550100"}, sms)
+	assert.Equal(t, []int{1}, approved)
+}
+
+func TestSyncFallsBackToSMS(t *testing.T) {
+	u := &model.User{ID: 1, Email: "a@example.com", CountryCode: "+1", Mobile: "5550100", AccountID: "acc-1", AccountStatus: model.AccountStatusSubmitted}
+	var sms int
+	statusRepo := &mockdb.AccountStatus{
+		TransitionFn: func(u *model.User, status string) (*model.AccountStatusChange, error) {
+			return &model.AccountStatusChange{FromStatus: u.AccountStatus, ToStatus: status}, nil
+		},
+	}
+	b := &mock.Broker{
+		GetAccountFn: func(id string) (*broker.Account, error) {
+			return &broker.Account{ID: id, Status: model.AccountStatusRejected}, nil
+		},
+	}
+	m := &mock.Mail{
+		SendWithDefaultsFn: func(subject, toEmail, content, html string) error {
+			return errors.New("sendgrid down")
+		},
+	}
+	mob := &mock.Mobile{
+		SendSMSFn: func(countryCode, mobile, body string) error {
+			sms++
+			return nil
+		},
+	}
+	log, _ := zap.NewDevelopment()
+	svc := accountsync.NewAccountSyncService(statusRepo, b, m, mob, nil, log)
+
+	ok, err := svc.Sync(u)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, sms)
+}
@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/alpacahq/ribbit-backend/apperr"
@@ -35,7 +34,6 @@ func (a *AssetRepo) CreateOrUpdate(ass *model.Asset) (*model.Asset, error) {
 	}
 	if res.RowsReturned() != 0 {
 		// update..
-		fmt.Println("updating...")
 		_, err := a.db.Model(ass).Column(
 			"class",
 			"exchange",
@@ -56,7 +54,6 @@ func (a *AssetRepo) CreateOrUpdate(ass *model.Asset) (*model.Asset, error) {
 		return ass, nil
 	} else {
 		// create
-		fmt.Println("creating...")
 		if err := a.db.Insert(ass); err != nil {
 			a.log.Warn("AssetRepo error: ", zap.Error(err))
 			return nil, apperr.DB
@@ -104,7 +101,6 @@ func (a *AssetRepo) Search(query string) ([]model.Asset, error) {
 	}
 
 	if err == nil {
-		fmt.Println(exactAsset)
 		assets = append([]model.Asset{exactAsset}, findAndDelete(assets, exactAsset)...)
 	}
 
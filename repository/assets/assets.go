@@ -1,8 +1,6 @@
 package assets
 
 import (
-	"fmt"
-
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/go-pg/pg/v9/orm"
 	"go.uber.org/zap"
@@ -11,11 +9,8 @@ import (
 )
 
 func init() {
-	err := godotenv.Load()
-
-	if err != nil {
-		panic(fmt.Errorf("unexpected error while initializing plaid client %w", err))
-	}
+	// like the config package, a missing .env leaves the environment as it is, tests run without one
+	_ = godotenv.Load()
 }
 
 // NewAuthService creates new auth service
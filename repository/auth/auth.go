@@ -3,17 +3,16 @@ package auth
 import (
 	"context"
 	"crypto/rand"
-	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	mag "github.com/magiclabs/magic-admin-go"
 	"github.com/magiclabs/magic-admin-go/client"
 	"github.com/magiclabs/magic-admin-go/token"
-	"github.com/rs/xid"
 
 	"github.com/alpacahq/ribbit-backend/apperr"
 	"github.com/alpacahq/ribbit-backend/magic"
@@ -22,23 +21,29 @@ import (
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/request"
 	"github.com/alpacahq/ribbit-backend/secret"
+	"github.com/alpacahq/ribbit-backend/webauthn"
 
 	shortuuid "github.com/lithammer/shortuuid/v3"
+	"go.uber.org/zap"
 )
 
 // NewAuthService creates new auth service
-func NewAuthService(userRepo model.UserRepo, accountRepo model.AccountRepo, jwt JWT, m mail.Service, mob mobile.Service, mag magic.Service) *Service {
-	return &Service{userRepo, accountRepo, jwt, m, mob, mag}
+func NewAuthService(userRepo model.UserRepo, accountRepo model.AccountRepo, sessions Sessions, mfa MFA, passkeys Passkeys, jwt JWT, m mail.Service, mob mobile.Service, mag magic.Service, log *zap.Logger) *Service {
+	return &Service{userRepo, accountRepo, sessions, mfa, passkeys, jwt, m, mob, mag, log}
 }
 
 // Service represents the auth application service
 type Service struct {
 	userRepo    model.UserRepo
 	accountRepo model.AccountRepo
+	sessions    Sessions
+	mfa         MFA
+	passkeys    Passkeys
 	jwt         JWT
 	m           mail.Service
 	mob         mobile.Service
 	mag         magic.Service
+	log         *zap.Logger
 }
 
 // JWT represents jwt interface
@@ -46,8 +51,27 @@ type JWT interface {
 	GenerateToken(*model.User) (string, string, error)
 }
 
+// Sessions opens a login session per device and rotates its refresh token
+type Sessions interface {
+	Start(*gin.Context, *model.User) (string, error)
+	Rotate(*gin.Context, string) (int, string, error)
+	RevokeAll(*model.User) error
+}
+
+// MFA runs the second step of a login for users with two-factor authentication
+type MFA interface {
+	Challenge(*model.User) (string, time.Time, error)
+	Redeem(token, code string) (*model.User, error)
+}
+
+// Passkeys runs WebAuthn passkey logins
+type Passkeys interface {
+	BeginLogin(clientIP string) (*webauthn.RequestOptions, error)
+	FinishLogin(*request.PasskeyAssertion) (*model.User, error)
+}
+
 // Authenticate tries to authenticate the user provided by username and password
-func (s *Service) Authenticate(c context.Context, email, password string) (*model.LoginResponseWithToken, error) {
+func (s *Service) Authenticate(c *gin.Context, email, password string) (*model.LoginResponseWithToken, error) {
 	u, err := s.userRepo.FindByEmail(email)
 	if err != nil {
 		return nil, apperr.New(http.StatusUnauthorized, "Invalid credentials. Please check and submit again.")
@@ -59,23 +83,14 @@ func (s *Service) Authenticate(c context.Context, email, password string) (*mode
 	// if !u.Active || !u.Verified {
 	// 	return nil, apperr.New(http.StatusUnauthorized, "User already exists.")
 	// }
-	token, expire, err := s.jwt.GenerateToken(u)
-	if err != nil {
-		return nil, apperr.New(http.StatusUnauthorized, "Invalid credentials. Please check and submit again.")
+	if u.MFAEnabled {
+		return s.challenge(u)
 	}
-	u.UpdateLastLogin()
-	u.Token = xid.New().String()
-	if err := s.userRepo.UpdateLogin(u); err != nil {
+	response, err := s.login(c, u)
+	if err != nil {
 		return nil, err
 	}
 
-	response := &model.LoginResponseWithToken{
-		Token:        token,
-		Expires:      expire,
-		RefreshToken: u.Token,
-		User:         *u,
-	}
-
 	if !u.Active {
 		v, _ := s.accountRepo.FindVerificationTokenByUser(u)
 		if v != nil {
@@ -98,9 +113,74 @@ func (s *Service) Authenticate(c context.Context, email, password string) (*mode
 	return response, nil
 }
 
-// Refresh refreshes jwt token and puts new claims inside
-func (s *Service) Refresh(c context.Context, refreshToken string) (*model.RefreshToken, error) {
-	user, err := s.userRepo.FindByToken(refreshToken)
+// challenge starts the second step of the login of a user with two-factor authentication, whichever way
+// they proved who they are first
+func (s *Service) challenge(u *model.User) (*model.LoginResponseWithToken, error) {
+	challenge, expires, err := s.mfa.Challenge(u)
+	if err != nil {
+		return nil, err
+	}
+	return &model.LoginResponseWithToken{
+		Expires:     expires.Format(time.RFC3339),
+		MFARequired: true,
+		MFAToken:    challenge,
+	}, nil
+}
+
+// AuthenticateMFA finishes the login of a user with two-factor authentication, exchanging the token returned
+// by Authenticate and a code from their authenticator app, or a recovery code, for a JWT
+func (s *Service) AuthenticateMFA(c *gin.Context, mfaToken, code string) (*model.LoginResponseWithToken, error) {
+	u, err := s.mfa.Redeem(mfaToken, code)
+	if err != nil {
+		return nil, err
+	}
+	return s.login(c, u)
+}
+
+// PasskeyOptions starts a passkey login and returns the options for navigator.credentials.get()
+func (s *Service) PasskeyOptions(c *gin.Context) (*webauthn.RequestOptions, error) {
+	return s.passkeys.BeginLogin(c.ClientIP())
+}
+
+// AuthenticatePasskey signs a user in with the passkey assertion of a login started with PasskeyOptions.
+// The authenticator already verified the user, so two-factor authentication isn't asked for
+func (s *Service) AuthenticatePasskey(c *gin.Context, r *request.PasskeyAssertion) (*model.LoginResponseWithToken, error) {
+	u, err := s.passkeys.FinishLogin(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.login(c, u)
+}
+
+// login issues a JWT and opens a session for a user who proved who they are
+func (s *Service) login(c *gin.Context, u *model.User) (*model.LoginResponseWithToken, error) {
+	token, expire, err := s.jwt.GenerateToken(u)
+	if err != nil {
+		return nil, apperr.New(http.StatusUnauthorized, "Invalid credentials. Please check and submit again.")
+	}
+	u.UpdateLastLogin()
+	if err := s.userRepo.UpdateLogin(u); err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.sessions.Start(c, u)
+	if err != nil {
+		return nil, err
+	}
+	return &model.LoginResponseWithToken{
+		Token:        token,
+		Expires:      expire,
+		RefreshToken: refreshToken,
+		User:         u,
+	}, nil
+}
+
+// Refresh refreshes jwt token and puts new claims inside, the refresh token is rotated and the new one returned
+func (s *Service) Refresh(c *gin.Context, refreshToken string) (*model.RefreshToken, error) {
+	userID, next, err := s.sessions.Rotate(c, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.View(userID)
 	if err != nil {
 		return nil, err
 	}
@@ -110,8 +190,9 @@ func (s *Service) Refresh(c context.Context, refreshToken string) (*model.Refres
 		return nil, apperr.Generic
 	}
 	return &model.RefreshToken{
-		Token:   token,
-		Expires: expire,
+		Token:        token,
+		Expires:      expire,
+		RefreshToken: next,
 	}, nil
 }
 
@@ -180,6 +261,10 @@ func (s *Service) RecoverPassword(c *gin.Context, email string, otp string, pass
 	if err := s.accountRepo.ResetPassword(u); err != nil {
 		return apperr.New(http.StatusInternalServerError, "Failed to change password, please try again.")
 	}
+	// whoever knew the old password is signed out too
+	if err := s.sessions.RevokeAll(u); err != nil {
+		return err
+	}
 
 	return apperr.New(http.StatusAccepted, "Password changed.")
 }
@@ -195,7 +280,7 @@ func (s *Service) RefVerify(c context.Context, referralCode string) (*model.Refe
 }
 
 // MobileVerify verifies the mobile verification code, i.e. (6-digit) code
-func (s *Service) MobileVerify(c context.Context, countryCode, mobile, code string, signup bool) (*model.AuthToken, error) {
+func (s *Service) MobileVerify(c *gin.Context, countryCode, mobile, code string, signup bool) (*model.AuthToken, error) {
 	// send code to twilio
 	err := s.mob.CheckCode(countryCode, mobile, code)
 	if err != nil {
@@ -205,6 +290,14 @@ func (s *Service) MobileVerify(c context.Context, countryCode, mobile, code stri
 	if err != nil {
 		return nil, err
 	}
+	// the mobile code stands in for the password, it doesn't skip the second factor
+	if u.MFAEnabled {
+		r, err := s.challenge(u)
+		if err != nil {
+			return nil, err
+		}
+		return &model.AuthToken{Expires: r.Expires, MFARequired: r.MFARequired, MFAToken: r.MFAToken}, nil
+	}
 	if signup { // signup case, make user verified and active
 		u.Verified = true
 		u.Active = true
@@ -222,14 +315,17 @@ func (s *Service) MobileVerify(c context.Context, countryCode, mobile, code stri
 		return nil, apperr.New(http.StatusUnauthorized, "Unauthorized")
 	}
 	u.UpdateLastLogin()
-	u.Token = xid.New().String()
 	if err := s.userRepo.UpdateLogin(u); err != nil {
 		return nil, err
 	}
+	refreshToken, err := s.sessions.Start(c, u)
+	if err != nil {
+		return nil, err
+	}
 	return &model.AuthToken{
 		Token:        token,
 		Expires:      expire,
-		RefreshToken: u.Token,
+		RefreshToken: refreshToken,
 	}, nil
 }
 
@@ -254,7 +350,7 @@ func (s *Service) Signup(c *gin.Context, e *request.EmailSignup, password string
 		return nil, apperr.New(http.StatusConflict, "User already exists.")
 	}
 	u := shortuuid.New()
-	v, err := s.accountRepo.CreateAndVerify(&model.User{Email: e.Email, Password: password, ReferralCode: u})
+	v, err := s.accountRepo.CreateAndVerify(&model.User{Email: e.Email, Password: password, ReferralCode: u, SignupIP: c.ClientIP()})
 	if err != nil {
 		return nil, err
 	}
@@ -265,7 +361,7 @@ func (s *Service) Signup(c *gin.Context, e *request.EmailSignup, password string
 	}
 	newUser, err2 := s.userRepo.View(v.UserID)
 	if err2 == nil { // user already exists
-		fmt.Println(newUser)
+		s.log.Info("User signed up", zap.Int("user_id", newUser.ID))
 		// user must be active and verified. Active is enabled/disabled by superadmin user. Verified depends on user verifying via /verification/:token or /mobile/verify
 		// if !newUser.Active || !newUser.Verified {
 		// 	return nil, apperr.Unauthorized
@@ -275,15 +371,18 @@ func (s *Service) Signup(c *gin.Context, e *request.EmailSignup, password string
 			return nil, apperr.New(http.StatusUnauthorized, "Unauthorized")
 		}
 		newUser.UpdateLastLogin()
-		newUser.Token = xid.New().String()
 		if err := s.userRepo.UpdateLogin(newUser); err != nil {
 			return nil, err
 		}
+		refreshToken, err := s.sessions.Start(c, newUser)
+		if err != nil {
+			return nil, err
+		}
 		return &model.LoginResponseWithToken{
 			Token:        token,
 			Expires:      expire,
-			RefreshToken: newUser.Token,
-			User:         *newUser,
+			RefreshToken: refreshToken,
+			User:         newUser,
 		}, nil
 	}
 
@@ -301,6 +400,7 @@ func (s *Service) Mobile(c *gin.Context, m *request.MobileSignup) error {
 	user := &model.User{
 		CountryCode: m.CountryCode,
 		Mobile:      m.Mobile,
+		SignupIP:    c.ClientIP(),
 	}
 	err = s.accountRepo.CreateWithMobile(user)
 	if err != nil {
@@ -361,20 +461,26 @@ func (s *Service) Magic(c *gin.Context, m *request.MagicSignup) (*model.LoginRes
 	// find by email
 	if user, err := s.userRepo.FindByEmail(issuer.Email); err == nil { // user already exists
 		// fmt.Println(user)
+		if user.MFAEnabled {
+			return s.challenge(user)
+		}
 		token, expire, err := s.jwt.GenerateToken(user)
 		if err != nil {
 			return nil, apperr.New(http.StatusUnauthorized, "Unauthorized")
 		}
 		user.UpdateLastLogin()
-		user.Token = xid.New().String()
 		if err := s.userRepo.UpdateLogin(user); err != nil {
 			return nil, err
 		}
+		refreshToken, err := s.sessions.Start(c, user)
+		if err != nil {
+			return nil, err
+		}
 		return &model.LoginResponseWithToken{
 			Token:        token,
 			Expires:      expire,
-			RefreshToken: user.Token,
-			User:         *user,
+			RefreshToken: refreshToken,
+			User:         user,
 		}, nil
 	} else {
 		u := shortuuid.New()
@@ -383,6 +489,7 @@ func (s *Service) Magic(c *gin.Context, m *request.MagicSignup) (*model.LoginRes
 			Verified:     true,
 			Active:       true,
 			ReferralCode: u,
+			SignupIP:     c.ClientIP(),
 		}
 		userID, err := s.accountRepo.CreateWithMagic(user)
 		if err != nil {
@@ -400,15 +507,18 @@ func (s *Service) Magic(c *gin.Context, m *request.MagicSignup) (*model.LoginRes
 				return nil, apperr.New(http.StatusUnauthorized, "Unauthorized")
 			}
 			newUser.UpdateLastLogin()
-			newUser.Token = xid.New().String()
 			if err := s.userRepo.UpdateLogin(newUser); err != nil {
 				return nil, err
 			}
+			refreshToken, err := s.sessions.Start(c, newUser)
+			if err != nil {
+				return nil, err
+			}
 			return &model.LoginResponseWithToken{
 				Token:        token,
 				Expires:      expire,
-				RefreshToken: newUser.Token,
-				User:         *newUser,
+				RefreshToken: refreshToken,
+				User:         newUser,
 			}, nil
 		}
 	}
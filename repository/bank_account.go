@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewBankAccountRepo returns a new BankAccountRepo instance
+func NewBankAccountRepo(db orm.DB, log *zap.Logger) *BankAccountRepo {
+	return &BankAccountRepo{db, log}
+}
+
+// BankAccountRepo is the client for our bank account model
+type BankAccountRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// Create creates a new bank account in our database
+func (b *BankAccountRepo) Create(account *model.BankAccount) (*model.BankAccount, error) {
+	if err := b.db.Insert(account); err != nil {
+		b.log.Warn("BankAccountRepo error: ", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return account, nil
+}
+
+// ListByUser returns the linked bank accounts of a user, newest first
+func (b *BankAccountRepo) ListByUser(userID int) ([]model.BankAccount, error) {
+	var accounts []model.BankAccount
+	sql := `SELECT * FROM bank_accounts WHERE (user_id = ? and deleted_at is null) ORDER BY id DESC`
+	_, err := b.db.Query(&accounts, sql, userID)
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return accounts, nil
+}
+
+// FindByRelationship returns the bank account of a user attached as the given ACH relationship
+func (b *BankAccountRepo) FindByRelationship(userID int, relationshipID string) (*model.BankAccount, error) {
+	var account = new(model.BankAccount)
+	sql := `SELECT * FROM bank_accounts WHERE (user_id = ? and relationship_id = ? and deleted_at is null) LIMIT 1`
+	_, err := b.db.QueryOne(account, sql, userID, relationshipID)
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Bank account not found.")
+	}
+	return account, nil
+}
+
+// CountByItem returns how many linked bank accounts still use a Plaid item
+func (b *BankAccountRepo) CountByItem(itemID string) (int, error) {
+	count, err := b.db.Model((*model.BankAccount)(nil)).Where("item_id = ? and deleted_at is null", itemID).Count()
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return count, nil
+}
+
+// ListByItem returns the linked bank accounts which use a Plaid item
+func (b *BankAccountRepo) ListByItem(itemID string) ([]model.BankAccount, error) {
+	var accounts []model.BankAccount
+	sql := `SELECT * FROM bank_accounts WHERE (item_id = ? and deleted_at is null)`
+	_, err := b.db.Query(&accounts, sql, itemID)
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return accounts, nil
+}
+
+// UpdateItemStatus sets the Plaid item status of every bank account using the item
+func (b *BankAccountRepo) UpdateItemStatus(itemID, status string) error {
+	_, err := b.db.Model((*model.BankAccount)(nil)).
+		Set("item_status = ?", status).
+		Set("updated_at = now()").
+		Where("item_id = ? and deleted_at is null", itemID).
+		Update()
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// UpdateStatus updates the status of a bank account
+func (b *BankAccountRepo) UpdateStatus(account *model.BankAccount) error {
+	_, err := b.db.Model(account).Column("status", "updated_at").WherePK().Update()
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// UpdateVerification updates the verification and status of a manually entered bank account
+func (b *BankAccountRepo) UpdateVerification(account *model.BankAccount) error {
+	_, err := b.db.Model(account).Column("verification", "status", "updated_at").WherePK().Update()
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// Delete sets deleted_at for a bank account
+func (b *BankAccountRepo) Delete(account *model.BankAccount) error {
+	account.Delete()
+	_, err := b.db.Model(account).Column("deleted_at").WherePK().Update()
+	if err != nil {
+		b.log.Warn("BankAccountRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
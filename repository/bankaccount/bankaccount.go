@@ -0,0 +1,223 @@
+package bankaccount
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/microdeposit"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"go.uber.org/zap"
+)
+
+// MaxVerifyAttempts is how many times a user may confirm the micro-deposit amounts before the bank account is rejected
+const MaxVerifyAttempts = 3
+
+// NewBankAccountService creates new bank account service
+func NewBankAccountService(bankAccountRepo model.BankAccountRepo, microDepositRepo model.MicroDepositRepo, microDeposits microdeposit.Service, broker broker.Service, secondFactor SecondFactor, log *zap.Logger) *Service {
+	return &Service{bankAccountRepo, microDepositRepo, microDeposits, broker, secondFactor, log}
+}
+
+// Service represents the bank account application service for manually entered bank accounts
+type Service struct {
+	bankAccountRepo  model.BankAccountRepo
+	microDepositRepo model.MicroDepositRepo
+	microDeposits    microdeposit.Service
+	broker           broker.Service
+	secondFactor     SecondFactor
+	log              *zap.Logger
+}
+
+// SecondFactor checks users with two-factor authentication confirmed it recently
+type SecondFactor interface {
+	RequireFresh(userID int) error
+}
+
+var (
+	errNoAccount          = apperr.New(http.StatusBadRequest, "Account not found.")
+	errInvalidRouting     = apperr.New(http.StatusBadRequest, "Routing number isn't valid.")
+	errNotPending         = apperr.New(http.StatusBadRequest, "Bank account doesn't need verification.")
+	errTooManyAttempts    = apperr.New(http.StatusForbidden, "Too many attempts. Please enter your bank account again.")
+	errMicroDepositFailed = apperr.New(http.StatusBadGateway, "Couldn't send the verification deposits. Try again later.")
+)
+
+// ValidRoutingNumber checks the length and checksum of an ABA routing number
+func ValidRoutingNumber(routing string) bool {
+	if len(routing) != 9 {
+		return false
+	}
+	weights := [3]int{3, 7, 1}
+	sum := 0
+	for i, r := range routing {
+		if r < '0' || r > '9' {
+			return false
+		}
+		sum += int(r-'0') * weights[i%3]
+	}
+	return sum%10 == 0
+}
+
+// AddManual attaches a bank account entered by hand as an ACH relationship and sends two micro-deposits to it.
+// The account can't be used for transfers until the user confirms their amounts with Verify.
+// Users with two-factor authentication must have confirmed their second factor recently.
+func (s *Service) AddManual(u *model.User, r *request.ManualBankAccount) (*model.BankAccount, error) {
+	if u.AccountID == "" {
+		return nil, errNoAccount
+	}
+	if err := s.secondFactor.RequireFresh(u.ID); err != nil {
+		return nil, err
+	}
+	if !ValidRoutingNumber(r.RoutingNumber) {
+		return nil, errInvalidRouting
+	}
+	owner := r.AccountOwnerName
+	if owner == "" {
+		owner = strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+
+	relationship, err := s.broker.CreateACHRelationship(u.AccountID, &broker.ACHRelationshipRequest{
+		AccountOwnerName:  owner,
+		BankAccountType:   r.BankAccountType,
+		BankAccountNumber: r.AccountNumber,
+		BankRoutingNumber: r.RoutingNumber,
+		Nickname:          r.Nickname,
+	})
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.bankAccountRepo.Create(&model.BankAccount{
+		UserID:         u.ID,
+		AccountID:      u.AccountID,
+		RelationshipID: relationship.ID,
+		AccountName:    r.Nickname,
+		Mask:           r.AccountNumber[len(r.AccountNumber)-4:],
+		Status:         relationship.Status,
+		Verification:   model.VerificationPending,
+		NumbersHash:    model.HashBankNumbers(r.RoutingNumber, r.AccountNumber),
+	})
+	if err != nil {
+		s.deleteRelationship(u.AccountID, relationship.ID)
+		return nil, err
+	}
+
+	amounts, err := randomAmounts()
+	if err == nil {
+		var reference string
+		if reference, err = s.microDeposits.Send(r.RoutingNumber, r.AccountNumber, r.BankAccountType, amounts); err == nil {
+			_, err = s.microDepositRepo.Create(&model.MicroDeposit{BankAccountID: account.ID, Reference: reference, Amounts: amounts})
+		}
+	}
+	if err != nil {
+		// without micro-deposits the account can never be verified, so don't keep it
+		s.log.Error("BankAccountService Error", zap.Int("bank_account_id", account.ID), zap.Error(err))
+		s.deleteRelationship(u.AccountID, relationship.ID)
+		if err := s.bankAccountRepo.Delete(account); err != nil {
+			s.log.Error("BankAccountService Error", zap.Int("bank_account_id", account.ID), zap.Error(err))
+		}
+		return nil, errMicroDepositFailed
+	}
+	return account, nil
+}
+
+// Verify activates a manually entered bank account when amounts match its micro-deposits. After
+// MaxVerifyAttempts wrong guesses the relationship is removed and the account must be entered again.
+func (s *Service) Verify(u *model.User, relationshipID string, amounts []float64) (*model.BankAccount, error) {
+	account, err := s.bankAccountRepo.FindByRelationship(u.ID, relationshipID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Verification == model.VerificationFailed {
+		return nil, errTooManyAttempts
+	}
+	if account.Verification != model.VerificationPending {
+		return nil, errNotPending
+	}
+	deposit, err := s.microDepositRepo.FindByBankAccount(account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the right amounts are refused too once the guesses are used up, they may have been brute forced
+	if deposit.Attempts >= MaxVerifyAttempts {
+		return nil, errTooManyAttempts
+	}
+
+	if matches(deposit.Amounts, amounts) {
+		verified := time.Now()
+		deposit.VerifiedAt = &verified
+		if err := s.microDepositRepo.Update(deposit); err != nil {
+			return nil, err
+		}
+		account.Verification = model.VerificationVerified
+		if err := s.bankAccountRepo.UpdateVerification(account); err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+
+	// concurrent guesses are counted by the database, only the one using up the last attempt removes the bank
+	if err := s.microDepositRepo.AddAttempt(deposit, MaxVerifyAttempts); err != nil {
+		return nil, err
+	}
+	if left := MaxVerifyAttempts - deposit.Attempts; left > 0 {
+		return nil, apperr.New(http.StatusBadRequest, fmt.Sprintf("The amounts don't match. %d attempts left.", left))
+	}
+	s.deleteRelationship(account.AccountID, account.RelationshipID)
+	account.Verification = model.VerificationFailed
+	account.Status = model.BankAccountCanceled
+	if err := s.bankAccountRepo.UpdateVerification(account); err != nil {
+		return nil, err
+	}
+	return nil, errTooManyAttempts
+}
+
+// deleteRelationship removes an ACH relationship we can't use, failures are only logged
+func (s *Service) deleteRelationship(accountID, relationshipID string) {
+	if err := s.broker.DeleteACHRelationship(accountID, relationshipID); err != nil {
+		s.log.Error("BankAccountService Error", zap.String("relationship_id", relationshipID), zap.Error(err))
+	}
+}
+
+// randomAmounts returns two different amounts between 1 and 99 cents
+func randomAmounts() ([]int, error) {
+	amounts := make([]int, 0, 2)
+	for len(amounts) < 2 {
+		n, err := rand.Int(rand.Reader, big.NewInt(99))
+		if err != nil {
+			return nil, err
+		}
+		if amount := int(n.Int64()) + 1; len(amounts) == 0 || amounts[0] != amount {
+			amounts = append(amounts, amount)
+		}
+	}
+	return amounts, nil
+}
+
+// matches compares the amounts in dollars given by the user to the cents sent, in any order
+func matches(sent []int, amounts []float64) bool {
+	if len(sent) != len(amounts) {
+		return false
+	}
+	given := make([]int, len(amounts))
+	for i, a := range amounts {
+		given[i] = int(math.Round(a * 100))
+	}
+	want := append([]int(nil), sent...)
+	sort.Ints(want)
+	sort.Ints(given)
+	for i := range want {
+		if want[i] != given[i] {
+			return false
+		}
+	}
+	return true
+}
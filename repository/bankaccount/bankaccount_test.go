@@ -0,0 +1,156 @@
+package bankaccount_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/microdeposit"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/bankaccount"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValidRoutingNumber(t *testing.T) {
+	cases := map[string]bool{
+		"021000021":  true,
+		"011401533":  true,
+		"091000019":  true,
+		"021000022":  false,
+		"12345678":   false,
+		"0210000210": false,
+		"02100002a":  false,
+	}
+	for routing, valid := range cases {
+		assert.Equal(t, valid, bankaccount.ValidRoutingNumber(routing), routing)
+	}
+}
+
+func TestManualBankAccount(t *testing.T) {
+	var account *model.BankAccount
+	var deposit *model.MicroDeposit
+	deleted := ""
+	bankAccounts := &mockdb.BankAccount{
+		CreateFn: func(a *model.BankAccount) (*model.BankAccount, error) {
+			a.ID = 1
+			account = a
+			return a, nil
+		},
+		FindByRelationshipFn: func(int, string) (*model.BankAccount, error) {
+			return account, nil
+		},
+		UpdateVerificationFn: func(*model.BankAccount) error { return nil },
+	}
+	microDeposits := &mockdb.MicroDeposit{
+		CreateFn: func(d *model.MicroDeposit) (*model.MicroDeposit, error) {
+			deposit = d
+			return d, nil
+		},
+		FindByBankAccountFn: func(int) (*model.MicroDeposit, error) {
+			return deposit, nil
+		},
+		UpdateFn: func(*model.MicroDeposit) error { return nil },
+		AddAttemptFn: func(d *model.MicroDeposit, maxAttempts int) error {
+			d.Attempts++
+			return nil
+		},
+	}
+	b := &mock.Broker{
+		CreateACHRelationshipFn: func(accountID string, r *broker.ACHRelationshipRequest) (*broker.ACHRelationship, error) {
+			return &broker.ACHRelationship{ID: "rel-1", AccountID: accountID, Status: model.BankAccountQueued}, nil
+		},
+		DeleteACHRelationshipFn: func(accountID, relationshipID string) error {
+			deleted = relationshipID
+			return nil
+		},
+	}
+	fake := microdeposit.NewFake(zap.NewNop())
+	fresh := &mock.SecondFactor{RequireFreshFn: func(int) error { return nil }}
+	s := bankaccount.NewBankAccountService(bankAccounts, microDeposits, fake, b, fresh, zap.NewNop())
+	u := &model.User{ID: 1, AccountID: "acc-1", FirstName: "Jane", LastName: "Doe"}
+
+	_, err := s.AddManual(u, &request.ManualBankAccount{RoutingNumber: "021000022", AccountNumber: "123456789", BankAccountType: "CHECKING"})
+	assertStatus(t, http.StatusBadRequest, err)
+
+	created, err := s.AddManual(u, &request.ManualBankAccount{RoutingNumber: "021000021", AccountNumber: "123456789", BankAccountType: "CHECKING"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, model.VerificationPending, created.Verification)
+	assert.Equal(t, "6789", created.Mask)
+	assert.False(t, created.Usable())
+	sent := fake.Sent(deposit.Reference)
+	assert.Len(t, sent, 2)
+	assert.Equal(t, sent, deposit.Amounts)
+
+	// wrong amounts use up an attempt
+	_, err = s.Verify(u, "rel-1", []float64{0, 0})
+	assertStatus(t, http.StatusBadRequest, err)
+	assert.Equal(t, 1, deposit.Attempts)
+
+	// the amounts may be confirmed in any order
+	verified, err := s.Verify(u, "rel-1", []float64{float64(sent[1]) / 100, float64(sent[0]) / 100})
+	assert.NoError(t, err)
+	assert.True(t, verified.Usable())
+	assert.NotNil(t, deposit.VerifiedAt)
+	assert.Empty(t, deleted)
+
+	_, err = s.Verify(u, "rel-1", []float64{0.01, 0.02})
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+func TestVerifyAttemptsLimit(t *testing.T) {
+	account := &model.BankAccount{ID: 1, UserID: 1, AccountID: "acc-1", RelationshipID: "rel-1", Status: model.BankAccountApproved, Verification: model.VerificationPending}
+	deposit := &model.MicroDeposit{BankAccountID: 1, Amounts: []int{12, 34}}
+	deleted := ""
+	bankAccounts := &mockdb.BankAccount{
+		FindByRelationshipFn: func(int, string) (*model.BankAccount, error) { return account, nil },
+		UpdateVerificationFn: func(*model.BankAccount) error { return nil },
+	}
+	microDeposits := &mockdb.MicroDeposit{
+		FindByBankAccountFn: func(int) (*model.MicroDeposit, error) { return deposit, nil },
+		UpdateFn:            func(*model.MicroDeposit) error { return nil },
+		AddAttemptFn: func(d *model.MicroDeposit, maxAttempts int) error {
+			if d.Attempts >= maxAttempts {
+				return apperr.New(http.StatusForbidden, "Too many attempts. Please enter your bank account again.")
+			}
+			d.Attempts++
+			return nil
+		},
+	}
+	b := &mock.Broker{
+		DeleteACHRelationshipFn: func(accountID, relationshipID string) error {
+			deleted = relationshipID
+			return nil
+		},
+	}
+	s := bankaccount.NewBankAccountService(bankAccounts, microDeposits, microdeposit.NewFake(zap.NewNop()), b, nil, zap.NewNop())
+	u := &model.User{ID: 1, AccountID: "acc-1"}
+
+	for i := 1; i < bankaccount.MaxVerifyAttempts; i++ {
+		_, err := s.Verify(u, "rel-1", []float64{0.34, 0.13})
+		assertStatus(t, http.StatusBadRequest, err)
+	}
+	_, err := s.Verify(u, "rel-1", []float64{0.34, 0.13})
+	assertStatus(t, http.StatusForbidden, err)
+	assert.Equal(t, model.VerificationFailed, account.Verification)
+	assert.Equal(t, model.BankAccountCanceled, account.Status)
+	assert.Equal(t, "rel-1", deleted)
+
+	// the right amounts don't help once the attempts are used up
+	_, err = s.Verify(u, "rel-1", []float64{0.12, 0.34})
+	assertStatus(t, http.StatusForbidden, err)
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, status, err.(*apperr.APPError).Status)
+	}
+}
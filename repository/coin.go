@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewCoinRepo returns a new CoinRepo instance, built on a transaction its entries are part of it
+func NewCoinRepo(db orm.DB, log *zap.Logger) *CoinRepo {
+	return &CoinRepo{db, log}
+}
+
+// CoinRepo is the client for the append-only coins ledger
+type CoinRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+var errInsufficientCoins = apperr.New(http.StatusBadRequest, "Not enough coins.")
+
+// Append adds an entry to a user's ledger. The user is locked while the entry is checked against their
+// balance, which can't go below zero. An entry with a reason already in the ledger isn't added again,
+// the existing entry is returned instead
+func (c *CoinRepo) Append(entry *model.CoinStatement) (*model.CoinStatement, error) {
+	err := inTx(c.db, func(db orm.DB) error {
+		if _, err := db.Exec(`SELECT id FROM users WHERE id = ? FOR UPDATE`, entry.UserID); err != nil {
+			return err
+		}
+		existing := new(model.CoinStatement)
+		res, err := db.Query(existing, `SELECT * FROM coin_statements WHERE (user_id = ? and reason = ?) LIMIT 1`, entry.UserID, entry.Reason)
+		if err != nil {
+			return err
+		}
+		if res.RowsReturned() > 0 {
+			*entry = *existing
+			return nil
+		}
+		if entry.Coins < 0 {
+			balance, err := balance(db, entry.UserID)
+			if err != nil {
+				return err
+			}
+			if balance+entry.Coins < 0 {
+				return errInsufficientCoins
+			}
+		}
+		return db.Insert(entry)
+	})
+	if err == errInsufficientCoins {
+		return nil, err
+	}
+	if err != nil {
+		c.log.Warn("CoinRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return entry, nil
+}
+
+// Balance returns the sum of a user's ledger entries
+func (c *CoinRepo) Balance(userID int) (int, error) {
+	b, err := balance(c.db, userID)
+	if err != nil {
+		c.log.Warn("CoinRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return b, nil
+}
+
+func balance(db orm.DB, userID int) (int, error) {
+	var b int
+	_, err := db.QueryOne(pg.Scan(&b), `SELECT COALESCE(SUM(coins), 0) FROM coin_statements WHERE user_id = ?`, userID)
+	return b, err
+}
+
+// History returns a page of a user's ledger entries, newest first
+func (c *CoinRepo) History(userID int, p *model.Pagination) ([]model.CoinStatement, error) {
+	var entries []model.CoinStatement
+	err := c.db.Model(&entries).Where("user_id = ?", userID).Order("id desc").Limit(p.Limit).Offset(p.Offset).Select()
+	if err != nil {
+		c.log.Warn("CoinRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return entries, nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewCoinRedemptionRepo returns a new CoinRedemptionRepo instance
+func NewCoinRedemptionRepo(db orm.DB, log *zap.Logger) *CoinRedemptionRepo {
+	return &CoinRedemptionRepo{db, log}
+}
+
+// CoinRedemptionRepo is the client for coin redemptions
+type CoinRedemptionRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// Create records a new coin redemption
+func (c *CoinRedemptionRepo) Create(r *model.CoinRedemption) (*model.CoinRedemption, error) {
+	if err := c.db.Insert(r); err != nil {
+		c.log.Warn("CoinRedemptionRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return r, nil
+}
+
+// Update saves the progress of a coin redemption
+func (c *CoinRedemptionRepo) Update(r *model.CoinRedemption) error {
+	if err := c.db.Update(r); err != nil {
+		c.log.Warn("CoinRedemptionRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
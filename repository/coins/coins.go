@@ -0,0 +1,86 @@
+package coins
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// Ledger opens the coins ledger on db, on a transaction the entries it appends are part of that transaction
+type Ledger func(db orm.DB) model.CoinRepo
+
+// NewCoinService creates new coins service
+func NewCoinService(ledger Ledger, redemptionRepo model.CoinRedemptionRepo, broker broker.Service, config *config.CoinsConfig, db orm.DB, log *zap.Logger) *Service {
+	return &Service{ledger, redemptionRepo, broker, config, db, log}
+}
+
+// Service represents the coins ledger application service
+type Service struct {
+	ledger         Ledger
+	redemptionRepo model.CoinRedemptionRepo
+	broker         broker.Service
+	config         *config.CoinsConfig
+	db             orm.DB
+	log            *zap.Logger
+}
+
+// Statement is a user's coin balance with a page of their ledger
+type Statement struct {
+	Balance int                   `json:"balance"`
+	History []model.CoinStatement `json:"history"`
+}
+
+// Award credits coins to a user on db, pass the transaction of the action earning them so that both
+// commit or roll back together. Awarding again for the same reason is a no-op returning the first entry
+func (s *Service) Award(db orm.DB, userID, coins int, reason string) (*model.CoinStatement, error) {
+	return s.Record(db, &model.CoinStatement{UserID: userID, Coins: coins, Type: model.CoinEarn, Reason: reason})
+}
+
+// Record appends an entry to a user's ledger on db. Earned coins must be positive, spent and expired
+// ones negative, and every entry needs a reason
+func (s *Service) Record(db orm.DB, entry *model.CoinStatement) (*model.CoinStatement, error) {
+	if entry.Reason == "" {
+		return nil, apperr.New(http.StatusBadRequest, "A reason is required.")
+	}
+	valid := false
+	switch entry.Type {
+	case model.CoinEarn:
+		valid = entry.Coins > 0
+	case model.CoinSpend, model.CoinExpire:
+		valid = entry.Coins < 0
+	case model.CoinAdjust:
+		valid = entry.Coins != 0
+	}
+	if !valid {
+		return nil, apperr.New(http.StatusBadRequest, "Invalid coin entry.")
+	}
+	return s.ledger(db).Append(entry)
+}
+
+// Balance returns the coin balance of a user
+func (s *Service) Balance(u *model.User) (int, error) {
+	return s.ledger(s.db).Balance(u.ID)
+}
+
+// Statement returns the coin balance of a user and a page of their ledger, newest first
+func (s *Service) Statement(u *model.User, p *model.Pagination) (*Statement, error) {
+	ledger := s.ledger(s.db)
+	balance, err := ledger.Balance(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := ledger.History(u.ID, p)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []model.CoinStatement{}
+	}
+	return &Statement{balance, history}, nil
+}
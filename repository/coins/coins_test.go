@@ -0,0 +1,92 @@
+package coins_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/coins"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRecord(t *testing.T) {
+	cases := []struct {
+		name   string
+		entry  *model.CoinStatement
+		status int
+	}{
+		{name: "no reason", entry: &model.CoinStatement{UserID: 1, Coins: 10, Type: model.CoinEarn}, status: http.StatusBadRequest},
+		{name: "negative earn", entry: &model.CoinStatement{UserID: 1, Coins: -10, Type: model.CoinEarn, Reason: "r"}, status: http.StatusBadRequest},
+		{name: "positive spend", entry: &model.CoinStatement{UserID: 1, Coins: 10, Type: model.CoinSpend, Reason: "r"}, status: http.StatusBadRequest},
+		{name: "empty adjustment", entry: &model.CoinStatement{UserID: 1, Type: model.CoinAdjust, Reason: "r"}, status: http.StatusBadRequest},
+		{name: "unknown type", entry: &model.CoinStatement{UserID: 1, Coins: 10, Type: "gift", Reason: "r"}, status: http.StatusBadRequest},
+		{name: "spend", entry: &model.CoinStatement{UserID: 1, Coins: -10, Type: model.CoinSpend, Reason: "redeem:1"}},
+		{name: "expire", entry: &model.CoinStatement{UserID: 1, Coins: -5, Type: model.CoinExpire, Reason: "expire:2021"}},
+		{name: "negative adjustment", entry: &model.CoinStatement{UserID: 1, Coins: -5, Type: model.CoinAdjust, Reason: "support:42"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var appended []*model.CoinStatement
+			ledger := &mockdb.Coin{
+				AppendFn: func(e *model.CoinStatement) (*model.CoinStatement, error) {
+					appended = append(appended, e)
+					return e, nil
+				},
+			}
+			s := coins.NewCoinService(func(orm.DB) model.CoinRepo { return ledger }, nil, nil, nil, nil, zap.NewNop())
+
+			_, err := s.Record(nil, tt.entry)
+			if tt.status != 0 {
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+				assert.Empty(t, appended)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []*model.CoinStatement{tt.entry}, appended)
+		})
+	}
+}
+
+func TestAwardJoinsTransaction(t *testing.T) {
+	tx := &pg.Tx{}
+	var on orm.DB
+	ledger := &mockdb.Coin{
+		AppendFn: func(e *model.CoinStatement) (*model.CoinStatement, error) {
+			return e, nil
+		},
+	}
+	s := coins.NewCoinService(func(db orm.DB) model.CoinRepo {
+		on = db
+		return ledger
+	}, nil, nil, nil, nil, zap.NewNop())
+
+	entry, err := s.Award(tx, 1, 50, "referral:2")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.CoinStatement{UserID: 1, Coins: 50, Type: model.CoinEarn, Reason: "referral:2"}, entry)
+	assert.Same(t, tx, on)
+}
+
+func TestStatement(t *testing.T) {
+	ledger := &mockdb.Coin{
+		BalanceFn: func(userID int) (int, error) {
+			return 40, nil
+		},
+		HistoryFn: func(userID int, p *model.Pagination) ([]model.CoinStatement, error) {
+			assert.Equal(t, 10, p.Limit)
+			return nil, nil
+		},
+	}
+	s := coins.NewCoinService(func(orm.DB) model.CoinRepo { return ledger }, nil, nil, nil, nil, zap.NewNop())
+
+	statement, err := s.Statement(&model.User{ID: 1}, &model.Pagination{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, &coins.Statement{Balance: 40, History: []model.CoinStatement{}}, statement)
+}
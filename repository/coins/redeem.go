@@ -0,0 +1,145 @@
+package coins
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"go.uber.org/zap"
+)
+
+var errReversed = apperr.New(http.StatusBadGateway, "Your coins couldn't be redeemed and were returned to your balance.")
+
+// Value returns the dollar value of coins at the configured rate, rounded down to the cent
+func (s *Service) Value(coins int) float64 {
+	return math.Floor(float64(coins)*s.config.USDRate*100+1e-9) / 100
+}
+
+// Redeem converts coins to cash and buys the asset with symbol for the user. It runs as a saga: the coins
+// are debited from the ledger, their value is journaled from the firm account to the user's account and a
+// notional market buy is placed. When the journal or the order fails the steps already taken are undone
+func (s *Service) Redeem(u *model.User, coins int, symbol string) (*model.CoinRedemption, error) {
+	if u.AccountID == "" {
+		return nil, apperr.New(http.StatusBadRequest, "Account not found.")
+	}
+	if s.config.FirmAccountID == "" {
+		return nil, apperr.New(http.StatusServiceUnavailable, "Coins can't be redeemed at the moment.")
+	}
+	amount := s.Value(coins)
+	if amount < s.config.MinRedemption {
+		return nil, apperr.New(http.StatusBadRequest, fmt.Sprintf("Redeem at least $%.2f worth of coins.", s.config.MinRedemption))
+	}
+	asset, err := s.broker.GetAsset(strings.ToUpper(symbol))
+	if err != nil {
+		return nil, err
+	}
+	if !asset.Tradable || !asset.Fractionable {
+		return nil, apperr.New(http.StatusBadRequest, "Coins can only be redeemed for fractionable assets.")
+	}
+
+	r, err := s.redemptionRepo.Create(&model.CoinRedemption{
+		UserID: u.ID,
+		Coins:  coins,
+		Amount: amount,
+		Symbol: asset.Symbol,
+		Status: model.RedemptionPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.Record(s.db, &model.CoinStatement{UserID: u.ID, Coins: -coins, Type: model.CoinSpend, Reason: reason(r)})
+	if err != nil {
+		r.Status = model.RedemptionRejected
+		r.Error = err.Error()
+		s.save(r)
+		return nil, err
+	}
+
+	journal, err := s.journal(s.config.FirmAccountID, u.AccountID, amount)
+	if err != nil {
+		return nil, s.compensate(u, r, err)
+	}
+	r.JournalID = journal.ID
+	s.save(r)
+
+	order, err := s.broker.CreateOrder(u.AccountID, &broker.OrderRequest{
+		Symbol:        asset.Symbol,
+		Notional:      strconv.FormatFloat(amount, 'f', 2, 64),
+		Side:          "buy",
+		Type:          "market",
+		TimeInForce:   "day",
+		ClientOrderID: reason(r),
+	})
+	if err != nil {
+		return nil, s.compensate(u, r, err)
+	}
+	r.OrderID = order.ID
+	r.Status = model.RedemptionComplete
+	s.save(r)
+	return r, nil
+}
+
+// compensate undoes the steps of a redemption which failed with cause: the journaled cash goes back to
+// the firm account and the coins back to the user. A step which can't be undone leaves it for review
+func (s *Service) compensate(u *model.User, r *model.CoinRedemption, cause error) error {
+	s.log.Warn("CoinService Error", zap.Int("redemption_id", r.ID), zap.Error(cause))
+	r.Error = cause.Error()
+	r.Status = model.RedemptionReversed
+	if r.JournalID != "" {
+		if _, err := s.journal(u.AccountID, s.config.FirmAccountID, r.Amount); err != nil {
+			return s.review(r, "reversing journal", err)
+		}
+	}
+	refund := &model.CoinStatement{UserID: u.ID, Coins: r.Coins, Type: model.CoinAdjust, Reason: reason(r) + ":refund"}
+	if _, err := s.Record(s.db, refund); err != nil {
+		return s.review(r, "refunding coins", err)
+	}
+	s.save(r)
+	return errReversed
+}
+
+// review flags a redemption which couldn't be undone for support to resolve
+func (s *Service) review(r *model.CoinRedemption, step string, err error) error {
+	s.log.Error("Coin redemption needs review", zap.Int("redemption_id", r.ID), zap.String("step", step), zap.Error(err))
+	r.Status = model.RedemptionNeedsReview
+	r.Error += "; " + step + ": " + err.Error()
+	s.save(r)
+	return apperr.New(http.StatusBadGateway, "Your coins couldn't be redeemed, our support team will get in touch.")
+}
+
+// journal moves amount of cash between two accounts, a journal the broker refused counts as failed
+func (s *Service) journal(from, to string, amount float64) (*broker.Journal, error) {
+	journal, err := s.broker.CreateJournal(&broker.JournalRequest{
+		FromAccount: from,
+		ToAccount:   to,
+		EntryType:   broker.JournalCash,
+		Amount:      strconv.FormatFloat(amount, 'f', 2, 64),
+		Description: "Coin redemption",
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(journal.Status) {
+	case "rejected", "canceled", "refused":
+		return nil, fmt.Errorf("journal %s was %s", journal.ID, strings.ToLower(journal.Status))
+	}
+	return journal, nil
+}
+
+// save records the progress of a redemption, the repo logs a failure and the saga carries on regardless
+// since the broker holds the state that matters
+func (s *Service) save(r *model.CoinRedemption) {
+	s.redemptionRepo.Update(r)
+}
+
+// reason is the ledger reason of a redemption's debit, it doubles as the client order id of its buy
+func reason(r *model.CoinRedemption) string {
+	return "redeem-" + strconv.Itoa(r.ID)
+}
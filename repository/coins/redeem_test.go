@@ -0,0 +1,168 @@
+package coins_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/coins"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRedeem(t *testing.T) {
+	cases := []struct {
+		name       string
+		coins      int
+		symbol     string
+		balance    int
+		orderErr   error
+		reverseErr error
+		status     int
+		outcome    string
+		ledger     []int
+		journals   []string
+		orders     []string
+	}{
+		{
+			name:   "below the minimum",
+			coins:  99,
+			symbol: "AAPL",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "asset isn't fractionable",
+			coins:  250,
+			symbol: "BRK.A",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "not enough coins",
+			coins:   250,
+			symbol:  "aapl",
+			balance: 100,
+			status:  http.StatusBadRequest,
+			outcome: model.RedemptionRejected,
+		},
+		{
+			name:     "buys the asset",
+			coins:    250,
+			symbol:   "aapl",
+			balance:  1000,
+			outcome:  model.RedemptionComplete,
+			ledger:   []int{-250},
+			journals: []string{"firm>acc-1:2.50"},
+			orders:   []string{"AAPL:2.50:redeem-7"},
+		},
+		{
+			name:     "order fails",
+			coins:    250,
+			symbol:   "AAPL",
+			balance:  1000,
+			orderErr: errors.New("market closed"),
+			status:   http.StatusBadGateway,
+			outcome:  model.RedemptionReversed,
+			ledger:   []int{-250, 250},
+			journals: []string{"firm>acc-1:2.50", "acc-1>firm:2.50"},
+			orders:   []string{"AAPL:2.50:redeem-7"},
+		},
+		{
+			name:       "reversing the journal fails",
+			coins:      250,
+			symbol:     "AAPL",
+			balance:    1000,
+			orderErr:   errors.New("market closed"),
+			reverseErr: errors.New("broker unavailable"),
+			status:     http.StatusBadGateway,
+			outcome:    model.RedemptionNeedsReview,
+			ledger:     []int{-250},
+			journals:   []string{"firm>acc-1:2.50", "acc-1>firm:2.50"},
+			orders:     []string{"AAPL:2.50:redeem-7"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var ledger []int
+			var journals, orders []string
+			var saved *model.CoinRedemption
+
+			balance := tt.balance
+			coinRepo := &mockdb.Coin{
+				AppendFn: func(e *model.CoinStatement) (*model.CoinStatement, error) {
+					if balance+e.Coins < 0 {
+						return nil, apperr.New(http.StatusBadRequest, "Not enough coins.")
+					}
+					balance += e.Coins
+					ledger = append(ledger, e.Coins)
+					return e, nil
+				},
+			}
+			redemptionRepo := &mockdb.CoinRedemption{
+				CreateFn: func(r *model.CoinRedemption) (*model.CoinRedemption, error) {
+					r.ID = 7
+					saved = r
+					return r, nil
+				},
+				UpdateFn: func(r *model.CoinRedemption) error {
+					saved = r
+					return nil
+				},
+			}
+			b := &mock.Broker{
+				GetAssetFn: func(symbol string) (*broker.Asset, error) {
+					return &broker.Asset{Symbol: symbol, Tradable: true, Fractionable: symbol == "AAPL"}, nil
+				},
+				CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+					journals = append(journals, j.FromAccount+">"+j.ToAccount+":"+j.Amount)
+					if j.FromAccount == "acc-1" && tt.reverseErr != nil {
+						return nil, tt.reverseErr
+					}
+					return &broker.Journal{ID: "j-1", Status: "executed"}, nil
+				},
+				CreateOrderFn: func(accountID string, o *broker.OrderRequest) (*broker.Order, error) {
+					assert.Equal(t, "buy", o.Side)
+					orders = append(orders, o.Symbol+":"+o.Notional+":"+o.ClientOrderID)
+					if tt.orderErr != nil {
+						return nil, tt.orderErr
+					}
+					return &broker.Order{ID: "o-1", Symbol: o.Symbol}, nil
+				},
+			}
+			cfg := &config.CoinsConfig{USDRate: 0.01, FirmAccountID: "firm", MinRedemption: 1}
+			s := coins.NewCoinService(func(orm.DB) model.CoinRepo { return coinRepo }, redemptionRepo, b, cfg, nil, zap.NewNop())
+
+			r, err := s.Redeem(&model.User{ID: 1, AccountID: "acc-1"}, tt.coins, tt.symbol)
+			if tt.status != 0 {
+				assert.Nil(t, r)
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "o-1", r.OrderID)
+			}
+			if tt.outcome != "" {
+				assert.Equal(t, tt.outcome, saved.Status)
+			} else {
+				assert.Nil(t, saved)
+			}
+			assert.Equal(t, tt.ledger, ledger)
+			assert.Equal(t, tt.journals, journals)
+			assert.Equal(t, tt.orders, orders)
+		})
+	}
+}
+
+func TestValue(t *testing.T) {
+	s := coins.NewCoinService(nil, nil, nil, &config.CoinsConfig{USDRate: 0.013}, nil, zap.NewNop())
+	assert.Equal(t, 1.28, s.Value(99))
+	assert.Equal(t, 13.0, s.Value(1000))
+}
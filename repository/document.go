@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewDocumentRepo returns a new DocumentRepo instance
+func NewDocumentRepo(db orm.DB, log *zap.Logger) *DocumentRepo {
+	return &DocumentRepo{db, log}
+}
+
+// DocumentRepo is the client for our document model
+type DocumentRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// Create creates a new document in our database
+func (d *DocumentRepo) Create(doc *model.Document) (*model.Document, error) {
+	if err := d.db.Insert(doc); err != nil {
+		d.log.Warn("DocumentRepo error: ", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return doc, nil
+}
+
+// View returns single document by ID
+func (d *DocumentRepo) View(id int) (*model.Document, error) {
+	var doc = new(model.Document)
+	sql := `SELECT * FROM documents WHERE (id = ? and deleted_at is null)`
+	_, err := d.db.QueryOne(doc, sql, id)
+	if err != nil {
+		d.log.Warn("DocumentRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Document not found.")
+	}
+	return doc, nil
+}
+
+// ListByUser returns all documents uploaded by a user
+func (d *DocumentRepo) ListByUser(userID int) ([]model.Document, error) {
+	var docs []model.Document
+	err := d.db.Model(&docs).Where("user_id = ?", userID).Where(notDeleted).Order("id desc").Select()
+	if err != nil {
+		d.log.Warn("DocumentRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return docs, nil
+}
+
+// ListAll returns every stored document
+func (d *DocumentRepo) ListAll() ([]model.Document, error) {
+	var docs []model.Document
+	err := d.db.Model(&docs).Where(notDeleted).Order("id asc").Select()
+	if err != nil {
+		d.log.Warn("DocumentRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return docs, nil
+}
+
+// UpdateStatus updates the upload status of a document
+func (d *DocumentRepo) UpdateStatus(doc *model.Document) error {
+	doc.UpdatedAt = time.Now()
+	_, err := d.db.Model(doc).Column("status", "error_response", "updated_at").WherePK().Update()
+	if err != nil {
+		d.log.Warn("DocumentRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package document
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	shortuuid "github.com/lithammer/shortuuid/v3"
+	"go.uber.org/zap"
+)
+
+// NewDocumentService creates new document service
+func NewDocumentService(userRepo model.UserRepo, documentRepo model.DocumentRepo, broker broker.Service, cipher secret.Encrypter, storagePath string, log *zap.Logger) *Service {
+	// a nil *Keyring wrapped in the interface is not == nil, unwrap it so the cipher checks below fire
+	if k, ok := cipher.(*secret.Keyring); ok && k == nil {
+		cipher = nil
+	}
+	return &Service{userRepo, documentRepo, broker, cipher, storagePath, log}
+}
+
+// Service represents the document application service
+type Service struct {
+	userRepo     model.UserRepo
+	documentRepo model.DocumentRepo
+	broker       broker.Service
+	cipher       secret.Encrypter
+	storagePath  string
+	log          *zap.Logger
+}
+
+// Upload stores an encrypted copy of the document and forwards it to the broker
+func (s *Service) Upload(userID int, doc *model.Document, content []byte) (*model.Document, error) {
+	if s.cipher == nil {
+		return nil, apperr.New(http.StatusInternalServerError, "Document storage is not configured.")
+	}
+	u, err := s.userRepo.View(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.AccountID == "" {
+		return nil, apperr.New(http.StatusBadRequest, "Account not found.")
+	}
+
+	encrypted, err := s.cipher.Encrypt(content)
+	if err != nil {
+		s.log.Error("DocumentService Error", zap.Error(err))
+		return nil, apperr.Generic
+	}
+	if err := os.MkdirAll(s.storagePath, 0700); err != nil {
+		s.log.Error("DocumentService Error", zap.Error(err))
+		return nil, apperr.Generic
+	}
+	path := filepath.Join(s.storagePath, strconv.Itoa(userID)+"-"+shortuuid.New()+".enc")
+	if err := ioutil.WriteFile(path, encrypted, 0600); err != nil {
+		s.log.Error("DocumentService Error", zap.Error(err))
+		return nil, apperr.Generic
+	}
+
+	doc.UserID = userID
+	doc.Path = path
+	doc.Size = int64(len(content))
+	doc.Status = model.DocumentPending
+	if _, err := s.documentRepo.Create(doc); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	s.forward(u.AccountID, doc, content)
+	return doc, nil
+}
+
+// List returns the documents uploaded by a user
+func (s *Service) List(userID int) ([]model.Document, error) {
+	return s.documentRepo.ListByUser(userID)
+}
+
+// View returns a single document owned by the user
+func (s *Service) View(userID, id int) (*model.Document, error) {
+	doc, err := s.documentRepo.View(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc.UserID != userID {
+		return nil, apperr.New(http.StatusNotFound, "Document not found.")
+	}
+	return doc, nil
+}
+
+// Retry forwards a document that previously failed to reach the broker
+func (s *Service) Retry(userID, id int) (*model.Document, error) {
+	doc, err := s.View(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Status == model.DocumentUploaded {
+		return nil, apperr.New(http.StatusBadRequest, "Document was already uploaded.")
+	}
+	if s.cipher == nil {
+		return nil, apperr.New(http.StatusInternalServerError, "Document storage is not configured.")
+	}
+	u, err := s.userRepo.View(userID)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := ioutil.ReadFile(doc.Path)
+	if err != nil {
+		s.log.Error("DocumentService Error", zap.Error(err))
+		return nil, apperr.Generic
+	}
+	content, err := s.cipher.Decrypt(encrypted)
+	if err != nil {
+		s.log.Error("DocumentService Error", zap.Error(err))
+		return nil, apperr.Generic
+	}
+	s.forward(u.AccountID, doc, content)
+	return doc, nil
+}
+
+// Reencrypt rewrites every stored document not yet under the current encryption key and returns the number rewritten
+func (s *Service) Reencrypt() (int, error) {
+	if s.cipher == nil {
+		return 0, apperr.New(http.StatusInternalServerError, "Document storage is not configured.")
+	}
+	docs, err := s.documentRepo.ListAll()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, doc := range docs {
+		encrypted, err := ioutil.ReadFile(doc.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if secret.EnvelopeKeyID(encrypted) == s.cipher.PrimaryID() {
+			continue
+		}
+		content, err := s.cipher.Decrypt(encrypted)
+		if err != nil {
+			return n, err
+		}
+		if encrypted, err = s.cipher.Encrypt(content); err != nil {
+			return n, err
+		}
+		// write next to the original and rename so a failure never leaves a partial file behind
+		tmp := doc.Path + ".tmp"
+		if err := ioutil.WriteFile(tmp, encrypted, 0600); err != nil {
+			return n, err
+		}
+		if err := os.Rename(tmp, doc.Path); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// forward sends the document to the broker and records the outcome
+func (s *Service) forward(accountID string, doc *model.Document, content []byte) {
+	err := s.broker.UploadDocuments(accountID, []broker.Document{{
+		DocumentType:    doc.DocumentType,
+		DocumentSubType: doc.DocumentSubType,
+		Content:         base64.StdEncoding.EncodeToString(content),
+		MimeType:        doc.MimeType,
+	}})
+	if err != nil {
+		doc.Status = model.DocumentFailed
+		doc.ErrorResponse = err.Error()
+	} else {
+		doc.Status = model.DocumentUploaded
+		doc.ErrorResponse = ""
+	}
+	if err := s.documentRepo.UpdateStatus(doc); err != nil {
+		s.log.Error("DocumentService Error", zap.Int("document_id", doc.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,155 @@
+package document_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/document"
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newKeyring(t *testing.T) *secret.Keyring {
+	b, err := secret.GenerateRandomBytes(32)
+	assert.Nil(t, err)
+	k, err := secret.ParseKeyring("k1:"+base64.StdEncoding.EncodeToString(b), "", "")
+	assert.Nil(t, err)
+	return k
+}
+
+func TestUploadAndRetry(t *testing.T) {
+	var stored *model.Document
+	docs := &mockdb.Document{
+		CreateFn: func(d *model.Document) (*model.Document, error) {
+			d.ID = 1
+			stored = d
+			return d, nil
+		},
+		ViewFn: func(int) (*model.Document, error) {
+			return stored, nil
+		},
+		UpdateStatusFn: func(*model.Document) error { return nil },
+	}
+	users := &mockdb.User{
+		ViewFn: func(id int) (*model.User, error) {
+			return &model.User{ID: id, AccountID: "acc-1"}, nil
+		},
+	}
+	var sent []broker.Document
+	fail := true
+	b := &mock.Broker{
+		UploadDocumentsFn: func(accountID string, d []broker.Document) error {
+			sent = d
+			if fail {
+				return errors.New("broker unavailable")
+			}
+			return nil
+		},
+	}
+	s := document.NewDocumentService(users, docs, b, newKeyring(t), t.TempDir(), zap.NewNop())
+	content := []byte("%PDF-1.4 passport scan")
+
+	doc, err := s.Upload(1, &model.Document{DocumentType: "identity_verification", FileName: "id.pdf", MimeType: "application/pdf"}, content)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, model.DocumentFailed, doc.Status)
+	assert.Equal(t, "broker unavailable", doc.ErrorResponse)
+	assert.Equal(t, int64(len(content)), doc.Size)
+	onDisk, err := ioutil.ReadFile(doc.Path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(onDisk), "passport")
+
+	// the retry decrypts the stored copy and sends the original bytes
+	fail = false
+	doc, err = s.Retry(1, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, model.DocumentUploaded, doc.Status)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(content), sent[0].Content)
+
+	_, err = s.Retry(1, 1)
+	assertStatus(t, http.StatusBadRequest, err)
+	_, err = s.View(2, 1)
+	assertStatus(t, http.StatusNotFound, err)
+}
+
+func TestReencrypt(t *testing.T) {
+	k1, err := secret.GenerateRandomBytes(32)
+	assert.Nil(t, err)
+	k2, err := secret.GenerateRandomBytes(32)
+	assert.Nil(t, err)
+	keys := "k1:" + base64.StdEncoding.EncodeToString(k1)
+	old, err := secret.ParseKeyring(keys, "", "")
+	assert.Nil(t, err)
+	rotated, err := secret.ParseKeyring(keys+",k2:"+base64.StdEncoding.EncodeToString(k2), "", "")
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	var stored []model.Document
+	for i, path := range []string{"a.enc", "b.enc"} {
+		keyring := old
+		if i == 1 {
+			keyring = rotated
+		}
+		encrypted, err := keyring.Encrypt([]byte("scan"))
+		assert.Nil(t, err)
+		assert.Nil(t, ioutil.WriteFile(dir+"/"+path, encrypted, 0600))
+		stored = append(stored, model.Document{ID: i + 1, Path: dir + "/" + path})
+	}
+	docs := &mockdb.Document{
+		ListAllFn: func() ([]model.Document, error) { return stored, nil },
+	}
+	s := document.NewDocumentService(nil, docs, nil, rotated, dir, zap.NewNop())
+
+	// only the file still under k1 is rewritten
+	n, err := s.Reencrypt()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	for _, doc := range stored {
+		encrypted, err := ioutil.ReadFile(doc.Path)
+		assert.NoError(t, err)
+		assert.Equal(t, "k2", secret.EnvelopeKeyID(encrypted))
+	}
+	n, err = s.Reencrypt()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestUploadWithoutKeyring(t *testing.T) {
+	var keyring *secret.Keyring
+	s := document.NewDocumentService(nil, nil, nil, keyring, t.TempDir(), zap.NewNop())
+	_, err := s.Upload(1, &model.Document{}, []byte("scan"))
+	assertStatus(t, http.StatusInternalServerError, err)
+	_, err = s.Reencrypt()
+	assertStatus(t, http.StatusInternalServerError, err)
+}
+
+func TestUploadWithoutAccount(t *testing.T) {
+	users := &mockdb.User{
+		ViewFn: func(id int) (*model.User, error) {
+			return &model.User{ID: id}, nil
+		},
+	}
+	s := document.NewDocumentService(users, nil, nil, newKeyring(t), t.TempDir(), zap.NewNop())
+	_, err := s.Upload(1, &model.Document{}, []byte("scan"))
+	assertStatus(t, http.StatusBadRequest, err)
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, status, err.(*apperr.APPError).Status)
+	}
+}
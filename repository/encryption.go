@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+const rotateBatchSize = 500
+
+// NewEncryptionRepo returns a new EncryptionRepo instance
+func NewEncryptionRepo(db orm.DB, log *zap.Logger) *EncryptionRepo {
+	return &EncryptionRepo{db, log}
+}
+
+// EncryptionRepo maintains encrypted columns
+type EncryptionRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// RotateColumn re-encrypts every value of an encrypted column that isn't sealed with the primary key,
+// plaintext values are encrypted too. It returns the number of values rewritten.
+func (e *EncryptionRepo) RotateColumn(table, column string, k *secret.Keyring) (int, error) {
+	n, lastID := 0, 0
+	for {
+		var rows []struct {
+			ID    int
+			Value string
+		}
+		_, err := e.db.Query(&rows, `SELECT id, ?0 AS value FROM ?1 WHERE id > ?2 AND ?0 IS NOT NULL AND ?0 != '' ORDER BY id LIMIT ?3`,
+			pg.Ident(column), pg.Ident(table), lastID, rotateBatchSize)
+		if err != nil {
+			e.log.Warn("EncryptionRepo Error", zap.Error(err))
+			return n, err
+		}
+		for _, row := range rows {
+			lastID = row.ID
+			rotated, err := e.rotateValue(table, column, row.ID, row.Value, k)
+			if err != nil {
+				return n, err
+			}
+			if rotated {
+				n++
+			}
+		}
+		if len(rows) < rotateBatchSize {
+			return n, nil
+		}
+	}
+}
+
+// rotateAttempts bounds how often a value the app keeps changing is read again
+const rotateAttempts = 5
+
+// rotateValue re-encrypts one value with the primary key. The update only applies while the column still
+// holds the value read, a value the app wrote meanwhile is read again instead of being overwritten.
+func (e *EncryptionRepo) rotateValue(table, column string, id int, value string, k *secret.Keyring) (bool, error) {
+	for i := 0; i < rotateAttempts; i++ {
+		if value == "" || secret.StringKeyID(value) == k.PrimaryID() {
+			return false, nil
+		}
+		plaintext := value
+		if secret.IsEncryptedString(value) {
+			var err error
+			if plaintext, err = k.DecryptString(value); err != nil {
+				e.log.Warn("EncryptionRepo Error", zap.String("table", table), zap.Int("id", id), zap.Error(err))
+				return false, err
+			}
+		}
+		encrypted, err := k.EncryptString(plaintext)
+		if err != nil {
+			return false, err
+		}
+		res, err := e.db.Exec(`UPDATE ?0 SET ?1 = ?2 WHERE id = ?3 AND ?1 = ?4`, pg.Ident(table), pg.Ident(column), encrypted, id, value)
+		if err != nil {
+			e.log.Warn("EncryptionRepo Error", zap.Error(err))
+			return false, err
+		}
+		if res.RowsAffected() == 1 {
+			return true, nil
+		}
+		var current struct{ Value *string }
+		_, err = e.db.QueryOne(&current, `SELECT ?0 AS value FROM ?1 WHERE id = ?2`, pg.Ident(column), pg.Ident(table), id)
+		if err == pg.ErrNoRows || err == nil && current.Value == nil {
+			return false, nil
+		}
+		if err != nil {
+			e.log.Warn("EncryptionRepo Error", zap.Error(err))
+			return false, err
+		}
+		value = *current.Value
+	}
+	return false, fmt.Errorf("%s.%s of row %d kept changing while it was re-encrypted", table, column, id)
+}
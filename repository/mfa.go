@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewMFARepo returns a new MFARepo instance
+func NewMFARepo(db orm.DB, log *zap.Logger) *MFARepo {
+	return &MFARepo{db, log}
+}
+
+// MFARepo is the client for the second factors of users and their pending login challenges
+type MFARepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// UpdateFactor saves the two-factor authentication columns of a user
+func (m *MFARepo) UpdateFactor(user *model.User) error {
+	_, err := m.db.Model(user).
+		Column("mfa_enabled", "mfa_secret", "mfa_recovery_hashes", "mfa_last_step", "mfa_verified_at", "mfa_failed_attempts", "mfa_locked_until", "updated_at").
+		WherePK().
+		Update()
+	if err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+var errFactorUsed = apperr.New(http.StatusConflict, "Code was already used.")
+
+// notLocked matches users whose second factor isn't locked at the time given as parameter
+const notLocked = "(mfa_locked_until is null or mfa_locked_until <= ?)"
+
+// UseStep records the time step of a verified code and clears the failed attempts. It fails when the step or a
+// later one was used first, so a code can't be replayed by concurrent requests
+func (m *MFARepo) UseStep(user *model.User, step int64, at time.Time) error {
+	res, err := m.db.Model(user).
+		Set("mfa_last_step = ?", step).
+		Set("mfa_verified_at = ?", at).
+		Set("mfa_failed_attempts = 0").
+		Set("updated_at = ?", at).
+		WherePK().
+		Where("coalesce(mfa_last_step, 0) < ?", step).
+		Where(notLocked, at).
+		Update()
+	if err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return errFactorUsed
+	}
+	user.MFALastStep = step
+	user.MFAVerifiedAt = &at
+	user.MFAFailedAttempts = 0
+	return nil
+}
+
+// UseRecoveryCode removes the hash of a recovery code and clears the failed attempts, it fails when the code
+// was used up first
+func (m *MFARepo) UseRecoveryCode(user *model.User, hash string, at time.Time) error {
+	res, err := m.db.Model(user).
+		Set("mfa_recovery_hashes = array_remove(mfa_recovery_hashes, ?)", hash).
+		Set("mfa_verified_at = ?", at).
+		Set("mfa_failed_attempts = 0").
+		Set("updated_at = ?", at).
+		WherePK().
+		Where("? = any(mfa_recovery_hashes)", hash).
+		Where(notLocked, at).
+		Update()
+	if err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return errFactorUsed
+	}
+	for i, h := range user.MFARecoveryHashes {
+		if h == hash {
+			user.MFARecoveryHashes = append(user.MFARecoveryHashes[:i:i], user.MFARecoveryHashes[i+1:]...)
+			break
+		}
+	}
+	user.MFAVerifiedAt = &at
+	user.MFAFailedAttempts = 0
+	return nil
+}
+
+// AddFailedAttempt counts a wrong code, the maxAttempts-th in a row locks the second factor until lockedUntil
+func (m *MFARepo) AddFailedAttempt(user *model.User, maxAttempts int, lockedUntil time.Time) error {
+	_, err := m.db.Model(user).
+		Set("mfa_failed_attempts = case when coalesce(mfa_failed_attempts, 0) + 1 >= ? then 0 else coalesce(mfa_failed_attempts, 0) + 1 end", maxAttempts).
+		Set("mfa_locked_until = case when coalesce(mfa_failed_attempts, 0) + 1 >= ? then ? else mfa_locked_until end", maxAttempts, lockedUntil).
+		Set("updated_at = ?", time.Now()).
+		WherePK().
+		Returning("mfa_failed_attempts, mfa_locked_until").
+		Update()
+	if err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// CreateChallenge records a new login challenge
+func (m *MFARepo) CreateChallenge(c *model.MFAChallenge) (*model.MFAChallenge, error) {
+	if err := m.db.Insert(c); err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return c, nil
+}
+
+// FindChallenge returns the login challenge with the token hash
+func (m *MFARepo) FindChallenge(tokenHash string) (*model.MFAChallenge, error) {
+	var c = new(model.MFAChallenge)
+	sql := `SELECT * FROM mfa_challenges WHERE (token_hash = ? and deleted_at is null) LIMIT 1`
+	_, err := m.db.QueryOne(c, sql, tokenHash)
+	if err != nil {
+		return nil, apperr.New(http.StatusUnauthorized, "Login expired. Please sign in again.")
+	}
+	return c, nil
+}
+
+// UpdateChallenge saves the failed attempts of a login challenge
+func (m *MFARepo) UpdateChallenge(c *model.MFAChallenge) error {
+	_, err := m.db.Model(c).Column("attempts", "updated_at").WherePK().Update()
+	if err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// DeleteChallenge removes a login challenge once it was used up
+func (m *MFARepo) DeleteChallenge(c *model.MFAChallenge) error {
+	c.Delete()
+	_, err := m.db.Model(c).Column("deleted_at").WherePK().Update()
+	if err != nil {
+		m.log.Warn("MFARepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
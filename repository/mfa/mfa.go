@@ -0,0 +1,267 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/secret"
+	"github.com/alpacahq/ribbit-backend/totp"
+
+	"go.uber.org/zap"
+)
+
+// NewMFAService creates new two-factor authentication service
+func NewMFAService(userRepo model.UserRepo, mfaRepo model.MFARepo, config *config.MFAConfig, log *zap.Logger) *Service {
+	return &Service{userRepo, mfaRepo, config, log}
+}
+
+// Service represents the TOTP two-factor authentication application service
+type Service struct {
+	userRepo model.UserRepo
+	mfaRepo  model.MFARepo
+	config   *config.MFAConfig
+	log      *zap.Logger
+}
+
+var now = time.Now
+
+var (
+	errInvalidCode   = apperr.New(http.StatusUnauthorized, "Invalid code.")
+	errEnabled       = apperr.New(http.StatusConflict, "Two-factor authentication is already on.")
+	errNotEnabled    = apperr.New(http.StatusBadRequest, "Two-factor authentication is off.")
+	errNotEnrolled   = apperr.New(http.StatusBadRequest, "Please start the two-factor authentication setup first.")
+	errLoginExpired  = apperr.New(http.StatusUnauthorized, "Login expired. Please sign in again.")
+	errFreshRequired = apperr.New(http.StatusForbidden, "Please confirm with a code from your authenticator app first.")
+	errLocked        = apperr.New(http.StatusTooManyRequests, "Too many wrong codes. Please try again later.")
+)
+
+// Enrollment is a new TOTP secret with its provisioning URI, shown as a QR code for the authenticator app to scan
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodes are single use codes which stand in for the authenticator app, they are only shown once
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// Enroll starts the two-factor setup of a user with a new secret, it only takes effect once confirmed with a code
+func (s *Service) Enroll(u *model.User) (*Enrollment, error) {
+	if u.MFAEnabled {
+		return nil, errEnabled
+	}
+	key, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, apperr.Generic
+	}
+	u.MFASecret = secret.EncryptedString(key)
+	u.MFALastStep = 0
+	if err := s.mfaRepo.UpdateFactor(u); err != nil {
+		return nil, err
+	}
+	account := u.Email
+	if account == "" {
+		account = u.Username
+	}
+	return &Enrollment{Secret: key, URI: totp.URI(s.config.Issuer, account, key)}, nil
+}
+
+// Confirm turns two-factor authentication on with the first code from the authenticator app and issues recovery codes
+func (s *Service) Confirm(u *model.User, code string) (*RecoveryCodes, error) {
+	if u.MFAEnabled {
+		return nil, errEnabled
+	}
+	if u.MFASecret == "" {
+		return nil, errNotEnrolled
+	}
+	step, ok := totp.Validate(string(u.MFASecret), code, now())
+	if !ok {
+		return nil, errInvalidCode
+	}
+	codes, hashes, err := s.recoveryCodes()
+	if err != nil {
+		return nil, apperr.Generic
+	}
+	t := now()
+	u.MFAEnabled = true
+	u.MFALastStep = step
+	u.MFARecoveryHashes = hashes
+	u.MFAVerifiedAt = &t
+	if err := s.mfaRepo.UpdateFactor(u); err != nil {
+		return nil, err
+	}
+	return &RecoveryCodes{codes}, nil
+}
+
+// Disable turns two-factor authentication off, it takes a code or a recovery code
+func (s *Service) Disable(u *model.User, code string) error {
+	if !u.MFAEnabled {
+		return errNotEnabled
+	}
+	if err := s.verify(u, code); err != nil {
+		return err
+	}
+	u.MFAEnabled = false
+	u.MFASecret = ""
+	u.MFARecoveryHashes = nil
+	u.MFALastStep = 0
+	u.MFAVerifiedAt = nil
+	u.MFAFailedAttempts = 0
+	u.MFALockedUntil = nil
+	return s.mfaRepo.UpdateFactor(u)
+}
+
+// Verify confirms a fresh second factor, withdrawals and bank linking may require one
+func (s *Service) Verify(u *model.User, code string) error {
+	if !u.MFAEnabled {
+		return errNotEnabled
+	}
+	return s.verify(u, code)
+}
+
+// RequireFresh fails unless the user confirmed their second factor recently, users without one pass
+func (s *Service) RequireFresh(userID int) error {
+	if s.config.FreshWindow <= 0 {
+		return nil
+	}
+	u, err := s.userRepo.View(userID)
+	if err != nil {
+		return err
+	}
+	if !u.MFAEnabled {
+		return nil
+	}
+	if u.MFAVerifiedAt == nil || now().Sub(*u.MFAVerifiedAt) > s.config.FreshWindow {
+		return errFreshRequired
+	}
+	return nil
+}
+
+// Challenge starts the second step of a login, the returned token is exchanged with Redeem
+func (s *Service) Challenge(u *model.User) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, apperr.Generic
+	}
+	expires := now().Add(s.config.ChallengeTTL)
+	_, err = s.mfaRepo.CreateChallenge(&model.MFAChallenge{UserID: u.ID, TokenHash: hash(token), ExpiresAt: expires})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// Redeem finishes a login with the challenge token and a code or recovery code and returns the user.
+// A challenge is used up once redeemed, when it expires or after too many wrong codes
+func (s *Service) Redeem(token, code string) (*model.User, error) {
+	challenge, err := s.mfaRepo.FindChallenge(hash(token))
+	if err != nil {
+		return nil, errLoginExpired
+	}
+	if now().After(challenge.ExpiresAt) || challenge.Attempts >= s.config.ChallengeAttempts {
+		s.discard(challenge)
+		return nil, errLoginExpired
+	}
+	u, err := s.userRepo.View(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(u, code); err != nil {
+		challenge.Attempts++
+		if err := s.mfaRepo.UpdateChallenge(challenge); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	s.discard(challenge)
+	return u, nil
+}
+
+func (s *Service) discard(challenge *model.MFAChallenge) {
+	if err := s.mfaRepo.DeleteChallenge(challenge); err != nil {
+		s.log.Warn("MFAService Error", zap.Int("challenge_id", challenge.ID), zap.Error(err))
+	}
+}
+
+// verify checks a code from the authenticator app, which can't be used twice, or else a recovery code,
+// which is used up. On success the second factor counts as fresh, too many wrong codes in a row lock it
+func (s *Service) verify(u *model.User, code string) error {
+	t := now()
+	if u.MFALockedUntil != nil && t.Before(*u.MFALockedUntil) {
+		return errLocked
+	}
+	var err error
+	if step, ok := totp.Validate(string(u.MFASecret), code, t); ok && step > u.MFALastStep {
+		err = s.mfaRepo.UseStep(u, step, t)
+	} else if h := hash(normalize(code)); s.hasRecoveryCode(u, h) {
+		if err = s.mfaRepo.UseRecoveryCode(u, h, t); err == nil {
+			s.log.Info("Recovery code used", zap.Int("user_id", u.ID), zap.Int("remaining", len(u.MFARecoveryHashes)))
+		}
+	} else {
+		err = errInvalidCode
+	}
+	// a conflict means a concurrent request used the code first or locked the second factor
+	if e, ok := err.(*apperr.APPError); ok && e.Status == http.StatusConflict {
+		err = errInvalidCode
+	}
+	if err == errInvalidCode && s.config.LockoutAttempts > 0 {
+		if err := s.mfaRepo.AddFailedAttempt(u, s.config.LockoutAttempts, t.Add(s.config.Lockout)); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Service) hasRecoveryCode(u *model.User, h string) bool {
+	for _, stored := range u.MFARecoveryHashes {
+		if stored == h {
+			return true
+		}
+	}
+	return false
+}
+
+// recoveryCodes returns new recovery codes and the hashes stored in their place
+func (s *Service) recoveryCodes() ([]string, []string, error) {
+	codes := make([]string, s.config.RecoveryCodes)
+	hashes := make([]string, s.config.RecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		c := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = c[:4] + "-" + c[4:]
+		hashes[i] = hash(c)
+	}
+	return codes, hashes, nil
+}
+
+// normalize strips what users type around a recovery code
+func normalize(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// newToken returns a random challenge token, only its hash is stored
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
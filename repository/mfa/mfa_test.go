@@ -0,0 +1,264 @@
+package mfa_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/mfa"
+	"github.com/alpacahq/ribbit-backend/totp"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	cfg   = &config.MFAConfig{Issuer: "Ribbit", ChallengeTTL: 5 * time.Minute, ChallengeAttempts: 3, FreshWindow: 5 * time.Minute, RecoveryCodes: 4}
+	today = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func code(t *testing.T, secret string, at time.Time) string {
+	c, err := totp.Code(secret, totp.Step(at))
+	assert.NoError(t, err)
+	return c
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, status, err.(*apperr.APPError).Status)
+	}
+}
+
+// factors fills in the conditional updates of repo like the database runs them, against the stored copy of a user
+func factors(repo *mockdb.MFA, stored *model.User) *mockdb.MFA {
+	locked := func(at time.Time) bool {
+		return stored.MFALockedUntil != nil && at.Before(*stored.MFALockedUntil)
+	}
+	repo.UseStepFn = func(u *model.User, step int64, at time.Time) error {
+		if stored.MFALastStep >= step || locked(at) {
+			return apperr.New(http.StatusConflict, "Code was already used.")
+		}
+		stored.MFALastStep, stored.MFAFailedAttempts = step, 0
+		u.MFALastStep, u.MFAVerifiedAt, u.MFAFailedAttempts = step, &at, 0
+		return nil
+	}
+	repo.UseRecoveryCodeFn = func(u *model.User, h string, at time.Time) error {
+		for i, have := range u.MFARecoveryHashes {
+			if have == h && !locked(at) {
+				u.MFARecoveryHashes = append(u.MFARecoveryHashes[:i:i], u.MFARecoveryHashes[i+1:]...)
+				u.MFAVerifiedAt, u.MFAFailedAttempts = &at, 0
+				return nil
+			}
+		}
+		return apperr.New(http.StatusConflict, "Code was already used.")
+	}
+	repo.AddFailedAttemptFn = func(u *model.User, max int, lockedUntil time.Time) error {
+		stored.MFAFailedAttempts++
+		if stored.MFAFailedAttempts >= max {
+			stored.MFAFailedAttempts, stored.MFALockedUntil = 0, &lockedUntil
+		}
+		u.MFAFailedAttempts, u.MFALockedUntil = stored.MFAFailedAttempts, stored.MFALockedUntil
+		return nil
+	}
+	return repo
+}
+
+// enrolled returns a user with two-factor authentication on, and their recovery codes
+func enrolled(t *testing.T, s *mfa.Service) (*model.User, []string) {
+	u := &model.User{ID: 1, Email: "alice@example.com"}
+	e, err := s.Enroll(u)
+	assert.NoError(t, err)
+	codes, err := s.Confirm(u, code(t, e.Secret, today))
+	assert.NoError(t, err)
+	return u, codes.Codes
+}
+
+func TestEnroll(t *testing.T) {
+	defer mfa.SetNow(today)()
+	saved := 0
+	repo := &mockdb.MFA{UpdateFactorFn: func(*model.User) error { saved++; return nil }}
+	s := mfa.NewMFAService(nil, repo, cfg, zap.NewNop())
+	u := &model.User{ID: 1, Email: "alice@example.com"}
+
+	e, err := s.Enroll(u)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(e.URI, "otpauth://totp/Ribbit:alice@example.com?"))
+	assert.Contains(t, e.URI, "secret="+e.Secret)
+	assert.False(t, u.MFAEnabled)
+
+	_, err = s.Confirm(u, "000000")
+	assertStatus(t, http.StatusUnauthorized, err)
+	assert.False(t, u.MFAEnabled)
+
+	codes, err := s.Confirm(u, code(t, e.Secret, today))
+	assert.NoError(t, err)
+	assert.True(t, u.MFAEnabled)
+	assert.Len(t, codes.Codes, 4)
+	// only the hashes of the recovery codes are stored
+	for i, c := range codes.Codes {
+		assert.Equal(t, hash(strings.Replace(c, "-", "", 1)), u.MFARecoveryHashes[i])
+	}
+	assert.Equal(t, 2, saved)
+
+	_, err = s.Enroll(u)
+	assertStatus(t, http.StatusConflict, err)
+}
+
+func TestVerify(t *testing.T) {
+	defer mfa.SetNow(today)()
+	stored := new(model.User)
+	repo := factors(&mockdb.MFA{UpdateFactorFn: func(u *model.User) error { *stored = *u; return nil }}, stored)
+	s := mfa.NewMFAService(nil, repo, cfg, zap.NewNop())
+	u, recovery := enrolled(t, s)
+
+	// the code confirming the enrollment can't be replayed
+	assertStatus(t, http.StatusUnauthorized, s.Verify(u, code(t, string(u.MFASecret), today)))
+	later := today.Add(time.Minute)
+	defer mfa.SetNow(later)()
+	assert.NoError(t, s.Verify(u, code(t, string(u.MFASecret), later)))
+
+	// recovery codes work once, however they are typed
+	assert.NoError(t, s.Verify(u, strings.ToUpper(recovery[0])))
+	assert.Len(t, u.MFARecoveryHashes, 3)
+	assertStatus(t, http.StatusUnauthorized, s.Verify(u, recovery[0]))
+
+	// a request which loaded the user before the code was used can't replay it
+	stale := *u
+	stale.MFALastStep = 0
+	next := later.Add(totp.Period)
+	defer mfa.SetNow(next)()
+	assert.NoError(t, s.Verify(u, code(t, string(u.MFASecret), next)))
+	assertStatus(t, http.StatusUnauthorized, s.Verify(&stale, code(t, string(u.MFASecret), next)))
+}
+
+func TestLockout(t *testing.T) {
+	defer mfa.SetNow(today)()
+	stored := new(model.User)
+	repo := factors(&mockdb.MFA{UpdateFactorFn: func(u *model.User) error { *stored = *u; return nil }}, stored)
+	lockout := *cfg
+	lockout.LockoutAttempts = 3
+	lockout.Lockout = 15 * time.Minute
+	s := mfa.NewMFAService(nil, repo, &lockout, zap.NewNop())
+	u, recovery := enrolled(t, s)
+
+	later := today.Add(time.Minute)
+	defer mfa.SetNow(later)()
+	for i := 0; i < 3; i++ {
+		assertStatus(t, http.StatusUnauthorized, s.Verify(u, "000000"))
+	}
+	// once locked not even a right code or a recovery code gets through
+	assertStatus(t, http.StatusTooManyRequests, s.Verify(u, code(t, string(u.MFASecret), later)))
+	assertStatus(t, http.StatusTooManyRequests, s.Disable(u, recovery[0]))
+	assert.Len(t, u.MFARecoveryHashes, 4)
+
+	unlocked := later.Add(16 * time.Minute)
+	defer mfa.SetNow(unlocked)()
+	assert.NoError(t, s.Verify(u, code(t, string(u.MFASecret), unlocked)))
+	assert.Equal(t, 0, stored.MFAFailedAttempts)
+}
+
+func TestRequireFresh(t *testing.T) {
+	defer mfa.SetNow(today)()
+	verified := today.Add(-time.Minute)
+	stale := today.Add(-time.Hour)
+	cases := []struct {
+		name   string
+		user   *model.User
+		status int
+	}{
+		{name: "without two-factor authentication", user: &model.User{ID: 1}},
+		{name: "verified recently", user: &model.User{ID: 1, MFAEnabled: true, MFAVerifiedAt: &verified}},
+		{name: "verified long ago", user: &model.User{ID: 1, MFAEnabled: true, MFAVerifiedAt: &stale}, status: http.StatusForbidden},
+		{name: "never verified", user: &model.User{ID: 1, MFAEnabled: true}, status: http.StatusForbidden},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &mockdb.User{ViewFn: func(int) (*model.User, error) { return tt.user, nil }}
+			s := mfa.NewMFAService(users, nil, cfg, zap.NewNop())
+
+			err := s.RequireFresh(1)
+			if tt.status == 0 {
+				assert.NoError(t, err)
+			} else {
+				assertStatus(t, tt.status, err)
+			}
+		})
+	}
+}
+
+func TestLoginChallenge(t *testing.T) {
+	defer mfa.SetNow(today)()
+	challenges := map[string]*model.MFAChallenge{}
+	repo := &mockdb.MFA{
+		UpdateFactorFn: func(*model.User) error { return nil },
+		UseStepFn: func(u *model.User, step int64, at time.Time) error {
+			u.MFALastStep, u.MFAVerifiedAt = step, &at
+			return nil
+		},
+		AddFailedAttemptFn: func(*model.User, int, time.Time) error { return nil },
+		CreateChallengeFn: func(c *model.MFAChallenge) (*model.MFAChallenge, error) {
+			challenges[c.TokenHash] = c
+			return c, nil
+		},
+		FindChallengeFn: func(h string) (*model.MFAChallenge, error) {
+			if c, ok := challenges[h]; ok {
+				return c, nil
+			}
+			return nil, apperr.New(http.StatusUnauthorized, "Login expired. Please sign in again.")
+		},
+		UpdateChallengeFn: func(*model.MFAChallenge) error { return nil },
+		DeleteChallengeFn: func(c *model.MFAChallenge) error {
+			delete(challenges, c.TokenHash)
+			return nil
+		},
+	}
+	var user *model.User
+	users := &mockdb.User{ViewFn: func(int) (*model.User, error) { return user, nil }}
+	s := mfa.NewMFAService(users, repo, cfg, zap.NewNop())
+	user, _ = enrolled(t, s)
+	later := today.Add(time.Minute)
+	defer mfa.SetNow(later)()
+
+	token, expires, err := s.Challenge(user)
+	assert.NoError(t, err)
+	assert.Equal(t, later.Add(5*time.Minute), expires)
+	assert.Contains(t, challenges, hash(token))
+
+	_, err = s.Redeem(token, "000000")
+	assertStatus(t, http.StatusUnauthorized, err)
+	u, err := s.Redeem(token, code(t, string(user.MFASecret), later))
+	assert.NoError(t, err)
+	assert.Equal(t, user, u)
+	// a challenge is only good for one login
+	_, err = s.Redeem(token, code(t, string(user.MFASecret), later))
+	assertStatus(t, http.StatusUnauthorized, err)
+
+	// too many wrong codes use the challenge up
+	token, _, _ = s.Challenge(user)
+	for i := 0; i < cfg.ChallengeAttempts; i++ {
+		_, err = s.Redeem(token, "000000")
+		assertStatus(t, http.StatusUnauthorized, err)
+	}
+	assert.Equal(t, cfg.ChallengeAttempts, challenges[hash(token)].Attempts)
+	_, err = s.Redeem(token, code(t, string(user.MFASecret), later.Add(totp.Period)))
+	assertStatus(t, http.StatusUnauthorized, err)
+	assert.NotContains(t, challenges, hash(token))
+
+	// and so does expiry
+	token, _, _ = s.Challenge(user)
+	defer mfa.SetNow(later.Add(6 * time.Minute))()
+	_, err = s.Redeem(token, code(t, string(user.MFASecret), later.Add(6*time.Minute)))
+	assertStatus(t, http.StatusUnauthorized, err)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewMicroDepositRepo returns a new MicroDepositRepo instance
+func NewMicroDepositRepo(db orm.DB, log *zap.Logger) *MicroDepositRepo {
+	return &MicroDepositRepo{db, log}
+}
+
+// MicroDepositRepo is the client for our micro-deposit model
+type MicroDepositRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// Create creates the micro-deposits of a bank account
+func (m *MicroDepositRepo) Create(deposit *model.MicroDeposit) (*model.MicroDeposit, error) {
+	if err := m.db.Insert(deposit); err != nil {
+		m.log.Warn("MicroDepositRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return deposit, nil
+}
+
+// FindByBankAccount returns the micro-deposits sent to a bank account
+func (m *MicroDepositRepo) FindByBankAccount(bankAccountID int) (*model.MicroDeposit, error) {
+	var deposit = new(model.MicroDeposit)
+	sql := `SELECT * FROM micro_deposits WHERE (bank_account_id = ? and deleted_at is null) LIMIT 1`
+	_, err := m.db.QueryOne(deposit, sql, bankAccountID)
+	if err != nil {
+		m.log.Warn("MicroDepositRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Micro-deposits not found.")
+	}
+	return deposit, nil
+}
+
+// Update stores the verification time of the micro-deposits, attempts only change through AddAttempt
+func (m *MicroDepositRepo) Update(deposit *model.MicroDeposit) error {
+	_, err := m.db.Model(deposit).Column("verified_at", "updated_at").WherePK().Update()
+	if err != nil {
+		m.log.Warn("MicroDepositRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// AddAttempt counts a wrong guess of the amounts, unless maxAttempts were already made
+func (m *MicroDepositRepo) AddAttempt(deposit *model.MicroDeposit, maxAttempts int) error {
+	res, err := m.db.Model(deposit).
+		Set("attempts = attempts + 1").
+		Set("updated_at = ?", time.Now()).
+		WherePK().
+		Where("attempts < ?", maxAttempts).
+		Returning("attempts").
+		Update()
+	if err != nil {
+		m.log.Warn("MicroDepositRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusForbidden, "Too many attempts. Please enter your bank account again.")
+	}
+	return nil
+}
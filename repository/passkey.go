@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewPasskeyRepo returns a new PasskeyRepo instance
+func NewPasskeyRepo(db orm.DB, log *zap.Logger) *PasskeyRepo {
+	return &PasskeyRepo{db, log}
+}
+
+// PasskeyRepo is the client for passkeys and their pending ceremonies
+type PasskeyRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+var errPasskeyNotFound = apperr.New(http.StatusNotFound, "Passkey not found.")
+
+// Create records a new passkey
+func (p *PasskeyRepo) Create(passkey *model.Passkey) (*model.Passkey, error) {
+	if err := p.db.Insert(passkey); err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return passkey, nil
+}
+
+// FindByCredential returns the passkey with the credential id
+func (p *PasskeyRepo) FindByCredential(credentialID string) (*model.Passkey, error) {
+	var passkey = new(model.Passkey)
+	sql := `SELECT * FROM passkeys WHERE (credential_id = ? and deleted_at is null) LIMIT 1`
+	_, err := p.db.QueryOne(passkey, sql, credentialID)
+	if err != nil {
+		return nil, errPasskeyNotFound
+	}
+	return passkey, nil
+}
+
+// ListByUser returns the passkeys of a user, oldest first
+func (p *PasskeyRepo) ListByUser(userID int) ([]model.Passkey, error) {
+	var passkeys []model.Passkey
+	sql := `SELECT * FROM passkeys WHERE (user_id = ? and deleted_at is null) ORDER BY id ASC`
+	_, err := p.db.Query(&passkeys, sql, userID)
+	if err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return passkeys, nil
+}
+
+// View returns a passkey of a user
+func (p *PasskeyRepo) View(userID, id int) (*model.Passkey, error) {
+	var passkey = new(model.Passkey)
+	sql := `SELECT * FROM passkeys WHERE (id = ? and user_id = ? and deleted_at is null) LIMIT 1`
+	_, err := p.db.QueryOne(passkey, sql, id, userID)
+	if err != nil {
+		return nil, errPasskeyNotFound
+	}
+	return passkey, nil
+}
+
+// UpdateSignCount saves the signature counter and last use of a passkey. A counter which isn't above the
+// stored one fails with a conflict, another login with the same or a later signature got there first
+func (p *PasskeyRepo) UpdateSignCount(passkey *model.Passkey) error {
+	q := p.db.Model(passkey).Column("sign_count", "last_used_at", "updated_at").WherePK()
+	if passkey.SignCount > 0 {
+		q = q.Where("sign_count < ?", passkey.SignCount)
+	}
+	res, err := q.Update()
+	if err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusConflict, "Passkey was already used with this signature counter.")
+	}
+	return nil
+}
+
+// Delete removes a passkey, it can't sign in anymore
+func (p *PasskeyRepo) Delete(passkey *model.Passkey) error {
+	passkey.Delete()
+	_, err := p.db.Model(passkey).Column("deleted_at").WherePK().Update()
+	if err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// CreateChallenge records the challenge of a new ceremony
+func (p *PasskeyRepo) CreateChallenge(c *model.PasskeyChallenge) (*model.PasskeyChallenge, error) {
+	if err := p.db.Insert(c); err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return c, nil
+}
+
+// FindChallenge returns the pending ceremony with the challenge
+func (p *PasskeyRepo) FindChallenge(challenge string) (*model.PasskeyChallenge, error) {
+	var c = new(model.PasskeyChallenge)
+	sql := `SELECT * FROM passkey_challenges WHERE (challenge = ? and deleted_at is null) LIMIT 1`
+	_, err := p.db.QueryOne(c, sql, challenge)
+	if err != nil {
+		return nil, apperr.New(http.StatusUnauthorized, "Passkey request expired. Please try again.")
+	}
+	return c, nil
+}
+
+// CountChallenges returns how many login ceremonies a client started since a time, used or not
+func (p *PasskeyRepo) CountChallenges(clientIP string, since time.Time) (int, error) {
+	count, err := p.db.Model((*model.PasskeyChallenge)(nil)).
+		Where("client_ip = ? and ceremony = ? and created_at >= ?", clientIP, model.PasskeyLogin, since).
+		Count()
+	if err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return count, nil
+}
+
+// PurgeChallenges deletes the ceremonies which expired before a time, used or not
+func (p *PasskeyRepo) PurgeChallenges(before time.Time) (int, error) {
+	res, err := p.db.Model((*model.PasskeyChallenge)(nil)).
+		Where("expires_at < ?", before).
+		Delete()
+	if err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return res.RowsAffected(), nil
+}
+
+// DeleteChallenge removes a ceremony once it was used, only if no other request used it first.
+// It fails with a conflict when the ceremony was already used
+func (p *PasskeyRepo) DeleteChallenge(c *model.PasskeyChallenge) error {
+	t := time.Now()
+	res, err := p.db.Model(c).
+		Set("deleted_at = ?", t).
+		WherePK().
+		Where("deleted_at is null").
+		Update()
+	if err != nil {
+		p.log.Warn("PasskeyRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() != 1 {
+		return apperr.New(http.StatusConflict, "Challenge was already used.")
+	}
+	c.DeletedAt = &t
+	return nil
+}
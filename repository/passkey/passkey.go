@@ -0,0 +1,275 @@
+package passkey
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/request"
+	"github.com/alpacahq/ribbit-backend/webauthn"
+
+	"go.uber.org/zap"
+)
+
+// NewPasskeyService creates new passkey service
+func NewPasskeyService(userRepo model.UserRepo, passkeyRepo model.PasskeyRepo, secondFactor SecondFactor, config *config.PasskeyConfig, log *zap.Logger) *Service {
+	rp := &webauthn.RelyingParty{
+		ID:               config.RPID,
+		Name:             config.RPName,
+		Origins:          config.Origins,
+		UserVerification: config.UserVerification,
+		Timeout:          config.ChallengeTTL,
+	}
+	return &Service{userRepo, passkeyRepo, secondFactor, config, rp, log}
+}
+
+// Service represents the WebAuthn passkey application service
+type Service struct {
+	userRepo     model.UserRepo
+	passkeyRepo  model.PasskeyRepo
+	secondFactor SecondFactor
+	config       *config.PasskeyConfig
+	rp           *webauthn.RelyingParty
+	log          *zap.Logger
+}
+
+// SecondFactor checks users with two-factor authentication confirmed it recently
+type SecondFactor interface {
+	RequireFresh(userID int) error
+}
+
+var now = time.Now
+
+var (
+	errMalformed   = apperr.New(http.StatusBadRequest, "Malformed passkey response.")
+	errExpired     = apperr.New(http.StatusUnauthorized, "Passkey request expired. Please try again.")
+	errNotVerified = apperr.New(http.StatusUnauthorized, "Passkey couldn't be verified.")
+	errRegistered  = apperr.New(http.StatusConflict, "Passkey is already registered.")
+	errRateLimited = apperr.New(http.StatusTooManyRequests, "Too many passkey logins. Please try again later.")
+)
+
+// BeginRegistration starts adding a passkey for a user and returns the options for navigator.credentials.create().
+// A passkey signs in without the second factor, so users with one must have confirmed it recently
+func (s *Service) BeginRegistration(u *model.User) (*webauthn.CreationOptions, error) {
+	if err := s.secondFactor.RequireFresh(u.ID); err != nil {
+		return nil, err
+	}
+	passkeys, err := s.passkeyRepo.ListByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]string, len(passkeys))
+	for i, p := range passkeys {
+		exclude[i] = p.CredentialID
+	}
+	challenge, err := s.newChallenge(u.ID, model.PasskeyRegistration, "")
+	if err != nil {
+		return nil, err
+	}
+	name := u.Email
+	if name == "" {
+		name = u.Username
+	}
+	displayName := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if displayName == "" {
+		displayName = name
+	}
+	user := webauthn.UserEntity{ID: userHandle(u.ID), Name: name, DisplayName: displayName}
+	return s.rp.CreationOptions(challenge, user, exclude), nil
+}
+
+// FinishRegistration verifies the new credential of a registration started with BeginRegistration and stores it
+func (s *Service) FinishRegistration(u *model.User, r *request.PasskeyRegistration) (*model.Passkey, error) {
+	if err := s.secondFactor.RequireFresh(u.ID); err != nil {
+		return nil, err
+	}
+	clientData, err := webauthn.Decode(r.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errMalformed
+	}
+	attestation, err := webauthn.Decode(r.Response.AttestationObject)
+	if err != nil {
+		return nil, errMalformed
+	}
+	challenge, err := s.challenge(clientData, model.PasskeyRegistration, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := s.rp.VerifyRegistration(challenge.Challenge, clientData, attestation)
+	if err != nil {
+		s.log.Info("Passkey registration rejected", zap.Int("user_id", u.ID), zap.Error(err))
+		return nil, errNotVerified
+	}
+	if id, err := webauthn.Decode(r.ID); err != nil || webauthn.Encode(id) != credential.ID {
+		return nil, errNotVerified
+	}
+	if _, err := s.passkeyRepo.FindByCredential(credential.ID); err == nil {
+		return nil, errRegistered
+	}
+
+	name := strings.TrimSpace(r.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	return s.passkeyRepo.Create(&model.Passkey{
+		UserID:       u.ID,
+		Name:         name,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    int64(credential.SignCount),
+		AAGUID:       webauthn.Encode(credential.AAGUID),
+	})
+}
+
+// BeginLogin starts a passkey login and returns the options for navigator.credentials.get(), the user
+// picks which of their passkeys to sign in with. Anyone can start one, so they are limited per client IP
+func (s *Service) BeginLogin(clientIP string) (*webauthn.RequestOptions, error) {
+	if s.config.LoginRateLimit > 0 {
+		count, err := s.passkeyRepo.CountChallenges(clientIP, now().Add(-s.config.LoginRateWindow))
+		if err != nil {
+			return nil, err
+		}
+		if count >= s.config.LoginRateLimit {
+			return nil, errRateLimited
+		}
+	}
+	challenge, err := s.newChallenge(0, model.PasskeyLogin, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	return s.rp.RequestOptions(challenge), nil
+}
+
+// FinishLogin verifies the assertion of a login started with BeginLogin and returns the passkey's user.
+// The signature counter must go up with every login unless the authenticator doesn't keep one, otherwise
+// the passkey was cloned and the login is refused
+func (s *Service) FinishLogin(r *request.PasskeyAssertion) (*model.User, error) {
+	clientData, err := webauthn.Decode(r.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errMalformed
+	}
+	authData, err := webauthn.Decode(r.Response.AuthenticatorData)
+	if err != nil {
+		return nil, errMalformed
+	}
+	signature, err := webauthn.Decode(r.Response.Signature)
+	if err != nil {
+		return nil, errMalformed
+	}
+	id, err := webauthn.Decode(r.ID)
+	if err != nil {
+		return nil, errMalformed
+	}
+	challenge, err := s.challenge(clientData, model.PasskeyLogin, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey, err := s.passkeyRepo.FindByCredential(webauthn.Encode(id))
+	if err != nil {
+		return nil, errNotVerified
+	}
+	if r.Response.UserHandle != "" {
+		if handle, err := webauthn.Decode(r.Response.UserHandle); err != nil || webauthn.Encode(handle) != userHandle(passkey.UserID) {
+			return nil, errNotVerified
+		}
+	}
+	signCount, err := s.rp.VerifyAssertion(challenge.Challenge, passkey.PublicKey, clientData, authData, signature)
+	if err != nil {
+		s.log.Info("Passkey login rejected", zap.Int("passkey_id", passkey.ID), zap.Error(err))
+		return nil, errNotVerified
+	}
+	if (signCount != 0 || passkey.SignCount != 0) && int64(signCount) <= passkey.SignCount {
+		s.log.Warn("Passkey signature counter went back, it may be cloned", zap.Int("user_id", passkey.UserID), zap.Int("passkey_id", passkey.ID),
+			zap.Int64("stored", passkey.SignCount), zap.Uint32("received", signCount))
+		return nil, errNotVerified
+	}
+
+	t := now()
+	passkey.SignCount = int64(signCount)
+	passkey.LastUsedAt = &t
+	if err := s.passkeyRepo.UpdateSignCount(passkey); err != nil {
+		if e, ok := err.(*apperr.APPError); ok && e.Status == http.StatusConflict {
+			return nil, errNotVerified
+		}
+		return nil, err
+	}
+	return s.userRepo.View(passkey.UserID)
+}
+
+// List returns the passkeys of a user
+func (s *Service) List(u *model.User) ([]model.Passkey, error) {
+	passkeys, err := s.passkeyRepo.ListByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if passkeys == nil {
+		passkeys = []model.Passkey{}
+	}
+	return passkeys, nil
+}
+
+// Delete removes a passkey of a user
+func (s *Service) Delete(u *model.User, id int) error {
+	passkey, err := s.passkeyRepo.View(u.ID, id)
+	if err != nil {
+		return err
+	}
+	return s.passkeyRepo.Delete(passkey)
+}
+
+// Purge deletes the ceremonies which expired, once they no longer count towards the login rate limit
+func (s *Service) Purge() (int, error) {
+	return s.passkeyRepo.PurgeChallenges(now().Add(-s.config.LoginRateWindow))
+}
+
+func (s *Service) newChallenge(userID int, ceremony, clientIP string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", apperr.Generic
+	}
+	_, err = s.passkeyRepo.CreateChallenge(&model.PasskeyChallenge{
+		UserID:    userID,
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		ClientIP:  clientIP,
+		ExpiresAt: now().Add(s.config.ChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// challenge returns the pending ceremony the client data answers and uses it up
+func (s *Service) challenge(clientDataJSON []byte, ceremony string, userID int) (*model.PasskeyChallenge, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, errMalformed
+	}
+	c, err := s.passkeyRepo.FindChallenge(clientData.Challenge)
+	if err != nil {
+		return nil, errExpired
+	}
+	// a challenge is used once, a concurrent request answering the same one loses
+	err = s.passkeyRepo.DeleteChallenge(c)
+	if e, ok := err.(*apperr.APPError); ok && e.Status == http.StatusConflict {
+		return nil, errExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Ceremony != ceremony || c.UserID != userID || now().After(c.ExpiresAt) {
+		return nil, errExpired
+	}
+	return c, nil
+}
+
+// userHandle is the WebAuthn user handle of a user, the authenticator returns it on login
+func userHandle(userID int) string {
+	return webauthn.Encode([]byte(strconv.Itoa(userID)))
+}
@@ -0,0 +1,403 @@
+package passkey_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/passkey"
+	"github.com/alpacahq/ribbit-backend/request"
+	"github.com/alpacahq/ribbit-backend/webauthn"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	cfg   = &config.PasskeyConfig{RPID: "alpa.ca", RPName: "Ribbit", Origins: []string{"https://alpa.ca"}, UserVerification: true, ChallengeTTL: 5 * time.Minute, LoginRateLimit: 3, LoginRateWindow: time.Minute}
+	today = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	alice = &model.User{ID: 1, Email: "alice@example.com", FirstName: "Alice", LastName: "Smith"}
+)
+
+func assertStatus(t *testing.T, status int, err error) {
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, status, err.(*apperr.APPError).Status)
+	}
+}
+
+// store is an in-memory passkey database, started keeps every challenge until it is purged.
+// With stale set used challenges are still found, like by a request racing the one using it
+type store struct {
+	passkeys   map[string]*model.Passkey
+	challenges map[string]*model.PasskeyChallenge
+	started    []*model.PasskeyChallenge
+	stale      bool
+}
+
+func newStore() (*store, *mockdb.Passkey) {
+	s := &store{passkeys: map[string]*model.Passkey{}, challenges: map[string]*model.PasskeyChallenge{}}
+	return s, &mockdb.Passkey{
+		CreateFn: func(p *model.Passkey) (*model.Passkey, error) {
+			p.ID = len(s.passkeys) + 1
+			s.passkeys[p.CredentialID] = p
+			return p, nil
+		},
+		FindByCredentialFn: func(id string) (*model.Passkey, error) {
+			if p, ok := s.passkeys[id]; ok {
+				copy := *p
+				return &copy, nil
+			}
+			return nil, apperr.New(http.StatusNotFound, "Passkey not found.")
+		},
+		ListByUserFn: func(userID int) ([]model.Passkey, error) {
+			var passkeys []model.Passkey
+			for _, p := range s.passkeys {
+				if p.UserID == userID {
+					passkeys = append(passkeys, *p)
+				}
+			}
+			return passkeys, nil
+		},
+		UpdateSignCountFn: func(p *model.Passkey) error {
+			s.passkeys[p.CredentialID] = p
+			return nil
+		},
+		CreateChallengeFn: func(c *model.PasskeyChallenge) (*model.PasskeyChallenge, error) {
+			c.CreatedAt = c.ExpiresAt.Add(-cfg.ChallengeTTL)
+			s.challenges[c.Challenge] = c
+			s.started = append(s.started, c)
+			return c, nil
+		},
+		CountChallengesFn: func(clientIP string, since time.Time) (int, error) {
+			count := 0
+			for _, c := range s.started {
+				if c.ClientIP == clientIP && c.Ceremony == model.PasskeyLogin && !c.CreatedAt.Before(since) {
+					count++
+				}
+			}
+			return count, nil
+		},
+		PurgeChallengesFn: func(before time.Time) (int, error) {
+			var kept []*model.PasskeyChallenge
+			for _, c := range s.started {
+				if !c.ExpiresAt.Before(before) {
+					kept = append(kept, c)
+				}
+			}
+			purged := len(s.started) - len(kept)
+			s.started = kept
+			return purged, nil
+		},
+		FindChallengeFn: func(challenge string) (*model.PasskeyChallenge, error) {
+			if c, ok := s.challenges[challenge]; ok && (c.DeletedAt == nil || s.stale) {
+				return c, nil
+			}
+			return nil, apperr.New(http.StatusUnauthorized, "Passkey request expired. Please try again.")
+		},
+		DeleteChallengeFn: func(c *model.PasskeyChallenge) error {
+			if c.DeletedAt != nil {
+				return apperr.New(http.StatusConflict, "Challenge was already used.")
+			}
+			used := time.Now()
+			c.DeletedAt = &used
+			return nil
+		},
+	}
+}
+
+func registration(att *webauthn.Attestation) *request.PasskeyRegistration {
+	return &request.PasskeyRegistration{
+		Name: "iPhone",
+		ID:   webauthn.Encode(att.CredentialID),
+		Response: request.AttestationResponse{
+			ClientDataJSON:    webauthn.Encode(att.ClientDataJSON),
+			AttestationObject: webauthn.Encode(att.AttestationObject),
+		},
+	}
+}
+
+func assertion(as *webauthn.Assertion) *request.PasskeyAssertion {
+	return &request.PasskeyAssertion{
+		ID: webauthn.Encode(as.CredentialID),
+		Response: request.AssertionResponse{
+			ClientDataJSON:    webauthn.Encode(as.ClientDataJSON),
+			AuthenticatorData: webauthn.Encode(as.AuthenticatorData),
+			Signature:         webauthn.Encode(as.Signature),
+			UserHandle:        webauthn.Encode(as.UserHandle),
+		},
+	}
+}
+
+func newService() (*passkey.Service, *store) {
+	return newServiceWith(&mock.SecondFactor{RequireFreshFn: func(int) error { return nil }})
+}
+
+func newServiceWith(secondFactor passkey.SecondFactor) (*passkey.Service, *store) {
+	db, repo := newStore()
+	users := &mockdb.User{
+		ViewFn: func(id int) (*model.User, error) {
+			if id != alice.ID {
+				return nil, apperr.New(http.StatusNotFound, "User not found.")
+			}
+			return alice, nil
+		},
+	}
+	return passkey.NewPasskeyService(users, repo, secondFactor, cfg, zap.NewNop()), db
+}
+
+// registered returns a service with a passkey of alice on the authenticator
+func registered(t *testing.T, a *webauthn.SoftAuthenticator) (*passkey.Service, *store, []byte) {
+	s, db := newService()
+	options, err := s.BeginRegistration(alice)
+	assert.NoError(t, err)
+	att, err := a.Create(options)
+	assert.NoError(t, err)
+	_, err = s.FinishRegistration(alice, registration(att))
+	assert.NoError(t, err)
+	return s, db, att.CredentialID
+}
+
+func TestRegistration(t *testing.T) {
+	defer passkey.SetNow(today)()
+	s, db := newService()
+	a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+
+	options, err := s.BeginRegistration(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, webauthn.RPEntity{ID: "alpa.ca", Name: "Ribbit"}, options.RP)
+	assert.Equal(t, webauthn.UserEntity{ID: webauthn.Encode([]byte("1")), Name: "alice@example.com", DisplayName: "Alice Smith"}, options.User)
+	assert.Empty(t, options.ExcludeCredentials)
+
+	att, err := a.Create(options)
+	assert.NoError(t, err)
+	p, err := s.FinishRegistration(alice, registration(att))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, p.UserID)
+	assert.Equal(t, "iPhone", p.Name)
+	assert.Equal(t, webauthn.Encode(att.CredentialID), p.CredentialID)
+	assert.NotEmpty(t, p.PublicKey)
+	// the challenge is used up
+	if assert.Len(t, db.challenges, 1) {
+		for _, c := range db.challenges {
+			assert.NotNil(t, c.DeletedAt)
+		}
+	}
+	_, err = s.FinishRegistration(alice, registration(att))
+	assertStatus(t, http.StatusUnauthorized, err)
+
+	// the passkey is excluded from the next registration
+	options, err = s.BeginRegistration(alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []webauthn.CredentialDescriptor{{Type: "public-key", ID: p.CredentialID}}, options.ExcludeCredentials)
+
+	// a registration started by another user can't be finished by alice
+	options, err = s.BeginRegistration(&model.User{ID: 2, Email: "bob@example.com"})
+	assert.NoError(t, err)
+	att, err = a.Create(options)
+	assert.NoError(t, err)
+	_, err = s.FinishRegistration(alice, registration(att))
+	assertStatus(t, http.StatusUnauthorized, err)
+
+	// nor after the challenge expired
+	options, err = s.BeginRegistration(alice)
+	assert.NoError(t, err)
+	att, err = a.Create(options)
+	assert.NoError(t, err)
+	defer passkey.SetNow(today.Add(6 * time.Minute))()
+	_, err = s.FinishRegistration(alice, registration(att))
+	assertStatus(t, http.StatusUnauthorized, err)
+}
+
+func TestRegistrationNeedsFreshSecondFactor(t *testing.T) {
+	defer passkey.SetNow(today)()
+	fresh := true
+	s, db := newServiceWith(&mock.SecondFactor{
+		RequireFreshFn: func(int) error {
+			if fresh {
+				return nil
+			}
+			return apperr.New(http.StatusForbidden, "Please confirm with a code from your authenticator app first.")
+		},
+	})
+	a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+
+	fresh = false
+	_, err := s.BeginRegistration(alice)
+	assertStatus(t, http.StatusForbidden, err)
+	assert.Empty(t, db.challenges)
+
+	// nor can a registration started with a fresh second factor be finished once it went stale
+	fresh = true
+	options, err := s.BeginRegistration(alice)
+	assert.NoError(t, err)
+	att, err := a.Create(options)
+	assert.NoError(t, err)
+	fresh = false
+	_, err = s.FinishRegistration(alice, registration(att))
+	assertStatus(t, http.StatusForbidden, err)
+	assert.Empty(t, db.passkeys)
+}
+
+func TestRegistrationRejected(t *testing.T) {
+	defer passkey.SetNow(today)()
+	cases := []struct {
+		name   string
+		device func(*webauthn.SoftAuthenticator)
+		req    func(*request.PasskeyRegistration)
+	}{
+		{name: "other origin", device: func(a *webauthn.SoftAuthenticator) { a.Origin = "https://evil.example" }},
+		{name: "other relying party", device: func(a *webauthn.SoftAuthenticator) { a.RPID = "evil.example" }},
+		{name: "user wasn't verified", device: func(a *webauthn.SoftAuthenticator) { a.UserVerified = false }},
+		{name: "other credential id", req: func(r *request.PasskeyRegistration) { r.ID = webauthn.Encode([]byte("other")) }},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newService()
+			a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+			if tt.device != nil {
+				tt.device(a)
+			}
+			options, err := s.BeginRegistration(alice)
+			assert.NoError(t, err)
+			att, err := a.Create(options)
+			assert.NoError(t, err)
+			r := registration(att)
+			if tt.req != nil {
+				tt.req(r)
+			}
+
+			_, err = s.FinishRegistration(alice, r)
+			assertStatus(t, http.StatusUnauthorized, err)
+			assert.Empty(t, db.passkeys)
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	defer passkey.SetNow(today)()
+	a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+	s, db, id := registered(t, a)
+
+	for i := 1; i <= 2; i++ {
+		options, err := s.BeginLogin("10.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, "alpa.ca", options.RPID)
+		as, err := a.Get(id, options.Challenge)
+		assert.NoError(t, err)
+
+		u, err := s.FinishLogin(assertion(as))
+		assert.NoError(t, err)
+		assert.Equal(t, alice, u)
+		p := db.passkeys[webauthn.Encode(id)]
+		assert.Equal(t, int64(i), p.SignCount)
+		assert.Equal(t, today, *p.LastUsedAt)
+
+		// an assertion logs in once
+		_, err = s.FinishLogin(assertion(as))
+		assertStatus(t, http.StatusUnauthorized, err)
+	}
+
+	// a registration challenge doesn't start a login
+	options, err := s.BeginRegistration(alice)
+	assert.NoError(t, err)
+	as, err := a.Get(id, options.Challenge)
+	assert.NoError(t, err)
+	_, err = s.FinishLogin(assertion(as))
+	assertStatus(t, http.StatusUnauthorized, err)
+}
+
+func TestChallengeUsedOnce(t *testing.T) {
+	defer passkey.SetNow(today)()
+	a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+	s, db, id := registered(t, a)
+	options, err := s.BeginLogin("10.0.0.1")
+	assert.NoError(t, err)
+	first, err := a.Get(id, options.Challenge)
+	assert.NoError(t, err)
+	second, err := a.Get(id, options.Challenge)
+	assert.NoError(t, err)
+
+	// the second login finds the challenge before the first used it up
+	db.stale = true
+	_, err = s.FinishLogin(assertion(first))
+	assert.NoError(t, err)
+	_, err = s.FinishLogin(assertion(second))
+	assertStatus(t, http.StatusUnauthorized, err)
+}
+
+func TestLoginRejected(t *testing.T) {
+	defer passkey.SetNow(today)()
+	cases := map[string]func(*request.PasskeyAssertion){
+		"other user handle": func(r *request.PasskeyAssertion) { r.Response.UserHandle = webauthn.Encode([]byte("2")) },
+		"unknown passkey":   func(r *request.PasskeyAssertion) { r.ID = webauthn.Encode([]byte("unknown")) },
+		"forged signature":  func(r *request.PasskeyAssertion) { r.Response.Signature = webauthn.Encode([]byte("forged")) },
+	}
+	for name, change := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+			s, _, id := registered(t, a)
+			options, err := s.BeginLogin("10.0.0.1")
+			assert.NoError(t, err)
+			as, err := a.Get(id, options.Challenge)
+			assert.NoError(t, err)
+			login := assertion(as)
+			change(login)
+
+			u, err := s.FinishLogin(login)
+			assert.Nil(t, u)
+			assertStatus(t, http.StatusUnauthorized, err)
+		})
+	}
+}
+
+func TestClonedPasskey(t *testing.T) {
+	defer passkey.SetNow(today)()
+	a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+	s, db, id := registered(t, a)
+	login := func() error {
+		options, err := s.BeginLogin("10.0.0.1")
+		assert.NoError(t, err)
+		as, err := a.Get(id, options.Challenge)
+		assert.NoError(t, err)
+		_, err = s.FinishLogin(assertion(as))
+		return err
+	}
+	assert.NoError(t, login())
+	assert.NoError(t, login())
+
+	// a copy of the key signs with a counter the original already used
+	a.SetSignCount(id, 1)
+	assertStatus(t, http.StatusUnauthorized, login())
+	assert.Equal(t, int64(2), db.passkeys[webauthn.Encode(id)].SignCount)
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	defer passkey.SetNow(today)()
+	s, db := newService()
+	for i := 0; i < cfg.LoginRateLimit; i++ {
+		_, err := s.BeginLogin("10.0.0.1")
+		assert.NoError(t, err)
+	}
+	_, err := s.BeginLogin("10.0.0.1")
+	assertStatus(t, http.StatusTooManyRequests, err)
+	_, err = s.BeginLogin("10.0.0.2")
+	assert.NoError(t, err)
+
+	// the limit applies per window
+	later := today.Add(cfg.LoginRateWindow + time.Second)
+	defer passkey.SetNow(later)()
+	_, err = s.BeginLogin("10.0.0.1")
+	assert.NoError(t, err)
+
+	// expired challenges are purged once they stopped counting
+	defer passkey.SetNow(today.Add(cfg.ChallengeTTL + cfg.LoginRateWindow + time.Second))()
+	purged, err := s.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.LoginRateLimit+1, purged)
+	assert.Len(t, db.started, 1)
+}
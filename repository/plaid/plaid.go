@@ -1,78 +1,35 @@
 package plaid
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"os"
-	"strings"
 
 	"github.com/alpacahq/ribbit-backend/request"
 
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mail"
 	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/secret"
 	"github.com/go-pg/pg/v9/orm"
-	"github.com/joho/godotenv"
 	"go.uber.org/zap"
-
-	"github.com/plaid/plaid-go/plaid"
-)
-
-var baseURL string = os.Getenv("BROKER_API_BASE")
-var token string = os.Getenv("BROKER_TOKEN")
-
-var (
-	PLAID_CLIENT_ID     = os.Getenv("PLAID_CLIENT_ID")
-	PLAID_SECRET        = os.Getenv("PLAID_SECRET")
-	PLAID_ENV           = os.Getenv("PLAID_ENV")
-	PLAID_PRODUCTS      = os.Getenv("PLAID_PRODUCTS")
-	PLAID_COUNTRY_CODES = os.Getenv("PLAID_COUNTRY_CODES")
-	PLAID_REDIRECT_URI  = os.Getenv("PLAID_REDIRECT_URI")
 )
 
-var environments = map[string]plaid.Environment{
-	"sandbox":     plaid.Sandbox,
-	"development": plaid.Development,
-	"production":  plaid.Production,
-}
-
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file. Did you copy .env.example to .env and fill it out?")
-	}
-}
-
-var client = func() *plaid.Client {
-	clientOptions := plaid.ClientOptions{
-		ClientID:    PLAID_CLIENT_ID,
-		Secret:      PLAID_SECRET,
-		Environment: environments[PLAID_ENV],
-		HTTPClient:  &http.Client{},
-	}
-	client, err := plaid.NewClient(clientOptions)
-	if err != nil {
-		panic(fmt.Errorf("unexpected error while initializing plaid client %w", err))
-	}
-	return client
-}()
-
-// NewAuthService creates new auth service
-func NewPlaidService(userRepo model.UserRepo, accountRepo model.AccountRepo, jwt JWT, db orm.DB, log *zap.Logger) *Service {
-	return &Service{userRepo, accountRepo, jwt, db, log}
+// NewPlaidService creates new plaid service
+func NewPlaidService(userRepo model.UserRepo, accountRepo model.AccountRepo, bankAccountRepo model.BankAccountRepo, provider model.BankLinkProvider, broker broker.Service, mail mail.Service, secondFactor SecondFactor, jwt JWT, db orm.DB, log *zap.Logger) *Service {
+	return &Service{userRepo, accountRepo, bankAccountRepo, provider, broker, mail, secondFactor, jwt, db, log}
 }
 
-// Service represents the auth application service
+// Service represents the plaid application service
 type Service struct {
-	userRepo    model.UserRepo
-	accountRepo model.AccountRepo
-	jwt         JWT
-	db          orm.DB
-	log         *zap.Logger
+	userRepo        model.UserRepo
+	accountRepo     model.AccountRepo
+	bankAccountRepo model.BankAccountRepo
+	provider        model.BankLinkProvider
+	broker          broker.Service
+	mail            mail.Service
+	secondFactor    SecondFactor
+	jwt             JWT
+	db              orm.DB
+	log             *zap.Logger
 }
 
 // JWT represents jwt interface
@@ -80,103 +37,160 @@ type JWT interface {
 	GenerateToken(*model.User) (string, string, error)
 }
 
+// SecondFactor checks users with two-factor authentication confirmed it recently
+type SecondFactor interface {
+	RequireFresh(userID int) error
+}
+
 func (s *Service) CreateLinkToken(c context.Context, accountID string, name string) (*model.PlaidAuthToken, error) {
-	countryCodes := strings.Split(PLAID_COUNTRY_CODES, ",")
-	products := strings.Split(PLAID_PRODUCTS, ",")
+	return s.createLinkToken(accountID, "")
+}
 
-	configs := plaid.LinkTokenConfigs{
-		User: &plaid.LinkTokenUser{
-			ClientUserID: accountID,
-		},
-		ClientName:   "Ribbit",
-		Products:     products,
-		CountryCodes: countryCodes,
-		Language:     "en",
+// CreateUpdateLinkToken creates a link token which opens Plaid Link in update mode for the item
+// of an existing bank account, so the user can repair the link after a login change or expiry
+func (s *Service) CreateUpdateLinkToken(u *model.User, relationshipID string) (*model.PlaidAuthToken, error) {
+	account, err := s.bankAccountRepo.FindByRelationship(u.ID, relationshipID)
+	if err != nil {
+		return nil, err
 	}
+	return s.createLinkToken(u.AccountID, string(account.AccessToken))
+}
 
-	resp, err := client.CreateLinkToken(configs)
+// createLinkToken creates a link token for a new item, or for the item of accessToken in update mode
+func (s *Service) createLinkToken(accountID, accessToken string) (*model.PlaidAuthToken, error) {
+	linkToken, err := s.provider.CreateLinkToken(accountID, accessToken)
 	if err != nil {
 		return nil, err
 	}
 
 	return &model.PlaidAuthToken{
-		LinkToken: resp.LinkToken,
+		LinkToken: linkToken,
 	}, nil
 }
 
-func (s *Service) SetAccessToken(c context.Context, id int, accountID string, e *request.SetAccessToken) (interface{}, error) {
-	response, err := client.ExchangePublicToken(e.PublicToken)
+// SetAccessToken exchanges the public token of a finished Plaid link, attaches the chosen account
+// to the user's brokerage account and stores it as a linked bank account. Users with two-factor
+// authentication must have confirmed their second factor recently
+func (s *Service) SetAccessToken(c context.Context, id int, accountID string, e *request.SetAccessToken) (*model.BankAccount, error) {
+	if err := s.secondFactor.RequireFresh(id); err != nil {
+		return nil, err
+	}
+	item, err := s.provider.ExchangePublicToken(e.PublicToken)
 	if err != nil {
 		return nil, err
 	}
-
-	auth, err := client.GetAuth(response.AccessToken)
+	auth, err := s.provider.GetAuth(item.AccessToken, e.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := s.provider.GetIdentity(item.AccessToken, e.AccountID)
 	if err != nil {
 		return nil, err
 	}
 
-	var bank_account_number, bank_routing_number, account_owner_name, bank_account_type, bank_account_name string
-	bank_account_type = "CHECKING"
-	if len(auth.Numbers.ACH) > 0 {
-		for _, account := range auth.Numbers.ACH {
-			if e.AccountID == account.AccountID {
-				bank_routing_number = account.Routing
-				bank_account_number = account.Account
-			}
-		}
+	relationship, err := s.broker.CreateACHRelationship(accountID, &broker.ACHRelationshipRequest{
+		AccountOwnerName:  identity.OwnerName,
+		BankAccountType:   auth.AccountType,
+		BankAccountNumber: auth.AccountNumber,
+		BankRoutingNumber: auth.RoutingNumber,
+		Nickname:          identity.AccountName,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if bank_routing_number == "" || bank_account_number == "" {
-		return nil, errors.New("Bank routing/account number not found")
+	bankAccount, err := s.bankAccountRepo.Create(&model.BankAccount{
+		UserID:         id,
+		AccessToken:    secret.EncryptedString(item.AccessToken),
+		ItemID:         item.ItemID,
+		PlaidAccountID: e.AccountID,
+		AccountID:      accountID,
+		RelationshipID: relationship.ID,
+		BankName:       auth.InstitutionName,
+		AccountName:    identity.AccountName,
+		Mask:           auth.Mask,
+		Status:         relationship.Status,
+		NumbersHash:    model.HashBankNumbers(auth.RoutingNumber, auth.AccountNumber),
+	})
+	if err != nil {
+		// don't leave a relationship at the broker that we can't list or detach
+		if err := s.broker.DeleteACHRelationship(accountID, relationship.ID); err != nil {
+			s.log.Error("PlaidService Error", zap.String("relationship_id", relationship.ID), zap.Error(err))
+		}
+		return nil, err
 	}
+	return bankAccount, nil
+}
 
-	identity, err := client.GetIdentity(response.AccessToken)
+// ListBankAccounts returns the user's linked bank accounts with their latest status at the broker.
+// Relationships made before bank accounts were stored locally are listed from the broker alone.
+func (s *Service) ListBankAccounts(u *model.User) ([]model.BankAccount, error) {
+	accounts, err := s.bankAccountRepo.ListByUser(u.ID)
 	if err != nil {
 		return nil, err
 	}
+	relationships, err := s.broker.ListACHRelationships(u.AccountID)
+	if err != nil {
+		// the local copy is still useful while the broker is unavailable
+		s.log.Warn("PlaidService Error", zap.Int("user_id", u.ID), zap.Error(err))
+		return accounts, nil
+	}
 
-	if len(identity.Accounts) > 0 {
-		for _, ele := range identity.Accounts {
-			if ele.AccountID == e.AccountID {
-				bank_account_name = ele.Name
-				if len(ele.Owners) > 0 {
-					for _, owners := range ele.Owners {
-						account_owner_name = owners.Names[0]
-					}
-				}
+	atBroker := make(map[string]broker.ACHRelationship, len(relationships))
+	for _, r := range relationships {
+		atBroker[r.ID] = r
+	}
+	for i := range accounts {
+		status := model.BankAccountCanceled
+		if r, ok := atBroker[accounts[i].RelationshipID]; ok {
+			status = r.Status
+			delete(atBroker, r.ID)
+		}
+		if accounts[i].Status != status {
+			accounts[i].Status = status
+			if err := s.bankAccountRepo.UpdateStatus(&accounts[i]); err != nil {
+				s.log.Warn("PlaidService Error", zap.Int("bank_account_id", accounts[i].ID), zap.Error(err))
 			}
 		}
 	}
-
-	client := &http.Client{}
-	attachAccount, _ := json.Marshal(map[string]string{
-		"account_owner_name":  account_owner_name,
-		"bank_account_type":   bank_account_type,
-		"bank_account_number": bank_account_number,
-		"bank_routing_number": bank_routing_number,
-		"nickname":            bank_account_name,
-	})
-	attachAccountBody := bytes.NewBuffer(attachAccount)
-
-	req, err := http.NewRequest("POST", baseURL+"/v1/accounts/"+accountID+"/ach_relationships", attachAccountBody)
-	if err != nil {
-		return nil, errors.New("Something went wrong. Try again later.")
+	for _, r := range relationships {
+		if _, ok := atBroker[r.ID]; ok {
+			accounts = append(accounts, model.BankAccount{
+				UserID:         u.ID,
+				AccountID:      u.AccountID,
+				RelationshipID: r.ID,
+				AccountName:    r.Nickname,
+				Status:         r.Status,
+			})
+		}
 	}
+	return accounts, nil
+}
 
-	req.Header.Add("Authorization", token)
-	cardBody, err := client.Do(req)
-
+// DetachBankAccount removes the ACH relationship at the broker, soft deletes the bank account
+// and removes the bank link item once no other linked account uses it
+func (s *Service) DetachBankAccount(u *model.User, relationshipID string) error {
+	account, err := s.bankAccountRepo.FindByRelationship(u.ID, relationshipID)
 	if err != nil {
-		return nil, errors.New("Something went wrong. Try again later.")
+		// relationships made before bank accounts were stored locally only exist at the broker
+		return s.broker.DeleteACHRelationship(u.AccountID, relationshipID)
 	}
-
-	responseData, err := ioutil.ReadAll(cardBody.Body)
-	if err != nil {
-		return nil, errors.New("Something went wrong. Try again later.")
+	if err := s.broker.DeleteACHRelationship(u.AccountID, relationshipID); err != nil {
+		return err
+	}
+	if err := s.bankAccountRepo.Delete(account); err != nil {
+		return err
 	}
 
-	var responseObject interface{}
-	json.Unmarshal(responseData, &responseObject)
-
-	return responseObject, nil
+	if account.ItemID == "" {
+		return nil
+	}
+	remaining, err := s.bankAccountRepo.CountByItem(account.ItemID)
+	if err != nil || remaining > 0 {
+		return nil
+	}
+	if err := s.provider.RemoveItem(string(account.AccessToken)); err != nil {
+		s.log.Warn("PlaidService Error", zap.Int("bank_account_id", account.ID), zap.Error(err))
+	}
+	return nil
 }
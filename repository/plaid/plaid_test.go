@@ -0,0 +1,115 @@
+package plaid_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/banklink"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/plaid"
+	"github.com/alpacahq/ribbit-backend/request"
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSetAccessToken(t *testing.T) {
+	cases := []struct {
+		name      string
+		createErr error
+	}{
+		{name: "links the account"},
+		{name: "removes the relationship when it can't be stored", createErr: errors.New("db down")},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *broker.ACHRelationshipRequest
+			var deleted string
+			b := &mock.Broker{
+				CreateACHRelationshipFn: func(accountID string, r *broker.ACHRelationshipRequest) (*broker.ACHRelationship, error) {
+					sent = r
+					return &broker.ACHRelationship{ID: "rel-1", AccountID: accountID, Status: model.BankAccountQueued}, nil
+				},
+				DeleteACHRelationshipFn: func(accountID, relationshipID string) error {
+					deleted = relationshipID
+					return nil
+				},
+			}
+			bankAccounts := &mockdb.BankAccount{
+				CreateFn: func(a *model.BankAccount) (*model.BankAccount, error) {
+					if tt.createErr != nil {
+						return nil, tt.createErr
+					}
+					return a, nil
+				},
+			}
+			fresh := &mock.SecondFactor{RequireFreshFn: func(int) error { return nil }}
+			s := plaid.NewPlaidService(nil, nil, bankAccounts, banklink.NewFake(), b, nil, fresh, nil, nil, zap.NewNop())
+
+			account, err := s.SetAccessToken(context.Background(), 1, "acc-1", &request.SetAccessToken{PublicToken: "public-sandbox", AccountID: "plaid-acc"})
+			if tt.createErr != nil {
+				assert.Equal(t, tt.createErr, err)
+				assert.Equal(t, "rel-1", deleted)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "021000021", sent.BankRoutingNumber)
+			assert.Equal(t, "CHECKING", sent.BankAccountType)
+			assert.Equal(t, "Alberta Bobbeth Charleson", sent.AccountOwnerName)
+			assert.Equal(t, "rel-1", account.RelationshipID)
+			assert.Equal(t, "First Platypus Bank", account.BankName)
+			assert.Equal(t, "0000", account.Mask)
+			assert.NotEmpty(t, account.ItemID)
+			assert.Empty(t, deleted)
+		})
+	}
+}
+
+func TestDetachBankAccount(t *testing.T) {
+	fake := banklink.NewFake()
+	item, _ := fake.ExchangePublicToken("public-sandbox")
+	for _, remaining := range []int{1, 0} {
+		b := &mock.Broker{DeleteACHRelationshipFn: func(string, string) error { return nil }}
+		bankAccounts := &mockdb.BankAccount{
+			FindByRelationshipFn: func(userID int, relationshipID string) (*model.BankAccount, error) {
+				return &model.BankAccount{ID: 1, UserID: userID, RelationshipID: relationshipID, AccessToken: secret.EncryptedString(item.AccessToken), ItemID: item.ItemID}, nil
+			},
+			DeleteFn:      func(*model.BankAccount) error { return nil },
+			CountByItemFn: func(string) (int, error) { return remaining, nil },
+		}
+		s := plaid.NewPlaidService(nil, nil, bankAccounts, fake, b, nil, nil, nil, nil, zap.NewNop())
+
+		assert.NoError(t, s.DetachBankAccount(&model.User{ID: 1, AccountID: "acc-1"}, "rel-1"))
+		// the item is only removed once no other linked account uses it
+		assert.Equal(t, remaining > 0, fake.Linked(item.AccessToken))
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	fake := banklink.NewFake()
+	fake.WebhookSecret = "signed"
+	var status string
+	bankAccounts := &mockdb.BankAccount{
+		ListByItemFn: func(string) ([]model.BankAccount, error) {
+			return []model.BankAccount{{ID: 1, UserID: 1, ItemID: "item-1", ItemStatus: model.ItemPendingExpiration}}, nil
+		},
+		UpdateItemStatusFn: func(itemID, s string) error {
+			status = s
+			return nil
+		},
+	}
+	s := plaid.NewPlaidService(nil, nil, bankAccounts, fake, nil, nil, nil, nil, nil, zap.NewNop())
+	body := []byte(`{"webhook_type":"ITEM","webhook_code":"PENDING_EXPIRATION","item_id":"item-1"}`)
+
+	assert.Error(t, s.HandleWebhook("forged", body))
+	assert.Empty(t, status)
+
+	// the accounts are already flagged so nobody is notified again
+	assert.NoError(t, s.HandleWebhook("signed", body))
+	assert.Equal(t, model.ItemPendingExpiration, status)
+}
@@ -0,0 +1,116 @@
+package plaid
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"go.uber.org/zap"
+)
+
+var errInvalidWebhook = apperr.New(http.StatusUnauthorized, "Invalid webhook signature.")
+
+// webhookError is the error attached to a Plaid webhook
+type webhookError struct {
+	ErrorCode string `json:"error_code"`
+}
+
+// Webhook is the part of a Plaid webhook payload we act on
+type Webhook struct {
+	WebhookType string        `json:"webhook_type"`
+	WebhookCode string        `json:"webhook_code"`
+	ItemID      string        `json:"item_id"`
+	Error       *webhookError `json:"error"`
+}
+
+// itemStatus maps an ITEM webhook to the item status it puts the bank accounts in, ok is false for webhooks we ignore
+func (w *Webhook) itemStatus() (status string, ok bool) {
+	if w.WebhookType != "ITEM" {
+		return "", false
+	}
+	switch w.WebhookCode {
+	case "ERROR":
+		if w.Error != nil && w.Error.ErrorCode == "ITEM_LOGIN_REQUIRED" {
+			return model.ItemLoginRequired, true
+		}
+	case "PENDING_EXPIRATION":
+		return model.ItemPendingExpiration, true
+	case "USER_PERMISSION_REVOKED":
+		return model.ItemPermissionRevoked, true
+	case "LOGIN_REPAIRED":
+		return "", true
+	}
+	return "", false
+}
+
+// notifications holds the message sent to the user when their bank link reaches an item status
+var notifications = map[string]struct{ subject, body string }{
+	model.ItemLoginRequired: {
+		"Your linked bank needs attention",
+		"We can no longer access your linked bank account %s. Please open the app and log in to your bank again to keep using it for transfers.",
+	},
+	model.ItemPendingExpiration: {
+		"Your bank link is about to expire",
+		"The connection to your linked bank account %s expires soon. Please open the app and log in to your bank again to keep using it for transfers.",
+	},
+	model.ItemPermissionRevoked: {
+		"Your bank link was revoked",
+		"Access to your linked bank account %s was revoked at your bank. Please link it again in the app if you want to keep using it for transfers.",
+	},
+}
+
+// HandleWebhook verifies a Plaid webhook and flags the bank accounts of its item when the link needs the user's attention
+func (s *Service) HandleWebhook(signature string, body []byte) error {
+	if err := s.provider.VerifyWebhook(signature, body); err != nil {
+		s.log.Warn("PlaidService Error", zap.Error(err))
+		return errInvalidWebhook
+	}
+	w := new(Webhook)
+	if err := json.Unmarshal(body, w); err != nil {
+		return apperr.BadRequest
+	}
+	status, ok := w.itemStatus()
+	if !ok || w.ItemID == "" {
+		return nil
+	}
+
+	accounts, err := s.bankAccountRepo.ListByItem(w.ItemID)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+	if err := s.bankAccountRepo.UpdateItemStatus(w.ItemID, status); err != nil {
+		return err
+	}
+	for i := range accounts {
+		if accounts[i].ItemStatus != status {
+			s.notify(&accounts[i], status)
+		}
+	}
+	return nil
+}
+
+// notify emails the owner of a bank account about its new item status, failures are only logged
+func (s *Service) notify(account *model.BankAccount, status string) {
+	n, ok := notifications[status]
+	if !ok {
+		return
+	}
+	u, err := s.userRepo.View(account.UserID)
+	if err != nil || u.Email == "" {
+		return
+	}
+	name := account.BankName
+	if account.Mask != "" {
+		name += " ending in " + account.Mask
+	}
+	body := fmt.Sprintf(n.body, name)
+	if err := s.mail.SendWithDefaults(n.subject, u.Email, body, "<p>"+body+"</p>"); err != nil {
+		s.log.Warn("PlaidService Error", zap.Int("user_id", u.ID), zap.Error(err))
+	}
+}
@@ -34,6 +34,11 @@ func Merge(dst, src interface{}) {
 				}
 				f := d.Elem().FieldByName(fieldName)
 				if f.IsValid() {
+					// allow named types such as secret.EncryptedString to be set from their underlying type
+					if v.Elem().Type() != f.Type() && v.Elem().Type().ConvertibleTo(f.Type()) {
+						f.Set(v.Elem().Convert(f.Type()))
+						continue
+					}
 					f.Set(v.Elem())
 				}
 			}
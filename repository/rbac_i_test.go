@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/alpacahq/ribbit-backend/geo"
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository"
 	"github.com/alpacahq/ribbit-backend/repository/account"
@@ -84,7 +85,7 @@ func (suite *RBACTestSuite) TestRBAC() {
 	err := roleRepo.CreateRoles()
 	assert.Nil(suite.T(), err)
 
-	accountService := account.NewAccountService(userRepo, accountRepo, rbac, secret.New())
+	accountService := account.NewAccountService(userRepo, accountRepo, rbac, secret.New(), geo.New(), nil)
 	err = accountService.Create(c, &model.User{
 		CountryCode: "+65",
 		Mobile:      "91919191",
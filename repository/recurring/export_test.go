@@ -0,0 +1,9 @@
+package recurring
+
+import "time"
+
+// SetNow pins the clock used by the service, call the returned func to restore it
+func SetNow(t time.Time) func() {
+	now = func() time.Time { return t }
+	return func() { now = time.Now }
+}
@@ -0,0 +1,60 @@
+package recurring
+
+import "time"
+
+// BankHoliday reports whether the Federal Reserve is closed for a holiday on the date.
+// Holidays falling on a Sunday are observed the following Monday, those on a Saturday aren't moved.
+func BankHoliday(date time.Time) bool {
+	y, m, d := date.Date()
+	wd := date.Weekday()
+	fixed := func(month time.Month, day int) bool {
+		if m == month && d == day {
+			return true
+		}
+		// observed on monday when it falls on a sunday
+		return wd == time.Monday && m == month && d == day+1
+	}
+	nth := func(month time.Month, weekday time.Weekday, n int) bool {
+		return m == month && wd == weekday && (d-1)/7 == n-1
+	}
+	last := func(month time.Month, weekday time.Weekday) bool {
+		return m == month && wd == weekday && d+7 > daysIn(month, y)
+	}
+
+	switch {
+	case fixed(time.January, 1),
+		nth(time.January, time.Monday, 3),  // Martin Luther King Jr. Day
+		nth(time.February, time.Monday, 3), // Washington's Birthday
+		last(time.May, time.Monday),        // Memorial Day
+		y >= 2022 && fixed(time.June, 19),  // Juneteenth
+		fixed(time.July, 4),
+		nth(time.September, time.Monday, 1),  // Labor Day
+		nth(time.October, time.Monday, 2),    // Columbus Day
+		fixed(time.November, 11),             // Veterans Day
+		nth(time.November, time.Thursday, 4), // Thanksgiving
+		fixed(time.December, 25):
+		return true
+	}
+	return false
+}
+
+// BusinessDay reports whether ACH transfers settle on the date
+func BusinessDay(date time.Time) bool {
+	if wd := date.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	return !BankHoliday(date)
+}
+
+// NextBusinessDay returns the first business day on or after the date
+func NextBusinessDay(date time.Time) time.Time {
+	for !BusinessDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// daysIn returns the number of days in a month
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
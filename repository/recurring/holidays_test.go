@@ -0,0 +1,56 @@
+package recurring_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/repository/recurring"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBankHoliday(t *testing.T) {
+	holidays := []time.Time{
+		day(2021, time.January, 1),
+		day(2021, time.January, 18),  // MLK day
+		day(2021, time.February, 15), // Washington's Birthday
+		day(2021, time.May, 31),      // Memorial Day
+		day(2021, time.July, 5),      // July 4th observed on monday
+		day(2021, time.September, 6), // Labor Day
+		day(2021, time.October, 11),  // Columbus Day
+		day(2021, time.November, 11), // Veterans Day
+		day(2021, time.November, 25), // Thanksgiving
+		day(2022, time.June, 20),     // Juneteenth observed on monday
+		day(2022, time.December, 26), // Christmas observed on monday
+		day(2023, time.January, 2),   // New Year observed on monday
+	}
+	for _, d := range holidays {
+		assert.True(t, recurring.BankHoliday(d), d.Format("2006-01-02"))
+	}
+	workdays := []time.Time{
+		day(2021, time.January, 11),
+		day(2021, time.May, 24),
+		day(2021, time.June, 18),     // before Juneteenth was observed
+		day(2021, time.December, 24), // christmas on a saturday isn't moved to friday
+		day(2021, time.November, 26),
+	}
+	for _, d := range workdays {
+		assert.False(t, recurring.BankHoliday(d), d.Format("2006-01-02"))
+	}
+}
+
+func TestNextBusinessDay(t *testing.T) {
+	cases := []struct{ date, want time.Time }{
+		{day(2021, time.September, 15), day(2021, time.September, 15)},
+		{day(2021, time.September, 18), day(2021, time.September, 20)}, // saturday
+		{day(2021, time.September, 4), day(2021, time.September, 7)},   // saturday before Labor Day
+		{day(2021, time.December, 25), day(2021, time.December, 27)},
+	}
+	for _, tt := range cases {
+		assert.Equal(t, tt.want, recurring.NextBusinessDay(tt.date), tt.date.Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,284 @@
+package recurring
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mail"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"go.uber.org/zap"
+)
+
+var now = time.Now
+
+// Depositor makes a single deposit from a linked bank, it is implemented by the transfer service
+type Depositor interface {
+	Deposit(u *model.User, bankID string, amount float64) (*broker.Transfer, error)
+}
+
+// NewRecurringDepositService creates new recurring deposit service
+func NewRecurringDepositService(userRepo model.UserRepo, bankAccountRepo model.BankAccountRepo, recurringRepo model.RecurringDepositRepo, depositor Depositor, mail mail.Service, config *config.TransferConfig, log *zap.Logger) *Service {
+	return &Service{userRepo, bankAccountRepo, recurringRepo, depositor, mail, config, log}
+}
+
+// Service represents the recurring deposit application service
+type Service struct {
+	userRepo        model.UserRepo
+	bankAccountRepo model.BankAccountRepo
+	recurringRepo   model.RecurringDepositRepo
+	depositor       Depositor
+	mail            mail.Service
+	config          *config.TransferConfig
+	log             *zap.Logger
+}
+
+var (
+	errNoAccount    = apperr.New(http.StatusBadRequest, "Account not found.")
+	errInvalidDay   = apperr.New(http.StatusBadRequest, "Day must be a weekday from 0 (Sunday) to 6 for weekly deposits and from 1 to 31 for monthly ones.")
+	errBankInactive = apperr.New(http.StatusBadRequest, "The bank account isn't active.")
+	errNotSuspended = apperr.New(http.StatusBadRequest, "Recurring deposit isn't suspended.")
+)
+
+// Create schedules a recurring deposit from one of the user's linked banks, the first deposit is made on the next scheduled day
+func (s *Service) Create(u *model.User, r *request.RecurringDeposit) (*model.RecurringDeposit, error) {
+	if u.AccountID == "" {
+		return nil, errNoAccount
+	}
+	if !validDay(r.Frequency, r.Day) {
+		return nil, errInvalidDay
+	}
+	if reason := s.bankInactive(u.ID, r.BankID); reason != "" {
+		return nil, errBankInactive
+	}
+	today := date(now())
+	return s.recurringRepo.Create(&model.RecurringDeposit{
+		UserID:         u.ID,
+		RelationshipID: r.BankID,
+		Amount:         r.Amount,
+		Frequency:      r.Frequency,
+		Day:            r.Day,
+		NextDate:       firstDate(r.Frequency, r.Day, today),
+		Status:         model.RecurringActive,
+		ActiveSince:    now(),
+	})
+}
+
+// List returns the user's recurring deposits
+func (s *Service) List(u *model.User) ([]model.RecurringDeposit, error) {
+	return s.recurringRepo.ListByUser(u.ID)
+}
+
+// Runs returns the history of a recurring deposit of the user
+func (s *Service) Runs(u *model.User, id int) ([]model.RecurringDepositRun, error) {
+	d, err := s.recurringRepo.View(u.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.recurringRepo.ListRuns(d.ID)
+}
+
+// Resume reactivates a suspended recurring deposit, runs missed while it was suspended are skipped
+func (s *Service) Resume(u *model.User, id int) (*model.RecurringDeposit, error) {
+	d, err := s.recurringRepo.View(u.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status != model.RecurringSuspended {
+		return nil, errNotSuspended
+	}
+	if reason := s.bankInactive(u.ID, d.RelationshipID); reason != "" {
+		return nil, errBankInactive
+	}
+	d.NextDate = nextAfter(d, date(now()))
+	d.Status = model.RecurringActive
+	d.SuspendedReason = ""
+	d.ActiveSince = now()
+	if err := s.recurringRepo.Update(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Cancel deletes a recurring deposit of the user
+func (s *Service) Cancel(u *model.User, id int) error {
+	d, err := s.recurringRepo.View(u.ID, id)
+	if err != nil {
+		return err
+	}
+	return s.recurringRepo.Delete(d)
+}
+
+// RunDue makes the deposits due today and returns how many were submitted. A deposit
+// scheduled on a weekend or bank holiday is made on the next business day.
+func (s *Service) RunDue() (int, error) {
+	today := date(now())
+	if !BusinessDay(today) {
+		return 0, nil
+	}
+	due, err := s.recurringRepo.ListDue(today)
+	if err != nil {
+		return 0, err
+	}
+	submitted := 0
+	for i := range due {
+		ok, err := s.run(&due[i], today)
+		if err != nil {
+			s.log.Warn("RecurringDepositService Error", zap.Int("recurring_deposit_id", due[i].ID), zap.Error(err))
+			continue
+		}
+		if ok {
+			submitted++
+		}
+	}
+	return submitted, nil
+}
+
+// run makes one deposit of d and schedules the next one, it reports whether the deposit was submitted
+func (s *Service) run(d *model.RecurringDeposit, today time.Time) (bool, error) {
+	run := &model.RecurringDepositRun{RecurringDepositID: d.ID, UserID: d.UserID, ScheduledFor: d.NextDate}
+
+	reason := s.bankInactive(d.UserID, d.RelationshipID)
+	if reason == "" && s.config.RecurringDepositMaxReturns > 0 {
+		returns, err := s.recurringRepo.CountReturns(d.ID, d.ActiveSince)
+		if err != nil {
+			return false, err
+		}
+		if returns >= s.config.RecurringDepositMaxReturns {
+			reason = fmt.Sprintf("%d deposits were returned by the bank", returns)
+		}
+	}
+	if reason != "" {
+		run.Status = model.RecurringRunSkipped
+		run.Error = reason
+		if err := s.recurringRepo.CreateRun(run); err != nil {
+			return false, err
+		}
+		return false, s.suspend(d, reason)
+	}
+
+	u, err := s.userRepo.View(d.UserID)
+	if err != nil {
+		return false, err
+	}
+	// the run is claimed before any money moves, another server or a later tick skips it even if recording it fails
+	if err := s.recurringRepo.Claim(d, nextAfter(d, today)); err != nil {
+		if e, ok := err.(*apperr.APPError); ok && e.Status == http.StatusConflict {
+			return false, nil
+		}
+		return false, err
+	}
+	t, err := s.depositor.Deposit(u, d.RelationshipID, d.Amount)
+	if err != nil {
+		run.Status = model.RecurringRunFailed
+		run.Error = err.Error()
+		s.notify(u, "Your recurring deposit failed", fmt.Sprintf("We couldn't make your scheduled deposit of $%.2f. We'll try again on the next scheduled day.", d.Amount))
+	} else {
+		run.Status = model.RecurringRunSubmitted
+		run.TransferID = t.ID
+	}
+	if err := s.recurringRepo.CreateRun(run); err != nil {
+		s.log.Error("RecurringDepositService Error", zap.Int("recurring_deposit_id", d.ID), zap.String("transfer_id", run.TransferID), zap.Error(err))
+	}
+	return run.Status == model.RecurringRunSubmitted, nil
+}
+
+// suspend stops a recurring deposit until the user resumes it and tells them why
+func (s *Service) suspend(d *model.RecurringDeposit, reason string) error {
+	d.Status = model.RecurringSuspended
+	d.SuspendedReason = reason
+	if err := s.recurringRepo.Update(d); err != nil {
+		return err
+	}
+	s.log.Info("Recurring deposit suspended", zap.Int("recurring_deposit_id", d.ID), zap.String("reason", reason))
+	if u, err := s.userRepo.View(d.UserID); err == nil {
+		s.notify(u, "Your recurring deposit is paused", fmt.Sprintf("We paused your recurring deposit of $%.2f because %s. You can resume it in the app once your bank account is in order.", d.Amount, reason))
+	}
+	return nil
+}
+
+// bankInactive returns why the linked bank can't be used for recurring deposits, or an empty string when it can
+func (s *Service) bankInactive(userID int, relationshipID string) string {
+	account, err := s.bankAccountRepo.FindByRelationship(userID, relationshipID)
+	if err != nil {
+		return "the linked bank account was removed"
+	}
+	switch {
+	case account.Status == model.BankAccountCanceled:
+		return "the linked bank account was canceled"
+	case !account.Usable():
+		return "the linked bank account isn't verified"
+	case account.ItemStatus == model.ItemLoginRequired || account.ItemStatus == model.ItemPermissionRevoked:
+		return "the connection to the linked bank account needs attention"
+	}
+	return ""
+}
+
+// notify emails the user about their recurring deposit, failures are only logged
+func (s *Service) notify(u *model.User, subject, body string) {
+	if u.Email == "" {
+		return
+	}
+	if err := s.mail.SendWithDefaults(subject, u.Email, body, "<p>"+body+"</p>"); err != nil {
+		s.log.Warn("RecurringDepositService Error", zap.Int("user_id", u.ID), zap.Error(err))
+	}
+}
+
+// validDay checks day against the frequency
+func validDay(frequency string, day int) bool {
+	if frequency == model.RecurringMonthly {
+		return day >= 1 && day <= 31
+	}
+	return day >= 0 && day <= 6
+}
+
+// firstDate returns the first scheduled date after today
+func firstDate(frequency string, day int, today time.Time) time.Time {
+	if frequency == model.RecurringMonthly {
+		if first := monthDay(today.Year(), today.Month(), day); first.After(today) {
+			return first
+		}
+		return monthDay(today.Year(), today.Month()+1, day)
+	}
+	days := (day - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+// nextAfter returns the first scheduled date of d after today, skipping the dates missed
+func nextAfter(d *model.RecurringDeposit, today time.Time) time.Time {
+	next := date(d.NextDate)
+	for !next.After(today) {
+		switch d.Frequency {
+		case model.RecurringMonthly:
+			next = monthDay(next.Year(), next.Month()+1, d.Day)
+		case model.RecurringBiweekly:
+			next = next.AddDate(0, 0, 14)
+		default:
+			next = next.AddDate(0, 0, 7)
+		}
+	}
+	return next
+}
+
+// monthDay returns the day of the month, moved to the last day of shorter months
+func monthDay(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// date truncates t to midnight UTC
+func date(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
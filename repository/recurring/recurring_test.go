@@ -0,0 +1,233 @@
+package recurring_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/recurring"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type depositor func(u *model.User, bankID string, amount float64) (*broker.Transfer, error)
+
+func (d depositor) Deposit(u *model.User, bankID string, amount float64) (*broker.Transfer, error) {
+	return d(u, bankID, amount)
+}
+
+func TestCreate(t *testing.T) {
+	// a wednesday
+	defer recurring.SetNow(time.Date(2021, time.September, 15, 10, 0, 0, 0, time.UTC))()
+	cases := []struct {
+		name      string
+		frequency string
+		day       int
+		want      time.Time
+		wantErr   bool
+	}{
+		{name: "weekly later this week", frequency: model.RecurringWeekly, day: int(time.Friday), want: day(2021, time.September, 17)},
+		{name: "weekly on today's weekday", frequency: model.RecurringWeekly, day: int(time.Wednesday), want: day(2021, time.September, 22)},
+		{name: "biweekly next week", frequency: model.RecurringBiweekly, day: int(time.Monday), want: day(2021, time.September, 20)},
+		{name: "monthly later this month", frequency: model.RecurringMonthly, day: 20, want: day(2021, time.September, 20)},
+		{name: "monthly next month", frequency: model.RecurringMonthly, day: 1, want: day(2021, time.October, 1)},
+		{name: "monthly on a day september doesn't have", frequency: model.RecurringMonthly, day: 31, want: day(2021, time.September, 30)},
+		{name: "weekday out of range", frequency: model.RecurringWeekly, day: 7, wantErr: true},
+		{name: "day of month 0", frequency: model.RecurringMonthly, day: 0, wantErr: true},
+	}
+	bankAccounts := &mockdb.BankAccount{
+		FindByRelationshipFn: func(userID int, relationshipID string) (*model.BankAccount, error) {
+			return &model.BankAccount{UserID: userID, RelationshipID: relationshipID, Status: model.BankAccountApproved}, nil
+		},
+	}
+	repo := &mockdb.RecurringDeposit{
+		CreateFn: func(d *model.RecurringDeposit) (*model.RecurringDeposit, error) { return d, nil },
+	}
+	s := recurring.NewRecurringDepositService(nil, bankAccounts, repo, nil, nil, &config.TransferConfig{}, zap.NewNop())
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := s.Create(&model.User{ID: 1, AccountID: "acc-1"}, &request.RecurringDeposit{BankID: "rel-1", Amount: 50, Frequency: tt.frequency, Day: tt.day})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, d.NextDate)
+			assert.Equal(t, model.RecurringActive, d.Status)
+		})
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	cases := []struct {
+		name       string
+		now        time.Time
+		deposit    model.RecurringDeposit
+		bank       *model.BankAccount
+		returns    int
+		depositErr error
+		claimed    bool
+		submitted  int
+		runStatus  string
+		nextDate   time.Time
+		status     string
+		emails     int
+	}{
+		{
+			name:      "weekly deposit due today",
+			now:       day(2021, time.September, 15),
+			deposit:   model.RecurringDeposit{Frequency: model.RecurringWeekly, Day: int(time.Wednesday), NextDate: day(2021, time.September, 15)},
+			submitted: 1,
+			runStatus: model.RecurringRunSubmitted,
+			nextDate:  day(2021, time.September, 22),
+			status:    model.RecurringActive,
+		},
+		{
+			name:     "nothing runs on a bank holiday",
+			now:      day(2021, time.September, 6),
+			deposit:  model.RecurringDeposit{Frequency: model.RecurringMonthly, Day: 6, NextDate: day(2021, time.September, 6)},
+			nextDate: day(2021, time.September, 6),
+			status:   model.RecurringActive,
+		},
+		{
+			name:      "scheduled on a holiday runs the next business day",
+			now:       day(2021, time.September, 7),
+			deposit:   model.RecurringDeposit{Frequency: model.RecurringMonthly, Day: 6, NextDate: day(2021, time.September, 6)},
+			submitted: 1,
+			runStatus: model.RecurringRunSubmitted,
+			nextDate:  day(2021, time.October, 6),
+			status:    model.RecurringActive,
+		},
+		{
+			name:      "missed runs are skipped",
+			now:       day(2021, time.September, 15),
+			deposit:   model.RecurringDeposit{Frequency: model.RecurringBiweekly, Day: int(time.Monday), NextDate: day(2021, time.August, 16)},
+			submitted: 1,
+			runStatus: model.RecurringRunSubmitted,
+			nextDate:  day(2021, time.September, 27),
+			status:    model.RecurringActive,
+		},
+		{
+			name:     "run claimed by another server",
+			now:      day(2021, time.September, 15),
+			deposit:  model.RecurringDeposit{Frequency: model.RecurringWeekly, Day: int(time.Wednesday), NextDate: day(2021, time.September, 15)},
+			claimed:  true,
+			nextDate: day(2021, time.September, 15),
+			status:   model.RecurringActive,
+		},
+		{
+			name:       "failed deposit is recorded",
+			now:        day(2021, time.September, 15),
+			deposit:    model.RecurringDeposit{Frequency: model.RecurringWeekly, Day: int(time.Wednesday), NextDate: day(2021, time.September, 15)},
+			depositErr: errors.New("over the limit"),
+			runStatus:  model.RecurringRunFailed,
+			nextDate:   day(2021, time.September, 22),
+			status:     model.RecurringActive,
+			emails:     1,
+		},
+		{
+			name:      "inactive bank suspends",
+			now:       day(2021, time.September, 15),
+			deposit:   model.RecurringDeposit{Frequency: model.RecurringWeekly, Day: int(time.Wednesday), NextDate: day(2021, time.September, 15)},
+			bank:      &model.BankAccount{Status: model.BankAccountCanceled},
+			runStatus: model.RecurringRunSkipped,
+			nextDate:  day(2021, time.September, 15),
+			status:    model.RecurringSuspended,
+			emails:    1,
+		},
+		{
+			name:      "repeated returns suspend",
+			now:       day(2021, time.September, 15),
+			deposit:   model.RecurringDeposit{Frequency: model.RecurringWeekly, Day: int(time.Wednesday), NextDate: day(2021, time.September, 15)},
+			returns:   2,
+			runStatus: model.RecurringRunSkipped,
+			nextDate:  day(2021, time.September, 15),
+			status:    model.RecurringSuspended,
+			emails:    1,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			defer recurring.SetNow(tt.now.Add(9 * time.Hour))()
+			d := tt.deposit
+			d.ID, d.UserID, d.RelationshipID, d.Amount, d.Status = 1, 1, "rel-1", 50, model.RecurringActive
+			bank := tt.bank
+			if bank == nil {
+				bank = &model.BankAccount{Status: model.BankAccountApproved}
+			}
+			var runs []*model.RecurringDepositRun
+			emails := 0
+
+			repo := &mockdb.RecurringDeposit{
+				ListDueFn: func(date time.Time) ([]model.RecurringDeposit, error) {
+					if d.NextDate.After(date) {
+						return nil, nil
+					}
+					return []model.RecurringDeposit{d}, nil
+				},
+				CountReturnsFn: func(int, time.Time) (int, error) { return tt.returns, nil },
+				CreateRunFn: func(r *model.RecurringDepositRun) error {
+					runs = append(runs, r)
+					return nil
+				},
+				UpdateFn: func(updated *model.RecurringDeposit) error {
+					d = *updated
+					return nil
+				},
+				ClaimFn: func(claimed *model.RecurringDeposit, next time.Time) error {
+					if tt.claimed || !claimed.NextDate.Equal(d.NextDate) {
+						return apperr.New(http.StatusConflict, "Recurring deposit already ran.")
+					}
+					claimed.NextDate = next
+					d = *claimed
+					return nil
+				},
+			}
+			bankAccounts := &mockdb.BankAccount{
+				FindByRelationshipFn: func(int, string) (*model.BankAccount, error) { return bank, nil },
+			}
+			users := &mockdb.User{
+				ViewFn: func(id int) (*model.User, error) {
+					return &model.User{ID: id, Email: "user@example.com", AccountID: "acc-1"}, nil
+				},
+			}
+			deposit := depositor(func(u *model.User, bankID string, amount float64) (*broker.Transfer, error) {
+				assert.False(t, tt.claimed)
+				if tt.depositErr != nil {
+					return nil, tt.depositErr
+				}
+				return &broker.Transfer{ID: "t-1", Amount: "50.00"}, nil
+			})
+			m := &mock.Mail{SendWithDefaultsFn: func(string, string, string, string) error {
+				emails++
+				return nil
+			}}
+			s := recurring.NewRecurringDepositService(users, bankAccounts, repo, deposit, m, &config.TransferConfig{RecurringDepositMaxReturns: 2}, zap.NewNop())
+
+			submitted, err := s.RunDue()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.submitted, submitted)
+			assert.Equal(t, tt.nextDate, d.NextDate)
+			assert.Equal(t, tt.status, d.Status)
+			assert.Equal(t, tt.emails, emails)
+			if tt.runStatus == "" {
+				assert.Empty(t, runs)
+				return
+			}
+			if assert.Len(t, runs, 1) {
+				assert.Equal(t, tt.runStatus, runs[0].Status)
+				assert.Equal(t, tt.deposit.NextDate, runs[0].ScheduledFor)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewRecurringDepositRepo returns a new RecurringDepositRepo instance
+func NewRecurringDepositRepo(db orm.DB, log *zap.Logger) *RecurringDepositRepo {
+	return &RecurringDepositRepo{db, log}
+}
+
+// RecurringDepositRepo is the client for our recurring deposit model
+type RecurringDepositRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// Create creates a new recurring deposit
+func (r *RecurringDepositRepo) Create(deposit *model.RecurringDeposit) (*model.RecurringDeposit, error) {
+	if err := r.db.Insert(deposit); err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return deposit, nil
+}
+
+// View returns a recurring deposit of a user
+func (r *RecurringDepositRepo) View(userID, id int) (*model.RecurringDeposit, error) {
+	var deposit = new(model.RecurringDeposit)
+	sql := `SELECT * FROM recurring_deposits WHERE (id = ? and user_id = ? and deleted_at is null) LIMIT 1`
+	_, err := r.db.QueryOne(deposit, sql, id, userID)
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Recurring deposit not found.")
+	}
+	return deposit, nil
+}
+
+// ListByUser returns the recurring deposits of a user
+func (r *RecurringDepositRepo) ListByUser(userID int) ([]model.RecurringDeposit, error) {
+	var deposits []model.RecurringDeposit
+	sql := `SELECT * FROM recurring_deposits WHERE (user_id = ? and deleted_at is null) ORDER BY id`
+	_, err := r.db.Query(&deposits, sql, userID)
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return deposits, nil
+}
+
+// ListDue returns the active recurring deposits scheduled on or before date
+func (r *RecurringDepositRepo) ListDue(date time.Time) ([]model.RecurringDeposit, error) {
+	var deposits []model.RecurringDeposit
+	sql := `SELECT * FROM recurring_deposits WHERE (status = ? and next_date <= ? and deleted_at is null) ORDER BY next_date, id`
+	_, err := r.db.Query(&deposits, sql, model.RecurringActive, date)
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return deposits, nil
+}
+
+// Update saves the schedule and status of a recurring deposit, unless it was canceled meanwhile
+func (r *RecurringDepositRepo) Update(deposit *model.RecurringDeposit) error {
+	res, err := r.db.Model(deposit).
+		Column("next_date", "last_run_at", "status", "suspended_reason", "active_since", "updated_at").
+		WherePK().
+		Where("deleted_at is null").
+		Update()
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusNotFound, "Recurring deposit not found.")
+	}
+	return nil
+}
+
+// Claim moves an active recurring deposit on to its next date before the deposit is made, only one
+// caller can claim a run so a date is never deposited twice
+func (r *RecurringDepositRepo) Claim(deposit *model.RecurringDeposit, next time.Time) error {
+	t := time.Now()
+	res, err := r.db.Model(deposit).
+		Set("next_date = ?", next).
+		Set("last_run_at = ?", t).
+		Set("updated_at = ?", t).
+		WherePK().
+		Where("next_date = ? and status = ? and deleted_at is null", deposit.NextDate, model.RecurringActive).
+		Update()
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusConflict, "Recurring deposit already ran.")
+	}
+	deposit.NextDate = next
+	deposit.LastRunAt = &t
+	return nil
+}
+
+// Delete sets deleted_at for a recurring deposit
+func (r *RecurringDepositRepo) Delete(deposit *model.RecurringDeposit) error {
+	deposit.Delete()
+	_, err := r.db.Model(deposit).Column("deleted_at").WherePK().Update()
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// CreateRun records a run of a recurring deposit
+func (r *RecurringDepositRepo) CreateRun(run *model.RecurringDepositRun) error {
+	if err := r.db.Insert(run); err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// ListRuns returns the runs of a recurring deposit, newest first
+func (r *RecurringDepositRepo) ListRuns(recurringDepositID int) ([]model.RecurringDepositRun, error) {
+	var runs []model.RecurringDepositRun
+	sql := `SELECT * FROM recurring_deposit_runs WHERE (recurring_deposit_id = ? and deleted_at is null) ORDER BY id DESC`
+	_, err := r.db.Query(&runs, sql, recurringDepositID)
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return runs, nil
+}
+
+// CountReturns returns how many deposits of a recurring deposit made since the given time were returned by the bank
+func (r *RecurringDepositRepo) CountReturns(recurringDepositID int, since time.Time) (int, error) {
+	var count int
+	sql := `SELECT count(*) FROM recurring_deposit_runs r JOIN transfers t ON t.transfer_id = r.transfer_id
+		WHERE r.recurring_deposit_id = ? AND r.created_at >= ? AND t.status = ? AND t.deleted_at IS NULL`
+	_, err := r.db.QueryOne(pg.Scan(&count), sql, recurringDepositID, since, model.TransferStatusReturned)
+	if err != nil {
+		r.log.Warn("RecurringDepositRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return count, nil
+}
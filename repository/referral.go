@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewReferralRepo returns a new ReferralRepo instance
+func NewReferralRepo(db orm.DB, log *zap.Logger) *ReferralRepo {
+	return &ReferralRepo{db, log}
+}
+
+// ReferralRepo is the client for the users referred by other users and the referral leaderboard
+type ReferralRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// a referred user counts as funded once one of their deposits completed
+const fundedSQL = `EXISTS (SELECT 1 FROM transfers AS t
+	WHERE t.user_id = u.id AND t.direction = ? AND t.status = ? AND t.deleted_at IS NULL)`
+
+// ListInvitees returns the users who signed up with the referrer's code, newest first, with the rewards
+// the referrer earned for each
+func (r *ReferralRepo) ListInvitees(referrer *model.User) ([]model.Invitee, error) {
+	var invitees []model.Invitee
+	sql := `SELECT u.first_name, u.last_name, u.account_status, u.created_at AS signed_up_at,
+		` + fundedSQL + ` AS funded,
+		(SELECT COALESCE(SUM(ur.reward_value), 0) FROM user_rewards AS ur
+			WHERE ur.user_id = ? AND ur.referee_id = u.id AND ur.status IN (?) AND ur.deleted_at IS NULL) AS pending,
+		(SELECT COALESCE(SUM(ur.reward_value), 0) FROM user_rewards AS ur
+			WHERE ur.user_id = ? AND ur.referee_id = u.id AND ur.status = ? AND ur.deleted_at IS NULL) AS paid
+		FROM users AS u
+		WHERE (u.referred_by = ? AND u.id <> ? AND u.deleted_at IS NULL)
+		ORDER BY u.created_at DESC`
+	_, err := r.db.Query(&invitees, sql,
+		model.TransferIncoming, model.TransferStatusComplete,
+		referrer.ID, pg.In([]string{model.RewardFailed, model.RewardDeadLetter, model.RewardInReview}),
+		referrer.ID, model.RewardPaid,
+		referrer.ReferralCode, referrer.ID)
+	if err != nil {
+		r.log.Warn("ReferralRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	for i := range invitees {
+		invitees[i].Name = model.DisplayName(invitees[i].FirstName, invitees[i].LastName)
+		invitees[i].SetStage()
+	}
+	return invitees, nil
+}
+
+// Rankings computes the limit users who referred the most users that funded their account, best first.
+// Ties go to the referrer who signed up first
+func (r *ReferralRepo) Rankings(limit int) ([]model.LeaderboardEntry, error) {
+	var rows []struct {
+		UserID    int
+		FirstName string
+		LastName  string
+		Referrals int
+	}
+	sql := `SELECT ref.id AS user_id, ref.first_name, ref.last_name, count(u.id) AS referrals
+		FROM users AS ref
+		JOIN users AS u ON u.referred_by = ref.referral_code AND u.id <> ref.id AND u.deleted_at IS NULL
+		WHERE (ref.referral_code <> '' AND ref.deleted_at IS NULL AND ` + fundedSQL + `)
+		GROUP BY ref.id
+		ORDER BY referrals DESC, ref.created_at, ref.id
+		LIMIT ?`
+	_, err := r.db.Query(&rows, sql, model.TransferIncoming, model.TransferStatusComplete, limit)
+	if err != nil {
+		r.log.Warn("ReferralRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	entries := make([]model.LeaderboardEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = model.LeaderboardEntry{
+			Rank:      i + 1,
+			UserID:    row.UserID,
+			Name:      model.DisplayName(row.FirstName, row.LastName),
+			Referrals: row.Referrals,
+		}
+	}
+	return entries, nil
+}
+
+// ReplaceLeaderboard swaps the published leaderboard for entries in one transaction
+func (r *ReferralRepo) ReplaceLeaderboard(entries []model.LeaderboardEntry) error {
+	err := inTx(r.db, func(db orm.DB) error {
+		if _, err := db.Exec(`DELETE FROM leaderboard_entries`); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return db.Insert(&entries)
+	})
+	if err != nil {
+		r.log.Warn("ReferralRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// Leaderboard returns the published leaderboard, best first
+func (r *ReferralRepo) Leaderboard() ([]model.LeaderboardEntry, error) {
+	var entries []model.LeaderboardEntry
+	sql := `SELECT * FROM leaderboard_entries WHERE (deleted_at is null) ORDER BY rank`
+	_, err := r.db.Query(&entries, sql)
+	if err != nil {
+		r.log.Warn("ReferralRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return entries, nil
+}
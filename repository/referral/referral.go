@@ -0,0 +1,106 @@
+package referral
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"go.uber.org/zap"
+)
+
+// NewReferralService creates new referral service
+func NewReferralService(referralRepo model.ReferralRepo, config *config.RewardConfig, log *zap.Logger) *Service {
+	return &Service{referralRepo, config, log}
+}
+
+// Service represents the referral application service
+type Service struct {
+	referralRepo model.ReferralRepo
+	config       *config.RewardConfig
+	log          *zap.Logger
+}
+
+// Link is a user's referral code and the link sharing it
+type Link struct {
+	Code string `json:"code"`
+	URL  string `json:"url"`
+}
+
+// Funnel counts the invitees who reached each stage, a funded invitee counts as KYC approved as well
+type Funnel struct {
+	SignedUp    int `json:"signed_up"`
+	KycApproved int `json:"kyc_approved"`
+	Funded      int `json:"funded"`
+}
+
+// Summary is a user's referral link, the funnel of the users they invited and the rewards earned for them
+type Summary struct {
+	Link
+	Funnel   Funnel          `json:"funnel"`
+	Pending  float64         `json:"pending"`
+	Paid     float64         `json:"paid"`
+	Invitees []model.Invitee `json:"invitees"`
+}
+
+// Link returns the referral link of a user, built from their referral code
+func (s *Service) Link(u *model.User) (*Link, error) {
+	if u.ReferralCode == "" {
+		return nil, apperr.New(http.StatusNotFound, "You don't have a referral code yet.")
+	}
+	return &Link{Code: u.ReferralCode, URL: s.config.LinkBase + u.ReferralCode}, nil
+}
+
+// Summary returns the referral link of a user with the users they invited
+func (s *Service) Summary(u *model.User) (*Summary, error) {
+	link, err := s.Link(u)
+	if err != nil {
+		return nil, err
+	}
+	invitees, err := s.referralRepo.ListInvitees(u)
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{Link: *link, Invitees: invitees}
+	if summary.Invitees == nil {
+		summary.Invitees = []model.Invitee{}
+	}
+	for _, i := range invitees {
+		summary.Funnel.SignedUp++
+		switch i.Stage {
+		case model.ReferralFunded:
+			summary.Funnel.Funded++
+			summary.Funnel.KycApproved++
+		case model.ReferralApproved:
+			summary.Funnel.KycApproved++
+		}
+		summary.Pending += i.Pending
+		summary.Paid += i.Paid
+	}
+	return summary, nil
+}
+
+// Leaderboard returns the published referral leaderboard
+func (s *Service) Leaderboard() ([]model.LeaderboardEntry, error) {
+	entries, err := s.referralRepo.Leaderboard()
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []model.LeaderboardEntry{}
+	}
+	return entries, nil
+}
+
+// RefreshLeaderboard recomputes the referral leaderboard and publishes it, it returns the number of entries
+func (s *Service) RefreshLeaderboard() (int, error) {
+	entries, err := s.referralRepo.Rankings(s.config.LeaderboardSize)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.referralRepo.ReplaceLeaderboard(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,75 @@
+package referral_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/referral"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var cfg = &config.RewardConfig{LinkBase: "https://alpa.ca/", LeaderboardSize: 3}
+
+func TestSummary(t *testing.T) {
+	invitees := []model.Invitee{
+		{AccountStatus: model.AccountStatusSubmitted},
+		{AccountStatus: model.AccountStatusApproved, Pending: 10},
+		{AccountStatus: model.AccountStatusActive, Funded: true, Paid: 15},
+		{AccountStatus: model.AccountStatusActive, Funded: true, Paid: 10, Pending: 5},
+	}
+	for i := range invitees {
+		invitees[i].SetStage()
+	}
+	repo := &mockdb.Referral{
+		ListInviteesFn: func(u *model.User) ([]model.Invitee, error) {
+			assert.Equal(t, "ALICE1", u.ReferralCode)
+			return invitees, nil
+		},
+	}
+	s := referral.NewReferralService(repo, cfg, zap.NewNop())
+
+	summary, err := s.Summary(&model.User{ID: 1, ReferralCode: "ALICE1"})
+	assert.NoError(t, err)
+	assert.Equal(t, referral.Link{Code: "ALICE1", URL: "https://alpa.ca/ALICE1"}, summary.Link)
+	assert.Equal(t, referral.Funnel{SignedUp: 4, KycApproved: 3, Funded: 2}, summary.Funnel)
+	assert.Equal(t, 15.0, summary.Pending)
+	assert.Equal(t, 25.0, summary.Paid)
+	assert.Equal(t, model.ReferralSignedUp, summary.Invitees[0].Stage)
+	assert.Equal(t, model.ReferralApproved, summary.Invitees[1].Stage)
+	assert.Equal(t, model.ReferralFunded, summary.Invitees[2].Stage)
+
+	_, err = s.Summary(&model.User{ID: 2})
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*apperr.APPError).Status)
+	}
+}
+
+func TestRefreshLeaderboard(t *testing.T) {
+	var published []model.LeaderboardEntry
+	repo := &mockdb.Referral{
+		RankingsFn: func(limit int) ([]model.LeaderboardEntry, error) {
+			assert.Equal(t, 3, limit)
+			return []model.LeaderboardEntry{
+				{Rank: 1, UserID: 7, Name: model.DisplayName("alice", "smith"), Referrals: 4},
+				{Rank: 2, UserID: 3, Name: model.DisplayName("", "Jones"), Referrals: 2},
+			}, nil
+		},
+		ReplaceLeaderboardFn: func(entries []model.LeaderboardEntry) error {
+			published = entries
+			return nil
+		},
+	}
+	s := referral.NewReferralService(repo, cfg, zap.NewNop())
+
+	n, err := s.RefreshLeaderboard()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "alice S.", published[0].Name)
+	assert.Equal(t, "Anonymous", published[1].Name)
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// uniqueViolation is the SQLSTATE postgres fails an insert with when it breaks a unique constraint
+const uniqueViolation = "23505"
+
+var errHasNewerVersion = apperr.New(http.StatusConflict, "The program already has a newer version.")
+
+// NewRewardRepo returns a new RewardRepo instance
+func NewRewardRepo(db orm.DB, log *zap.Logger) *RewardRepo {
+	return &RewardRepo{db, log}
+}
+
+// RewardRepo is the client for the referral program config and the rewards paid
+type RewardRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// InEffect returns the newest referral program in effect for users signing up at t
+func (r *RewardRepo) InEffect(at time.Time) (*model.Reward, error) {
+	var program = new(model.Reward)
+	sql := `SELECT * FROM rewards WHERE ((starts_at is null or starts_at <= ?) and (ends_at is null or ends_at > ?) and deleted_at is null)
+		ORDER BY id DESC LIMIT 1`
+	_, err := r.db.QueryOne(program, sql, at, at)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "No referral program is in effect.")
+	}
+	return program, nil
+}
+
+// ListPrograms returns every version of every referral program, newest first
+func (r *RewardRepo) ListPrograms() ([]model.Reward, error) {
+	var programs []model.Reward
+	sql := `SELECT * FROM rewards WHERE (deleted_at is null) ORDER BY id DESC`
+	_, err := r.db.Query(&programs, sql)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return programs, nil
+}
+
+// ViewProgram returns a single version of a referral program
+func (r *RewardRepo) ViewProgram(id int) (*model.Reward, error) {
+	var program = new(model.Reward)
+	sql := `SELECT * FROM rewards WHERE (id = ? and deleted_at is null) LIMIT 1`
+	_, err := r.db.QueryOne(program, sql, id)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Reward program not found.")
+	}
+	return program, nil
+}
+
+// CreateProgram adds a referral program, when it is a new version of previous that one ends as this one starts.
+// It fails with a conflict when previous already has a newer version
+func (r *RewardRepo) CreateProgram(program *model.Reward, previous *model.Reward) (*model.Reward, error) {
+	err := inTx(r.db, func(db orm.DB) error {
+		if previous != nil {
+			if _, err := db.Exec(`SELECT id FROM rewards WHERE id = ? FOR UPDATE`, previous.ID); err != nil {
+				return err
+			}
+			var successors int
+			_, err := db.QueryOne(pg.Scan(&successors), `SELECT count(*) FROM rewards WHERE previous_id = ? AND deleted_at IS NULL`, previous.ID)
+			if err != nil {
+				return err
+			}
+			if successors > 0 {
+				return errHasNewerVersion
+			}
+			if _, err := db.Model(previous).Column("ends_at", "updated_at").WherePK().Update(); err != nil {
+				return err
+			}
+		}
+		return db.Insert(program)
+	})
+	if err == errHasNewerVersion {
+		return nil, err
+	}
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return program, nil
+}
+
+// EndProgram saves the end date of a referral program
+func (r *RewardRepo) EndProgram(program *model.Reward) error {
+	_, err := r.db.Model(program).Column("ends_at", "updated_at").WherePK().Update()
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// SpentBudget returns the value of the rewards of a program paid or still owed
+func (r *RewardRepo) SpentBudget(programID int) (float64, error) {
+	var spent float64
+	sql := `SELECT COALESCE(SUM(reward_value), 0) FROM user_rewards
+		WHERE program_id = ? AND status NOT IN (?, ?) AND deleted_at IS NULL`
+	_, err := r.db.QueryOne(pg.Scan(&spent), sql, programID, model.RewardVoided, model.RewardSkipped)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return spent, nil
+}
+
+// FindReferrer returns the user owning a referral code
+func (r *RewardRepo) FindReferrer(referralCode string) (*model.User, error) {
+	var user = new(model.User)
+	sql := `SELECT "user".* FROM "users" AS "user" WHERE ("user"."referral_code" = ? and "user"."deleted_at" is null) LIMIT 1`
+	_, err := r.db.QueryOne(user, sql, strings.ToUpper(strings.TrimSpace(referralCode)))
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Referrer not found.")
+	}
+	return user, nil
+}
+
+// CountSignups returns how many users other than exceptUserID signed up with a referral code between from and to
+func (r *RewardRepo) CountSignups(referralCode string, from, to time.Time, exceptUserID int) (int, error) {
+	count, err := r.db.Model((*model.User)(nil)).
+		Where("upper(trim(referred_by)) = ?", strings.ToUpper(strings.TrimSpace(referralCode))).
+		Where("created_at >= ? and created_at <= ? and id <> ?", from, to, exceptUserID).
+		Where(notDeleted).
+		Count()
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return count, nil
+}
+
+// FindUserReward returns the reward of a type paid to a user for a referee, it returns nil when there is none
+func (r *RewardRepo) FindUserReward(userID, refereeID int, rewardType string) (*model.UserReward, error) {
+	var reward = new(model.UserReward)
+	sql := `SELECT * FROM user_rewards WHERE (user_id = ? and referee_id = ? and reward_type = ? and deleted_at is null) LIMIT 1`
+	res, err := r.db.Query(reward, sql, userID, refereeID, rewardType)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	if res.RowsReturned() == 0 {
+		return nil, nil
+	}
+	return reward, nil
+}
+
+// CountReferees returns for how many referred users, other than exceptRefereeID, a referrer was rewarded under a program
+func (r *RewardRepo) CountReferees(programID, referrerID, exceptRefereeID int) (int, error) {
+	var count int
+	sql := `SELECT count(DISTINCT referee_id) FROM user_rewards
+		WHERE program_id = ? AND user_id = ? AND referred_by = ? AND referee_id <> ? AND reward_value > 0 AND deleted_at IS NULL`
+	_, err := r.db.QueryOne(pg.Scan(&count), sql, programID, referrerID, referrerID, exceptRefereeID)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return count, nil
+}
+
+// CreateUserReward records a reward, it fails with a conflict when the recipient already has a reward of
+// its type for the referee. The reward's program is locked while check looks at how much of it was used
+// up and the reward is inserted, so concurrent payouts can't go over the program's limits together
+func (r *RewardRepo) CreateUserReward(reward *model.UserReward, check func(model.RewardUsage) error) (*model.UserReward, error) {
+	err := inTx(r.db, func(db orm.DB) error {
+		if _, err := db.Exec(`SELECT id FROM rewards WHERE id = ? FOR UPDATE`, reward.ProgramID); err != nil {
+			return err
+		}
+		if err := check(&RewardRepo{db, r.log}); err != nil {
+			return err
+		}
+		return db.Insert(reward)
+	})
+	if pgErr, ok := err.(pg.Error); ok && pgErr.Field('C') == uniqueViolation {
+		return nil, apperr.New(http.StatusConflict, "Reward was already recorded.")
+	}
+	if e, ok := err.(*apperr.APPError); ok {
+		return nil, e
+	}
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return reward, nil
+}
+
+// UpdateUserReward stores the outcome of a payout, only if the reward is still in one of the from statuses.
+// It fails with a conflict when another payout or an admin changed the reward first
+func (r *RewardRepo) UpdateUserReward(reward *model.UserReward, from ...string) error {
+	res, err := r.db.Model(reward).
+		Column(
+			"journal_id",
+			"journal_status",
+			"symbol",
+			"order_id",
+			"reward_transfer_status",
+			"error_response",
+			"status",
+			"attempts",
+			"next_attempt_at",
+			"updated_at",
+		).
+		WherePK().
+		Where("status IN (?)", pg.In(from)).
+		Update()
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusConflict, "Reward was changed in the meantime.")
+	}
+	return nil
+}
+
+// ClaimUserReward marks a reward as processing, only if it is still in the status it was loaded with.
+// It fails with a conflict when another payout claimed the reward first
+func (r *RewardRepo) ClaimUserReward(reward *model.UserReward) error {
+	res, err := r.db.Model(reward).
+		Set("status = ?", model.RewardProcessing).
+		Set("updated_at = ?", time.Now()).
+		WherePK().
+		Where("status = ?", reward.Status).
+		Update()
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusConflict, "Reward is already being paid.")
+	}
+	reward.Status = model.RewardProcessing
+	return nil
+}
+
+// ViewUserReward returns a single reward
+func (r *RewardRepo) ViewUserReward(id int) (*model.UserReward, error) {
+	var reward = &model.UserReward{ID: id}
+	if err := r.db.Select(reward); err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Reward not found.")
+	}
+	return reward, nil
+}
+
+// ListDueRewards returns the failed rewards whose next attempt is due
+func (r *RewardRepo) ListDueRewards(now time.Time) ([]model.UserReward, error) {
+	var rewards []model.UserReward
+	sql := `SELECT * FROM user_rewards WHERE (status = ? and next_attempt_at <= ? and deleted_at is null) ORDER BY next_attempt_at`
+	_, err := r.db.Query(&rewards, sql, model.RewardFailed, now)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return rewards, nil
+}
+
+// ListOutstandingRewards returns the failed and dead-lettered rewards, oldest first
+func (r *RewardRepo) ListOutstandingRewards() ([]model.UserReward, error) {
+	var rewards []model.UserReward
+	sql := `SELECT * FROM user_rewards WHERE (status in (?, ?) and deleted_at is null) ORDER BY id`
+	_, err := r.db.Query(&rewards, sql, model.RewardFailed, model.RewardDeadLetter)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return rewards, nil
+}
+
+// ListRewardsInReview returns the rewards held for manual review, oldest first
+func (r *RewardRepo) ListRewardsInReview() ([]model.UserReward, error) {
+	var rewards []model.UserReward
+	sql := `SELECT * FROM user_rewards WHERE (status = ? and deleted_at is null) ORDER BY id`
+	_, err := r.db.Query(&rewards, sql, model.RewardInReview)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return rewards, nil
+}
+
+// ListUnsettledRewards returns the paid rewards whose journal the broker hasn't executed yet, oldest first
+func (r *RewardRepo) ListUnsettledRewards() ([]model.UserReward, error) {
+	var rewards []model.UserReward
+	sql := `SELECT * FROM user_rewards WHERE (status = ? and journal_id <> '' and coalesce(journal_status, '') <> ? and deleted_at is null) ORDER BY id`
+	_, err := r.db.Query(&rewards, sql, model.RewardPaid, broker.JournalExecuted)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return rewards, nil
+}
+
+// ListStuckRewards returns the pending and processing rewards last updated before a time, oldest first
+func (r *RewardRepo) ListStuckRewards(before time.Time) ([]model.UserReward, error) {
+	var rewards []model.UserReward
+	sql := `SELECT * FROM user_rewards WHERE (status in (?, ?) and updated_at < ? and deleted_at is null) ORDER BY id`
+	_, err := r.db.Query(&rewards, sql, model.RewardPending, model.RewardProcessing, before)
+	if err != nil {
+		r.log.Warn("RewardRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return rewards, nil
+}
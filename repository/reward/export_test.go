@@ -0,0 +1,18 @@
+package reward
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SetNow pins the clock used by the service, call the returned func to restore it
+func SetNow(t time.Time) func() {
+	now = func() time.Time { return t }
+	return func() { now = time.Now }
+}
+
+// SetIntn pins the random picks of stock rewards, call the returned func to restore it
+func SetIntn(fn func(int) int) func() {
+	intn = fn
+	return func() { intn = rand.Intn }
+}
@@ -0,0 +1,113 @@
+package reward
+
+import (
+	"strings"
+
+	"github.com/alpacahq/ribbit-backend/model"
+)
+
+// Reasons recorded on rewards flagged by the fraud rules and the score each adds
+const (
+	sharedDevice     = "Referrer and referee share a device."
+	sharedBank       = "Referrer and referee linked the same bank account."
+	sharedSignupIP   = "Referrer and referee signed up from the same IP."
+	signupVelocity   = "Too many signups with the referral code in a short time."
+	disposableDomain = "Referee signed up with a disposable email domain."
+
+	strongSignal = 100
+	weakSignal   = 50
+)
+
+// assessment is the outcome of the fraud rules for a referral
+type assessment struct {
+	score   int
+	reasons []string
+}
+
+func (a *assessment) flag(score int, reason string) {
+	a.score += score
+	a.reasons = append(a.reasons, reason)
+}
+
+// rule adds to the assessment of the referral of referee by referrer
+type rule func(s *Service, referee, referrer *model.User, a *assessment) error
+
+var rules = []rule{deviceRule, bankRule, signupIPRule, velocityRule, disposableRule}
+
+// assess scores the referral of referee by referrer with every fraud rule
+func (s *Service) assess(referee, referrer *model.User) (*assessment, error) {
+	a := new(assessment)
+	for _, r := range rules {
+		if err := r(s, referee, referrer, a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func deviceRule(s *Service, referee, referrer *model.User, a *assessment) error {
+	if referee.DeviceID != "" && referee.DeviceID == referrer.DeviceID {
+		a.flag(strongSignal, sharedDevice)
+	}
+	return nil
+}
+
+func bankRule(s *Service, referee, referrer *model.User, a *assessment) error {
+	refereeBanks, err := s.bankAccountRepo.ListByUser(referee.ID)
+	if err != nil || len(refereeBanks) == 0 {
+		return err
+	}
+	referrerBanks, err := s.bankAccountRepo.ListByUser(referrer.ID)
+	if err != nil {
+		return err
+	}
+	for _, mine := range refereeBanks {
+		for _, theirs := range referrerBanks {
+			if mine.NumbersHash != "" && mine.NumbersHash == theirs.NumbersHash ||
+				mine.PlaidAccountID != "" && mine.PlaidAccountID == theirs.PlaidAccountID {
+				a.flag(strongSignal, sharedBank)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func signupIPRule(s *Service, referee, referrer *model.User, a *assessment) error {
+	if referee.SignupIP != "" && referee.SignupIP == referrer.SignupIP {
+		a.flag(weakSignal, sharedSignupIP)
+	}
+	return nil
+}
+
+func velocityRule(s *Service, referee, referrer *model.User, a *assessment) error {
+	if s.config.VelocityMax <= 0 {
+		return nil
+	}
+	// the window ends at the referee's signup, a referral assessed late isn't flagged for signups that came after it
+	count, err := s.rewardRepo.CountSignups(referee.ReferredBy, referee.CreatedAt.Add(-s.config.VelocityWindow), referee.CreatedAt, referee.ID)
+	if err != nil {
+		return err
+	}
+	// count leaves out the referee, who is one of the signups as well
+	if count+1 > s.config.VelocityMax {
+		a.flag(weakSignal, signupVelocity)
+	}
+	return nil
+}
+
+func disposableRule(s *Service, referee, referrer *model.User, a *assessment) error {
+	at := strings.LastIndex(referee.Email, "@")
+	if at < 0 {
+		return nil
+	}
+	domain := strings.ToLower(strings.TrimSpace(referee.Email[at+1:]))
+	for _, d := range s.config.DisposableDomains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" && (domain == d || strings.HasSuffix(domain, "."+d)) {
+			a.flag(weakSignal, disposableDomain)
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package reward_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestFraudRules(t *testing.T) {
+	defer reward.SetNow(today)()
+	fraudCfg := &config.RewardConfig{
+		SweepAccountID:    "sweep",
+		RetryBackoff:      15 * time.Minute,
+		MaxAttempts:       3,
+		FraudThreshold:    100,
+		VelocityMax:       5,
+		VelocityWindow:    24 * time.Hour,
+		DisposableDomains: []string{"mailinator.com", "yopmail.com"},
+	}
+	referrer := &model.User{ID: 1, AccountID: "acc-1", ReferralCode: "ALICE1", DeviceID: "device-1", SignupIP: "10.0.0.1"}
+
+	cases := []struct {
+		name     string
+		referee  *model.User
+		banks    map[int][]model.BankAccount
+		signups  int
+		score    int
+		reasons  []string
+		journals int
+	}{
+		{
+			name:     "clean referral",
+			referee:  &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1", Email: "bob@example.com", DeviceID: "device-2", SignupIP: "10.0.0.2"},
+			journals: 2,
+		},
+		{
+			name:    "shared device",
+			referee: &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1", Email: "bob@example.com", DeviceID: "device-1"},
+			score:   100,
+			reasons: []string{"Referrer and referee share a device."},
+		},
+		{
+			name:    "shared bank account",
+			referee: &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1", Email: "bob@example.com"},
+			banks: map[int][]model.BankAccount{
+				1: {{NumbersHash: model.HashBankNumbers("121000358", "000123456789")}},
+				2: {{NumbersHash: model.HashBankNumbers("021000021", "000987654321")}, {NumbersHash: model.HashBankNumbers("121000358", "000123456789")}},
+			},
+			score:   100,
+			reasons: []string{"Referrer and referee linked the same bank account."},
+		},
+		{
+			name:     "same signup IP alone is below the threshold",
+			referee:  &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1", Email: "bob@example.com", SignupIP: "10.0.0.1"},
+			score:    50,
+			reasons:  []string{"Referrer and referee signed up from the same IP."},
+			journals: 2,
+		},
+		{
+			name:    "disposable email and signup velocity",
+			referee: &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1", Email: "bob@Mailinator.com"},
+			signups: 5,
+			score:   100,
+			reasons: []string{"Too many signups with the referral code in a short time.", "Referee signed up with a disposable email domain."},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.referee.CreatedAt = today.Add(-time.Hour)
+			rewards := &ledger{}
+			journals := 0
+			rewardRepo := &mockdb.Reward{
+				InEffectFn: func(time.Time) (*model.Reward, error) {
+					return program, nil
+				},
+				FindReferrerFn: func(code string) (*model.User, error) {
+					return referrer, nil
+				},
+				CountSignupsFn: func(code string, from, to time.Time, exceptUserID int) (int, error) {
+					assert.Equal(t, "ALICE1", code)
+					assert.Equal(t, today.Add(-25*time.Hour), from)
+					assert.Equal(t, today.Add(-time.Hour), to)
+					assert.Equal(t, 2, exceptUserID)
+					return tt.signups, nil
+				},
+				FindUserRewardFn: func(int, int, string) (*model.UserReward, error) {
+					return nil, nil
+				},
+				CountRefereesFn: func(int, int, int) (int, error) {
+					return 0, nil
+				},
+				CreateUserRewardFn: rewards.create,
+				UpdateUserRewardFn: rewards.update,
+			}
+			bankRepo := &mockdb.BankAccount{
+				ListByUserFn: func(userID int) ([]model.BankAccount, error) {
+					return tt.banks[userID], nil
+				},
+			}
+			b := &mock.Broker{
+				CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+					journals++
+					return &broker.Journal{ID: "j-1", Status: "queued"}, nil
+				},
+			}
+			s := reward.NewRewardService(nil, rewardRepo, bankRepo, nil, b, fraudCfg, zap.NewNop())
+
+			assert.NoError(t, s.OnApproved(tt.referee))
+			assert.Equal(t, tt.journals, journals)
+			if assert.Len(t, rewards.rewards, 2) {
+				for _, r := range rewards.rewards {
+					assert.Equal(t, tt.score, r.FraudScore)
+					assert.Equal(t, tt.reasons, r.FraudReasons)
+					if tt.journals == 0 {
+						assert.Equal(t, model.RewardInReview, r.Status)
+						assert.Empty(t, r.JournalID)
+					} else {
+						assert.Equal(t, model.RewardPaid, r.Status)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	defer reward.SetNow(today)()
+	cases := []struct {
+		name    string
+		admin   bool
+		reward  model.UserReward
+		void    bool
+		status  int
+		outcome string
+	}{
+		{name: "not an admin", reward: model.UserReward{ID: 1, Status: model.RewardInReview}, status: http.StatusForbidden},
+		{name: "approve a failed reward", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardFailed}, status: http.StatusConflict},
+		{name: "approve", admin: true, reward: model.UserReward{ID: 1, UserID: 2, RewardValue: 7.5, Status: model.RewardInReview}, outcome: model.RewardPaid},
+		{name: "void", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardInReview}, void: true, outcome: model.RewardVoided},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rbac := &mock.RBAC{
+				EnforceRoleFn: func(*gin.Context, model.AccessRole) bool {
+					return tt.admin
+				},
+			}
+			userRepo := &mockdb.User{
+				ViewFn: func(id int) (*model.User, error) {
+					return &model.User{ID: id, AccountID: "acc-2"}, nil
+				},
+			}
+			rewardRepo := &mockdb.Reward{
+				ViewUserRewardFn: func(id int) (*model.UserReward, error) {
+					r := tt.reward
+					return &r, nil
+				},
+				UpdateUserRewardFn: func(*model.UserReward, ...string) error {
+					return nil
+				},
+				ClaimUserRewardFn: claim,
+			}
+			b := &mock.Broker{
+				CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+					assert.Equal(t, "acc-2", j.ToAccount)
+					assert.Equal(t, "7.50", j.Amount)
+					return &broker.Journal{ID: "j-1", Status: "queued"}, nil
+				},
+			}
+			s := reward.NewRewardService(userRepo, rewardRepo, nil, rbac, b, cfg, zap.NewNop())
+
+			var r *model.UserReward
+			var err error
+			if tt.void {
+				r, err = s.Void(nil, 1)
+			} else {
+				r, err = s.Approve(nil, 1)
+			}
+			if tt.status != 0 {
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outcome, r.Status)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package reward
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPrograms returns every version of every referral program, admins only
+func (s *Service) ListPrograms(c *gin.Context) ([]model.Reward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	return s.rewardRepo.ListPrograms()
+}
+
+// ViewProgram returns a version of a referral program, admins only
+func (s *Service) ViewProgram(c *gin.Context, id int) (*model.Reward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	return s.rewardRepo.ViewProgram(id)
+}
+
+// CreateProgram adds a referral program, admins only
+func (s *Service) CreateProgram(c *gin.Context, req *request.RewardProgram) (*model.Reward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	program, err := s.program(req)
+	if err != nil {
+		return nil, err
+	}
+	program.Version = 1
+	return s.rewardRepo.CreateProgram(program, nil)
+}
+
+// UpdateProgram adds a new version of a referral program, admins only. Users who signed up before the new
+// version starts keep being rewarded under the previous one, which ends as the new version starts.
+// Only the latest version can be updated
+func (s *Service) UpdateProgram(c *gin.Context, id int, req *request.RewardProgram) (*model.Reward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	previous, err := s.rewardRepo.ViewProgram(id)
+	if err != nil {
+		return nil, err
+	}
+	if previous.EndsAt != nil && !previous.EndsAt.After(now()) {
+		return nil, apperr.New(http.StatusConflict, "The program has ended.")
+	}
+	program, err := s.program(req)
+	if err != nil {
+		return nil, err
+	}
+	if previous.StartsAt != nil && !program.StartsAt.After(*previous.StartsAt) {
+		return nil, apperr.New(http.StatusBadRequest, "A new version must start after the previous one.")
+	}
+	program.Version = previous.Version + 1
+	program.PreviousID = previous.ID
+	if previous.EndsAt == nil || previous.EndsAt.After(*program.StartsAt) {
+		previous.EndsAt = program.StartsAt
+	}
+	return s.rewardRepo.CreateProgram(program, previous)
+}
+
+// EndProgram ends a referral program now, admins only. A program which hasn't started yet never takes effect
+func (s *Service) EndProgram(c *gin.Context, id int) (*model.Reward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	program, err := s.rewardRepo.ViewProgram(id)
+	if err != nil {
+		return nil, err
+	}
+	end := now()
+	if program.StartsAt != nil && program.StartsAt.After(end) {
+		end = *program.StartsAt
+	}
+	if program.EndsAt != nil && !program.EndsAt.After(end) {
+		return program, nil
+	}
+	program.EndsAt = &end
+	if err := s.rewardRepo.EndProgram(program); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// program validates a referral program request, the stocks of a stock reward must be fractionable since
+// the reward is a notional buy
+func (s *Service) program(req *request.RewardProgram) (*model.Reward, error) {
+	start := now()
+	if req.StartsAt != nil {
+		start = *req.StartsAt
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(start) {
+		return nil, apperr.New(http.StatusBadRequest, "A program must end after it starts.")
+	}
+	program := &model.Reward{
+		Name:                 req.Name,
+		StartsAt:             &start,
+		EndsAt:               req.EndsAt,
+		Type:                 req.Type,
+		Budget:               req.Budget,
+		PerAccountLimit:      req.PerAccountLimit,
+		ReferralKycReward:    req.ReferralKycReward,
+		ReferralSignupReward: req.ReferralSignupReward,
+		ReferreKycReward:     req.ReferreKycReward,
+	}
+	if req.Type != model.ProgramStock {
+		return program, nil
+	}
+	if len(req.Stocks) == 0 {
+		return nil, apperr.New(http.StatusBadRequest, "A stock reward needs at least one stock.")
+	}
+	for _, st := range req.Stocks {
+		asset, err := s.broker.GetAsset(strings.ToUpper(st.Symbol))
+		if err != nil {
+			return nil, err
+		}
+		if !asset.Tradable || !asset.Fractionable {
+			return nil, apperr.New(http.StatusBadRequest, asset.Symbol+" can't be bought in fractions.")
+		}
+		program.Stocks = append(program.Stocks, model.RewardStock{Symbol: asset.Symbol, Weight: st.Weight})
+	}
+	return program, nil
+}
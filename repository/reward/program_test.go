@@ -0,0 +1,146 @@
+package reward_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestUpdateProgram(t *testing.T) {
+	defer reward.SetNow(today)()
+	started := today.Add(-30 * 24 * time.Hour)
+	next := today.Add(24 * time.Hour)
+	ended := today.Add(-time.Hour)
+
+	cases := []struct {
+		name     string
+		admin    bool
+		previous *model.Reward
+		req      *request.RewardProgram
+		status   int
+	}{
+		{
+			name:     "not an admin",
+			previous: &model.Reward{ID: 1, Version: 1, StartsAt: &started},
+			req:      &request.RewardProgram{Name: "Spring", Type: model.ProgramCash},
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "program has ended",
+			admin:    true,
+			previous: &model.Reward{ID: 1, Version: 1, StartsAt: &started, EndsAt: &ended},
+			req:      &request.RewardProgram{Name: "Spring", Type: model.ProgramCash},
+			status:   http.StatusConflict,
+		},
+		{
+			name:     "stock which isn't fractionable",
+			admin:    true,
+			previous: &model.Reward{ID: 1, Version: 1, StartsAt: &started},
+			req:      &request.RewardProgram{Name: "Spring", Type: model.ProgramStock, Stocks: []request.RewardStock{{Symbol: "brk.a", Weight: 1}}},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "ends before it starts",
+			admin:    true,
+			previous: &model.Reward{ID: 1, Version: 1, StartsAt: &started},
+			req:      &request.RewardProgram{Name: "Spring", Type: model.ProgramCash, StartsAt: &next, EndsAt: &today},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "new version",
+			admin:    true,
+			previous: &model.Reward{ID: 1, Version: 1, StartsAt: &started},
+			req:      &request.RewardProgram{Name: "Spring", Type: model.ProgramStock, StartsAt: &next, Budget: 1000, Stocks: []request.RewardStock{{Symbol: "aapl", Weight: 2}}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var created, ended *model.Reward
+			rbac := &mock.RBAC{
+				EnforceRoleFn: func(c *gin.Context, role model.AccessRole) bool {
+					assert.Equal(t, model.AdminRole, role)
+					return tt.admin
+				},
+			}
+			rewardRepo := &mockdb.Reward{
+				ViewProgramFn: func(id int) (*model.Reward, error) {
+					p := *tt.previous
+					return &p, nil
+				},
+				CreateProgramFn: func(program, previous *model.Reward) (*model.Reward, error) {
+					created, ended = program, previous
+					return program, nil
+				},
+			}
+			b := &mock.Broker{
+				GetAssetFn: func(symbol string) (*broker.Asset, error) {
+					return &broker.Asset{Symbol: symbol, Tradable: true, Fractionable: symbol == "AAPL"}, nil
+				},
+			}
+			s := reward.NewRewardService(nil, rewardRepo, nil, rbac, b, cfg, zap.NewNop())
+
+			program, err := s.UpdateProgram(nil, 1, tt.req)
+			if tt.status != 0 {
+				assert.Nil(t, program)
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 2, created.Version)
+			assert.Equal(t, 1, created.PreviousID)
+			assert.Equal(t, []model.RewardStock{{Symbol: "AAPL", Weight: 2}}, created.Stocks)
+			// the previous version ends as the new one starts
+			assert.Equal(t, next, *ended.EndsAt)
+			assert.True(t, ended.InEffect(today))
+			assert.False(t, ended.InEffect(next))
+			assert.True(t, created.InEffect(next))
+		})
+	}
+}
+
+func TestEndProgram(t *testing.T) {
+	defer reward.SetNow(today)()
+	upcoming := today.Add(48 * time.Hour)
+	cases := []struct {
+		name    string
+		program *model.Reward
+		end     time.Time
+	}{
+		{name: "running", program: &model.Reward{ID: 1}, end: today},
+		{name: "not started yet", program: &model.Reward{ID: 1, StartsAt: &upcoming}, end: upcoming},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rbac := &mock.RBAC{
+				EnforceRoleFn: func(*gin.Context, model.AccessRole) bool { return true },
+			}
+			rewardRepo := &mockdb.Reward{
+				ViewProgramFn: func(id int) (*model.Reward, error) {
+					return tt.program, nil
+				},
+				EndProgramFn: func(*model.Reward) error {
+					return nil
+				},
+			}
+			s := reward.NewRewardService(nil, rewardRepo, nil, rbac, nil, cfg, zap.NewNop())
+
+			program, err := s.EndProgram(nil, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.end, *program.EndsAt)
+		})
+	}
+}
@@ -0,0 +1,511 @@
+package reward
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NewRewardService creates new referral reward service
+func NewRewardService(userRepo model.UserRepo, rewardRepo model.RewardRepo, bankAccountRepo model.BankAccountRepo, rbac model.RBACService, broker broker.Service, config *config.RewardConfig, log *zap.Logger) *Service {
+	return &Service{userRepo, rewardRepo, bankAccountRepo, rbac, broker, config, log}
+}
+
+// Service represents the referral reward application service, it journals rewards from the firm sweep account
+type Service struct {
+	userRepo        model.UserRepo
+	rewardRepo      model.RewardRepo
+	bankAccountRepo model.BankAccountRepo
+	rbac            model.RBACService
+	broker          broker.Service
+	config          *config.RewardConfig
+	log             *zap.Logger
+}
+
+// referral is a referee referred by referrer under program, scored by the fraud rules at most once
+type referral struct {
+	program    *model.Reward
+	referee    *model.User
+	referrer   *model.User
+	assessment *assessment
+}
+
+var (
+	now  = time.Now
+	intn = rand.Intn
+)
+
+var (
+	errNoSweepAccount = errors.New("reward sweep account isn't configured")
+	errNoAccount      = errors.New("recipient has no brokerage account")
+)
+
+// Reasons recorded on rewards which were skipped
+const (
+	limitReached      = "Per account referral limit reached."
+	budgetExhausted   = "Referral program budget exhausted."
+	programHasNoStock = "Referral program has no stocks to pick from."
+	payoutInterrupted = "Payout was interrupted, check the broker for its journal before retrying."
+)
+
+// OnApproved rewards a referred user and their referrer when the user's account is approved
+func (s *Service) OnApproved(u *model.User) error {
+	r, ok := s.referral(u)
+	if !ok {
+		return nil
+	}
+	if err := s.pay(r, u, model.RewardReferreKyc, r.program.ReferreKycReward); err != nil {
+		return err
+	}
+	return s.pay(r, r.referrer, model.RewardReferralKyc, r.program.ReferralKycReward)
+}
+
+// OnFunded rewards the referrer the first time a referred user funds their account
+func (s *Service) OnFunded(u *model.User) error {
+	r, ok := s.referral(u)
+	if !ok {
+		return nil
+	}
+	return s.pay(r, r.referrer, model.RewardReferralSignup, r.program.ReferralSignupReward)
+}
+
+// referral returns the referral of u under the program in effect when u signed up, ok is false when there is nothing to reward
+func (s *Service) referral(u *model.User) (*referral, bool) {
+	if strings.TrimSpace(u.ReferredBy) == "" {
+		return nil, false
+	}
+	referrer, err := s.rewardRepo.FindReferrer(u.ReferredBy)
+	if err != nil || referrer.ID == u.ID {
+		return nil, false
+	}
+	program, err := s.rewardRepo.InEffect(u.CreatedAt)
+	if err != nil {
+		return nil, false
+	}
+	return &referral{program: program, referee: u, referrer: referrer}, true
+}
+
+// pay rewards recipient with amount for a referral and records the outcome. A reward is paid once per
+// recipient, referee and type: it is recorded pending first and only journaled when no other payout
+// recorded it before. The referrer's rewards count towards the program's per account limit and every
+// reward towards its budget. A referral flagged by the fraud rules is held for manual review
+func (s *Service) pay(r *referral, recipient *model.User, rewardType string, amount float64) error {
+	if amount <= 0 {
+		return nil
+	}
+	program := r.program
+	existing, err := s.rewardRepo.FindUserReward(recipient.ID, r.referee.ID, rewardType)
+	if err != nil || existing != nil {
+		return err
+	}
+	reward := &model.UserReward{
+		UserID:      recipient.ID,
+		RefereeID:   r.referee.ID,
+		ProgramID:   program.ID,
+		ReferredBy:  r.referrer.ID,
+		RewardValue: float32(amount),
+		RewardType:  rewardType,
+		Status:      model.RewardPending,
+	}
+	if program.Type == model.ProgramStock && len(program.Stocks) > 0 {
+		reward.Symbol = pick(program.Stocks)
+	}
+	if r.assessment == nil {
+		if r.assessment, err = s.assess(r.referee, r.referrer); err != nil {
+			return err
+		}
+	}
+	reward.FraudScore = r.assessment.score
+	reward.FraudReasons = r.assessment.reasons
+	if s.config.FraudThreshold > 0 && reward.FraudScore >= s.config.FraudThreshold {
+		reward.Status = model.RewardInReview
+	}
+
+	_, err = s.rewardRepo.CreateUserReward(reward, func(usage model.RewardUsage) error {
+		reason, err := skip(usage, program, reward)
+		if err != nil || reason == "" {
+			return err
+		}
+		reward.RewardValue = 0
+		reward.Symbol = ""
+		reward.Status = model.RewardSkipped
+		reward.ErrorResponse = reason
+		return nil
+	})
+	if err != nil {
+		return ignoreConflict(err)
+	}
+	if reward.Status == model.RewardInReview {
+		s.log.Info("Referral reward held for review", zap.Int("user_id", reward.UserID), zap.Int("referee_id", reward.RefereeID), zap.Int("fraud_score", reward.FraudScore))
+	}
+	if reward.Status != model.RewardPending {
+		return nil
+	}
+	s.record(reward, recipient)
+	return s.rewardRepo.UpdateUserReward(reward, model.RewardPending)
+}
+
+// ignoreConflict drops the error of a reward another payout recorded first
+func ignoreConflict(err error) error {
+	if e, ok := err.(*apperr.APPError); ok && e.Status == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// skip returns why a reward can't be paid under program, it is empty when the reward can be paid.
+// usage must be read while the program is locked, so that concurrent payouts see each other's rewards
+func skip(usage model.RewardUsage, program *model.Reward, reward *model.UserReward) (string, error) {
+	if program.Type == model.ProgramStock && len(program.Stocks) == 0 {
+		return programHasNoStock, nil
+	}
+	if program.PerAccountLimit > 0 && reward.UserID == reward.ReferredBy {
+		count, err := usage.CountReferees(program.ID, reward.ReferredBy, reward.RefereeID)
+		if err != nil {
+			return "", err
+		}
+		if count >= program.PerAccountLimit {
+			return limitReached, nil
+		}
+	}
+	if program.Budget > 0 {
+		spent, err := usage.SpentBudget(program.ID)
+		if err != nil {
+			return "", err
+		}
+		if spent+float64(reward.RewardValue) > program.Budget {
+			return budgetExhausted, nil
+		}
+	}
+	return "", nil
+}
+
+// pick returns the symbol of a stock picked at random, the chance of each is proportional to its weight
+func pick(stocks []model.RewardStock) string {
+	total := 0
+	for _, st := range stocks {
+		total += st.Weight
+	}
+	if total <= 0 {
+		return stocks[0].Symbol
+	}
+	n := intn(total)
+	for _, st := range stocks {
+		if n < st.Weight {
+			return st.Symbol
+		}
+		n -= st.Weight
+	}
+	return stocks[len(stocks)-1].Symbol
+}
+
+// record pays a reward to its recipient and records the outcome on it: the cash is journaled and for a
+// stock reward spent on a notional buy of its symbol. A step which already succeeded isn't repeated.
+// A failure is scheduled for a retry with exponential backoff or dead-lettered once out of attempts
+func (s *Service) record(reward *model.UserReward, recipient *model.User) {
+	if err := s.deliver(reward, recipient); err != nil {
+		s.fail(reward, err)
+		return
+	}
+	reward.Status = model.RewardPaid
+	reward.RewardTransferStatus = true
+	reward.ErrorResponse = ""
+	reward.NextAttemptAt = nil
+}
+
+// fail records why a reward wasn't paid and schedules its retry, or dead-letters it once out of attempts
+func (s *Service) fail(reward *model.UserReward, err error) {
+	s.log.Warn("RewardService Error", zap.Int("user_id", reward.UserID), zap.String("reward_type", reward.RewardType), zap.Error(err))
+	reward.Attempts++
+	reward.ErrorResponse = err.Error()
+	if reward.Attempts >= s.config.MaxAttempts {
+		reward.Status = model.RewardDeadLetter
+		reward.NextAttemptAt = nil
+		return
+	}
+	next := now().Add(s.backoff(reward.Attempts))
+	reward.Status = model.RewardFailed
+	reward.NextAttemptAt = &next
+}
+
+func (s *Service) deliver(reward *model.UserReward, recipient *model.User) error {
+	amount := strconv.FormatFloat(float64(reward.RewardValue), 'f', 2, 64)
+	if reward.JournalID == "" {
+		journal, err := s.journal(recipient, amount)
+		if err != nil {
+			return err
+		}
+		reward.JournalID = journal.ID
+		reward.JournalStatus = strings.ToLower(journal.Status)
+	}
+	if reward.Symbol == "" || reward.OrderID != "" {
+		return nil
+	}
+	order, err := s.broker.CreateOrder(recipient.AccountID, &broker.OrderRequest{
+		Symbol:        reward.Symbol,
+		Notional:      amount,
+		Side:          "buy",
+		Type:          "market",
+		TimeInForce:   "day",
+		ClientOrderID: "reward-" + strconv.Itoa(reward.UserID) + "-" + strconv.Itoa(reward.RefereeID) + "-" + reward.RewardType,
+	})
+	if err != nil {
+		return err
+	}
+	reward.OrderID = order.ID
+	return nil
+}
+
+// backoff returns the delay before the next attempt once a reward failed attempts times
+func (s *Service) backoff(attempts int) time.Duration {
+	shift := attempts - 1
+	if shift > 16 {
+		shift = 16
+	}
+	return s.config.RetryBackoff << uint(shift)
+}
+
+// attempt journals an outstanding reward again and saves the outcome. The reward is claimed first so
+// that a payout running at the same time, or a second admin, can't journal it twice
+func (s *Service) attempt(reward *model.UserReward) error {
+	recipient, err := s.userRepo.View(reward.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.rewardRepo.ClaimUserReward(reward); err != nil {
+		return err
+	}
+	s.record(reward, recipient)
+	return s.rewardRepo.UpdateUserReward(reward, model.RewardProcessing)
+}
+
+// RetryDue retries the failed rewards whose next attempt is due and returns the number paid
+func (s *Service) RetryDue() (int, error) {
+	rewards, err := s.rewardRepo.ListDueRewards(now())
+	if err != nil {
+		return 0, err
+	}
+	return s.attemptAll(rewards), nil
+}
+
+// Reconcile checks the journals of paid rewards with the broker and returns the number which are owed
+// again. A journal the broker rejects after it was queued fails its reward, which is then retried like
+// any other. A reward left pending or processing for longer than StuckAfter is dead-lettered, its
+// journal may or may not have been made so an admin has to check the broker before retrying it
+func (s *Service) Reconcile() (int, error) {
+	rewards, err := s.rewardRepo.ListUnsettledRewards()
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for i := range rewards {
+		r := &rewards[i]
+		journal, err := s.broker.GetJournal(r.JournalID)
+		if err != nil {
+			s.log.Warn("RewardService Error", zap.Int("reward_id", r.ID), zap.Error(err))
+			continue
+		}
+		status := strings.ToLower(journal.Status)
+		if status == r.JournalStatus {
+			continue
+		}
+		from := r.Status
+		r.JournalStatus = status
+		if journalFailed(status) {
+			s.fail(r, errors.New("journal "+r.JournalID+" was "+status))
+			r.JournalID = ""
+			r.RewardTransferStatus = false
+			failed++
+		}
+		if err := s.rewardRepo.UpdateUserReward(r, from); err != nil {
+			s.log.Warn("RewardService Error", zap.Int("reward_id", r.ID), zap.Error(err))
+		}
+	}
+
+	stuck, err := s.rewardRepo.ListStuckRewards(now().Add(-s.config.StuckAfter))
+	if err != nil {
+		return failed, err
+	}
+	for i := range stuck {
+		r := &stuck[i]
+		s.log.Warn("Referral reward payout was interrupted", zap.Int("reward_id", r.ID), zap.String("status", r.Status))
+		from := r.Status
+		r.Status = model.RewardDeadLetter
+		r.NextAttemptAt = nil
+		r.ErrorResponse = payoutInterrupted
+		if err := s.rewardRepo.UpdateUserReward(r, from); err != nil {
+			s.log.Warn("RewardService Error", zap.Int("reward_id", r.ID), zap.Error(err))
+		}
+	}
+	return failed, nil
+}
+
+// Outstanding returns the rewards still owed and their total value, the liability a payout would settle
+func (s *Service) Outstanding() ([]model.UserReward, float64, error) {
+	rewards, err := s.rewardRepo.ListOutstandingRewards()
+	if err != nil {
+		return nil, 0, err
+	}
+	var total float64
+	for _, r := range rewards {
+		total += float64(r.RewardValue)
+	}
+	return rewards, total, nil
+}
+
+// Payout attempts every outstanding reward now, dead-lettered ones included, and returns the number paid
+func (s *Service) Payout() (int, error) {
+	rewards, err := s.rewardRepo.ListOutstandingRewards()
+	if err != nil {
+		return 0, err
+	}
+	return s.attemptAll(rewards), nil
+}
+
+func (s *Service) attemptAll(rewards []model.UserReward) int {
+	paid := 0
+	for i := range rewards {
+		if err := s.attempt(&rewards[i]); err != nil {
+			s.log.Warn("RewardService Error", zap.Int("reward_id", rewards[i].ID), zap.Error(err))
+			continue
+		}
+		if rewards[i].Status == model.RewardPaid {
+			paid++
+		}
+	}
+	return paid
+}
+
+// ListOutstanding returns the failed and dead-lettered rewards, admins only
+func (s *Service) ListOutstanding(c *gin.Context) ([]model.UserReward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	return s.rewardRepo.ListOutstandingRewards()
+}
+
+// Retry attempts an outstanding reward right away, admins only. A dead-lettered reward gets a fresh set of attempts
+func (s *Service) Retry(c *gin.Context, id int) (*model.UserReward, error) {
+	reward, err := s.outstanding(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if reward.Status == model.RewardDeadLetter {
+		reward.Attempts = 0
+	}
+	if err := s.attempt(reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+// ListReview returns the rewards held for manual review by the fraud rules, admins only
+func (s *Service) ListReview(c *gin.Context) ([]model.UserReward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	return s.rewardRepo.ListRewardsInReview()
+}
+
+// Approve releases a reward held for review and pays it, admins only. A failed payment is retried like any other
+func (s *Service) Approve(c *gin.Context, id int) (*model.UserReward, error) {
+	reward, err := s.inReview(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attempt(reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+// Void gives up on an outstanding reward or one held for review so that it is never paid, admins only.
+// A reward a payout claimed in the meantime isn't voided
+func (s *Service) Void(c *gin.Context, id int) (*model.UserReward, error) {
+	reward, err := s.view(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reward.Outstanding() && reward.Status != model.RewardInReview {
+		return nil, apperr.New(http.StatusConflict, "Reward isn't outstanding.")
+	}
+	reward.Status = model.RewardVoided
+	reward.NextAttemptAt = nil
+	if err := s.rewardRepo.UpdateUserReward(reward, model.RewardFailed, model.RewardDeadLetter, model.RewardInReview); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+func (s *Service) view(c *gin.Context, id int) (*model.UserReward, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	return s.rewardRepo.ViewUserReward(id)
+}
+
+func (s *Service) outstanding(c *gin.Context, id int) (*model.UserReward, error) {
+	reward, err := s.view(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reward.Outstanding() {
+		return nil, apperr.New(http.StatusConflict, "Reward isn't outstanding.")
+	}
+	return reward, nil
+}
+
+func (s *Service) inReview(c *gin.Context, id int) (*model.UserReward, error) {
+	reward, err := s.view(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if reward.Status != model.RewardInReview {
+		return nil, apperr.New(http.StatusConflict, "Reward isn't in review.")
+	}
+	return reward, nil
+}
+
+// journal moves amount of cash from the firm sweep account to the recipient's account
+func (s *Service) journal(recipient *model.User, amount string) (*broker.Journal, error) {
+	if s.config.SweepAccountID == "" {
+		return nil, errNoSweepAccount
+	}
+	if recipient.AccountID == "" {
+		return nil, errNoAccount
+	}
+	journal, err := s.broker.CreateJournal(&broker.JournalRequest{
+		FromAccount: s.config.SweepAccountID,
+		ToAccount:   recipient.AccountID,
+		EntryType:   broker.JournalCash,
+		Amount:      amount,
+		Description: "Referral reward",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := strings.ToLower(journal.Status); journalFailed(status) {
+		return nil, errors.New("journal " + journal.ID + " was " + status)
+	}
+	return journal, nil
+}
+
+// journalFailed reports whether a journal in the status will never move the cash
+func journalFailed(status string) bool {
+	switch status {
+	case "rejected", "canceled", "refused", "deleted":
+		return true
+	}
+	return false
+}
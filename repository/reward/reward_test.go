@@ -0,0 +1,503 @@
+package reward_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	program = &model.Reward{ID: 1, PerAccountLimit: 2, ReferralKycReward: 10, ReferralSignupReward: 5, ReferreKycReward: 7.5}
+	cfg     = &config.RewardConfig{SweepAccountID: "sweep", RetryBackoff: 15 * time.Minute, MaxAttempts: 3}
+	today   = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	retryAt = today.Add(15 * time.Minute)
+	noBanks = &mockdb.BankAccount{ListByUserFn: func(int) ([]model.BankAccount, error) { return nil, nil }}
+)
+
+// ledger stores rewards like the repo, a reward recorded pending is updated with the outcome of its payout
+type ledger struct {
+	rewards []model.UserReward
+	created []string
+}
+
+func (l *ledger) create(r *model.UserReward) (*model.UserReward, error) {
+	for _, stored := range l.rewards {
+		if stored.UserID == r.UserID && stored.RefereeID == r.RefereeID && stored.RewardType == r.RewardType {
+			return nil, apperr.New(http.StatusConflict, "Reward was already recorded.")
+		}
+	}
+	l.created = append(l.created, r.Status)
+	l.rewards = append(l.rewards, *r)
+	return r, nil
+}
+
+func (l *ledger) update(r *model.UserReward, from ...string) error {
+	for i, stored := range l.rewards {
+		if stored.UserID == r.UserID && stored.RefereeID == r.RefereeID && stored.RewardType == r.RewardType {
+			l.rewards[i] = *r
+		}
+	}
+	return nil
+}
+
+// claim marks a reward as processing like the repo does when no other payout claimed it
+func claim(r *model.UserReward) error {
+	r.Status = model.RewardProcessing
+	return nil
+}
+
+func TestOnApproved(t *testing.T) {
+	defer reward.SetNow(today)()
+	referrer := &model.User{ID: 1, AccountID: "acc-1", ReferralCode: "ALICE1"}
+
+	cases := []struct {
+		name     string
+		user     *model.User
+		paid     []string
+		referees int
+		journal  error
+		journals []string
+		rewards  []model.UserReward
+	}{
+		{
+			name: "not referred",
+			user: &model.User{ID: 2, AccountID: "acc-2"},
+		},
+		{
+			name: "unknown referral code",
+			user: &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "NOBODY"},
+		},
+		{
+			name:     "pays the referred user and the referrer",
+			user:     &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "alice1"},
+			journals: []string{"acc-2:7.50", "acc-1:10.00"},
+			rewards: []model.UserReward{
+				{UserID: 2, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardValue: 7.5, RewardType: model.RewardReferreKyc, JournalID: "j-1", JournalStatus: "queued", RewardTransferStatus: true, Status: model.RewardPaid},
+				{UserID: 1, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardValue: 10, RewardType: model.RewardReferralKyc, JournalID: "j-1", JournalStatus: "queued", RewardTransferStatus: true, Status: model.RewardPaid},
+			},
+		},
+		{
+			name: "already paid",
+			user: &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1"},
+			paid: []string{model.RewardReferreKyc, model.RewardReferralKyc},
+		},
+		{
+			name:     "referrer over the per account limit",
+			user:     &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1"},
+			referees: 2,
+			journals: []string{"acc-2:7.50"},
+			rewards: []model.UserReward{
+				{UserID: 2, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardValue: 7.5, RewardType: model.RewardReferreKyc, JournalID: "j-1", JournalStatus: "queued", RewardTransferStatus: true, Status: model.RewardPaid},
+				{UserID: 1, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardType: model.RewardReferralKyc, ErrorResponse: "Per account referral limit reached.", Status: model.RewardSkipped},
+			},
+		},
+		{
+			name:     "journal fails",
+			user:     &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1"},
+			journal:  errors.New("insufficient funds in sweep account"),
+			journals: []string{"acc-2:7.50", "acc-1:10.00"},
+			rewards: []model.UserReward{
+				{UserID: 2, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardValue: 7.5, RewardType: model.RewardReferreKyc, ErrorResponse: "insufficient funds in sweep account", Status: model.RewardFailed, Attempts: 1, NextAttemptAt: &retryAt},
+				{UserID: 1, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardValue: 10, RewardType: model.RewardReferralKyc, ErrorResponse: "insufficient funds in sweep account", Status: model.RewardFailed, Attempts: 1, NextAttemptAt: &retryAt},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var journals []string
+			rewards := &ledger{}
+
+			rewardRepo := &mockdb.Reward{
+				InEffectFn: func(time.Time) (*model.Reward, error) {
+					return program, nil
+				},
+				FindReferrerFn: func(code string) (*model.User, error) {
+					if code != "ALICE1" && code != "alice1" {
+						return nil, apperr.New(http.StatusNotFound, "Referrer not found.")
+					}
+					return referrer, nil
+				},
+				FindUserRewardFn: func(userID, refereeID int, rewardType string) (*model.UserReward, error) {
+					for _, paid := range tt.paid {
+						if paid == rewardType {
+							return &model.UserReward{UserID: userID, RefereeID: refereeID, RewardType: rewardType}, nil
+						}
+					}
+					return nil, nil
+				},
+				CountRefereesFn: func(programID, referrerID, exceptRefereeID int) (int, error) {
+					return tt.referees, nil
+				},
+				CreateUserRewardFn: rewards.create,
+				UpdateUserRewardFn: rewards.update,
+			}
+			b := &mock.Broker{
+				CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+					assert.Equal(t, "sweep", j.FromAccount)
+					assert.Equal(t, broker.JournalCash, j.EntryType)
+					journals = append(journals, j.ToAccount+":"+j.Amount)
+					if tt.journal != nil {
+						return nil, tt.journal
+					}
+					return &broker.Journal{ID: "j-1", Status: "queued"}, nil
+				},
+			}
+			s := reward.NewRewardService(nil, rewardRepo, noBanks, nil, b, cfg, zap.NewNop())
+
+			assert.NoError(t, s.OnApproved(tt.user))
+			assert.Equal(t, tt.journals, journals)
+			assert.Equal(t, tt.rewards, rewards.rewards)
+		})
+	}
+}
+
+func TestOnFunded(t *testing.T) {
+	defer reward.SetNow(today)()
+	rewards := &ledger{}
+	rewardRepo := &mockdb.Reward{
+		InEffectFn: func(time.Time) (*model.Reward, error) {
+			return program, nil
+		},
+		FindReferrerFn: func(code string) (*model.User, error) {
+			return &model.User{ID: 1, ReferralCode: code}, nil
+		},
+		FindUserRewardFn: func(userID, refereeID int, rewardType string) (*model.UserReward, error) {
+			return nil, nil
+		},
+		CountRefereesFn: func(programID, referrerID, exceptRefereeID int) (int, error) {
+			return 0, nil
+		},
+		CreateUserRewardFn: rewards.create,
+		UpdateUserRewardFn: rewards.update,
+	}
+	b := &mock.Broker{
+		CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+			return &broker.Journal{ID: "j-1", Status: "queued"}, nil
+		},
+	}
+	s := reward.NewRewardService(nil, rewardRepo, noBanks, nil, b, cfg, zap.NewNop())
+
+	// the referrer has no brokerage account yet, the reward is recorded unpaid
+	assert.NoError(t, s.OnFunded(&model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1"}))
+	assert.Equal(t, []model.UserReward{
+		{UserID: 1, RefereeID: 2, ProgramID: 1, ReferredBy: 1, RewardValue: 5, RewardType: model.RewardReferralSignup, ErrorResponse: "recipient has no brokerage account", Status: model.RewardFailed, Attempts: 1, NextAttemptAt: &retryAt},
+	}, rewards.rewards)
+}
+
+func TestOnFundedConcurrently(t *testing.T) {
+	defer reward.SetNow(today)()
+	rewards := &ledger{}
+	rewardRepo := &mockdb.Reward{
+		InEffectFn: func(time.Time) (*model.Reward, error) {
+			return program, nil
+		},
+		FindReferrerFn: func(code string) (*model.User, error) {
+			return &model.User{ID: 1, AccountID: "acc-1", ReferralCode: code}, nil
+		},
+		// both payouts look before either recorded the reward
+		FindUserRewardFn: func(userID, refereeID int, rewardType string) (*model.UserReward, error) {
+			return nil, nil
+		},
+		CountRefereesFn: func(programID, referrerID, exceptRefereeID int) (int, error) {
+			return 0, nil
+		},
+		CreateUserRewardFn: rewards.create,
+		UpdateUserRewardFn: rewards.update,
+	}
+	journals := 0
+	b := &mock.Broker{
+		CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+			journals++
+			return &broker.Journal{ID: "j-1", Status: "queued"}, nil
+		},
+	}
+	s := reward.NewRewardService(nil, rewardRepo, noBanks, nil, b, cfg, zap.NewNop())
+
+	referee := &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1"}
+	assert.NoError(t, s.OnFunded(referee))
+	assert.NoError(t, s.OnFunded(referee))
+	assert.Equal(t, 1, journals)
+	assert.Equal(t, []string{model.RewardPending}, rewards.created)
+	assert.Equal(t, model.RewardPaid, rewards.rewards[0].Status)
+}
+
+func TestRetryDue(t *testing.T) {
+	defer reward.SetNow(today)()
+	failed := func(id, attempts int) model.UserReward {
+		return model.UserReward{ID: id, UserID: 1, RewardValue: 10, Status: model.RewardFailed, Attempts: attempts, NextAttemptAt: &today}
+	}
+	due := []model.UserReward{failed(1, 1), failed(2, 1), failed(3, 2)}
+	updated := map[int]model.UserReward{}
+
+	userRepo := &mockdb.User{
+		ViewFn: func(id int) (*model.User, error) {
+			return &model.User{ID: id, AccountID: "acc-1"}, nil
+		},
+	}
+	rewardRepo := &mockdb.Reward{
+		ListDueRewardsFn: func(at time.Time) ([]model.UserReward, error) {
+			assert.Equal(t, today, at)
+			return due, nil
+		},
+		UpdateUserRewardFn: func(r *model.UserReward, from ...string) error {
+			updated[r.ID] = *r
+			return nil
+		},
+		ClaimUserRewardFn: claim,
+	}
+	calls := 0
+	b := &mock.Broker{
+		CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+			calls++
+			if calls == 1 {
+				return &broker.Journal{ID: "j-1", Status: "executed"}, nil
+			}
+			return &broker.Journal{ID: "j-2", Status: "rejected"}, nil
+		},
+	}
+	s := reward.NewRewardService(userRepo, rewardRepo, nil, nil, b, cfg, zap.NewNop())
+
+	paid, err := s.RetryDue()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, paid)
+
+	assert.Equal(t, model.RewardPaid, updated[1].Status)
+	assert.Equal(t, "j-1", updated[1].JournalID)
+	assert.Nil(t, updated[1].NextAttemptAt)
+
+	// the backoff doubles after every failed attempt
+	assert.Equal(t, model.RewardFailed, updated[2].Status)
+	assert.Equal(t, 2, updated[2].Attempts)
+	assert.Equal(t, today.Add(30*time.Minute), *updated[2].NextAttemptAt)
+	assert.Equal(t, "journal j-2 was rejected", updated[2].ErrorResponse)
+
+	assert.Equal(t, model.RewardDeadLetter, updated[3].Status)
+	assert.Equal(t, 3, updated[3].Attempts)
+	assert.Nil(t, updated[3].NextAttemptAt)
+}
+
+func TestReconcile(t *testing.T) {
+	defer reward.SetNow(today)()
+	paid := func(id int, journalID string) model.UserReward {
+		return model.UserReward{ID: id, UserID: 1, RewardValue: 10, JournalID: journalID, JournalStatus: "queued", RewardTransferStatus: true, Status: model.RewardPaid}
+	}
+	unsettled := []model.UserReward{paid(1, "j-1"), paid(2, "j-2"), paid(3, "j-3")}
+	stuck := []model.UserReward{{ID: 4, UserID: 1, RewardValue: 10, Status: model.RewardProcessing}}
+	updated := map[int]model.UserReward{}
+
+	rewardRepo := &mockdb.Reward{
+		ListUnsettledRewardsFn: func() ([]model.UserReward, error) {
+			return unsettled, nil
+		},
+		ListStuckRewardsFn: func(before time.Time) ([]model.UserReward, error) {
+			assert.Equal(t, today.Add(-time.Hour), before)
+			return stuck, nil
+		},
+		UpdateUserRewardFn: func(r *model.UserReward, from ...string) error {
+			updated[r.ID] = *r
+			return nil
+		},
+	}
+	statuses := map[string]string{"j-1": "executed", "j-2": "rejected", "j-3": "queued"}
+	b := &mock.Broker{
+		GetJournalFn: func(id string) (*broker.Journal, error) {
+			return &broker.Journal{ID: id, Status: statuses[id]}, nil
+		},
+	}
+	c := *cfg
+	c.StuckAfter = time.Hour
+	s := reward.NewRewardService(nil, rewardRepo, nil, nil, b, &c, zap.NewNop())
+
+	failed, err := s.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failed)
+
+	assert.Equal(t, model.RewardPaid, updated[1].Status)
+	assert.Equal(t, "executed", updated[1].JournalStatus)
+
+	// the rejected journal is made again on the next retry
+	assert.Equal(t, model.RewardFailed, updated[2].Status)
+	assert.Empty(t, updated[2].JournalID)
+	assert.False(t, updated[2].RewardTransferStatus)
+	assert.Equal(t, "journal j-2 was rejected", updated[2].ErrorResponse)
+	assert.Equal(t, retryAt, *updated[2].NextAttemptAt)
+
+	_, ok := updated[3]
+	assert.False(t, ok)
+
+	assert.Equal(t, model.RewardDeadLetter, updated[4].Status)
+	assert.NotEmpty(t, updated[4].ErrorResponse)
+}
+
+func TestAdmin(t *testing.T) {
+	defer reward.SetNow(today)()
+	cases := []struct {
+		name     string
+		admin    bool
+		reward   model.UserReward
+		void     bool
+		claimed  bool
+		status   int
+		outcome  string
+		attempts int
+	}{
+		{name: "not an admin", reward: model.UserReward{ID: 1, Status: model.RewardFailed}, status: http.StatusForbidden},
+		{name: "already paid", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardPaid}, status: http.StatusConflict},
+		{name: "retry claimed by another payout", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardFailed}, claimed: true, status: http.StatusConflict},
+		{name: "retry dead letter", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardDeadLetter, Attempts: 3}, outcome: model.RewardFailed, attempts: 1},
+		{name: "void", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardDeadLetter, Attempts: 3}, void: true, outcome: model.RewardVoided, attempts: 3},
+		{name: "void claimed by a payout meanwhile", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardFailed}, void: true, claimed: true, status: http.StatusConflict},
+		{name: "void paid", admin: true, reward: model.UserReward{ID: 1, Status: model.RewardPaid}, void: true, status: http.StatusConflict},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rbac := &mock.RBAC{
+				EnforceRoleFn: func(*gin.Context, model.AccessRole) bool {
+					return tt.admin
+				},
+			}
+			userRepo := &mockdb.User{
+				ViewFn: func(id int) (*model.User, error) {
+					return &model.User{ID: id, AccountID: "acc-1"}, nil
+				},
+			}
+			rewardRepo := &mockdb.Reward{
+				ViewUserRewardFn: func(id int) (*model.UserReward, error) {
+					r := tt.reward
+					return &r, nil
+				},
+				UpdateUserRewardFn: func(r *model.UserReward, from ...string) error {
+					if !tt.void {
+						assert.Equal(t, []string{model.RewardProcessing}, from)
+						return nil
+					}
+					if tt.claimed {
+						return apperr.New(http.StatusConflict, "Reward was changed in the meantime.")
+					}
+					assert.Contains(t, from, tt.reward.Status)
+					return nil
+				},
+				ClaimUserRewardFn: func(r *model.UserReward) error {
+					if tt.claimed {
+						return apperr.New(http.StatusConflict, "Reward is already being paid.")
+					}
+					return claim(r)
+				},
+			}
+			b := &mock.Broker{
+				CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+					assert.False(t, tt.claimed)
+					return nil, errors.New("sweep account closed")
+				},
+			}
+			s := reward.NewRewardService(userRepo, rewardRepo, nil, rbac, b, cfg, zap.NewNop())
+
+			var r *model.UserReward
+			var err error
+			if tt.void {
+				r, err = s.Void(nil, 1)
+			} else {
+				r, err = s.Retry(nil, 1)
+			}
+			if tt.status != 0 {
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.outcome, r.Status)
+			// a retried dead letter starts over on its attempts
+			assert.Equal(t, tt.attempts, r.Attempts)
+		})
+	}
+}
+
+func TestProgramRules(t *testing.T) {
+	defer reward.SetNow(today)()
+	defer reward.SetIntn(func(n int) int { return 3 })()
+	signup := today.Add(-24 * time.Hour)
+
+	cases := []struct {
+		name    string
+		program *model.Reward
+		spent   float64
+		orders  []string
+		rewards []model.UserReward
+	}{
+		{
+			name:    "budget exhausted",
+			program: &model.Reward{ID: 2, Type: model.ProgramCash, Budget: 100, ReferralSignupReward: 5},
+			spent:   96,
+			rewards: []model.UserReward{
+				{UserID: 1, RefereeID: 2, ProgramID: 2, ReferredBy: 1, RewardType: model.RewardReferralSignup, ErrorResponse: "Referral program budget exhausted.", Status: model.RewardSkipped},
+			},
+		},
+		{
+			name: "random stock",
+			program: &model.Reward{ID: 3, Type: model.ProgramStock, Budget: 100, ReferralSignupReward: 5, Stocks: []model.RewardStock{
+				{Symbol: "AAPL", Weight: 3},
+				{Symbol: "TSLA", Weight: 1},
+			}},
+			spent:  95,
+			orders: []string{"acc-1:TSLA:5.00"},
+			rewards: []model.UserReward{
+				{UserID: 1, RefereeID: 2, ProgramID: 3, ReferredBy: 1, RewardValue: 5, RewardType: model.RewardReferralSignup, JournalID: "j-1", JournalStatus: "queued", Symbol: "TSLA", OrderID: "o-1", RewardTransferStatus: true, Status: model.RewardPaid},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var orders []string
+			rewards := &ledger{}
+			rewardRepo := &mockdb.Reward{
+				InEffectFn: func(at time.Time) (*model.Reward, error) {
+					assert.Equal(t, signup, at)
+					return tt.program, nil
+				},
+				FindReferrerFn: func(code string) (*model.User, error) {
+					return &model.User{ID: 1, AccountID: "acc-1", ReferralCode: code}, nil
+				},
+				FindUserRewardFn: func(userID, refereeID int, rewardType string) (*model.UserReward, error) {
+					return nil, nil
+				},
+				SpentBudgetFn: func(programID int) (float64, error) {
+					assert.Equal(t, tt.program.ID, programID)
+					return tt.spent, nil
+				},
+				CreateUserRewardFn: rewards.create,
+				UpdateUserRewardFn: rewards.update,
+			}
+			b := &mock.Broker{
+				CreateJournalFn: func(j *broker.JournalRequest) (*broker.Journal, error) {
+					return &broker.Journal{ID: "j-1", Status: "queued"}, nil
+				},
+				CreateOrderFn: func(accountID string, o *broker.OrderRequest) (*broker.Order, error) {
+					orders = append(orders, accountID+":"+o.Symbol+":"+o.Notional)
+					return &broker.Order{ID: "o-1"}, nil
+				},
+			}
+			s := reward.NewRewardService(nil, rewardRepo, noBanks, nil, b, cfg, zap.NewNop())
+
+			referee := &model.User{ID: 2, AccountID: "acc-2", ReferredBy: "ALICE1"}
+			referee.CreatedAt = signup
+			assert.NoError(t, s.OnFunded(referee))
+			assert.Equal(t, tt.orders, orders)
+			assert.Equal(t, tt.rewards, rewards.rewards)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewSessionRepo returns a new SessionRepo instance
+func NewSessionRepo(db orm.DB, log *zap.Logger) *SessionRepo {
+	return &SessionRepo{db, log}
+}
+
+// SessionRepo is the client for login sessions
+type SessionRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+var errSessionNotFound = apperr.New(http.StatusNotFound, "Session not found.")
+
+// Create records a new session
+func (s *SessionRepo) Create(session *model.Session) (*model.Session, error) {
+	if err := s.db.Insert(session); err != nil {
+		s.log.Warn("SessionRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return session, nil
+}
+
+// FindByToken returns the session whose current refresh token has the hash, revoked ones included
+func (s *SessionRepo) FindByToken(tokenHash string) (*model.Session, error) {
+	var session = new(model.Session)
+	sql := `SELECT * FROM sessions WHERE (token_hash = ? and deleted_at is null) LIMIT 1`
+	_, err := s.db.QueryOne(session, sql, tokenHash)
+	if err != nil {
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+// FindByRotatedToken returns the session which once issued a refresh token with the hash
+func (s *SessionRepo) FindByRotatedToken(tokenHash string) (*model.Session, error) {
+	var session = new(model.Session)
+	sql := `SELECT * FROM sessions WHERE (? = ANY(rotated_hashes) and deleted_at is null) LIMIT 1`
+	_, err := s.db.QueryOne(session, sql, tokenHash)
+	if err != nil {
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+// Rotate saves a session with its new refresh token, only if its token is still previousHash and it
+// wasn't revoked meanwhile. It fails with a conflict when another refresh got there first
+func (s *SessionRepo) Rotate(session *model.Session, previousHash string) error {
+	res, err := s.db.Model(session).
+		Column("token_hash", "rotated_hashes", "ip", "user_agent", "last_used_at", "updated_at").
+		WherePK().
+		Where("token_hash = ? and revoked_at is null", previousHash).
+		Update()
+	if err != nil {
+		s.log.Warn("SessionRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusConflict, "Session was already refreshed.")
+	}
+	return nil
+}
+
+// ListByUser returns the sessions a user is signed in with, most recently used first
+func (s *SessionRepo) ListByUser(userID int) ([]model.Session, error) {
+	var sessions []model.Session
+	sql := `SELECT * FROM sessions WHERE (user_id = ? and revoked_at is null and deleted_at is null) ORDER BY last_used_at DESC`
+	_, err := s.db.Query(&sessions, sql, userID)
+	if err != nil {
+		s.log.Warn("SessionRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return sessions, nil
+}
+
+// View returns a session of a user which wasn't revoked
+func (s *SessionRepo) View(userID, id int) (*model.Session, error) {
+	var session = new(model.Session)
+	sql := `SELECT * FROM sessions WHERE (id = ? and user_id = ? and revoked_at is null and deleted_at is null) LIMIT 1`
+	_, err := s.db.QueryOne(session, sql, id, userID)
+	if err != nil {
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+// Revoke signs a session out, its refresh tokens stop working
+func (s *SessionRepo) Revoke(session *model.Session) error {
+	t := time.Now()
+	session.RevokedAt = &t
+	_, err := s.db.Model(session).Column("revoked_at", "updated_at").WherePK().Update()
+	if err != nil {
+		s.log.Warn("SessionRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// RevokeAll signs a user out of every session
+func (s *SessionRepo) RevokeAll(userID int) error {
+	sql := `UPDATE sessions SET revoked_at = now(), updated_at = now() WHERE (user_id = ? and revoked_at is null)`
+	if _, err := s.db.Exec(sql, userID); err != nil {
+		s.log.Warn("SessionRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NewSessionService creates new session service
+func NewSessionService(sessionRepo model.SessionRepo, config *config.SessionConfig, log *zap.Logger) *Service {
+	return &Service{sessionRepo, config, log}
+}
+
+// Service represents the login session application service
+type Service struct {
+	sessionRepo model.SessionRepo
+	config      *config.SessionConfig
+	log         *zap.Logger
+}
+
+var now = time.Now
+
+// maxRotatedHashes bounds how many replaced refresh tokens a session remembers for reuse detection
+const maxRotatedHashes = 32
+
+var errInvalidToken = apperr.New(http.StatusUnauthorized, "Invalid refresh token. Please sign in again.")
+
+// deviceHeader names the device a client signs in from, it falls back to the device id on the user's profile
+const deviceHeader = "X-Device-ID"
+
+// Start opens a session for a user signing in with the request and returns its refresh token
+func (s *Service) Start(c *gin.Context, u *model.User) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", apperr.Generic
+	}
+	device := u.DeviceID
+	if d := c.GetHeader(deviceHeader); d != "" {
+		device = d
+	}
+	_, err = s.sessionRepo.Create(&model.Session{
+		UserID:     u.ID,
+		TokenHash:  hash(token),
+		DeviceID:   device,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		LastUsedAt: now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Rotate trades a refresh token for a new one and returns the id of the session's user. A token which
+// was already traded in means it leaked, the session is revoked so neither copy works anymore
+func (s *Service) Rotate(c *gin.Context, token string) (int, string, error) {
+	old := hash(token)
+	session, err := s.sessionRepo.FindByToken(old)
+	if err != nil {
+		s.reused(old)
+		return 0, "", errInvalidToken
+	}
+	if session.RevokedAt != nil || now().Sub(session.LastUsedAt) > s.config.IdleTimeout {
+		return 0, "", errInvalidToken
+	}
+
+	next, err := newToken()
+	if err != nil {
+		return 0, "", apperr.Generic
+	}
+	session.RotatedHashes = append(session.RotatedHashes, old)
+	if len(session.RotatedHashes) > maxRotatedHashes {
+		session.RotatedHashes = session.RotatedHashes[len(session.RotatedHashes)-maxRotatedHashes:]
+	}
+	session.TokenHash = hash(next)
+	session.IP = c.ClientIP()
+	session.UserAgent = c.Request.UserAgent()
+	session.LastUsedAt = now()
+	if err := s.sessionRepo.Rotate(session, old); err != nil {
+		if e, ok := err.(*apperr.APPError); ok && e.Status == http.StatusConflict {
+			// another refresh traded the same token in first
+			s.revoke(session, "refresh token used twice")
+			return 0, "", errInvalidToken
+		}
+		return 0, "", err
+	}
+	return session.UserID, next, nil
+}
+
+// reused revokes the session which issued a refresh token that was since rotated out, if any
+func (s *Service) reused(tokenHash string) {
+	session, err := s.sessionRepo.FindByRotatedToken(tokenHash)
+	if err != nil || session.RevokedAt != nil {
+		return
+	}
+	s.revoke(session, "rotated refresh token reused")
+}
+
+func (s *Service) revoke(session *model.Session, reason string) {
+	s.log.Warn("Session revoked", zap.Int("user_id", session.UserID), zap.Int("session_id", session.ID), zap.String("reason", reason))
+	if err := s.sessionRepo.Revoke(session); err != nil {
+		s.log.Warn("SessionService Error", zap.Int("session_id", session.ID), zap.Error(err))
+	}
+}
+
+// List returns the devices a user is signed in on
+func (s *Service) List(u *model.User) ([]model.Session, error) {
+	sessions, err := s.sessionRepo.ListByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []model.Session{}
+	}
+	return sessions, nil
+}
+
+// Revoke signs a user out of one of their sessions
+func (s *Service) Revoke(u *model.User, id int) error {
+	session, err := s.sessionRepo.View(u.ID, id)
+	if err != nil {
+		return err
+	}
+	return s.sessionRepo.Revoke(session)
+}
+
+// RevokeAll signs a user out of every device
+func (s *Service) RevokeAll(u *model.User) error {
+	return s.sessionRepo.RevokeAll(u.ID)
+}
+
+// newToken returns a random refresh token, only its hash is stored
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
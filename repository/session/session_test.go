@@ -0,0 +1,181 @@
+package session_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	cfg   = &config.SessionConfig{IdleTimeout: 720 * time.Hour}
+	today = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func request() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/refresh", nil)
+	c.Request.RemoteAddr = "10.0.0.9:4321"
+	c.Request.Header.Set("User-Agent", "ribbit-ios/2.1")
+	c.Request.Header.Set("X-Device-ID", "iphone-1")
+	return c
+}
+
+func TestStart(t *testing.T) {
+	defer session.SetNow(today)()
+	var created *model.Session
+	repo := &mockdb.Session{
+		CreateFn: func(s *model.Session) (*model.Session, error) {
+			created = s
+			return s, nil
+		},
+	}
+	s := session.NewSessionService(repo, cfg, zap.NewNop())
+
+	token, err := s.Start(request(), &model.User{ID: 7, DeviceID: "profile-device"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, &model.Session{
+		UserID:     7,
+		TokenHash:  hash(token),
+		DeviceID:   "iphone-1",
+		IP:         "10.0.0.9",
+		UserAgent:  "ribbit-ios/2.1",
+		LastUsedAt: today,
+	}, created)
+}
+
+func TestRotate(t *testing.T) {
+	defer session.SetNow(today)()
+	revokedAt := today.Add(-time.Hour)
+
+	cases := []struct {
+		name    string
+		current *model.Session
+		rotated *model.Session
+		stale   bool
+		revoked bool
+		ok      bool
+	}{
+		{
+			name:    "rotates the token",
+			current: &model.Session{ID: 1, UserID: 7, TokenHash: hash("token-1"), LastUsedAt: today.Add(-time.Hour)},
+			ok:      true,
+		},
+		{
+			name: "unknown token",
+		},
+		{
+			name:    "rotated token reused revokes the session",
+			rotated: &model.Session{ID: 1, UserID: 7, TokenHash: hash("token-2"), RotatedHashes: []string{hash("token-1")}},
+			revoked: true,
+		},
+		{
+			name:    "revoked session",
+			current: &model.Session{ID: 1, UserID: 7, TokenHash: hash("token-1"), LastUsedAt: today, RevokedAt: &revokedAt},
+		},
+		{
+			name:    "idle session",
+			current: &model.Session{ID: 1, UserID: 7, TokenHash: hash("token-1"), LastUsedAt: today.Add(-721 * time.Hour)},
+		},
+		{
+			name:    "token refreshed twice at once",
+			current: &model.Session{ID: 1, UserID: 7, TokenHash: hash("token-1"), LastUsedAt: today},
+			stale:   true,
+			revoked: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved *model.Session
+			revoked := false
+			repo := &mockdb.Session{
+				FindByTokenFn: func(h string) (*model.Session, error) {
+					if tt.current == nil || h != tt.current.TokenHash {
+						return nil, apperr.New(http.StatusNotFound, "Session not found.")
+					}
+					return tt.current, nil
+				},
+				FindByRotatedTokenFn: func(h string) (*model.Session, error) {
+					if tt.rotated == nil {
+						return nil, apperr.New(http.StatusNotFound, "Session not found.")
+					}
+					assert.Equal(t, hash("token-1"), h)
+					return tt.rotated, nil
+				},
+				RotateFn: func(s *model.Session, previous string) error {
+					assert.Equal(t, hash("token-1"), previous)
+					if tt.stale {
+						return apperr.New(http.StatusConflict, "Session was already refreshed.")
+					}
+					saved = s
+					return nil
+				},
+				RevokeFn: func(*model.Session) error {
+					revoked = true
+					return nil
+				},
+			}
+			s := session.NewSessionService(repo, cfg, zap.NewNop())
+
+			userID, next, err := s.Rotate(request(), "token-1")
+			assert.Equal(t, tt.revoked, revoked)
+			if !tt.ok {
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, http.StatusUnauthorized, err.(*apperr.APPError).Status)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 7, userID)
+			assert.Equal(t, hash(next), saved.TokenHash)
+			assert.Equal(t, []string{hash("token-1")}, saved.RotatedHashes)
+			assert.Equal(t, today, saved.LastUsedAt)
+			assert.Equal(t, "10.0.0.9", saved.IP)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	var revoked *model.Session
+	repo := &mockdb.Session{
+		ViewFn: func(userID, id int) (*model.Session, error) {
+			if userID != 7 {
+				return nil, apperr.New(http.StatusNotFound, "Session not found.")
+			}
+			return &model.Session{ID: id, UserID: userID}, nil
+		},
+		RevokeFn: func(s *model.Session) error {
+			revoked = s
+			return nil
+		},
+	}
+	s := session.NewSessionService(repo, cfg, zap.NewNop())
+
+	// a user can't sign out another user's session
+	err := s.Revoke(&model.User{ID: 8}, 3)
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, http.StatusNotFound, err.(*apperr.APPError).Status)
+	}
+	assert.Nil(t, revoked)
+
+	assert.NoError(t, s.Revoke(&model.User{ID: 7}, 3))
+	assert.Equal(t, 3, revoked.ID)
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewTransferRepo returns a new TransferRepo instance
+func NewTransferRepo(db orm.DB, log *zap.Logger) *TransferRepo {
+	return &TransferRepo{db, log}
+}
+
+// TransferRepo is the client for our local transfer ledger
+type TransferRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// Create records a new transfer in the ledger together with the first event of its timeline
+func (t *TransferRepo) Create(transfer *model.Transfer) (*model.Transfer, error) {
+	err := inTx(t.db, func(db orm.DB) error {
+		if err := db.Insert(transfer); err != nil {
+			return err
+		}
+		return db.Insert(&model.TransferEvent{TransferID: transfer.ID, ToStatus: transfer.Status})
+	})
+	if err != nil {
+		t.log.Warn("TransferRepo error: ", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return transfer, nil
+}
+
+// View returns a transfer of a user
+func (t *TransferRepo) View(userID, id int) (*model.Transfer, error) {
+	var transfer = new(model.Transfer)
+	sql := `SELECT * FROM transfers WHERE (id = ? and user_id = ? and deleted_at is null) LIMIT 1`
+	_, err := t.db.QueryOne(transfer, sql, id, userID)
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Transfer not found.")
+	}
+	return transfer, nil
+}
+
+// FindByTransferID returns a transfer of a user by its broker transfer id
+func (t *TransferRepo) FindByTransferID(userID int, transferID string) (*model.Transfer, error) {
+	var transfer = new(model.Transfer)
+	sql := `SELECT * FROM transfers WHERE (user_id = ? and transfer_id = ? and deleted_at is null) LIMIT 1`
+	_, err := t.db.QueryOne(transfer, sql, userID, transferID)
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Transfer not found.")
+	}
+	return transfer, nil
+}
+
+// ListPending returns the transfers whose status may still change
+func (t *TransferRepo) ListPending() ([]model.Transfer, error) {
+	var transfers []model.Transfer
+	sql := `SELECT * FROM transfers WHERE (status NOT IN (?, ?, ?, ?) and deleted_at is null) ORDER BY account_id, id`
+	_, err := t.db.Query(&transfers, sql, model.TransferStatusComplete, model.TransferStatusReturned, model.TransferStatusCanceled, model.TransferStatusRejected)
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return transfers, nil
+}
+
+// UpdateStatus moves a transfer to a new status and adds the change to its timeline
+func (t *TransferRepo) UpdateStatus(transfer *model.Transfer, status, reason string) (*model.TransferEvent, error) {
+	event := &model.TransferEvent{TransferID: transfer.ID, FromStatus: transfer.Status, ToStatus: status, Reason: reason}
+	transfer.Status = status
+	transfer.Reason = reason
+	err := inTx(t.db, func(db orm.DB) error {
+		if _, err := db.Model(transfer).Column("status", "reason", "updated_at").WherePK().Update(); err != nil {
+			return err
+		}
+		return db.Insert(event)
+	})
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return event, nil
+}
+
+// ListEvents returns the timeline of a transfer, oldest first
+func (t *TransferRepo) ListEvents(transferID int) ([]model.TransferEvent, error) {
+	var events []model.TransferEvent
+	sql := `SELECT * FROM transfer_events WHERE (transfer_id = ? and deleted_at is null) ORDER BY id`
+	_, err := t.db.Query(&events, sql, transferID)
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return events, nil
+}
+
+// SumSince returns the total amount a user transferred in direction since the given time,
+// rejected, canceled and returned transfers are left out
+func (t *TransferRepo) SumSince(userID int, direction string, since time.Time) (float64, error) {
+	total, err := sumSince(t.db, userID, direction, since)
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return 0, apperr.DB
+	}
+	return total, nil
+}
+
+func sumSince(db orm.DB, userID int, direction string, since time.Time) (float64, error) {
+	var total float64
+	sql := `SELECT COALESCE(SUM(amount), 0) FROM transfers
+		WHERE user_id = ? AND direction = ? AND created_at >= ? AND status NOT IN (?, ?, ?) AND deleted_at IS NULL`
+	_, err := db.QueryOne(pg.Scan(&total), sql, userID, direction, since,
+		model.TransferStatusRejected, model.TransferStatusCanceled, model.TransferStatusReturned)
+	return total, err
+}
+
+// Reserve records a transfer as pending once check accepts it. The user is locked while check sums up
+// their ledger and the transfer is inserted, so concurrent transfers can't both fit in what is left of a limit
+func (t *TransferRepo) Reserve(transfer *model.Transfer, check func(model.TransferSum) error) error {
+	transfer.Status = model.TransferStatusPending
+	err := inTx(t.db, func(db orm.DB) error {
+		if _, err := db.Exec(`SELECT id FROM users WHERE id = ? FOR UPDATE`, transfer.UserID); err != nil {
+			return err
+		}
+		err := check(func(userID int, direction string, since time.Time) (float64, error) {
+			return sumSince(db, userID, direction, since)
+		})
+		if err != nil {
+			return err
+		}
+		if err := db.Insert(transfer); err != nil {
+			return err
+		}
+		return db.Insert(&model.TransferEvent{TransferID: transfer.ID, ToStatus: transfer.Status})
+	})
+	if e, ok := err.(*apperr.APPError); ok {
+		return e
+	}
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// Accept links a pending transfer to the transfer the broker created and moves it to the broker's status
+func (t *TransferRepo) Accept(transfer *model.Transfer, transferID, status string) error {
+	event := &model.TransferEvent{TransferID: transfer.ID, FromStatus: transfer.Status, ToStatus: status}
+	transfer.TransferID = transferID
+	transfer.Status = status
+	err := inTx(t.db, func(db orm.DB) error {
+		if _, err := db.Model(transfer).Column("transfer_id", "status", "updated_at").WherePK().Update(); err != nil {
+			return err
+		}
+		return db.Insert(event)
+	})
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// Discard removes a pending transfer the broker refused, together with its timeline
+func (t *TransferRepo) Discard(transfer *model.Transfer) error {
+	err := inTx(t.db, func(db orm.DB) error {
+		if _, err := db.Exec(`DELETE FROM transfer_events WHERE transfer_id = ?`, transfer.ID); err != nil {
+			return err
+		}
+		_, err := db.Exec(`DELETE FROM transfers WHERE id = ? AND status = ?`, transfer.ID, model.TransferStatusPending)
+		return err
+	})
+	if err != nil {
+		t.log.Warn("TransferRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
+
+// NewTransferLimitRepo returns a new TransferLimitRepo instance
+func NewTransferLimitRepo(db orm.DB, log *zap.Logger) *TransferLimitRepo {
+	return &TransferLimitRepo{db, log}
+}
+
+// TransferLimitRepo is the client for admin overrides of the transfer limits
+type TransferLimitRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// FindByUser returns the overrides of a user, with every cap unset when an admin never changed them
+func (t *TransferLimitRepo) FindByUser(userID int) (*model.TransferLimit, error) {
+	var limit = new(model.TransferLimit)
+	sql := `SELECT * FROM transfer_limits WHERE (user_id = ? and deleted_at is null) LIMIT 1`
+	res, err := t.db.Query(limit, sql, userID)
+	if err != nil {
+		t.log.Warn("TransferLimitRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	if res.RowsReturned() == 0 {
+		return &model.TransferLimit{UserID: userID}, nil
+	}
+	return limit, nil
+}
+
+// Save stores a user's overrides together with their per transfer limit
+func (t *TransferLimitRepo) Save(limit *model.TransferLimit, u *model.User) (*model.TransferLimit, error) {
+	err := inTx(t.db, func(db orm.DB) error {
+		if limit.ID == 0 {
+			if err := db.Insert(limit); err != nil {
+				return err
+			}
+		} else {
+			_, err := db.Model(limit).Column(
+				"deposit_daily",
+				"deposit_weekly",
+				"deposit_monthly",
+				"withdrawal_daily",
+				"withdrawal_weekly",
+				"withdrawal_monthly",
+				"updated_at",
+			).WherePK().Update()
+			if err != nil {
+				return err
+			}
+		}
+		u.Update()
+		_, err := db.Model(u).Column("per_account_limit", "updated_at").WherePK().Update()
+		return err
+	})
+	if err != nil {
+		t.log.Warn("TransferLimitRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return limit, nil
+}
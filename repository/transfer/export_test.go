@@ -0,0 +1,12 @@
+package transfer
+
+import "time"
+
+// SetNow pins the clock used for limit periods and returns a func restoring it
+func SetNow(t time.Time) func() {
+	now = func() time.Time { return t }
+	return func() { now = time.Now }
+}
+
+// HashOTP exposes how withdrawal OTPs are stored
+var HashOTP = hashOTP
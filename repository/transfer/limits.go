@@ -0,0 +1,212 @@
+package transfer
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit periods, each one resets at the start of its calendar period in UTC
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+var periods = []string{Daily, Weekly, Monthly}
+
+// now is swapped in tests to pin the limit periods
+var now = time.Now
+
+// Window is the cap of one period and how much of it has been used
+type Window struct {
+	Period string  `json:"period"`
+	Limit  float64 `json:"limit"`
+	Used   float64 `json:"used"`
+	// Remaining is nil when the period has no limit
+	Remaining *float64  `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Limits are the transfer caps of a user and what remains of them
+type Limits struct {
+	// PerTransfer caps a single transfer, 0 means no limit
+	PerTransfer float64  `json:"per_transfer"`
+	Deposit     []Window `json:"deposit"`
+	Withdrawal  []Window `json:"withdrawal"`
+}
+
+// UserLimits is what admins see and change for a user
+type UserLimits struct {
+	Overrides *model.TransferLimit `json:"overrides"`
+	Limits    *Limits              `json:"limits"`
+}
+
+// PeriodBounds returns the start of the period containing now and the start of the next one
+func PeriodBounds(period string, now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case Weekly:
+		// weeks start on monday
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case Monthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// caps returns the daily, weekly and monthly caps of direction, overrides win over the global config
+func (s *Service) caps(o *model.TransferLimit, direction string) map[string]float64 {
+	pick := func(override *float64, def float64) float64 {
+		if override != nil {
+			return *override
+		}
+		return def
+	}
+	if direction == model.TransferOutgoing {
+		return map[string]float64{
+			Daily:   pick(o.WithdrawalDaily, s.config.WithdrawalDailyLimit),
+			Weekly:  pick(o.WithdrawalWeekly, s.config.WithdrawalWeeklyLimit),
+			Monthly: pick(o.WithdrawalMonthly, s.config.WithdrawalMonthlyLimit),
+		}
+	}
+	return map[string]float64{
+		Daily:   pick(o.DepositDaily, s.config.DepositDailyLimit),
+		Weekly:  pick(o.DepositWeekly, s.config.DepositWeeklyLimit),
+		Monthly: pick(o.DepositMonthly, s.config.DepositMonthlyLimit),
+	}
+}
+
+// windows returns the limit windows of direction for a user at the given time
+func (s *Service) windows(u *model.User, o *model.TransferLimit, direction string, at time.Time, sum model.TransferSum) ([]Window, error) {
+	caps := s.caps(o, direction)
+	windows := make([]Window, 0, len(periods))
+	for _, period := range periods {
+		start, end := PeriodBounds(period, at)
+		used, err := sum(u.ID, direction, start)
+		if err != nil {
+			return nil, err
+		}
+		w := Window{Period: period, Limit: caps[period], Used: used, ResetsAt: end}
+		if w.Limit > 0 {
+			remaining := w.Limit - used
+			if remaining < 0 {
+				remaining = 0
+			}
+			w.Remaining = &remaining
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// Limits returns the transfer caps of a user and what remains of them
+func (s *Service) Limits(u *model.User) (*Limits, error) {
+	o, err := s.limitRepo.FindByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.limits(u, o)
+}
+
+func (s *Service) limits(u *model.User, o *model.TransferLimit) (*Limits, error) {
+	t := now()
+	deposit, err := s.windows(u, o, model.TransferIncoming, t, s.transferRepo.SumSince)
+	if err != nil {
+		return nil, err
+	}
+	withdrawal, err := s.windows(u, o, model.TransferOutgoing, t, s.transferRepo.SumSince)
+	if err != nil {
+		return nil, err
+	}
+	return &Limits{PerTransfer: u.PerAccountLimit, Deposit: deposit, Withdrawal: withdrawal}, nil
+}
+
+// checkLimits rejects a transfer of amount in direction which would go over one of the user's caps,
+// sum adds up what the user already transferred
+func (s *Service) checkLimits(u *model.User, direction string, amount float64, sum model.TransferSum) error {
+	if u.PerAccountLimit > 0 && amount > u.PerAccountLimit {
+		return apperr.New(http.StatusBadRequest, fmt.Sprintf("A single transfer can't be more than $%.2f.", u.PerAccountLimit))
+	}
+	o, err := s.limitRepo.FindByUser(u.ID)
+	if err != nil {
+		return err
+	}
+	windows, err := s.windows(u, o, direction, now(), sum)
+	if err != nil {
+		return err
+	}
+	kind := "deposit"
+	if direction == model.TransferOutgoing {
+		kind = "withdrawal"
+	}
+	for _, w := range windows {
+		if w.Remaining != nil && amount > *w.Remaining {
+			return apperr.New(http.StatusBadRequest, fmt.Sprintf("This transfer is over your %s %s limit, you have $%.2f left until %s.",
+				w.Period, kind, *w.Remaining, w.ResetsAt.Format("Jan 2, 2006 15:04 MST")))
+		}
+	}
+	return nil
+}
+
+// ViewUserLimits returns the overrides and effective limits of any user, admins only
+func (s *Service) ViewUserLimits(c *gin.Context, userID int) (*UserLimits, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	u, err := s.userRepo.View(userID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.limitRepo.FindByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := s.limits(u, o)
+	if err != nil {
+		return nil, err
+	}
+	return &UserLimits{o, limits}, nil
+}
+
+// UpdateUserLimits replaces the overrides of a user, a nil cap goes back to the global default.
+// A nil perTransfer keeps the user's per transfer limit as it is. Admins only
+func (s *Service) UpdateUserLimits(c *gin.Context, userID int, update *model.TransferLimit, perTransfer *float64) (*UserLimits, error) {
+	if !s.rbac.EnforceRole(c, model.AdminRole) {
+		return nil, apperr.New(http.StatusForbidden, "Forbidden")
+	}
+	u, err := s.userRepo.View(userID)
+	if err != nil {
+		return nil, err
+	}
+	o, err := s.limitRepo.FindByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	o.DepositDaily = update.DepositDaily
+	o.DepositWeekly = update.DepositWeekly
+	o.DepositMonthly = update.DepositMonthly
+	o.WithdrawalDaily = update.WithdrawalDaily
+	o.WithdrawalWeekly = update.WithdrawalWeekly
+	o.WithdrawalMonthly = update.WithdrawalMonthly
+	if perTransfer != nil {
+		u.PerAccountLimit = *perTransfer
+	}
+	if o, err = s.limitRepo.Save(o, u); err != nil {
+		return nil, err
+	}
+	limits, err := s.limits(u, o)
+	if err != nil {
+		return nil, err
+	}
+	return &UserLimits{o, limits}, nil
+}
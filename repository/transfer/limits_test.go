@@ -0,0 +1,153 @@
+package transfer_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/transfer"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestPeriodBounds(t *testing.T) {
+	// a wednesday
+	now := time.Date(2021, time.September, 15, 13, 30, 0, 0, time.UTC)
+	cases := []struct {
+		period     string
+		start, end time.Time
+	}{
+		{transfer.Daily, time.Date(2021, time.September, 15, 0, 0, 0, 0, time.UTC), time.Date(2021, time.September, 16, 0, 0, 0, 0, time.UTC)},
+		{transfer.Weekly, time.Date(2021, time.September, 13, 0, 0, 0, 0, time.UTC), time.Date(2021, time.September, 20, 0, 0, 0, 0, time.UTC)},
+		{transfer.Monthly, time.Date(2021, time.September, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range cases {
+		start, end := transfer.PeriodBounds(tt.period, now)
+		assert.Equal(t, tt.start, start, tt.period)
+		assert.Equal(t, tt.end, end, tt.period)
+	}
+
+	// sunday still belongs to the week which started on monday
+	start, _ := transfer.PeriodBounds(transfer.Weekly, time.Date(2021, time.September, 19, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2021, time.September, 13, 0, 0, 0, 0, time.UTC), start)
+}
+
+func TestDepositLimits(t *testing.T) {
+	// a wednesday, so the day, week and month all start at different times
+	now := time.Date(2021, time.September, 15, 13, 30, 0, 0, time.UTC)
+	defer transfer.SetNow(now)()
+	override := 0.0
+	cases := []struct {
+		name      string
+		user      *model.User
+		amount    float64
+		used      map[string]float64
+		overrides *model.TransferLimit
+		status    int
+	}{
+		{
+			name:   "within every limit",
+			user:   &model.User{ID: 1, AccountID: "acc-1"},
+			amount: 500,
+			used:   map[string]float64{transfer.Daily: 400, transfer.Weekly: 1000, transfer.Monthly: 2000},
+		},
+		{
+			name:   "over the daily limit",
+			user:   &model.User{ID: 1, AccountID: "acc-1"},
+			amount: 700,
+			used:   map[string]float64{transfer.Daily: 400, transfer.Weekly: 400, transfer.Monthly: 400},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "over the monthly limit",
+			user:   &model.User{ID: 1, AccountID: "acc-1"},
+			amount: 100,
+			used:   map[string]float64{transfer.Daily: 0, transfer.Weekly: 0, transfer.Monthly: 4950},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:      "admin removed the daily limit",
+			user:      &model.User{ID: 1, AccountID: "acc-1"},
+			amount:    700,
+			used:      map[string]float64{transfer.Daily: 400, transfer.Weekly: 400, transfer.Monthly: 400},
+			overrides: &model.TransferLimit{UserID: 1, DepositDaily: &override},
+		},
+		{
+			name:   "over the per transfer limit",
+			user:   &model.User{ID: 1, AccountID: "acc-1", PerAccountLimit: 250},
+			amount: 300,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded []*model.Transfer
+			transferRepo := &mockdb.Transfer{
+				ReserveFn: func(tr *model.Transfer, check func(model.TransferSum) error) error {
+					err := check(func(userID int, direction string, since time.Time) (float64, error) {
+						for period, used := range tt.used {
+							if start, _ := transfer.PeriodBounds(period, now); start.Equal(since) {
+								return used, nil
+							}
+						}
+						return 0, nil
+					})
+					if err != nil {
+						return err
+					}
+					recorded = append(recorded, tr)
+					return nil
+				},
+				AcceptFn: func(tr *model.Transfer, transferID, status string) error {
+					tr.TransferID = transferID
+					tr.Status = status
+					return nil
+				},
+			}
+			limitRepo := &mockdb.TransferLimit{
+				FindByUserFn: func(id int) (*model.TransferLimit, error) {
+					if tt.overrides != nil {
+						return tt.overrides, nil
+					}
+					return &model.TransferLimit{UserID: id}, nil
+				},
+			}
+			// banks attached before they were stored locally are only known to the broker
+			bankAccounts := &mockdb.BankAccount{
+				FindByRelationshipFn: func(int, string) (*model.BankAccount, error) {
+					return nil, apperr.New(http.StatusNotFound, "Bank account not found.")
+				},
+			}
+			b := &mock.Broker{
+				CreateTransferFn: func(id string, r *broker.TransferRequest) (*broker.Transfer, error) {
+					return &broker.Transfer{ID: "t-1", Amount: r.Amount, Direction: r.Direction, Status: "QUEUED"}, nil
+				},
+			}
+			cfg := &config.TransferConfig{DepositDailyLimit: 1000, DepositWeeklyLimit: 2500, DepositMonthlyLimit: 5000}
+			s := transfer.NewTransferService(nil, nil, transferRepo, limitRepo, bankAccounts, nil, b, nil, nil, cfg, nil, nil, zap.NewNop())
+
+			_, err := s.Deposit(tt.user, "bank-1", tt.amount)
+			if tt.status != 0 {
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+				assert.Empty(t, recorded)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.Len(t, recorded, 1) {
+				assert.Equal(t, model.TransferIncoming, recorded[0].Direction)
+				assert.Equal(t, tt.amount, recorded[0].Amount)
+				assert.Equal(t, "t-1", recorded[0].TransferID)
+			}
+		})
+	}
+}
@@ -1,26 +1,335 @@
 package transfer
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mail"
 	"github.com/alpacahq/ribbit-backend/model"
+
 	"github.com/go-pg/pg/v9/orm"
 	"go.uber.org/zap"
 )
 
-// NewAuthService creates new auth service
-func NewTransferService(userRepo model.UserRepo, accountRepo model.AccountRepo, jwt JWT, db orm.DB, log *zap.Logger) *Service {
-	return &Service{userRepo, accountRepo, jwt, db, log}
+// NewTransferService creates new transfer service
+func NewTransferService(userRepo model.UserRepo, otpRepo model.WithdrawalOTPRepo, transferRepo model.TransferRepo, limitRepo model.TransferLimitRepo, bankAccountRepo model.BankAccountRepo, rbac model.RBACService, broker broker.Service, mail mail.Service, secondFactor SecondFactor, config *config.TransferConfig, jwt JWT, db orm.DB, log *zap.Logger) *Service {
+	return &Service{userRepo, otpRepo, transferRepo, limitRepo, bankAccountRepo, rbac, broker, mail, secondFactor, config, jwt, db, log}
 }
 
-// Service represents the auth application service
+// Service represents the transfer application service
 type Service struct {
-	userRepo    model.UserRepo
-	accountRepo model.AccountRepo
-	jwt         JWT
-	db          orm.DB
-	log         *zap.Logger
+	userRepo        model.UserRepo
+	otpRepo         model.WithdrawalOTPRepo
+	transferRepo    model.TransferRepo
+	limitRepo       model.TransferLimitRepo
+	bankAccountRepo model.BankAccountRepo
+	rbac            model.RBACService
+	broker          broker.Service
+	mail            mail.Service
+	secondFactor    SecondFactor
+	config          *config.TransferConfig
+	jwt             JWT
+	db              orm.DB
+	log             *zap.Logger
 }
 
 // JWT represents jwt interface
 type JWT interface {
 	GenerateToken(*model.User) (string, string, error)
 }
+
+// SecondFactor checks users with two-factor authentication confirmed it recently
+type SecondFactor interface {
+	RequireFresh(userID int) error
+}
+
+var (
+	errNoAccount          = apperr.New(http.StatusBadRequest, "Account not found.")
+	errInvalidAmount      = apperr.New(http.StatusBadRequest, "Amount must be greater than 0.")
+	errVerificationNeeded = apperr.New(http.StatusForbidden, "Please verify the withdrawal with the OTP sent to your email.")
+	errInvalidOTP         = apperr.New(http.StatusUnauthorized, "Invalid OTP")
+	errExpiredOTP         = apperr.New(http.StatusUnauthorized, "OTP expired, please request a new one.")
+	errOTPMismatch        = apperr.New(http.StatusUnauthorized, "This OTP was sent for a different withdrawal, please request a new one.")
+	errBankNotFound       = apperr.New(http.StatusNotFound, "Bank account not found.")
+	errBankNotApproved    = apperr.New(http.StatusBadRequest, "Bank account isn't approved yet.")
+	errInsufficientCash   = apperr.New(http.StatusBadRequest, "Amount is more than your withdrawable cash.")
+	errBankNotVerified    = apperr.New(http.StatusForbidden, "Please confirm the micro-deposit amounts before using this bank account.")
+)
+
+// Deposit pulls amount from the linked bank bankID into the user's account
+func (s *Service) Deposit(u *model.User, bankID string, amount float64) (*broker.Transfer, error) {
+	if u.AccountID == "" {
+		return nil, errNoAccount
+	}
+	if amount <= 0 {
+		return nil, errInvalidAmount
+	}
+	if err := s.checkVerified(u, bankID); err != nil {
+		return nil, err
+	}
+	return s.create(u, bankID, model.TransferIncoming, amount)
+}
+
+// RequestWithdrawalOTP emails the user a one time password which confirms their next withdrawal,
+// only of amount to the linked bank bankID
+func (s *Service) RequestWithdrawalOTP(u *model.User, bankID string, amount float64) error {
+	if amount <= 0 {
+		return errInvalidAmount
+	}
+	code, err := newOTP()
+	if err != nil {
+		s.log.Error("TransferService Error", zap.Error(err))
+		return apperr.Generic
+	}
+	otp := &model.WithdrawalOTP{
+		UserID:         u.ID,
+		RelationshipID: bankID,
+		Amount:         amount,
+		CodeHash:       hashOTP(code),
+		ExpiresAt:      now().Add(s.config.WithdrawalOTPTTL),
+	}
+	if _, err := s.otpRepo.Create(otp); err != nil {
+		return err
+	}
+	if err := s.mail.SendWithdrawalOTPEmail(u.Email, code, amount, s.bankName(u, bankID)); err != nil {
+		s.log.Warn("TransferService Error", zap.Int("user_id", u.ID), zap.Error(err))
+		return apperr.New(http.StatusInternalServerError, "Failed to send the OTP, please try again.")
+	}
+	return nil
+}
+
+// Withdraw sends amount of the user's withdrawable cash to the linked bank bankID.
+// The user must either have logged in recently or confirm the withdrawal with an OTP, and users with
+// two-factor authentication must have confirmed their second factor recently as well.
+func (s *Service) Withdraw(u *model.User, bankID string, amount float64, otp string) (*broker.Transfer, error) {
+	if u.AccountID == "" {
+		return nil, errNoAccount
+	}
+	if amount <= 0 {
+		return nil, errInvalidAmount
+	}
+	if err := s.verifyRecentAuth(u, bankID, amount, otp); err != nil {
+		return nil, err
+	}
+	if err := s.secondFactor.RequireFresh(u.ID); err != nil {
+		return nil, err
+	}
+	if err := s.checkBank(u.AccountID, bankID); err != nil {
+		return nil, err
+	}
+	if err := s.checkVerified(u, bankID); err != nil {
+		return nil, err
+	}
+
+	account, err := s.broker.GetTradingAccount(u.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	withdrawable, err := strconv.ParseFloat(account.CashWithdrawable, 64)
+	if err != nil {
+		s.log.Warn("TransferService Error", zap.String("cash_withdrawable", account.CashWithdrawable), zap.Error(err))
+		return nil, apperr.Generic
+	}
+	if amount > withdrawable {
+		return nil, errInsufficientCash
+	}
+	t, err := s.create(u, bankID, model.TransferOutgoing, amount)
+	if err != nil {
+		return nil, err
+	}
+	s.sendWithdrawalEmail(u, t)
+	return t, nil
+}
+
+// Timeline is a transfer with the history of its status changes
+type Timeline struct {
+	*model.Transfer
+	Events []model.TransferEvent `json:"events"`
+}
+
+// Timeline returns a transfer of the user with its status changes
+func (s *Service) Timeline(u *model.User, id int) (*Timeline, error) {
+	t, err := s.transferRepo.View(u.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.transferRepo.ListEvents(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Timeline{t, events}, nil
+}
+
+// Cancel cancels a transfer which hasn't been sent to clearing yet and records it in our ledger,
+// it returns the broker's response
+func (s *Service) Cancel(u *model.User, transferID string) (interface{}, error) {
+	if u.AccountID == "" {
+		return nil, errNoAccount
+	}
+	resp, err := s.broker.DeleteTransfer(u.AccountID, transferID)
+	if err != nil {
+		return nil, err
+	}
+	// transfers made before the ledger existed only live at the broker
+	t, err := s.transferRepo.FindByTransferID(u.ID, transferID)
+	if err != nil {
+		return resp, nil
+	}
+	if _, err := s.transferRepo.UpdateStatus(t, model.TransferStatusCanceled, ""); err != nil {
+		s.log.Error("TransferService Error", zap.Int("user_id", u.ID), zap.String("transfer_id", transferID), zap.Error(err))
+	}
+	return resp, nil
+}
+
+// create checks the user's limits and records the transfer as pending in our ledger in one go, so it counts
+// towards the limits of any other transfer before it's requested from the broker. A transfer the broker refuses
+// is discarded again. The money is already moving once the broker accepts it, so failing to record its id is only logged.
+func (s *Service) create(u *model.User, bankID, direction string, amount float64) (*broker.Transfer, error) {
+	entry := &model.Transfer{
+		UserID:         u.ID,
+		AccountID:      u.AccountID,
+		RelationshipID: bankID,
+		Direction:      direction,
+		Amount:         amount,
+	}
+	err := s.transferRepo.Reserve(entry, func(sum model.TransferSum) error {
+		return s.checkLimits(u, direction, amount, sum)
+	})
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.broker.CreateTransfer(u.AccountID, &broker.TransferRequest{
+		TransferType:   "ach",
+		RelationshipID: bankID,
+		Amount:         strconv.FormatFloat(amount, 'f', 2, 64),
+		Direction:      direction,
+	})
+	if err != nil {
+		if err := s.transferRepo.Discard(entry); err != nil {
+			s.log.Error("TransferService Error", zap.Int("user_id", u.ID), zap.Int("id", entry.ID), zap.Error(err))
+		}
+		return nil, err
+	}
+	if err := s.transferRepo.Accept(entry, t.ID, t.Status); err != nil {
+		s.log.Error("TransferService Error", zap.Int("user_id", u.ID), zap.String("transfer_id", t.ID), zap.Error(err))
+	}
+	return t, nil
+}
+
+// verifyRecentAuth accepts a login within the reauth window, otherwise code must match the last
+// unexpired OTP issued to u for a withdrawal of amount to bankID. Wrong guesses are counted and lock
+// the OTP after the configured attempts
+func (s *Service) verifyRecentAuth(u *model.User, bankID string, amount float64, code string) error {
+	if code == "" {
+		if u.LastLogin != nil && now().Sub(*u.LastLogin) <= s.config.WithdrawalReauthWindow {
+			return nil
+		}
+		return errVerificationNeeded
+	}
+	otp, err := s.otpRepo.FindLatest(u.ID)
+	if err != nil {
+		return errInvalidOTP
+	}
+	if otp.UsedAt != nil || !now().Before(otp.ExpiresAt) {
+		return errExpiredOTP
+	}
+	if subtle.ConstantTimeCompare([]byte(hashOTP(code)), []byte(otp.CodeHash)) != 1 {
+		if err := s.otpRepo.AddAttempt(otp, s.config.WithdrawalOTPMaxAttempts); err != nil {
+			return err
+		}
+		return errInvalidOTP
+	}
+	// a correct code is refused too once the guesses are used up, it may have been brute forced
+	if otp.Attempts >= s.config.WithdrawalOTPMaxAttempts {
+		return errExpiredOTP
+	}
+	if otp.RelationshipID != bankID || math.Round(otp.Amount*100) != math.Round(amount*100) {
+		return errOTPMismatch
+	}
+	return s.otpRepo.Use(otp)
+}
+
+// newOTP returns a random six digit code, only its hash is stored
+func newOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashOTP(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkBank makes sure bankID is an approved bank of the account that is past its cooling-off period
+func (s *Service) checkBank(accountID, bankID string) error {
+	relationships, err := s.broker.ListACHRelationships(accountID)
+	if err != nil {
+		return err
+	}
+	for _, r := range relationships {
+		if r.ID != bankID {
+			continue
+		}
+		if r.Status != "APPROVED" {
+			return errBankNotApproved
+		}
+		linked, err := time.Parse(time.RFC3339, r.CreatedAt)
+		if err != nil {
+			s.log.Warn("TransferService Error", zap.String("created_at", r.CreatedAt), zap.Error(err))
+			return apperr.Generic
+		}
+		if available := linked.Add(s.config.WithdrawalCoolingOff); now().Before(available) {
+			return apperr.New(http.StatusForbidden, fmt.Sprintf("Withdrawals to a newly linked bank are available from %s.", available.UTC().Format("Jan 2, 2006 15:04 MST")))
+		}
+		return nil
+	}
+	return errBankNotFound
+}
+
+// checkVerified rejects manually entered banks whose micro-deposits aren't confirmed yet.
+// Banks attached before they were stored locally only exist at the broker and are accepted.
+func (s *Service) checkVerified(u *model.User, bankID string) error {
+	account, err := s.bankAccountRepo.FindByRelationship(u.ID, bankID)
+	if err != nil {
+		return nil
+	}
+	if !account.Usable() {
+		return errBankNotVerified
+	}
+	return nil
+}
+
+// bankName names the linked bank bankID in emails, as far as we know it
+func (s *Service) bankName(u *model.User, bankID string) string {
+	account, err := s.bankAccountRepo.FindByRelationship(u.ID, bankID)
+	if err != nil || account.BankName == "" {
+		return "your linked bank account"
+	}
+	if account.Mask != "" {
+		return account.BankName + " account ending in " + account.Mask
+	}
+	return account.BankName
+}
+
+// sendWithdrawalEmail confirms a requested withdrawal to the user, failures are only logged
+func (s *Service) sendWithdrawalEmail(u *model.User, t *broker.Transfer) {
+	content := fmt.Sprintf("We received your request to withdraw $%s to your linked bank account. Funds usually arrive within 1-3 business days. If you didn't request this withdrawal, please contact support immediately.", t.Amount)
+	html := "<p>" + content + "</p>"
+	if err := s.mail.SendWithDefaults("Withdrawal requested", u.Email, content, html); err != nil {
+		s.log.Warn("TransferService Error", zap.Int("user_id", u.ID), zap.Error(err))
+	}
+}
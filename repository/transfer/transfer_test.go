@@ -0,0 +1,375 @@
+package transfer_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/transfer"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWithdraw(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	stale := now.Add(-time.Hour)
+	linkedLongAgo := now.Add(-30 * 24 * time.Hour).Format(time.RFC3339)
+	linkedToday := now.Add(-time.Hour).Format(time.RFC3339)
+
+	cases := []struct {
+		name      string
+		user      *model.User
+		bank      string
+		amount    float64
+		otp       string
+		banks     []broker.ACHRelationship
+		verify    string
+		status    int
+		transfers int
+	}{
+		{
+			name:   "stale login without otp",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 10,
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "otp issued to another user",
+			user:   &model.User{ID: 2, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 10,
+			otp:    "123456",
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong otp",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 10,
+			otp:    "654321",
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "expired otp",
+			user:   &model.User{ID: 3, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 10,
+			otp:    "123456",
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "otp sent for another amount",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 50,
+			otp:    "123456",
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "otp sent for another bank",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-2",
+			amount: 25.5,
+			otp:    "123456",
+			banks:  []broker.ACHRelationship{{ID: "bank-2", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "otp out of attempts",
+			user:   &model.User{ID: 4, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 10,
+			otp:    "654321",
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusTooManyRequests,
+		},
+		{
+			name:   "correct otp out of attempts",
+			user:   &model.User{ID: 4, AccountID: "acc-1", LastLogin: &stale},
+			bank:   "bank-1",
+			amount: 10,
+			otp:    "123456",
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "bank in cooling-off period",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &recent},
+			bank:   "bank-1",
+			amount: 10,
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedToday}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unknown bank",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &recent},
+			bank:   "bank-2",
+			amount: 10,
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "more than withdrawable cash",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &recent},
+			bank:   "bank-1",
+			amount: 100.01,
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "manually entered bank not verified",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &recent},
+			bank:   "bank-1",
+			amount: 10,
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			verify: model.VerificationPending,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "second factor not confirmed recently",
+			user:   &model.User{ID: 1, AccountID: "acc-1", LastLogin: &recent, MFAEnabled: true},
+			bank:   "bank-1",
+			amount: 10,
+			banks:  []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:      "recent login",
+			user:      &model.User{ID: 1, AccountID: "acc-1", LastLogin: &recent},
+			bank:      "bank-1",
+			amount:    100,
+			banks:     []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			transfers: 1,
+		},
+		{
+			name:      "valid otp",
+			user:      &model.User{ID: 1, AccountID: "acc-1", LastLogin: &stale},
+			bank:      "bank-1",
+			amount:    25.5,
+			otp:       "123456",
+			banks:     []broker.ACHRelationship{{ID: "bank-1", Status: "APPROVED", CreatedAt: linkedLongAgo}},
+			transfers: 1,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []*broker.TransferRequest
+			var emails []string
+
+			otps := map[int]*model.WithdrawalOTP{
+				1: {UserID: 1, RelationshipID: "bank-1", Amount: 25.5, CodeHash: transfer.HashOTP("123456"), ExpiresAt: now.Add(time.Minute)},
+				3: {UserID: 3, CodeHash: transfer.HashOTP("123456"), ExpiresAt: now.Add(-time.Minute)},
+				4: {UserID: 4, CodeHash: transfer.HashOTP("123456"), ExpiresAt: now.Add(time.Minute), Attempts: 5},
+			}
+			otpRepo := &mockdb.WithdrawalOTP{
+				FindLatestFn: func(userID int) (*model.WithdrawalOTP, error) {
+					if otp, ok := otps[userID]; ok {
+						return otp, nil
+					}
+					return nil, errors.New("not found")
+				},
+				AddAttemptFn: func(otp *model.WithdrawalOTP, maxAttempts int) error {
+					if otp.Attempts >= maxAttempts {
+						return apperr.New(http.StatusTooManyRequests, "Too many attempts, please request a new OTP.")
+					}
+					otp.Attempts++
+					return nil
+				},
+				UseFn: func(otp *model.WithdrawalOTP) error {
+					used := time.Now()
+					otp.UsedAt = &used
+					return nil
+				},
+			}
+			b := &mock.Broker{
+				ListACHRelationshipsFn: func(string) ([]broker.ACHRelationship, error) {
+					return tt.banks, nil
+				},
+				GetTradingAccountFn: func(id string) (*broker.TradingAccount, error) {
+					return &broker.TradingAccount{ID: id, CashWithdrawable: "100"}, nil
+				},
+				CreateTransferFn: func(id string, r *broker.TransferRequest) (*broker.Transfer, error) {
+					requests = append(requests, r)
+					return &broker.Transfer{ID: "t-1", AccountID: id, Amount: r.Amount, Direction: r.Direction}, nil
+				},
+			}
+			m := &mock.Mail{
+				SendWithDefaultsFn: func(subject, toEmail, content, html string) error {
+					emails = append(emails, subject)
+					return nil
+				},
+			}
+			transferRepo := &mockdb.Transfer{
+				ReserveFn: func(tr *model.Transfer, check func(model.TransferSum) error) error {
+					return check(func(int, string, time.Time) (float64, error) {
+						return 0, nil
+					})
+				},
+				AcceptFn: func(*model.Transfer, string, string) error {
+					return nil
+				},
+			}
+			limitRepo := &mockdb.TransferLimit{
+				FindByUserFn: func(id int) (*model.TransferLimit, error) {
+					return &model.TransferLimit{UserID: id}, nil
+				},
+			}
+			bankAccounts := &mockdb.BankAccount{
+				FindByRelationshipFn: func(userID int, relationshipID string) (*model.BankAccount, error) {
+					return &model.BankAccount{UserID: userID, RelationshipID: relationshipID, Verification: tt.verify}, nil
+				},
+			}
+			secondFactor := &mock.SecondFactor{
+				RequireFreshFn: func(int) error {
+					if tt.user.MFAEnabled {
+						return apperr.New(http.StatusForbidden, "Please confirm with a code from your authenticator app first.")
+					}
+					return nil
+				},
+			}
+			cfg := &config.TransferConfig{WithdrawalCoolingOff: 72 * time.Hour, WithdrawalReauthWindow: 5 * time.Minute, WithdrawalOTPMaxAttempts: 5}
+			s := transfer.NewTransferService(nil, otpRepo, transferRepo, limitRepo, bankAccounts, nil, b, m, secondFactor, cfg, nil, nil, zap.NewNop())
+
+			tr, err := s.Withdraw(tt.user, tt.bank, tt.amount, tt.otp)
+			if tt.status != 0 {
+				assert.Nil(t, tr)
+				if assert.IsType(t, &apperr.APPError{}, err) {
+					assert.Equal(t, tt.status, err.(*apperr.APPError).Status)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "OUTGOING", requests[0].Direction)
+				assert.Equal(t, tt.bank, requests[0].RelationshipID)
+			}
+			assert.Len(t, requests, tt.transfers)
+			assert.Len(t, emails, tt.transfers)
+		})
+	}
+}
+
+func TestDepositRefused(t *testing.T) {
+	var discarded []*model.Transfer
+	transferRepo := &mockdb.Transfer{
+		ReserveFn: func(tr *model.Transfer, check func(model.TransferSum) error) error {
+			tr.ID = 7
+			return nil
+		},
+		DiscardFn: func(tr *model.Transfer) error {
+			discarded = append(discarded, tr)
+			return nil
+		},
+	}
+	bankAccounts := &mockdb.BankAccount{
+		FindByRelationshipFn: func(int, string) (*model.BankAccount, error) {
+			return nil, apperr.New(http.StatusNotFound, "Bank account not found.")
+		},
+	}
+	b := &mock.Broker{
+		CreateTransferFn: func(string, *broker.TransferRequest) (*broker.Transfer, error) {
+			return nil, apperr.New(http.StatusUnprocessableEntity, "Insufficient funds.")
+		},
+	}
+	s := transfer.NewTransferService(nil, nil, transferRepo, nil, bankAccounts, nil, b, nil, nil, &config.TransferConfig{}, nil, nil, zap.NewNop())
+
+	_, err := s.Deposit(&model.User{ID: 1, AccountID: "acc-1"}, "bank-1", 100)
+	assert.Error(t, err)
+	if assert.Len(t, discarded, 1) {
+		assert.Equal(t, 7, discarded[0].ID)
+	}
+}
+
+func TestRequestWithdrawalOTP(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	defer transfer.SetNow(now)()
+
+	var stored *model.WithdrawalOTP
+	otpRepo := &mockdb.WithdrawalOTP{
+		CreateFn: func(otp *model.WithdrawalOTP) (*model.WithdrawalOTP, error) {
+			stored = otp
+			return otp, nil
+		},
+	}
+	var mailed, bank string
+	var amount float64
+	m := &mock.Mail{
+		SendWithdrawalOTPEmailFn: func(email, code string, a float64, b string) error {
+			mailed, amount, bank = code, a, b
+			return nil
+		},
+	}
+	bankAccounts := &mockdb.BankAccount{
+		FindByRelationshipFn: func(userID int, relationshipID string) (*model.BankAccount, error) {
+			return &model.BankAccount{UserID: userID, RelationshipID: relationshipID, BankName: "Chase", Mask: "0000"}, nil
+		},
+	}
+	cfg := &config.TransferConfig{WithdrawalOTPTTL: 10 * time.Minute}
+	s := transfer.NewTransferService(nil, otpRepo, nil, nil, bankAccounts, nil, nil, m, nil, cfg, nil, nil, zap.NewNop())
+
+	assert.NoError(t, s.RequestWithdrawalOTP(&model.User{ID: 1, Email: "john@example.com"}, "bank-1", 25.5))
+	assert.Len(t, mailed, 6)
+	// the email names the withdrawal the otp confirms
+	assert.Equal(t, 25.5, amount)
+	assert.Equal(t, "Chase account ending in 0000", bank)
+	assert.Equal(t, 1, stored.UserID)
+	assert.Equal(t, "bank-1", stored.RelationshipID)
+	assert.Equal(t, 25.5, stored.Amount)
+	assert.Equal(t, transfer.HashOTP(mailed), stored.CodeHash)
+	assert.NotContains(t, stored.CodeHash, mailed)
+	assert.Equal(t, now.Add(10*time.Minute), stored.ExpiresAt)
+}
+
+func TestCancel(t *testing.T) {
+	cases := []struct {
+		name     string
+		local    bool
+		canceled []string
+	}{
+		{name: "records the cancellation in the ledger", local: true, canceled: []string{"t-1"}},
+		{name: "transfer made before the ledger", local: false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var canceled []string
+			b := &mock.Broker{
+				DeleteTransferFn: func(accountID, transferID string) (interface{}, error) {
+					return map[string]interface{}{"id": transferID}, nil
+				},
+			}
+			transferRepo := &mockdb.Transfer{
+				FindByTransferIDFn: func(userID int, transferID string) (*model.Transfer, error) {
+					if !tt.local {
+						return nil, apperr.New(http.StatusNotFound, "Transfer not found.")
+					}
+					return &model.Transfer{ID: 1, UserID: userID, TransferID: transferID, Status: model.TransferStatusQueued}, nil
+				},
+				UpdateStatusFn: func(tr *model.Transfer, status, reason string) (*model.TransferEvent, error) {
+					assert.Equal(t, model.TransferStatusCanceled, status)
+					canceled = append(canceled, tr.TransferID)
+					return &model.TransferEvent{TransferID: tr.ID, FromStatus: tr.Status, ToStatus: status}, nil
+				},
+			}
+			s := transfer.NewTransferService(nil, nil, transferRepo, nil, nil, nil, b, nil, nil, &config.TransferConfig{}, nil, nil, zap.NewNop())
+
+			resp, err := s.Cancel(&model.User{ID: 1, AccountID: "acc-1"}, "t-1")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"id": "t-1"}, resp)
+			assert.Equal(t, tt.canceled, canceled)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package transfersync
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mail"
+	"github.com/alpacahq/ribbit-backend/mobile"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"go.uber.org/zap"
+)
+
+// notifications holds the message sent to the user when their transfer reaches a status, by direction
+var notifications = map[string]map[string]struct{ subject, body string }{
+	model.TransferIncoming: {
+		model.TransferStatusComplete: {
+			"Your deposit has arrived",
+			"Your deposit of $%s is complete and ready to invest.",
+		},
+		model.TransferStatusReturned: {
+			"Your deposit was returned",
+			"Your deposit of $%s was returned by your bank. Please check your bank account and try again.",
+		},
+	},
+	model.TransferOutgoing: {
+		model.TransferStatusComplete: {
+			"Your withdrawal is on its way",
+			"Your withdrawal of $%s was sent to your bank and should arrive shortly.",
+		},
+		model.TransferStatusReturned: {
+			"Your withdrawal was returned",
+			"Your withdrawal of $%s was returned by your bank and the cash is back in your account. Please check your linked bank account.",
+		},
+	},
+}
+
+// pageSize is how many transfers of an account are asked from the broker at once
+const pageSize = 100
+
+// Rewards pays the referral rewards due when a referred user funds their account
+type Rewards interface {
+	OnFunded(*model.User) error
+}
+
+// NewTransferSyncService creates new transfer sync service
+func NewTransferSyncService(transferRepo model.TransferRepo, userRepo model.UserRepo, broker broker.Service, mail mail.Service, mobile mobile.Service, rewards Rewards, log *zap.Logger) *Service {
+	return &Service{transferRepo, userRepo, broker, mail, mobile, rewards, log}
+}
+
+// Service represents the transfer status sync application service
+type Service struct {
+	transferRepo model.TransferRepo
+	userRepo     model.UserRepo
+	broker       broker.Service
+	mail         mail.Service
+	mobile       mobile.Service
+	rewards      Rewards
+	log          *zap.Logger
+}
+
+// SyncAll polls the broker for the status of every pending transfer and returns the number of transfers that changed
+func (s *Service) SyncAll() (int, error) {
+	pending, err := s.transferRepo.ListPending()
+	if err != nil {
+		return 0, err
+	}
+	byAccount := map[string][]*model.Transfer{}
+	var accounts []string
+	for i := range pending {
+		id := pending[i].AccountID
+		if _, ok := byAccount[id]; !ok {
+			accounts = append(accounts, id)
+		}
+		byAccount[id] = append(byAccount[id], &pending[i])
+	}
+
+	changed := 0
+	for _, accountID := range accounts {
+		atBroker, err := s.findAtBroker(accountID, byAccount[accountID])
+		if err != nil {
+			s.log.Warn("TransferSyncService Error", zap.String("account_id", accountID), zap.Error(err))
+			continue
+		}
+		for _, t := range byAccount[accountID] {
+			bt, ok := atBroker[t.TransferID]
+			if !ok {
+				continue
+			}
+			updated, err := s.Apply(t, bt.Status, bt.Reason)
+			if err != nil {
+				s.log.Warn("TransferSyncService Error", zap.Int("transfer_id", t.ID), zap.Error(err))
+				continue
+			}
+			if updated {
+				changed++
+			}
+		}
+	}
+	return changed, nil
+}
+
+// findAtBroker pages through the transfers of an account, newest first, until every one of pending is found
+// or the broker has no more
+func (s *Service) findAtBroker(accountID string, pending []*model.Transfer) (map[string]broker.Transfer, error) {
+	wanted := make(map[string]bool, len(pending))
+	for _, t := range pending {
+		if t.TransferID != "" {
+			wanted[t.TransferID] = true
+		}
+	}
+	found := make(map[string]broker.Transfer, len(wanted))
+	for offset := 0; len(found) < len(wanted); offset += pageSize {
+		page, err := s.broker.ListTransfers(accountID, pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page {
+			if wanted[t.ID] {
+				found[t.ID] = t
+			}
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+	return found, nil
+}
+
+// Apply moves a transfer to the status reported by the broker and notifies the user when it completed or was returned,
+// a completed deposit also pays any referral reward due on funding. It reports whether the status changed
+func (s *Service) Apply(t *model.Transfer, status, reason string) (bool, error) {
+	if status == "" || status == t.Status {
+		return false, nil
+	}
+	event, err := s.transferRepo.UpdateStatus(t, status, reason)
+	if err != nil {
+		return false, err
+	}
+	s.log.Info("Transfer status changed",
+		zap.Int("transfer_id", t.ID),
+		zap.String("from", event.FromStatus),
+		zap.String("to", event.ToStatus))
+	s.notify(t)
+	if t.Direction == model.TransferIncoming && event.ToStatus == model.TransferStatusComplete {
+		s.funded(t)
+	}
+	return true, nil
+}
+
+// funded pays the referral rewards of the user who made a completed deposit, only their first deposit earns one
+func (s *Service) funded(t *model.Transfer) {
+	u, err := s.userRepo.View(t.UserID)
+	if err == nil {
+		err = s.rewards.OnFunded(u)
+	}
+	if err != nil {
+		s.log.Warn("TransferSyncService Error", zap.Int("user_id", t.UserID), zap.Error(err))
+	}
+}
+
+// notify emails the user about the new status of their transfer, falling back to sms when the email can't be sent
+func (s *Service) notify(t *model.Transfer) {
+	n, ok := notifications[t.Direction][t.Status]
+	if !ok {
+		return
+	}
+	u, err := s.userRepo.View(t.UserID)
+	if err != nil {
+		s.log.Warn("TransferSyncService Error", zap.Int("user_id", t.UserID), zap.Error(err))
+		return
+	}
+	body := fmt.Sprintf(n.body, strconv.FormatFloat(t.Amount, 'f', 2, 64))
+	if u.Email != "" {
+		err := s.mail.SendWithDefaults(n.subject, u.Email, body, "<p>"+body+"</p>")
+		if err == nil {
+			return
+		}
+		s.log.Warn("TransferSyncService Error", zap.Int("user_id", u.ID), zap.Error(err))
+	}
+	if u.Mobile != "" {
+		if err := s.mobile.SendSMS(u.CountryCode, u.Mobile, body); err != nil {
+			s.log.Warn("TransferSyncService Error", zap.Int("user_id", u.ID), zap.Error(err))
+		}
+	}
+}
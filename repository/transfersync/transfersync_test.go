@@ -0,0 +1,106 @@
+package transfersync_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/transfersync"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSyncAll(t *testing.T) {
+	pending := []model.Transfer{
+		{ID: 1, UserID: 1, AccountID: "acc-1", TransferID: "t-1", Direction: model.TransferIncoming, Amount: 100, Status: model.TransferStatusQueued},
+		{ID: 2, UserID: 1, AccountID: "acc-1", TransferID: "t-2", Direction: model.TransferIncoming, Amount: 50, Status: model.TransferStatusSentToClearing},
+		{ID: 3, UserID: 2, AccountID: "acc-2", TransferID: "t-3", Direction: model.TransferOutgoing, Amount: 20, Status: model.TransferStatusSentToClearing},
+		{ID: 4, UserID: 3, AccountID: "acc-3", TransferID: "t-4", Direction: model.TransferIncoming, Amount: 10, Status: model.TransferStatusQueued},
+	}
+	// older transfers of acc-1 push t-2 onto the second page
+	older := make([]broker.Transfer, 150)
+	for i := range older {
+		older[i] = broker.Transfer{ID: fmt.Sprintf("old-%d", i), Status: model.TransferStatusComplete}
+	}
+	atBroker := map[string][]broker.Transfer{
+		"acc-1": append(append([]broker.Transfer{{ID: "t-1", Status: model.TransferStatusSentToClearing}}, older...),
+			broker.Transfer{ID: "t-2", Status: model.TransferStatusComplete}),
+		"acc-2": {
+			{ID: "t-3", Status: model.TransferStatusReturned, Reason: "R01 insufficient funds"},
+		},
+	}
+	var events []string
+	var emails, sms []string
+
+	transferRepo := &mockdb.Transfer{
+		ListPendingFn: func() ([]model.Transfer, error) {
+			return pending, nil
+		},
+		UpdateStatusFn: func(t *model.Transfer, status, reason string) (*model.TransferEvent, error) {
+			events = append(events, t.TransferID+":"+status)
+			e := &model.TransferEvent{TransferID: t.ID, FromStatus: t.Status, ToStatus: status, Reason: reason}
+			t.Status, t.Reason = status, reason
+			return e, nil
+		},
+	}
+	userRepo := &mockdb.User{
+		ViewFn: func(id int) (*model.User, error) {
+			if id == 2 {
+				return &model.User{ID: id, CountryCode: "+1", Mobile: "5550100"}, nil
+			}
+			return &model.User{ID: id, Email: "a@example.com"}, nil
+		},
+	}
+	b := &mock.Broker{
+		ListTransfersFn: func(id string, limit, offset int) ([]broker.Transfer, error) {
+			transfers, ok := atBroker[id]
+			if !ok {
+				return nil, errors.New("broker unavailable")
+			}
+			if offset >= len(transfers) {
+				return nil, nil
+			}
+			if offset+limit < len(transfers) {
+				return transfers[offset : offset+limit], nil
+			}
+			return transfers[offset:], nil
+		},
+	}
+	m := &mock.Mail{
+		SendWithDefaultsFn: func(subject, toEmail, content, html string) error {
+			emails = append(emails, subject)
+			return nil
+		},
+	}
+	mob := &mock.Mobile{
+		SendSMSFn: func(code, mobile, body string) error {
+			sms = append(sms, body)
+			return nil
+		},
+	}
+
+	var funded []int
+	rewards := &mock.Rewards{
+		OnFundedFn: func(u *model.User) error {
+			funded = append(funded, u.ID)
+			return nil
+		},
+	}
+
+	s := transfersync.NewTransferSyncService(transferRepo, userRepo, b, m, mob, rewards, zap.NewNop())
+	changed, err := s.SyncAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, changed)
+	assert.Equal(t, []string{"t-1:SENT_TO_CLEARING", "t-2:COMPLETE", "t-3:RETURNED"}, events)
+	// only completions and returns are worth a notification
+	assert.Equal(t, []string{"Your deposit has arrived"}, emails)
+	assert.Equal(t, []string{"Your withdrawal of $20.00 was returned by your bank and the cash is back in your account. Please check your linked bank account."}, sms)
+	assert.Equal(t, "R01 insufficient funds", pending[2].Reason)
+	// the completed deposit may earn a referral reward
+	assert.Equal(t, []int{1}, funded)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewTrustedContactRepo returns a new TrustedContactRepo instance
+func NewTrustedContactRepo(db orm.DB, log *zap.Logger) *TrustedContactRepo {
+	return &TrustedContactRepo{db, log}
+}
+
+// TrustedContactRepo is the client for our trusted contact model
+type TrustedContactRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+// FindByUser returns the trusted contact of a user
+func (t *TrustedContactRepo) FindByUser(userID int) (*model.TrustedContact, error) {
+	var contact = new(model.TrustedContact)
+	sql := `SELECT * FROM trusted_contacts WHERE (user_id = ? and deleted_at is null) ORDER BY id DESC LIMIT 1`
+	_, err := t.db.QueryOne(contact, sql, userID)
+	if err != nil {
+		t.log.Warn("TrustedContactRepo Error", zap.Error(err))
+		return nil, apperr.New(http.StatusNotFound, "Trusted contact not found.")
+	}
+	return contact, nil
+}
+
+// Create creates a new trusted contact in our database
+func (t *TrustedContactRepo) Create(contact *model.TrustedContact) (*model.TrustedContact, error) {
+	if err := t.db.Insert(contact); err != nil {
+		t.log.Warn("TrustedContactRepo error: ", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return contact, nil
+}
+
+// Update updates a trusted contact's details
+func (t *TrustedContactRepo) Update(contact *model.TrustedContact) (*model.TrustedContact, error) {
+	_, err := t.db.Model(contact).Column(
+		"given_name",
+		"family_name",
+		"email",
+		"phone",
+		"street_address",
+		"city",
+		"state",
+		"postal_code",
+		"country",
+		"updated_at",
+	).WherePK().Update()
+	if err != nil {
+		t.log.Warn("TrustedContactRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return contact, nil
+}
+
+// Delete sets deleted_at for a trusted contact
+func (t *TrustedContactRepo) Delete(contact *model.TrustedContact) error {
+	contact.Delete()
+	_, err := t.db.Model(contact).Column("deleted_at").WherePK().Update()
+	if err != nil {
+		t.log.Warn("TrustedContactRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package trustedcontact
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"go.uber.org/zap"
+)
+
+// NewTrustedContactService creates new trusted contact service
+func NewTrustedContactService(userRepo model.UserRepo, contactRepo model.TrustedContactRepo, broker broker.Service, log *zap.Logger) *Service {
+	return &Service{userRepo, contactRepo, broker, log}
+}
+
+// Service represents the trusted contact application service
+type Service struct {
+	userRepo    model.UserRepo
+	contactRepo model.TrustedContactRepo
+	broker      broker.Service
+	log         *zap.Logger
+}
+
+// View returns the trusted contact of the user
+func (s *Service) View(userID int) (*model.TrustedContact, error) {
+	return s.contactRepo.FindByUser(userID)
+}
+
+// Add stores a new trusted contact and syncs it to the user's broker account if one exists
+func (s *Service) Add(userID int, contact *model.TrustedContact) (*model.TrustedContact, error) {
+	if err := validate(contact); err != nil {
+		return nil, err
+	}
+	if _, err := s.contactRepo.FindByUser(userID); err == nil {
+		return nil, apperr.New(http.StatusConflict, "Trusted contact already exists.")
+	}
+	if err := s.sync(userID, contact); err != nil {
+		return nil, err
+	}
+	contact.UserID = userID
+	return s.contactRepo.Create(contact)
+}
+
+// Update replaces the details of the user's trusted contact
+func (s *Service) Update(userID int, update *model.TrustedContact) (*model.TrustedContact, error) {
+	if err := validate(update); err != nil {
+		return nil, err
+	}
+	contact, err := s.contactRepo.FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sync(userID, update); err != nil {
+		return nil, err
+	}
+	update.Base = contact.Base
+	update.ID = contact.ID
+	update.UserID = userID
+	return s.contactRepo.Update(update)
+}
+
+// Remove deletes the user's trusted contact locally and at the broker
+func (s *Service) Remove(userID int) error {
+	contact, err := s.contactRepo.FindByUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.sync(userID, nil); err != nil {
+		return err
+	}
+	return s.contactRepo.Delete(contact)
+}
+
+// sync patches the trusted contact on the user's broker account, users who haven't signed yet are skipped
+// as the contact is sent along with the account application
+func (s *Service) sync(userID int, contact *model.TrustedContact) error {
+	u, err := s.userRepo.View(userID)
+	if err != nil {
+		return err
+	}
+	if u.AccountID == "" {
+		return nil
+	}
+	patch := map[string]interface{}{"trusted_contact": BrokerContact(contact)}
+	if err := s.broker.UpdateAccount(u.AccountID, patch); err != nil {
+		s.log.Warn("TrustedContactService Error", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// BrokerContact converts a trusted contact to the broker representation, nil stays nil
+func BrokerContact(contact *model.TrustedContact) *broker.TrustedContact {
+	if contact == nil {
+		return nil
+	}
+	tc := &broker.TrustedContact{
+		GivenName:  contact.GivenName,
+		FamilyName: contact.FamilyName,
+		Email:      contact.Email,
+		Phone:      contact.Phone,
+		City:       contact.City,
+		State:      contact.State,
+		PostalCode: contact.PostalCode,
+		Country:    contact.Country,
+	}
+	if contact.StreetAddress != "" {
+		tc.StreetAddress = []string{contact.StreetAddress}
+	}
+	return tc
+}
+
+// validate checks that the broker can reach the contact, it requires at least one of email, phone or address
+func validate(contact *model.TrustedContact) error {
+	if contact.Email == "" && contact.Phone == "" && contact.StreetAddress == "" {
+		return apperr.New(http.StatusBadRequest, "An email, phone or street address is required.")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+// inTx runs fn in a transaction when db can start one, otherwise fn runs on db as is
+// so repos built on top of an existing transaction join it
+func inTx(db orm.DB, fn func(orm.DB) error) error {
+	if pgdb, ok := db.(*pg.DB); ok {
+		return pgdb.RunInTransaction(func(tx *pg.Tx) error {
+			return fn(tx)
+		})
+	}
+	return fn(db)
+}
@@ -152,6 +152,9 @@ func (u *UserRepo) Update(user *model.User) (*model.User, error) {
 		"city",
 		"state",
 		"country",
+		"country_of_citizenship",
+		"country_of_birth",
+		"country_of_tax_residence",
 		"tax_id_type",
 		"tax_id",
 		"funding_source",
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+
+	"github.com/go-pg/pg/v9/orm"
+	"go.uber.org/zap"
+)
+
+// NewWithdrawalOTPRepo returns a new WithdrawalOTPRepo instance
+func NewWithdrawalOTPRepo(db orm.DB, log *zap.Logger) *WithdrawalOTPRepo {
+	return &WithdrawalOTPRepo{db, log}
+}
+
+// WithdrawalOTPRepo is the client for the one time passwords confirming withdrawals
+type WithdrawalOTPRepo struct {
+	db  orm.DB
+	log *zap.Logger
+}
+
+var errWithdrawalOTPUsed = apperr.New(http.StatusConflict, "OTP was already used.")
+
+// Create stores a new OTP, the ones issued to the user before it are no longer accepted
+func (w *WithdrawalOTPRepo) Create(otp *model.WithdrawalOTP) (*model.WithdrawalOTP, error) {
+	err := inTx(w.db, func(db orm.DB) error {
+		_, err := db.Model((*model.WithdrawalOTP)(nil)).
+			Set("used_at = ?", time.Now()).
+			Where("user_id = ? and used_at is null", otp.UserID).
+			Update()
+		if err != nil {
+			return err
+		}
+		return db.Insert(otp)
+	})
+	if err != nil {
+		w.log.Warn("WithdrawalOTPRepo Error", zap.Error(err))
+		return nil, apperr.DB
+	}
+	return otp, nil
+}
+
+// FindLatest returns the OTP issued to the user last
+func (w *WithdrawalOTPRepo) FindLatest(userID int) (*model.WithdrawalOTP, error) {
+	var otp = new(model.WithdrawalOTP)
+	sql := `SELECT * FROM withdrawal_otps WHERE (user_id = ? and deleted_at is null) ORDER BY id DESC LIMIT 1`
+	_, err := w.db.QueryOne(otp, sql, userID)
+	if err != nil {
+		return nil, apperr.New(http.StatusNotFound, "OTP not found.")
+	}
+	return otp, nil
+}
+
+// AddAttempt counts a wrong guess, it fails once the OTP has used up maxAttempts
+func (w *WithdrawalOTPRepo) AddAttempt(otp *model.WithdrawalOTP, maxAttempts int) error {
+	res, err := w.db.Model(otp).
+		Set("attempts = attempts + 1").
+		Set("updated_at = ?", time.Now()).
+		WherePK().
+		Where("attempts < ?", maxAttempts).
+		Returning("attempts").
+		Update()
+	if err != nil {
+		w.log.Warn("WithdrawalOTPRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return apperr.New(http.StatusTooManyRequests, "Too many attempts, please request a new OTP.")
+	}
+	return nil
+}
+
+// Use marks the OTP as redeemed, only if no other request redeemed it first
+func (w *WithdrawalOTPRepo) Use(otp *model.WithdrawalOTP) error {
+	t := time.Now()
+	res, err := w.db.Model(otp).
+		Set("used_at = ?", t).
+		Set("updated_at = ?", t).
+		WherePK().
+		Where("used_at is null").
+		Update()
+	if err != nil {
+		w.log.Warn("WithdrawalOTPRepo Error", zap.Error(err))
+		return apperr.DB
+	}
+	if res.RowsAffected() == 0 {
+		return errWithdrawalOTPUsed
+	}
+	otp.UsedAt = &t
+	return nil
+}
@@ -108,6 +108,9 @@ type Update struct {
 	City                              *string `json:"city"`
 	State                             *string `json:"state"`
 	Country                           *string `json:"country"`
+	CountryOfCitizenship              *string `json:"country_of_citizenship"`
+	CountryOfBirth                    *string `json:"country_of_birth"`
+	CountryOfTaxResidence             *string `json:"country_of_tax_residence"`
 	TaxIDType                         *string `json:"tax_id_type"`
 	TaxID                             *string `json:"tax_id"`
 	FundingSource                     *string `json:"funding_source"`
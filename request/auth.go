@@ -54,3 +54,34 @@ func RecoverPassword(c *gin.Context) (*RecoverPasswordPayload, error) {
 	}
 	return rpp, nil
 }
+
+// LoginMFAPayload stores the challenge token returned by /login and the code of the second factor
+type LoginMFAPayload struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginMFA parses out the challenge token and code in gin's request context, into LoginMFAPayload
+func LoginMFA(c *gin.Context) (*LoginMFAPayload, error) {
+	p := new(LoginMFAPayload)
+	if err := c.ShouldBindJSON(p); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// MFACode stores a code from the authenticator app or a recovery code
+type MFACode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFA parses out the code in gin's request context, into MFACode
+func MFA(c *gin.Context) (*MFACode, error) {
+	p := new(MFACode)
+	if err := c.ShouldBindJSON(p); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return p, nil
+}
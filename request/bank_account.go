@@ -49,3 +49,37 @@ func AccountID(c *gin.Context) (int, error) {
 	}
 	return id, nil
 }
+
+// ManualBankAccount contains the numbers of a bank account entered by hand
+type ManualBankAccount struct {
+	RoutingNumber    string `json:"routing_number" binding:"required,len=9,numeric"`
+	AccountNumber    string `json:"account_number" binding:"required,min=4,max=17,numeric" redact:"true"`
+	BankAccountType  string `json:"bank_account_type" binding:"required,oneof=CHECKING SAVINGS"`
+	AccountOwnerName string `json:"account_owner_name"`
+	Nickname         string `json:"nickname"`
+}
+
+// ManualBankAccountBody validates a manually entered bank account
+func ManualBankAccountBody(c *gin.Context) (*ManualBankAccount, error) {
+	data := new(ManualBankAccount)
+	if err := c.ShouldBindJSON(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// MicroDepositAmounts contains the amounts of the two micro-deposits as seen by the user on their statement
+type MicroDepositAmounts struct {
+	Amounts []float64 `json:"amounts" binding:"required,len=2,dive,gt=0,lt=1"`
+}
+
+// MicroDepositAmountsBody validates confirmed micro-deposit amounts
+func MicroDepositAmountsBody(c *gin.Context) (*MicroDepositAmounts, error) {
+	data := new(MicroDepositAmounts)
+	if err := c.ShouldBindJSON(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,23 @@
+package request
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Redeem contains the coins to redeem and the asset to buy with them
+type Redeem struct {
+	Coins  int    `json:"coins" binding:"required,gt=0"`
+	Symbol string `json:"symbol" binding:"required"`
+}
+
+// RedeemBody validates coin redemptions
+func RedeemBody(c *gin.Context) (*Redeem, error) {
+	data := new(Redeem)
+	if err := c.ShouldBindJSON(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
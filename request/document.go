@@ -0,0 +1,23 @@
+package request
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocumentUpload contains the metadata sent along with an uploaded document
+type DocumentUpload struct {
+	DocumentType    string `form:"document_type" binding:"required,oneof=identity_verification address_verification date_of_birth_verification tax_id_verification account_approval_letter w8ben"`
+	DocumentSubType string `form:"document_sub_type" binding:"omitempty,max=64"`
+}
+
+// Document validates document upload request
+func Document(c *gin.Context) (*DocumentUpload, error) {
+	var d DocumentUpload
+	if err := c.ShouldBind(&d); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return &d, nil
+}
@@ -0,0 +1,56 @@
+package request
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasskeyRegistration is the credential navigator.credentials.create() resolves to, with its binary
+// values base64url encoded, and a name for the passkey
+type PasskeyRegistration struct {
+	Name     string              `json:"name"`
+	ID       string              `json:"id" binding:"required"`
+	Response AttestationResponse `json:"response" binding:"required"`
+}
+
+// AttestationResponse is the AuthenticatorAttestationResponse of a new passkey
+type AttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AttestationObject string `json:"attestationObject" binding:"required"`
+}
+
+// PasskeyRegister parses out the new passkey in gin's request context, into PasskeyRegistration
+func PasskeyRegister(c *gin.Context) (*PasskeyRegistration, error) {
+	p := new(PasskeyRegistration)
+	if err := c.ShouldBindJSON(p); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// PasskeyAssertion is the credential navigator.credentials.get() resolves to, with its binary values
+// base64url encoded
+type PasskeyAssertion struct {
+	ID       string            `json:"id" binding:"required"`
+	Response AssertionResponse `json:"response" binding:"required"`
+}
+
+// AssertionResponse is the AuthenticatorAssertionResponse of a passkey login
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// PasskeyLogin parses out the passkey assertion in gin's request context, into PasskeyAssertion
+func PasskeyLogin(c *gin.Context) (*PasskeyAssertion, error) {
+	p := new(PasskeyAssertion)
+	if err := c.ShouldBindJSON(p); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return p, nil
+}
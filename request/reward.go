@@ -0,0 +1,40 @@
+package request
+
+import (
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RewardProgram contains a version of a referral program. A program without a start date starts right
+// away and one without an end date runs until it is ended or replaced by a new version
+type RewardProgram struct {
+	Name                 string        `json:"name" binding:"required"`
+	StartsAt             *time.Time    `json:"starts_at"`
+	EndsAt               *time.Time    `json:"ends_at"`
+	Type                 string        `json:"type" binding:"required,oneof=cash stock"`
+	Stocks               []RewardStock `json:"stocks" binding:"dive"`
+	Budget               float64       `json:"budget" binding:"gte=0"`
+	PerAccountLimit      int           `json:"per_account_limit" binding:"gte=0"`
+	ReferralKycReward    float64       `json:"referral_kyc_reward" binding:"gte=0"`
+	ReferralSignupReward float64       `json:"referral_signup_reward" binding:"gte=0"`
+	ReferreKycReward     float64       `json:"referre_kyc_reward" binding:"gte=0"`
+}
+
+// RewardStock contains a stock a stock reward may buy and its weight
+type RewardStock struct {
+	Symbol string `json:"symbol" binding:"required"`
+	Weight int    `json:"weight" binding:"required,gt=0"`
+}
+
+// RewardProgramBody validates referral programs
+func RewardProgramBody(c *gin.Context) (*RewardProgram, error) {
+	data := new(RewardProgram)
+	if err := c.ShouldBindJSON(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
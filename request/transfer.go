@@ -0,0 +1,78 @@
+package request
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Withdraw contains the withdrawal amount and the OTP confirming it
+type Withdraw struct {
+	Amount float64 `form:"amount" json:"amount" binding:"required,gt=0"`
+	OTP    string  `form:"otp" json:"otp"`
+}
+
+// WithdrawBody validates withdrawal requests, sent either as a form like deposits or as json
+func WithdrawBody(c *gin.Context) (*Withdraw, error) {
+	data := new(Withdraw)
+	if err := c.ShouldBind(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// WithdrawalOTP contains the amount of the withdrawal an OTP is requested for
+type WithdrawalOTP struct {
+	Amount float64 `form:"amount" json:"amount" binding:"required,gt=0"`
+}
+
+// WithdrawalOTPBody validates withdrawal OTP requests, sent either as a form or as json
+func WithdrawalOTPBody(c *gin.Context) (*WithdrawalOTP, error) {
+	data := new(WithdrawalOTP)
+	if err := c.ShouldBind(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// TransferLimits contains an admin's overrides of a user's transfer caps, a missing cap falls back to the global default
+type TransferLimits struct {
+	DepositDaily      *float64 `json:"deposit_daily" binding:"omitempty,gte=0"`
+	DepositWeekly     *float64 `json:"deposit_weekly" binding:"omitempty,gte=0"`
+	DepositMonthly    *float64 `json:"deposit_monthly" binding:"omitempty,gte=0"`
+	WithdrawalDaily   *float64 `json:"withdrawal_daily" binding:"omitempty,gte=0"`
+	WithdrawalWeekly  *float64 `json:"withdrawal_weekly" binding:"omitempty,gte=0"`
+	WithdrawalMonthly *float64 `json:"withdrawal_monthly" binding:"omitempty,gte=0"`
+	PerTransfer       *float64 `json:"per_transfer" binding:"omitempty,gte=0"`
+}
+
+// TransferLimitsBody validates transfer limit overrides
+func TransferLimitsBody(c *gin.Context) (*TransferLimits, error) {
+	data := new(TransferLimits)
+	if err := c.ShouldBindJSON(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// RecurringDeposit contains a deposit schedule. Day is the weekday, 0 being Sunday, for weekly and
+// biweekly deposits and the day of the month for monthly ones
+type RecurringDeposit struct {
+	BankID    string  `json:"bank_id" binding:"required"`
+	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	Frequency string  `json:"frequency" binding:"required,oneof=weekly biweekly monthly"`
+	Day       int     `json:"day" binding:"min=0,max=31"`
+}
+
+// RecurringDepositBody validates deposit schedules
+func RecurringDepositBody(c *gin.Context) (*RecurringDeposit, error) {
+	data := new(RecurringDeposit)
+	if err := c.ShouldBindJSON(data); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,30 @@
+package request
+
+import (
+	"github.com/alpacahq/ribbit-backend/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedContact contains the trusted contact details
+type TrustedContact struct {
+	GivenName     string `json:"given_name" binding:"required,max=64"`
+	FamilyName    string `json:"family_name" binding:"required,max=64"`
+	Email         string `json:"email" binding:"omitempty,email"`
+	Phone         string `json:"phone" binding:"omitempty,max=20"`
+	StreetAddress string `json:"street_address" binding:"omitempty,max=255"`
+	City          string `json:"city" binding:"omitempty,max=64"`
+	State         string `json:"state" binding:"omitempty,max=64"`
+	PostalCode    string `json:"postal_code" binding:"omitempty,max=10"`
+	Country       string `json:"country" binding:"omitempty,len=3"`
+}
+
+// TrustedContactSave validates trusted contact add and update requests
+func TrustedContactSave(c *gin.Context) (*TrustedContact, error) {
+	var t TrustedContact
+	if err := c.ShouldBindJSON(&t); err != nil {
+		apperr.Response(c, err)
+		return nil, err
+	}
+	return &t, nil
+}
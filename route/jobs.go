@@ -0,0 +1,83 @@
+package route
+
+import (
+	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/repository"
+	"github.com/alpacahq/ribbit-backend/repository/accountsync"
+	"github.com/alpacahq/ribbit-backend/repository/mfa"
+	"github.com/alpacahq/ribbit-backend/repository/passkey"
+	"github.com/alpacahq/ribbit-backend/repository/recurring"
+	"github.com/alpacahq/ribbit-backend/repository/referral"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/alpacahq/ribbit-backend/repository/transfer"
+	"github.com/alpacahq/ribbit-backend/repository/transfersync"
+	"github.com/alpacahq/ribbit-backend/scheduler"
+
+	"go.uber.org/zap"
+)
+
+// SetupJobs registers the periodic background jobs on the scheduler
+func (s *Services) SetupJobs(sched *scheduler.Scheduler) {
+	jobs := config.GetJobsConfig()
+
+	userRepo := repository.NewUserRepo(s.DB, s.Log)
+	bankAccountRepo := repository.NewBankAccountRepo(s.DB, s.Log)
+	rewardService := reward.NewRewardService(userRepo, repository.NewRewardRepo(s.DB, s.Log), bankAccountRepo, repository.NewRBACService(userRepo), s.Broker, config.GetRewardConfig(), s.Log)
+	accountSyncService := accountsync.NewAccountSyncService(repository.NewAccountStatusRepo(s.DB, s.Log), s.Broker, s.Mail, s.Mobile, rewardService, s.Log)
+	sched.Every("sync_accounts", jobs.AccountSyncInterval, func() error {
+		changed, err := accountSyncService.SyncAll()
+		if changed > 0 {
+			s.Log.Info("Synced broker accounts", zap.Int("changed", changed))
+		}
+		return err
+	})
+
+	transferRepo := repository.NewTransferRepo(s.DB, s.Log)
+	transferSyncService := transfersync.NewTransferSyncService(transferRepo, userRepo, s.Broker, s.Mail, s.Mobile, rewardService, s.Log)
+	sched.Every("sync_transfers", jobs.TransferSyncInterval, func() error {
+		changed, err := transferSyncService.SyncAll()
+		if changed > 0 {
+			s.Log.Info("Synced transfers", zap.Int("changed", changed))
+		}
+		return err
+	})
+
+	transferConfig := config.GetTransferConfig()
+	mfaService := mfa.NewMFAService(userRepo, repository.NewMFARepo(s.DB, s.Log), config.GetMFAConfig(), s.Log)
+	transferService := transfer.NewTransferService(userRepo, repository.NewWithdrawalOTPRepo(s.DB, s.Log), transferRepo, repository.NewTransferLimitRepo(s.DB, s.Log), bankAccountRepo, repository.NewRBACService(userRepo), s.Broker, s.Mail, mfaService, transferConfig, s.JWT, s.DB, s.Log)
+	recurringDepositService := recurring.NewRecurringDepositService(userRepo, bankAccountRepo, repository.NewRecurringDepositRepo(s.DB, s.Log), transferService, s.Mail, transferConfig, s.Log)
+	sched.Every("recurring_deposits", jobs.RecurringDepositInterval, func() error {
+		submitted, err := recurringDepositService.RunDue()
+		if submitted > 0 {
+			s.Log.Info("Made recurring deposits", zap.Int("submitted", submitted))
+		}
+		return err
+	})
+
+	sched.Every("retry_rewards", jobs.RewardRetryInterval, func() error {
+		failed, err := rewardService.Reconcile()
+		if failed > 0 {
+			s.Log.Warn("Referral reward journals were rejected", zap.Int("failed", failed))
+		}
+		if err != nil {
+			return err
+		}
+		paid, err := rewardService.RetryDue()
+		if paid > 0 {
+			s.Log.Info("Paid failed referral rewards", zap.Int("paid", paid))
+		}
+		return err
+	})
+
+	passkeyService := passkey.NewPasskeyService(userRepo, repository.NewPasskeyRepo(s.DB, s.Log), mfaService, config.GetPasskeyConfig(), s.Log)
+	sched.Every("purge_passkey_challenges", jobs.PasskeyPurgeInterval, func() error {
+		_, err := passkeyService.Purge()
+		return err
+	})
+
+	referralService := referral.NewReferralService(repository.NewReferralRepo(s.DB, s.Log), config.GetRewardConfig(), s.Log)
+	sched.Every("referral_leaderboard", jobs.LeaderboardInterval, func() error {
+		_, err := referralService.RefreshLeaderboard()
+		return err
+	})
+}
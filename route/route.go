@@ -3,31 +3,48 @@ package route
 import (
 	"net/http"
 
+	"github.com/alpacahq/ribbit-backend/banklink"
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/config"
 	"github.com/alpacahq/ribbit-backend/docs"
+	"github.com/alpacahq/ribbit-backend/geo"
 	"github.com/alpacahq/ribbit-backend/magic"
 	"github.com/alpacahq/ribbit-backend/mail"
+	"github.com/alpacahq/ribbit-backend/microdeposit"
 	mw "github.com/alpacahq/ribbit-backend/middleware"
 	"github.com/alpacahq/ribbit-backend/mobile"
+	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository"
 	"github.com/alpacahq/ribbit-backend/repository/account"
 	assets "github.com/alpacahq/ribbit-backend/repository/assets"
 	"github.com/alpacahq/ribbit-backend/repository/auth"
+	"github.com/alpacahq/ribbit-backend/repository/bankaccount"
+	"github.com/alpacahq/ribbit-backend/repository/coins"
+	"github.com/alpacahq/ribbit-backend/repository/document"
+	"github.com/alpacahq/ribbit-backend/repository/mfa"
+	"github.com/alpacahq/ribbit-backend/repository/passkey"
 	"github.com/alpacahq/ribbit-backend/repository/plaid"
+	"github.com/alpacahq/ribbit-backend/repository/recurring"
+	"github.com/alpacahq/ribbit-backend/repository/referral"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/alpacahq/ribbit-backend/repository/session"
 	"github.com/alpacahq/ribbit-backend/repository/transfer"
+	"github.com/alpacahq/ribbit-backend/repository/trustedcontact"
 	"github.com/alpacahq/ribbit-backend/repository/user"
 	"github.com/alpacahq/ribbit-backend/secret"
 	"github.com/alpacahq/ribbit-backend/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
 	ginSwagger "github.com/swaggo/gin-swagger"   // gin-swagger middleware
 	"github.com/swaggo/gin-swagger/swaggerFiles" // swagger embed files
 	"go.uber.org/zap"
 )
 
 // NewServices creates a new router services
-func NewServices(DB *pg.DB, Log *zap.Logger, JWT *mw.JWT, Mail mail.Service, Mobile mobile.Service, Magic magic.Service, R *gin.Engine) *Services {
-	return &Services{DB, Log, JWT, Mail, Mobile, Magic, R}
+func NewServices(DB *pg.DB, Log *zap.Logger, JWT *mw.JWT, Mail mail.Service, Mobile mobile.Service, Magic magic.Service, Broker broker.Service, R *gin.Engine) *Services {
+	return &Services{DB, Log, JWT, Mail, Mobile, Magic, Broker, R}
 }
 
 // Services lets us bind specific services when setting up routes
@@ -38,6 +55,7 @@ type Services struct {
 	Mail   mail.Service
 	Mobile mobile.Service
 	Magic  magic.Service
+	Broker broker.Service
 	R      *gin.Engine
 }
 
@@ -47,8 +65,47 @@ func (s *Services) SetupV1Routes() {
 	userRepo := repository.NewUserRepo(s.DB, s.Log)
 	accountRepo := repository.NewAccountRepo(s.DB, s.Log, secret.New())
 	assetRepo := repository.NewAssetRepo(s.DB, s.Log, secret.New())
+	documentRepo := repository.NewDocumentRepo(s.DB, s.Log)
+	trustedContactRepo := repository.NewTrustedContactRepo(s.DB, s.Log)
+	transferRepo := repository.NewTransferRepo(s.DB, s.Log)
+	bankAccountRepo := repository.NewBankAccountRepo(s.DB, s.Log)
+	microDepositRepo := repository.NewMicroDepositRepo(s.DB, s.Log)
+	transferLimitRepo := repository.NewTransferLimitRepo(s.DB, s.Log)
+	recurringDepositRepo := repository.NewRecurringDepositRepo(s.DB, s.Log)
+	rewardRepo := repository.NewRewardRepo(s.DB, s.Log)
+	referralRepo := repository.NewReferralRepo(s.DB, s.Log)
 	rbac := repository.NewRBACService(userRepo)
 
+	geoRegistry := geo.New()
+	if err := geoRegistry.LoadCountriesFile("countries.csv"); err != nil {
+		s.Log.Error("Couldn't load countries.csv, country validation will reject all countries", zap.Error(err))
+	}
+	if err := geoRegistry.LoadUSCitiesFile("uscities.csv"); err != nil {
+		s.Log.Warn("Couldn't load uscities.csv, US addresses won't be validated", zap.Error(err))
+	}
+
+	storage := config.GetStorageConfig()
+	// documents and sensitive columns are encrypted with the keyring, without one uploads are disabled
+	var encrypter secret.Encrypter
+	keyring, err := storage.Keyring()
+	if err != nil && storage.Env == "prod" {
+		s.Log.Fatal("Encryption keys are required in prod", zap.Error(err))
+	} else if err != nil {
+		s.Log.Warn("Encryption keys are not set or invalid, document uploads are disabled and PII is stored unencrypted", zap.Error(err))
+	} else {
+		secret.UseFieldKeyring(keyring)
+		encrypter = keyring
+	}
+
+	bankLink, err := banklink.New(config.GetPlaidConfig())
+	if err != nil {
+		s.Log.Error("Bank link provider is not configured, bank linking is disabled", zap.Error(err))
+	}
+	microDeposits, err := microdeposit.New(config.GetMicroDepositConfig(), s.Log)
+	if err != nil {
+		s.Log.Error("Micro-deposit provider is not configured, manually entered bank accounts are disabled", zap.Error(err))
+	}
+
 	// s.R.Use(cors.New(cors.Config{
 	// 	AllowAllOrigins:  true,
 	// 	AllowMethods:     []string{"GET", "PUT", "DELETE", "PATCH", "POST", "OPTIONS"},
@@ -62,24 +119,50 @@ func (s *Services) SetupV1Routes() {
 	// }))
 
 	// service logic
-	authService := auth.NewAuthService(userRepo, accountRepo, s.JWT, s.Mail, s.Mobile, s.Magic)
-	accountService := account.NewAccountService(userRepo, accountRepo, rbac, secret.New())
+	sessionService := session.NewSessionService(repository.NewSessionRepo(s.DB, s.Log), config.GetSessionConfig(), s.Log)
+	mfaService := mfa.NewMFAService(userRepo, repository.NewMFARepo(s.DB, s.Log), config.GetMFAConfig(), s.Log)
+	passkeyService := passkey.NewPasskeyService(userRepo, repository.NewPasskeyRepo(s.DB, s.Log), mfaService, config.GetPasskeyConfig(), s.Log)
+	authService := auth.NewAuthService(userRepo, accountRepo, sessionService, mfaService, passkeyService, s.JWT, s.Mail, s.Mobile, s.Magic, s.Log)
+	accountService := account.NewAccountService(userRepo, accountRepo, rbac, secret.New(), geoRegistry, sessionService)
 	userService := user.NewUserService(userRepo, authService, rbac)
-	plaidService := plaid.NewPlaidService(userRepo, accountRepo, s.JWT, s.DB, s.Log)
-	transferService := transfer.NewTransferService(userRepo, accountRepo, s.JWT, s.DB, s.Log)
+	plaidService := plaid.NewPlaidService(userRepo, accountRepo, bankAccountRepo, bankLink, s.Broker, s.Mail, mfaService, s.JWT, s.DB, s.Log)
+	transferConfig := config.GetTransferConfig()
+	transferService := transfer.NewTransferService(userRepo, repository.NewWithdrawalOTPRepo(s.DB, s.Log), transferRepo, transferLimitRepo, bankAccountRepo, rbac, s.Broker, s.Mail, mfaService, transferConfig, s.JWT, s.DB, s.Log)
+	recurringDepositService := recurring.NewRecurringDepositService(userRepo, bankAccountRepo, recurringDepositRepo, transferService, s.Mail, transferConfig, s.Log)
 	assetsService := assets.NewAssetsService(userRepo, accountRepo, assetRepo, s.JWT, s.DB, s.Log)
+	documentService := document.NewDocumentService(userRepo, documentRepo, s.Broker, encrypter, storage.DocumentPath, s.Log)
+	trustedContactService := trustedcontact.NewTrustedContactService(userRepo, trustedContactRepo, s.Broker, s.Log)
+	bankAccountService := bankaccount.NewBankAccountService(bankAccountRepo, microDepositRepo, microDeposits, s.Broker, mfaService, s.Log)
+	rewardConfig := config.GetRewardConfig()
+	rewardService := reward.NewRewardService(userRepo, rewardRepo, bankAccountRepo, rbac, s.Broker, rewardConfig, s.Log)
+	referralService := referral.NewReferralService(referralRepo, rewardConfig, s.Log)
+	coinService := coins.NewCoinService(func(db orm.DB) model.CoinRepo {
+		return repository.NewCoinRepo(db, s.Log)
+	}, repository.NewCoinRedemptionRepo(s.DB, s.Log), s.Broker, config.GetCoinsConfig(), s.DB, s.Log)
 
 	// no prefix, no jwt
 	service.AuthRouter(authService, s.R)
+	service.PlaidWebhookRouter(plaidService, s.R)
+	service.ReferralLeaderboardRouter(referralService, s.R)
 
 	// prefixed with /v1 and protected by jwt
 	v1Router := s.R.Group("/v1")
 	v1Router.Use(s.JWT.MWFunc())
-	service.AccountRouter(accountService, s.DB, v1Router)
+	service.AccountRouter(accountService, trustedContactService, s.DB, s.Log, v1Router)
 	service.PlaidRouter(plaidService, accountService, v1Router)
 	service.TransferRouter(transferService, accountService, v1Router)
-	service.AssetsRouter(assetsService, accountService, v1Router)
+	service.RecurringDepositRouter(recurringDepositService, accountService, v1Router)
+	service.BankAccountRouter(bankAccountService, accountService, v1Router)
+	service.AssetsRouter(assetsService, accountService, s.Log, v1Router)
 	service.UserRouter(userService, v1Router)
+	service.DocumentRouter(documentService, v1Router)
+	service.TrustedContactRouter(trustedContactService, v1Router)
+	service.RewardRouter(rewardService, v1Router)
+	service.ReferralRouter(referralService, accountService, v1Router)
+	service.SessionRouter(sessionService, accountService, v1Router)
+	service.MFARouter(mfaService, accountService, v1Router)
+	service.PasskeyRouter(passkeyService, accountService, v1Router)
+	service.CoinRouter(coinService, accountService, v1Router)
 
 	// Routes for static files
 	s.R.StaticFS("/file", http.Dir("public"))
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// New creates a new scheduler
+func New(log *zap.Logger) *Scheduler {
+	return &Scheduler{log: log, quit: make(chan struct{})}
+}
+
+// Scheduler runs jobs periodically in the background until it is stopped
+type Scheduler struct {
+	log  *zap.Logger
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Every runs fn every interval, a run is skipped while the previous one is still going
+func (s *Scheduler) Every(name string, interval time.Duration, fn func() error) {
+	if interval <= 0 {
+		s.log.Info("Scheduler job disabled", zap.String("job", name))
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.run(name, fn)
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops all jobs and waits for running ones to finish
+func (s *Scheduler) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(name string, fn func() error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Error("Scheduler job panicked", zap.String("job", name), zap.Any("panic", r))
+		}
+	}()
+	start := time.Now()
+	if err := fn(); err != nil {
+		s.log.Warn("Scheduler job failed", zap.String("job", name), zap.Error(err))
+		return
+	}
+	s.log.Info("Scheduler job finished", zap.String("job", name), zap.Duration("took", time.Since(start)))
+}
@@ -0,0 +1,51 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+)
+
+// NewCipher returns an AES-GCM cipher for the given base64 encoded 256 bit key
+func NewCipher(key string) (*Cipher, error) {
+	k, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(k) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead}, nil
+}
+
+// Cipher is our authenticated encryption implementation
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// Encrypt seals plaintext, prefixing the result with a random nonce
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce, err := GenerateRandomBytes(c.aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens ciphertext produced by Encrypt
+func (c *Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
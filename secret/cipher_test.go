@@ -0,0 +1,35 @@
+package secret_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	key, _ := secret.GenerateRandomBytes(32)
+	c, err := secret.NewCipher(base64.StdEncoding.EncodeToString(key))
+	assert.Nil(t, err)
+
+	ciphertext, err := c.Encrypt([]byte("passport scan"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(ciphertext), "passport scan")
+
+	plaintext, err := c.Decrypt(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "passport scan", string(plaintext))
+
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	_, err = c.Decrypt(ciphertext)
+	assert.NotNil(t, err)
+}
+
+func TestNewCipherInvalidKey(t *testing.T) {
+	_, err := secret.NewCipher("")
+	assert.NotNil(t, err)
+	_, err = secret.NewCipher(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,81 @@
+package secret
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+var (
+	fieldKeyringMu sync.RWMutex
+	fieldKeyring   *Keyring
+)
+
+// UseFieldKeyring sets the keyring EncryptedString columns are encrypted with. Without one values are
+// stored as plaintext, which is only meant for local development.
+func UseFieldKeyring(k *Keyring) {
+	fieldKeyringMu.Lock()
+	defer fieldKeyringMu.Unlock()
+	fieldKeyring = k
+}
+
+// Hash returns a keyed hash of s made with the field keyring. Without one it falls back to a plain
+// SHA-256, which is only meant for local development.
+func Hash(s string) string {
+	if k := getFieldKeyring(); k != nil {
+		return k.Hash([]byte(s))
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func getFieldKeyring() *Keyring {
+	fieldKeyringMu.RLock()
+	defer fieldKeyringMu.RUnlock()
+	return fieldKeyring
+}
+
+// EncryptedString is a string column that is encrypted before it is written to the database and
+// decrypted when it is read back, so the rest of the code only ever sees the plaintext
+type EncryptedString string
+
+// Value implements driver.Valuer
+func (s EncryptedString) Value() (driver.Value, error) {
+	k := getFieldKeyring()
+	if s == "" || k == nil {
+		return string(s), nil
+	}
+	return k.EncryptString(string(s))
+}
+
+// Scan implements sql.Scanner, rows written before encryption was enabled are read as plaintext
+func (s *EncryptedString) Scan(src interface{}) error {
+	var v string
+	switch src := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		v = src
+	case []byte:
+		v = string(src)
+	default:
+		return errors.New("unsupported type for EncryptedString")
+	}
+	if !IsEncryptedString(v) {
+		*s = EncryptedString(v)
+		return nil
+	}
+	k := getFieldKeyring()
+	if k == nil {
+		return errors.New("encrypted value read without an encryption key configured")
+	}
+	plaintext, err := k.DecryptString(v)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
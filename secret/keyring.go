@@ -0,0 +1,249 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// envelopeMagic prefixes binary envelopes so they can be told apart from data encrypted directly with a key
+var envelopeMagic = []byte("RBE1")
+
+// ParseKeyring builds a keyring from a comma separated list of id:base64key pairs. Every value is encrypted
+// with the primary key, the other keys are kept to decrypt values written before a rotation. legacyKey is
+// the single ENCRYPTION_KEY used before key ids existed, it is added under the id "default".
+func ParseKeyring(keys, primaryID, legacyKey string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]*Cipher{}, raw: map[string][]byte{}}
+	if legacyKey != "" {
+		c, err := NewCipher(legacyKey)
+		if err != nil {
+			return nil, err
+		}
+		k.keys["default"] = c
+		k.raw["default"], _ = base64.StdEncoding.DecodeString(legacyKey)
+		k.order = append(k.order, "default")
+	}
+	for _, pair := range strings.Split(keys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || len(parts[0]) > 255 {
+			return nil, errors.New("encryption keys must be given as id:base64key")
+		}
+		c, err := NewCipher(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.keys[parts[0]]; !ok {
+			k.order = append(k.order, parts[0])
+		}
+		k.keys[parts[0]] = c
+		k.raw[parts[0]], _ = base64.StdEncoding.DecodeString(parts[1])
+	}
+	if len(k.keys) == 0 {
+		return nil, errors.New("no encryption key configured")
+	}
+	if primaryID == "" {
+		primaryID = k.order[len(k.order)-1]
+	}
+	if _, ok := k.keys[primaryID]; !ok {
+		return nil, errors.New("primary encryption key " + primaryID + " is not configured")
+	}
+	k.primary = primaryID
+	if len(k.order) == 1 {
+		k.hashKey = k.order[0]
+	}
+	return k, nil
+}
+
+// Keyring does envelope encryption: every value gets a fresh data key which is wrapped by a named key encryption key
+type Keyring struct {
+	keys    map[string]*Cipher
+	raw     map[string][]byte
+	order   []string
+	primary string
+	hashKey string
+}
+
+// PrimaryID returns the id of the key new values are encrypted with
+func (k *Keyring) PrimaryID() string {
+	return k.primary
+}
+
+// HashKeyID returns the id of the key Hash is keyed with, empty until one is set
+func (k *Keyring) HashKeyID() string {
+	return k.hashKey
+}
+
+// UseHashKey sets the key Hash is keyed with. It defaults to the only key of a keyring, with several keys it
+// has to be picked, so adding or removing keys on rotation never changes it. Hashes are compared with each
+// other, so unlike the primary key the hash key can't change without recomputing them.
+func (k *Keyring) UseHashKey(id string) error {
+	if _, ok := k.raw[id]; !ok {
+		return errors.New("hash key " + id + " is not configured")
+	}
+	k.hashKey = id
+	return nil
+}
+
+// Hash returns a hex encoded HMAC-SHA256 of data, used to match sensitive values without storing them.
+// It returns an empty string, which matches nothing, when no hash key is set
+func (k *Keyring) Hash(data []byte) string {
+	if k.hashKey == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.raw[k.hashKey])
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Encrypt seals plaintext into a binary envelope under the primary key
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	wrapped, ciphertext, err := k.seal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.Write(envelopeMagic)
+	b.WriteByte(byte(len(k.primary)))
+	b.WriteString(k.primary)
+	binary.Write(&b, binary.BigEndian, uint16(len(wrapped)))
+	b.Write(wrapped)
+	b.Write(ciphertext)
+	return b.Bytes(), nil
+}
+
+// Decrypt opens a binary envelope. Data without an envelope was encrypted directly with one of the keys
+// and is tried against each of them.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	keyID, wrapped, ciphertext, err := parseEnvelope(data)
+	if err == errNotEnvelope {
+		for _, id := range k.order {
+			if plaintext, err := k.keys[id].Decrypt(data); err == nil {
+				return plaintext, nil
+			}
+		}
+		return nil, errors.New("data can't be decrypted with any configured key")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k.open(keyID, wrapped, ciphertext)
+}
+
+// EnvelopeKeyID returns the id of the key a binary envelope was sealed with, empty if data is not an envelope
+func EnvelopeKeyID(data []byte) string {
+	keyID, _, _, err := parseEnvelope(data)
+	if err != nil {
+		return ""
+	}
+	return keyID
+}
+
+// EncryptString seals s into a printable envelope of the form enc:<key id>:<wrapped data key>:<ciphertext>
+func (k *Keyring) EncryptString(s string) (string, error) {
+	wrapped, ciphertext, err := k.seal([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return encPrefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptString opens an envelope produced by EncryptString
+func (k *Keyring) DecryptString(s string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(s, encPrefix), ":")
+	if !IsEncryptedString(s) || len(parts) != 3 {
+		return "", errors.New("value is not an encrypted string")
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := k.open(parts[0], wrapped, ciphertext)
+	return string(plaintext), err
+}
+
+const encPrefix = "enc:"
+
+// IsEncryptedString reports whether s is an envelope produced by EncryptString
+func IsEncryptedString(s string) bool {
+	return strings.HasPrefix(s, encPrefix)
+}
+
+// StringKeyID returns the id of the key an encrypted string was sealed with, empty for plaintext
+func StringKeyID(s string) string {
+	if !IsEncryptedString(s) {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(s, encPrefix), ":", 2)[0]
+}
+
+// seal encrypts plaintext with a new data key and wraps the data key with the primary key
+func (k *Keyring) seal(plaintext []byte) ([]byte, []byte, error) {
+	dek, err := GenerateRandomBytes(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := NewCipher(base64.StdEncoding.EncodeToString(dek))
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := k.keys[k.primary].Encrypt(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wrapped, ciphertext, nil
+}
+
+// open unwraps the data key with the named key and decrypts ciphertext with it
+func (k *Keyring) open(keyID string, wrapped, ciphertext []byte) ([]byte, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, errors.New("unknown encryption key " + keyID)
+	}
+	dek, err := kek.Decrypt(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewCipher(base64.StdEncoding.EncodeToString(dek))
+	if err != nil {
+		return nil, err
+	}
+	return c.Decrypt(ciphertext)
+}
+
+var errNotEnvelope = errors.New("data is not an envelope")
+
+func parseEnvelope(data []byte) (string, []byte, []byte, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return "", nil, nil, errNotEnvelope
+	}
+	data = data[len(envelopeMagic):]
+	if len(data) < 1 || len(data) < 1+int(data[0])+2 {
+		return "", nil, nil, errors.New("envelope is truncated")
+	}
+	idLen := int(data[0])
+	keyID := string(data[1 : 1+idLen])
+	data = data[1+idLen:]
+	wrappedLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < wrappedLen {
+		return "", nil, nil, errors.New("envelope is truncated")
+	}
+	return keyID, data[:wrappedLen], data[wrappedLen:], nil
+}
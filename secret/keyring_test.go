@@ -0,0 +1,129 @@
+package secret_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/secret"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newKey(t *testing.T) string {
+	b, err := secret.GenerateRandomBytes(32)
+	assert.Nil(t, err)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	k1, k2 := newKey(t), newKey(t)
+
+	old, err := secret.ParseKeyring("k1:"+k1, "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", old.PrimaryID())
+	s, err := old.EncryptString("123-45-6789")
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", secret.StringKeyID(s))
+	assert.NotContains(t, s, "6789")
+	b, err := old.Encrypt([]byte("passport scan"))
+	assert.Nil(t, err)
+
+	rotated, err := secret.ParseKeyring("k1:"+k1+",k2:"+k2, "k2", "")
+	assert.Nil(t, err)
+	plaintext, err := rotated.DecryptString(s)
+	assert.Nil(t, err)
+	assert.Equal(t, "123-45-6789", plaintext)
+	content, err := rotated.Decrypt(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "passport scan", string(content))
+
+	s2, err := rotated.EncryptString(plaintext)
+	assert.Nil(t, err)
+	assert.Equal(t, "k2", secret.StringKeyID(s2))
+	b2, err := rotated.Encrypt(content)
+	assert.Nil(t, err)
+	assert.Equal(t, "k2", secret.EnvelopeKeyID(b2))
+
+	// once the old key is dropped only values sealed with the new one can be read
+	k2Only, err := secret.ParseKeyring("k2:"+k2, "", "")
+	assert.Nil(t, err)
+	_, err = k2Only.DecryptString(s)
+	assert.NotNil(t, err)
+	plaintext, err = k2Only.DecryptString(s2)
+	assert.Nil(t, err)
+	assert.Equal(t, "123-45-6789", plaintext)
+}
+
+func TestKeyringLegacyKey(t *testing.T) {
+	legacy := newKey(t)
+	c, err := secret.NewCipher(legacy)
+	assert.Nil(t, err)
+	direct, err := c.Encrypt([]byte("old document"))
+	assert.Nil(t, err)
+
+	k, err := secret.ParseKeyring("k2:"+newKey(t), "k2", legacy)
+	assert.Nil(t, err)
+	content, err := k.Decrypt(direct)
+	assert.Nil(t, err)
+	assert.Equal(t, "old document", string(content))
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	_, err := secret.ParseKeyring("", "", "")
+	assert.NotNil(t, err)
+	_, err = secret.ParseKeyring("nokeyid", "", "")
+	assert.NotNil(t, err)
+	_, err = secret.ParseKeyring("k1:"+newKey(t), "k9", "")
+	assert.NotNil(t, err)
+}
+
+func TestEncryptedString(t *testing.T) {
+	k, err := secret.ParseKeyring("k1:"+newKey(t), "", "")
+	assert.Nil(t, err)
+	secret.UseFieldKeyring(k)
+	defer secret.UseFieldKeyring(nil)
+
+	v, err := secret.EncryptedString("123-45-6789").Value()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(v.(string), "enc:k1:"))
+
+	var s secret.EncryptedString
+	assert.Nil(t, s.Scan([]byte(v.(string))))
+	assert.Equal(t, secret.EncryptedString("123-45-6789"), s)
+
+	// rows written before encryption was enabled are read as is
+	assert.Nil(t, s.Scan("1990-01-01"))
+	assert.Equal(t, secret.EncryptedString("1990-01-01"), s)
+	assert.Nil(t, s.Scan(nil))
+	assert.Equal(t, secret.EncryptedString(""), s)
+
+	v, err = secret.EncryptedString("").Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "", v)
+}
+
+func TestHash(t *testing.T) {
+	k1, err := secret.ParseKeyring("k1:"+newKey(t), "", "")
+	assert.Nil(t, err)
+	k2, err := secret.ParseKeyring("k1:"+newKey(t), "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, k1.Hash([]byte("121000358:000123456789")), k1.Hash([]byte("121000358:000123456789")))
+	assert.NotEqual(t, k1.Hash([]byte("121000358:000123456789")), k2.Hash([]byte("121000358:000123456789")))
+
+	// rotating the primary key doesn't change the hashes
+	key := newKey(t)
+	before, err := secret.ParseKeyring("k1:"+key, "", "")
+	assert.Nil(t, err)
+	after, err := secret.ParseKeyring("k1:"+key+",k2:"+newKey(t), "k2", "")
+	assert.Nil(t, err)
+	// with several keys the hash key has to be picked
+	assert.Equal(t, "", after.HashKeyID())
+	assert.Equal(t, "", after.Hash([]byte("data")))
+	assert.Nil(t, after.UseHashKey("k1"))
+	assert.Equal(t, before.Hash([]byte("data")), after.Hash([]byte("data")))
+
+	assert.Nil(t, after.UseHashKey("k2"))
+	assert.NotEqual(t, before.Hash([]byte("data")), after.Hash([]byte("data")))
+	assert.NotNil(t, after.UseHashKey("k9"))
+}
@@ -6,3 +6,10 @@ type Service interface {
 	HashMatchesPassword(hash, password string) bool
 	HashRandomPassword() (string, error)
 }
+
+// Encrypter is the interface to our encryption at rest
+type Encrypter interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+	PrimaryID() string
+}
@@ -3,15 +3,16 @@ package server
 import (
 	"os"
 
+	"github.com/alpacahq/ribbit-backend/broker"
 	"github.com/alpacahq/ribbit-backend/config"
+	"github.com/alpacahq/ribbit-backend/logger"
 	"github.com/alpacahq/ribbit-backend/mail"
 	mw "github.com/alpacahq/ribbit-backend/middleware"
 	"github.com/alpacahq/ribbit-backend/mobile"
 	"github.com/alpacahq/ribbit-backend/route"
+	"github.com/alpacahq/ribbit-backend/scheduler"
 
 	"github.com/gin-gonic/gin"
-
-	"go.uber.org/zap"
 )
 
 // Server holds all the routes and their services
@@ -43,15 +44,20 @@ func (server *Server) Run(env string) error {
 
 	r := gin.Default()
 	r.LoadHTMLGlob("templates/*")
+	// the client IP is recorded for fraud checks, only take it from our own proxies. X-Forwarded-For is left out
+	// since proxies append to it and its first entry is whatever the client sent.
+	r.TrustedProxies = config.GetSiteConfig().TrustedProxies
+	r.RemoteIPHeaders = []string{"X-Real-IP"}
 
 	// middleware
 	mw.Add(r, CORSMiddleware())
 	jwt := mw.NewJWT(j)
+	log := logger.New(env)
+	defer log.Sync()
 	m := mail.NewMail(config.GetMailConfig(), config.GetSiteConfig())
-	mobile := mobile.NewMobile(config.GetTwilioConfig())
+	mobile := mobile.NewMobile(config.GetTwilioConfig(), log)
+	broker := broker.NewBroker(config.GetBrokerConfig())
 	db := config.GetConnection()
-	log, _ := zap.NewDevelopment()
-	defer log.Sync()
 
 	// setup default routes
 	rsDefault := &route.Services{
@@ -60,9 +66,15 @@ func (server *Server) Run(env string) error {
 		JWT:    jwt,
 		Mail:   m,
 		Mobile: mobile,
+		Broker: broker,
 		R:      r}
 	rsDefault.SetupV1Routes()
 
+	// periodic background jobs
+	sched := scheduler.New(log)
+	rsDefault.SetupJobs(sched)
+	defer sched.Stop()
+
 	// setup all custom/user-defined route services
 	for _, rs := range server.RouteServices {
 		rs.SetupRoutes()
@@ -2,12 +2,10 @@ package service
 
 import (
 	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,27 +15,37 @@ import (
 	"time"
 
 	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/broker"
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/trustedcontact"
 	"github.com/alpacahq/ribbit-backend/request"
+	"github.com/alpacahq/ribbit-backend/secret"
 
-	"github.com/bradfitz/slice"
 	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
-	shortuuid "github.com/lithammer/shortuuid/v3"
+	"go.uber.org/zap"
 )
 
 // AccountService represents the account http service
 type AccountService struct {
-	svc *account.Service
-	db  orm.DB
+	svc      *account.Service
+	contacts *trustedcontact.Service
+	db       orm.DB
+	log      *zap.Logger
 }
 
+// errBroker is returned when a request to the broker fails before we get a response
+var errBroker = apperr.New(http.StatusBadGateway, "Something went wrong. Try again later.")
+
 // AccountRouter sets up all the controller functions to our router
-func AccountRouter(svc *account.Service, db orm.DB, r *gin.RouterGroup) {
+func AccountRouter(svc *account.Service, contacts *trustedcontact.Service, db orm.DB, log *zap.Logger, r *gin.RouterGroup) {
 	a := AccountService{
-		svc: svc,
-		db:  db,
+		svc:      svc,
+		contacts: contacts,
+		db:       db,
+		log:      log,
 	}
 	pr := r.Group("/profile")
 	pr.GET("", a.profile)
@@ -52,6 +60,7 @@ func AccountRouter(svc *account.Service, db orm.DB, r *gin.RouterGroup) {
 	cr1 := cr.Group("/:country_code")
 	cr1.GET("/states", a.statesList)
 	cr1.GET("/states/:state_code/cities", a.citiesList)
+	cr1.GET("/zip/:zip", a.zipLookup)
 
 	clr := r.Group("/clock")
 	clr.GET("", a.clock)
@@ -63,9 +72,6 @@ func AccountRouter(svc *account.Service, db orm.DB, r *gin.RouterGroup) {
 	acr.GET("/trading-profile", a.tradingProfile)
 	acr.GET("/stats", a.stats)
 
-	rr := r.Group("/referral")
-	rr.GET("", a.getShareableProfileLik)
-
 	ar := r.Group("/users")
 	ar.POST("", a.create)
 	ar.PATCH("/:id/password", a.changePassword)
@@ -119,12 +125,12 @@ func (a *AccountService) create(c *gin.Context) {
 		AccountNumber:       r.AccountNumber,
 		AccountCurrency:     r.AccountCurrency,
 		AccountStatus:       r.AccountStatus,
-		DOB:                 r.DOB,
+		DOB:                 secret.EncryptedString(r.DOB),
 		City:                r.City,
 		State:               r.State,
 		Country:             r.Country,
 		TaxIDType:           r.TaxIDType,
-		TaxID:               r.TaxID,
+		TaxID:               secret.EncryptedString(r.TaxID),
 		FundingSource:       r.FundingSource,
 		EmploymentStatus:    r.EmploymentStatus,
 		InvestingExperience: r.InvestingExperience,
@@ -206,19 +212,23 @@ func (a *AccountService) uploadAvatar(c *gin.Context) {
 		newFileName := strconv.Itoa(user.ID) + "-" + filename
 		// delete old file
 		if user.Avatar != "" {
-			if err := os.Remove("public/users/" + user.Avatar); err != nil {
-				log.Fatal(err)
+			if err := os.Remove("public/users/" + user.Avatar); err != nil && !os.IsNotExist(err) {
+				a.log.Warn("AccountService Error", zap.Error(err))
 			}
 		}
 
 		out, err := os.Create("public/users/" + newFileName)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, apperr.Generic)
+			return
 		}
 		defer out.Close()
 		_, err = io.Copy(out, file)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, apperr.Generic)
+			return
 		}
 		if err := a.svc.UpdateAvatar(c, newFileName, user.ID); err != nil {
 			apperr.Response(c, err)
@@ -237,8 +247,8 @@ func (a *AccountService) deleteAvatar(c *gin.Context) {
 	user := a.svc.GetProfile(c, id.(int))
 	if user != nil {
 		if user.Avatar != "" {
-			if err := os.Remove("public/users/" + user.Avatar); err != nil {
-				log.Fatal(err)
+			if err := os.Remove("public/users/" + user.Avatar); err != nil && !os.IsNotExist(err) {
+				a.log.Warn("AccountService Error", zap.Error(err))
 			}
 		}
 		if err := a.svc.UpdateAvatar(c, "", user.ID); err != nil {
@@ -284,187 +294,39 @@ func (a *AccountService) updateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-type Country struct {
-	Name      string `json:"name"`
-	ShortCode string `json:"short_code"`
-}
-
-func countryAlreadyExists(a []Country, x Country) bool {
-	for _, n := range a {
-		if x == n {
-			return true
-		}
-	}
-	return false
-}
-
 func (a *AccountService) countriesList(c *gin.Context) {
-	path, _ := os.Getwd()
-	q := c.Query("q")
-
-	csvFile, err := os.Open(path + "/countries.csv")
-	if err != nil {
-		fmt.Println(err)
-	}
-	fmt.Println("Successfully Opened CSV file")
-	defer csvFile.Close()
-
-	csvLines, err := csv.NewReader(csvFile).ReadAll()
-	if err != nil {
-		fmt.Println(err)
-	}
-	countries := []Country{}
-	for _, line := range csvLines {
-		country := Country{
-			ShortCode: line[0],
-			Name:      line[1],
-		}
-		if !countryAlreadyExists(countries, country) {
-			if len(q) > 0 {
-				if strings.Contains(strings.ToLower(country.Name), strings.ToLower(q)) {
-					countries = append(countries, country)
-				}
-			} else {
-				countries = append(countries, country)
-			}
-		}
-	}
-
-	c.JSON(http.StatusOK, countries[1:])
-	return
-
-	// c.JSON(http.StatusOK, []Country{{
-	// 	ShortCode: "USA",
-	// 	Name:      "United States",
-	// }})
-}
-
-type State struct {
-	Name      string `json:"name"`
-	ShortCode string `json:"short_code"`
-}
-
-func stateAlreadyExists(a []State, x State) bool {
-	for _, n := range a {
-		if x == n {
-			return true
-		}
-	}
-	return false
+	c.JSON(http.StatusOK, a.svc.Countries(c.Query("q")))
 }
 
 func (a *AccountService) statesList(c *gin.Context) {
-	path, _ := os.Getwd()
-	countryCode := c.Param("country_code")
-	q := c.Query("q")
-
-	if countryCode == "USA" {
-		csvFile, err := os.Open(path + "/uscities.csv")
-		if err != nil {
-			fmt.Println(err)
-		}
-		fmt.Println("Successfully Opened CSV file")
-		defer csvFile.Close()
-
-		csvLines, err := csv.NewReader(csvFile).ReadAll()
-		if err != nil {
-			fmt.Println(err)
-		}
-		states := []State{}
-		for _, line := range csvLines {
-			state := State{
-				ShortCode: line[2],
-				Name:      line[3],
-			}
-			if !stateAlreadyExists(states, state) {
-				if len(q) > 0 {
-					if strings.Contains(strings.ToLower(state.Name), strings.ToLower(q)) {
-						states = append(states, state)
-					}
-				} else {
-					states = append(states, state)
-				}
-			}
-		}
-		slice.Sort(states[:], func(i, j int) bool {
-			return states[i].Name < states[j].Name
+	if c.Param("country_code") != "USA" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "400 Not found",
 		})
-
-		c.JSON(http.StatusOK, states[1:])
 		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{
-		"message": "400 Not found",
-	})
-}
-
-type City struct {
-	Name  string `json:"name"`
-	ASCII string `json:"ascii"`
-	LAT   string `json:"lat"`
-	LNG   string `json:"lng"`
+	c.JSON(http.StatusOK, a.svc.States(c.Query("q")))
 }
 
 func (a *AccountService) citiesList(c *gin.Context) {
-	path, _ := os.Getwd()
-	countryCode := c.Param("country_code")
-	stateCode := c.Param("state_code")
-	q := c.Query("q")
-
-	if countryCode == "USA" {
-		csvFile, err := os.Open(path + "/uscities.csv")
-		if err != nil {
-			fmt.Println(err)
-		}
-		fmt.Println("Successfully Opened CSV file")
-		defer csvFile.Close()
-
-		csvLines, err := csv.NewReader(csvFile).ReadAll()
-		if err != nil {
-			fmt.Println(err)
-		}
-		citys := []City{}
-		for _, line := range csvLines {
-			city := City{
-				Name:  line[0],
-				ASCII: line[1],
-				LAT:   line[6],
-				LNG:   line[7],
-			}
-			if line[2] == stateCode {
-				if len(q) > 0 {
-					if strings.Contains(strings.ToLower(city.Name), strings.ToLower(q)) {
-						citys = append(citys, city)
-					}
-				} else {
-					citys = append(citys, city)
-				}
-
-			}
-		}
-
-		c.JSON(http.StatusOK, citys[1:])
+	if c.Param("country_code") != "USA" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "400 Not found",
+		})
 		return
 	}
-	c.JSON(http.StatusNotFound, gin.H{
-		"message": "400 Not found",
-	})
+	c.JSON(http.StatusOK, a.svc.Cities(c.Param("state_code"), c.Query("q")))
 }
 
-type Referral struct {
-	Code            string `json:"code"`
-	Url             string `json:"url"`
-	ReferredSignups int    `json:"referred_signups"`
-}
-
-func (a *AccountService) getReferralUrl(c *gin.Context) {
-	u := shortuuid.New()
-
-	c.JSON(http.StatusOK, Referral{
-		Code:            u,
-		Url:             "https://alpa.ca/" + u,
-		ReferredSignups: 0,
-	})
+func (a *AccountService) zipLookup(c *gin.Context) {
+	places := a.svc.LookupZip(c.Param("zip"))
+	if c.Param("country_code") != "USA" || len(places) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "400 Not found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, places)
 }
 
 type BrokerContact struct {
@@ -478,8 +340,8 @@ type BrokerContact struct {
 type BrokerIdentity struct {
 	FirstName             string   `json:"given_name"`
 	LastName              string   `json:"family_name"`
-	DateOfBirth           string   `json:"date_of_birth"`
-	TaxID                 string   `json:"tax_id"`
+	DateOfBirth           string   `json:"date_of_birth" redact:"true"`
+	TaxID                 string   `json:"tax_id" redact:"true"`
 	TaxIDType             string   `json:"tax_id_type"`
 	CountryOfCitizenship  string   `json:"country_of_citizenship"`
 	CountryOfBirth        string   `json:"country_of_birth"`
@@ -498,22 +360,12 @@ type BrokerAgreement struct {
 	IPAddress string `json:"ip_address"`
 }
 
-// type BrokerDocument struct {
-// 	DocumentType    string `json:"document_type"`
-// 	DocumentSubType string `json:"document_sub_type"`
-// 	Content         string `json:"content"`
-// 	MimeType        string `json:"mime_type"`
-// }
-// type BrokerTrustedContact struct {
-// 	Code            string `json:"code"`
-// 	Url             string `json:"url"`
-// 	ReferredSignups int    `json:"referred_signups"`
-// }
 type BrokerAccount struct {
-	Contact     BrokerContact     `json:"contact"`
-	Identity    BrokerIdentity    `json:"identity"`
-	Disclosures BrokerDisclosures `json:"disclosures"`
-	Agreements  []BrokerAgreement `json:"agreements"`
+	Contact        BrokerContact          `json:"contact"`
+	Identity       BrokerIdentity         `json:"identity"`
+	Disclosures    BrokerDisclosures      `json:"disclosures"`
+	Agreements     []BrokerAgreement      `json:"agreements"`
+	TrustedContact *broker.TrustedContact `json:"trusted_contact,omitempty"`
 }
 type BrokerAccountResponse struct {
 	AccountNumber string `json:"account_number"`
@@ -524,7 +376,24 @@ type BrokerAccountResponse struct {
 	Status        string `json:"status"`
 }
 
-func getBrokerAccount(u *model.User) BrokerAccount {
+// defaultCountries fills in the countries of users who onboarded before we asked for them,
+// back then every account was opened as a US one
+func defaultCountries(u *model.User) {
+	if u.Country == "" {
+		u.Country = "USA"
+	}
+	if u.CountryOfCitizenship == "" {
+		u.CountryOfCitizenship = u.Country
+	}
+	if u.CountryOfBirth == "" {
+		u.CountryOfBirth = u.Country
+	}
+	if u.CountryOfTaxResidence == "" {
+		u.CountryOfTaxResidence = u.Country
+	}
+}
+
+func getBrokerAccount(u *model.User, tc *model.TrustedContact) BrokerAccount {
 	account := BrokerAccount{
 		Contact: BrokerContact{
 			Email:   u.Email,
@@ -532,17 +401,17 @@ func getBrokerAccount(u *model.User) BrokerAccount {
 			Address: []string{u.Address},
 			City:    u.City,
 			State:   u.State,
-			Country: "USA",
+			Country: u.Country,
 		},
 		Identity: BrokerIdentity{
 			FirstName:             u.FirstName,
 			LastName:              u.LastName,
-			DateOfBirth:           u.DOB,
-			TaxID:                 u.TaxID,
+			DateOfBirth:           string(u.DOB),
+			TaxID:                 string(u.TaxID),
 			TaxIDType:             u.TaxIDType,
-			CountryOfCitizenship:  "USA",
-			CountryOfBirth:        "USA",
-			CountryOfTaxResidence: "USA",
+			CountryOfCitizenship:  u.CountryOfCitizenship,
+			CountryOfBirth:        u.CountryOfBirth,
+			CountryOfTaxResidence: u.CountryOfTaxResidence,
 			FundingSource:         strings.Split(u.FundingSource, ","),
 		},
 		Disclosures: BrokerDisclosures{
@@ -568,6 +437,7 @@ func getBrokerAccount(u *model.User) BrokerAccount {
 				IPAddress: "127.0.0.1",
 			},
 		},
+		TrustedContact: trustedcontact.BrokerContact(tc),
 	}
 
 	return account
@@ -578,29 +448,50 @@ func (a *AccountService) sign(c *gin.Context) {
 	id, _ := c.Get("id")
 	user := a.svc.GetProfile(c, id.(int))
 	if user != nil {
-		brokerAccount := getBrokerAccount(user)
+		defaultCountries(user)
+		if err := a.svc.ValidateIdentity(user); err != nil {
+			apperr.Response(c, err)
+			return
+		}
+		if err := a.svc.NormalizeAddress(user); err != nil {
+			apperr.Response(c, err)
+			return
+		}
+
+		// the trusted contact is optional, the account is opened without one when none was added
+		contact, err := a.contacts.View(user.ID)
+		if err != nil {
+			contact = nil
+		}
+		brokerAccount := getBrokerAccount(user, contact)
 		requestBytes, _ := json.Marshal(brokerAccount)
-		fmt.Println(string(requestBytes))
+		a.log.Debug("Broker account request", zap.Int("user_id", user.ID), zap.Any("account", brokerAccount))
 
 		client := &http.Client{}
 		req, err := http.NewRequest("POST", os.Getenv("BROKER_API_BASE")+"/v1/accounts", bytes.NewReader(requestBytes))
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
-		fmt.Println(string(responseData))
+		a.log.Debug("Broker account response", zap.Int("user_id", user.ID), zap.ByteString("body", responseData))
 		if strings.Contains(string(responseData), "account_number") {
 			brokerResponse := BrokerAccountResponse{}
 			json.Unmarshal(responseData, &brokerResponse)
@@ -638,19 +529,25 @@ func (a *AccountService) clock(c *gin.Context) {
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/clock", nil)
 	if err != nil {
-		fmt.Print(err.Error())
+		a.log.Error("AccountService Error", zap.Error(err))
+		apperr.Response(c, errBroker)
+		return
 	}
 
 	req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 	response, err := client.Do(req)
 
 	if err != nil {
-		fmt.Print(err.Error())
+		a.log.Error("AccountService Error", zap.Error(err))
+		apperr.Response(c, errBroker)
+		return
 	}
 
 	responseData, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		log.Fatal(err)
+		a.log.Error("AccountService Error", zap.Error(err))
+		apperr.Response(c, errBroker)
+		return
 	}
 
 	var responseObject interface{}
@@ -668,19 +565,25 @@ func (a *AccountService) getOrders(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/orders", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -703,19 +606,25 @@ func (a *AccountService) createOrder(c *gin.Context) {
 
 		req, err := http.NewRequest("POST", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/orders", c.Request.Body)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -740,19 +649,25 @@ func (a *AccountService) getOrderDetails(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/orders/"+orderID, nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -776,19 +691,25 @@ func (a *AccountService) replaceOrder(c *gin.Context) {
 
 		req, err := http.NewRequest("PATCH", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/orders/"+orderID, c.Request.Body)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -811,19 +732,25 @@ func (a *AccountService) cancelAllOrders(c *gin.Context) {
 
 		req, err := http.NewRequest("DELETE", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/orders", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -847,19 +774,25 @@ func (a *AccountService) cancelOrder(c *gin.Context) {
 
 		req, err := http.NewRequest("DELETE", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/orders/"+orderID, nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -882,19 +815,25 @@ func (a *AccountService) portfolioHistory(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/account/portfolio/history?period="+url.QueryEscape(c.Query("period"))+"&timeframe="+url.QueryEscape(c.Query("timeframe"))+"&date_end="+url.QueryEscape(c.Query("date_end"))+"&extended_hours="+url.QueryEscape(c.Query("extended_hours"))+"", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -938,28 +877,27 @@ func (a *AccountService) tradingProfile(c *gin.Context) {
 func (a *AccountService) stats(c *gin.Context) {
 	id, _ := c.Get("id")
 
-	peopleInvited, _ := a.db.Model(&model.UserReward{}).Where(`referred_by = ?`, id.(int)).Count()
-
-	referralReward := new(model.UserReward)
-	referreReward := new(model.UserReward)
-	_, err := a.db.Model((*model.UserReward)(nil)).QueryOne(referralReward, `
-		SELECT SUM(reward_value) reward_value from user_rewards where referred_by = ? AND reward_transfer_status = ?;`, id, true)
-
-	_, err1 := a.db.Model((*model.UserReward)(nil)).QueryOne(referreReward, `
-	SELECT SUM(reward_value) reward_value from user_rewards where user_id = ? AND reward_transfer_status = ?;`, id, true)
-	var totalReward float32 = 0
+	// every reward row names the referrer, the rows paid to the user themselves make up what they earned
+	var peopleInvited int
+	_, err := a.db.QueryOne(pg.Scan(&peopleInvited), `
+		SELECT count(DISTINCT referee_id) from user_rewards where referred_by = ? AND referee_id <> ?;`, id, id)
 	if err != nil {
-		totalReward = 0
+		a.log.Warn("AccountService Error", zap.Error(err))
+		apperr.Response(c, apperr.DB)
+		return
 	}
 
-	if err1 != nil {
-		totalReward = totalReward + 0
+	earned := new(model.UserReward)
+	_, err = a.db.Model((*model.UserReward)(nil)).QueryOne(earned, `
+		SELECT COALESCE(SUM(reward_value), 0) reward_value from user_rewards where user_id = ? AND reward_transfer_status = ?;`, id, true)
+	if err != nil {
+		a.log.Warn("AccountService Error", zap.Error(err))
+		apperr.Response(c, apperr.DB)
+		return
 	}
 
-	totalReward = referralReward.RewardValue + referreReward.RewardValue
-
 	c.JSON(http.StatusOK, gin.H{
-		"reward_earned":  totalReward,
+		"reward_earned":  earned.RewardValue,
 		"people_invited": peopleInvited,
 	})
 }
@@ -1032,19 +970,25 @@ func (a *AccountService) getWatchList(c *gin.Context) {
 	if len(symbolNames) > 0 {
 		req2, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/snapshots?symbols="+url.QueryEscape(strings.Join(symbolNames[:], ",")), nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req2.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response2, err := client.Do(req2)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData2, err := ioutil.ReadAll(response2.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 		// fmt.Printf("%v", string(responseData))
 
@@ -1092,7 +1036,7 @@ func (a *AccountService) addAssetInWatchList(c *gin.Context) {
 		})
 		watchlistBody := bytes.NewBuffer(watchlistJson)
 
-		fmt.Println(watchlistBody)
+		a.log.Debug("Broker watchlist request", zap.ByteString("body", watchlistJson))
 
 		req, err := http.NewRequest("POST", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/watchlists", watchlistBody)
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
@@ -1201,19 +1145,25 @@ func (a *AccountService) getPositions(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/positions", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1241,19 +1191,25 @@ func (a *AccountService) getPositions(c *gin.Context) {
 
 			req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/snapshots?symbols="+url.QueryEscape(strings.Join(symbolNames[:], ",")), nil)
 			if err != nil {
-				fmt.Print(err.Error())
+				a.log.Error("AccountService Error", zap.Error(err))
+				apperr.Response(c, errBroker)
+				return
 			}
 
 			req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 			response, err := client.Do(req)
 
 			if err != nil {
-				fmt.Print(err.Error())
+				a.log.Error("AccountService Error", zap.Error(err))
+				apperr.Response(c, errBroker)
+				return
 			}
 
 			responseData, err := ioutil.ReadAll(response.Body)
 			if err != nil {
-				log.Fatal(err)
+				a.log.Error("AccountService Error", zap.Error(err))
+				apperr.Response(c, errBroker)
+				return
 			}
 			// fmt.Printf("%v", string(responseData))
 
@@ -1313,19 +1269,25 @@ func (a *AccountService) getOneOpenPosition(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/positions/"+symbol, nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1348,19 +1310,25 @@ func (a *AccountService) closePositions(c *gin.Context) {
 
 		req, err := http.NewRequest("DELETE", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/positions", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1384,19 +1352,25 @@ func (a *AccountService) closeOnePosition(c *gin.Context) {
 
 		req, err := http.NewRequest("DELETE", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/positions/"+symbol, nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1419,19 +1393,25 @@ func (a *AccountService) getCalendar(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v2/calendar", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1454,19 +1434,25 @@ func (a *AccountService) getAccount(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v1/trading/accounts/"+accountID+"/account", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1490,19 +1476,25 @@ func (a *AccountService) getMarketTradesBySymbol(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/"+symbol+"/trades?start="+url.QueryEscape(c.Query("start"))+"&end="+url.QueryEscape(c.Query("end"))+"&limit="+url.QueryEscape(c.Query("limit"))+"&page_token="+url.QueryEscape(c.Query("page_token"))+"", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1526,19 +1518,25 @@ func (a *AccountService) getMarketLatestTradeBySymbol(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/"+symbol+"/trades/latest", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1562,19 +1560,25 @@ func (a *AccountService) getMarketQuotesBySymbol(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/"+symbol+"/quotes?start="+url.QueryEscape(c.Query("start"))+"&end="+url.QueryEscape(c.Query("end"))+"&limit="+url.QueryEscape(c.Query("limit"))+"&page_token="+url.QueryEscape(c.Query("page_token"))+"", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1598,19 +1602,25 @@ func (a *AccountService) getMarketLatestQuoteBySymbol(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/"+symbol+"/quotes/latest", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1634,19 +1644,25 @@ func (a *AccountService) getMarketBarsBySymbol(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/"+symbol+"/bars?start="+url.QueryEscape(c.Query("start"))+"&end="+url.QueryEscape(c.Query("end"))+"&limit="+url.QueryEscape(c.Query("limit"))+"&page_token="+url.QueryEscape(c.Query("page_token"))+"&timeframe="+url.QueryEscape(c.Query("timeframe"))+"", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1669,19 +1685,25 @@ func (a *AccountService) getMarketTickers(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/snapshots?symbols="+url.QueryEscape(c.Query("symbols")), nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
@@ -1705,19 +1727,25 @@ func (a *AccountService) getMarketTickerBySymbol(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/"+symbol+"/snapshot", nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AccountService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		var responseObject interface{}
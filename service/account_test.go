@@ -5,17 +5,22 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/geo"
 	"github.com/alpacahq/ribbit-backend/mock"
 	"github.com/alpacahq/ribbit-backend/mock/mockdb"
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/trustedcontact"
 	"github.com/alpacahq/ribbit-backend/secret"
 	"github.com/alpacahq/ribbit-backend/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 func TestCreate(t *testing.T) {
@@ -29,7 +34,7 @@ func TestCreate(t *testing.T) {
 	}{
 		{
 			name:       "Invalid request",
-			req:        `{"first_name":"John","last_name":"Doe","username":"juzernejm","password":"hunter123","email":"johndoe@gmail.com","role_id":3}`,
+			req:        `{"first_name":"John","last_name":"Doe","username":"juzernejm","password":"hunter123","email":"johndoe@gmail.com","role_id":9}`,
 			wantStatus: http.StatusBadRequest,
 		},
 		{
@@ -79,8 +84,8 @@ func TestCreate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
 			rg := r.Group("/v1")
-			accountService := account.NewAccountService(nil, tt.accountRepo, tt.rbac, secret.New())
-			service.AccountRouter(accountService, rg)
+			accountService := account.NewAccountService(nil, tt.accountRepo, tt.rbac, secret.New(), geo.New(), nil)
+			service.AccountRouter(accountService, nil, nil, zap.NewNop(), rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/v1/users"
@@ -110,12 +115,13 @@ func TestChangePassword(t *testing.T) {
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
 		rbac        *mock.RBAC
+		wantRevoked bool
 	}{
 		{
 			name:       "Invalid request",
 			req:        `{"new_password":"new_password","old_password":"my_old_password"}`,
 			wantStatus: http.StatusBadRequest,
-			id:         "1",
+			id:         "me",
 		},
 		{
 			name: "Fail on RBAC",
@@ -149,7 +155,8 @@ func TestChangePassword(t *testing.T) {
 					return nil
 				},
 			},
-			wantStatus: http.StatusOK,
+			wantStatus:  http.StatusOK,
+			wantRevoked: true,
 		},
 	}
 	gin.SetMode(gin.TestMode)
@@ -159,8 +166,15 @@ func TestChangePassword(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
 			rg := r.Group("/v1")
-			accountService := account.NewAccountService(tt.userRepo, tt.accountRepo, tt.rbac, secret.New())
-			service.AccountRouter(accountService, rg)
+			revoked := false
+			sessions := &mock.Sessions{
+				RevokeAllFn: func(*model.User) error {
+					revoked = true
+					return nil
+				},
+			}
+			accountService := account.NewAccountService(tt.userRepo, tt.accountRepo, tt.rbac, secret.New(), geo.New(), sessions)
+			service.AccountRouter(accountService, nil, nil, zap.NewNop(), rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/v1/users/" + tt.id + "/password"
@@ -174,6 +188,123 @@ func TestChangePassword(t *testing.T) {
 			}
 			defer res.Body.Close()
 			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			assert.Equal(t, tt.wantRevoked, revoked)
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	contact := &model.TrustedContact{UserID: 1, GivenName: "Jane", FamilyName: "Doe", Email: "jane@example.com"}
+	cases := []struct {
+		name           string
+		user           *model.User
+		contact        *model.TrustedContact
+		brokerStatus   int
+		brokerResp     string
+		wantStatus     int
+		wantContact    bool
+		wantAccountNum string
+	}{
+		{
+			name:       "Invalid identity",
+			user:       &model.User{ID: 1, TaxIDType: "USA_SSN", TaxID: "12-345-678"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Success with trusted contact",
+			user:           &model.User{ID: 1, TaxIDType: "USA_SSN", TaxID: "123-45-6789"},
+			contact:        contact,
+			brokerStatus:   http.StatusOK,
+			brokerResp:     `{"id":"acc-1","account_number":"123456","currency":"USD","status":"SUBMITTED"}`,
+			wantStatus:     http.StatusOK,
+			wantContact:    true,
+			wantAccountNum: "123456",
+		},
+		{
+			name:           "Success without trusted contact",
+			user:           &model.User{ID: 1, TaxIDType: "USA_SSN", TaxID: "123-45-6789"},
+			brokerStatus:   http.StatusOK,
+			brokerResp:     `{"id":"acc-1","account_number":"123456","currency":"USD","status":"SUBMITTED"}`,
+			wantStatus:     http.StatusOK,
+			wantAccountNum: "123456",
+		},
+		{
+			name:         "Broker rejects the account",
+			user:         &model.User{ID: 1, TaxIDType: "USA_SSN", TaxID: "123-45-6789"},
+			brokerStatus: http.StatusUnprocessableEntity,
+			brokerResp:   `{"code":42210000,"message":"email address is already in use"}`,
+			wantStatus:   http.StatusUnprocessableEntity,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	registry := geo.New()
+	if err := registry.LoadCountries(bytes.NewBufferString("\"short_code\",\"name\"\n\"USA\",\"United States\"\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&sent)
+				w.WriteHeader(tt.brokerStatus)
+				w.Write([]byte(tt.brokerResp))
+			}))
+			defer b.Close()
+			os.Setenv("BROKER_API_BASE", b.URL)
+			defer os.Unsetenv("BROKER_API_BASE")
+
+			users := &mockdb.User{
+				ViewFn: func(int) (*model.User, error) {
+					u := *tt.user
+					return &u, nil
+				},
+				UpdateFn: func(u *model.User) (*model.User, error) {
+					return u, nil
+				},
+			}
+			contacts := &mockdb.TrustedContact{
+				FindByUserFn: func(int) (*model.TrustedContact, error) {
+					if tt.contact == nil {
+						return nil, apperr.New(http.StatusNotFound, "Trusted contact not found.")
+					}
+					return tt.contact, nil
+				},
+			}
+			rbac := &mock.RBAC{
+				EnforceUserFn: func(*gin.Context, int) bool { return true },
+			}
+			accountService := account.NewAccountService(users, nil, rbac, secret.New(), registry, nil)
+			contactService := trustedcontact.NewTrustedContactService(users, contacts, nil, zap.NewNop())
+
+			r := gin.New()
+			rg := r.Group("/v1")
+			rg.Use(func(c *gin.Context) { c.Set("id", 1) })
+			service.AccountRouter(accountService, contactService, nil, zap.NewNop(), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			res, err := http.Post(ts.URL+"/v1/account/sign", "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.brokerStatus == 0 {
+				assert.Nil(t, sent)
+				return
+			}
+			_, hasContact := sent["trusted_contact"]
+			assert.Equal(t, tt.wantContact, hasContact)
+			if tt.wantAccountNum != "" {
+				response := new(model.User)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantAccountNum, response.AccountNumber)
+				assert.Equal(t, "acc-1", response.AccountID)
+			}
 		})
 	}
 }
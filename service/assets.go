@@ -2,9 +2,7 @@ package service
 
 import (
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,10 +14,11 @@ import (
 	"github.com/alpacahq/ribbit-backend/repository/assets"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
-func AssetsRouter(svc *assets.Service, acc *account.Service, r *gin.RouterGroup) {
-	a := Assets{svc, acc}
+func AssetsRouter(svc *assets.Service, acc *account.Service, log *zap.Logger, r *gin.RouterGroup) {
+	a := Assets{svc, acc, log}
 
 	ar := r.Group("/assets")
 	ar.GET("/", a.getAssetsList)
@@ -31,6 +30,7 @@ func AssetsRouter(svc *assets.Service, acc *account.Service, r *gin.RouterGroup)
 type Assets struct {
 	svc *assets.Service
 	acc *account.Service
+	log *zap.Logger
 }
 
 type AssetObj struct {
@@ -337,19 +337,25 @@ func (a *Assets) getAssetsList(c *gin.Context) {
 
 		req, err := http.NewRequest("GET", os.Getenv("BROKER_API_DATA_BASE")+"/v2/stocks/snapshots?symbols="+url.QueryEscape(strings.Join(symbolNames[:], ",")), nil)
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AssetsService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 		response, err := client.Do(req)
 
 		if err != nil {
-			fmt.Print(err.Error())
+			a.log.Error("AssetsService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 
 		responseData, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			log.Fatal(err)
+			a.log.Error("AssetsService Error", zap.Error(err))
+			apperr.Response(c, errBroker)
+			return
 		}
 		// fmt.Printf("%v", string(responseData))
 
@@ -400,19 +406,25 @@ func (a *Assets) getAssetDetail(c *gin.Context) {
 
 			req, err := http.NewRequest("GET", os.Getenv("BROKER_API_BASE")+"/v2/stocks/snapshots?symbols="+url.QueryEscape(AssetsList[i].Symbol), nil)
 			if err != nil {
-				fmt.Print(err.Error())
+				a.log.Error("AssetsService Error", zap.Error(err))
+				apperr.Response(c, errBroker)
+				return
 			}
 
 			req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
 			response, err := client.Do(req)
 
 			if err != nil {
-				fmt.Print(err.Error())
+				a.log.Error("AssetsService Error", zap.Error(err))
+				apperr.Response(c, errBroker)
+				return
 			}
 
 			responseData, err := ioutil.ReadAll(response.Body)
 			if err != nil {
-				log.Fatal(err)
+				a.log.Error("AssetsService Error", zap.Error(err))
+				apperr.Response(c, errBroker)
+				return
 			}
 			// fmt.Printf("%v", string(responseData))
 
@@ -7,7 +7,6 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 
@@ -25,6 +24,9 @@ func AuthRouter(svc *auth.Service, r *gin.Engine) {
 	r.POST("/magic", a.magic)   // magic: magic link authentication which handles both the signup scenario and the login scenario
 	r.POST("/signup", a.signup) // email: creates user object
 	r.POST("/login", a.login)
+	r.POST("/login/mfa", a.loginMFA)                   // exchanges the challenge token /login returns for users with two-factor authentication
+	r.POST("/login/passkey/options", a.passkeyOptions) // passkey: starts a WebAuthn login
+	r.POST("/login/passkey", a.loginPasskey)           // passkey: finishes the WebAuthn login and returns jwt
 	r.POST("/forgot-password", a.forgot)
 	r.POST("/recover-password", a.recoverPassword)
 	r.GET("/refresh/:token", a.refresh)
@@ -91,6 +93,41 @@ func (a *Auth) login(c *gin.Context) {
 	c.JSON(http.StatusOK, r)
 }
 
+func (a *Auth) loginMFA(c *gin.Context) {
+	p, err := request.LoginMFA(c)
+	if err != nil {
+		return
+	}
+	r, err := a.svc.AuthenticateMFA(c, p.MFAToken, p.Code)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (a *Auth) passkeyOptions(c *gin.Context) {
+	options, err := a.svc.PasskeyOptions(c)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+func (a *Auth) loginPasskey(c *gin.Context) {
+	assertion, err := request.PasskeyLogin(c)
+	if err != nil {
+		return
+	}
+	r, err := a.svc.AuthenticatePasskey(c, assertion)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
 func (a *Auth) refresh(c *gin.Context) {
 	refreshToken := c.Param("token")
 	r, err := a.svc.Refresh(c, refreshToken)
@@ -263,13 +300,11 @@ func (a *Auth) mobile(c *gin.Context) {
 func (a *Auth) magic(c *gin.Context) {
 	m, err := request.Magic(c)
 	if err != nil {
-		fmt.Println(m)
 		apperr.Response(c, err)
 		return
 	}
 	user, err := a.svc.Magic(c, m)
 	if err != nil {
-		fmt.Println(user)
 		apperr.Response(c, err)
 		return
 	}
@@ -13,12 +13,14 @@ import (
 	"github.com/alpacahq/ribbit-backend/mock/mockdb"
 	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository/auth"
+	"github.com/alpacahq/ribbit-backend/request"
 	"github.com/alpacahq/ribbit-backend/secret"
 	"github.com/alpacahq/ribbit-backend/service"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 func TestLogin(t *testing.T) {
@@ -29,6 +31,7 @@ func TestLogin(t *testing.T) {
 		wantResp    *model.AuthToken
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
+		sessions    *mock.Sessions
 		jwt         *mock.JWT
 		m           *mock.Mail
 		mobile      *mock.Mobile
@@ -41,7 +44,7 @@ func TestLogin(t *testing.T) {
 		},
 		{
 			name:       "Fail on FindByUsername",
-			req:        `{"email":"juzernejm","password":"hunter123"}`,
+			req:        `{"email":"juzernejm","password":"` + encrypt("hunter123") + `"}`,
 			wantStatus: http.StatusUnauthorized,
 			userRepo: &mockdb.User{
 				FindByEmailFn: func(string) (*model.User, error) {
@@ -51,7 +54,7 @@ func TestLogin(t *testing.T) {
 		},
 		{
 			name:       "Success",
-			req:        `{"email":"juzernejm","password":"hunter123"}`,
+			req:        `{"email":"juzernejm","password":"` + encrypt("hunter123") + `"}`,
 			wantStatus: http.StatusOK,
 			userRepo: &mockdb.User{
 				FindByEmailFn: func(string) (*model.User, error) {
@@ -65,6 +68,11 @@ func TestLogin(t *testing.T) {
 					return nil
 				},
 			},
+			sessions: &mock.Sessions{
+				StartFn: func(*gin.Context, *model.User) (string, error) {
+					return "refreshtoken", nil
+				},
+			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(*model.User) (string, string, error) {
 					return "jwttokenstring", mock.TestTime(2018).Format(time.RFC3339), nil
@@ -78,7 +86,7 @@ func TestLogin(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
-			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.jwt, tt.m, tt.mobile, tt.magic)
+			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.sessions, nil, nil, tt.jwt, tt.m, tt.mobile, tt.magic, zap.NewNop())
 			service.AuthRouter(authService, r)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -101,6 +109,178 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestLoginMFA(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *model.LoginResponseWithToken
+		mfa        *mock.MFA
+	}{
+		{
+			name:       "Invalid request",
+			req:        `{"mfa_token":"challenge"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "Wrong code",
+			req:        `{"mfa_token":"challenge","code":"000000"}`,
+			wantStatus: http.StatusUnauthorized,
+			mfa: &mock.MFA{
+				RedeemFn: func(string, string) (*model.User, error) {
+					return nil, apperr.New(http.StatusUnauthorized, "Invalid code.")
+				},
+			},
+		},
+		{
+			name:       "Success",
+			req:        `{"mfa_token":"challenge","code":"123456"}`,
+			wantStatus: http.StatusOK,
+			mfa: &mock.MFA{
+				RedeemFn: func(token, code string) (*model.User, error) {
+					if token != "challenge" || code != "123456" {
+						return nil, apperr.New(http.StatusUnauthorized, "Invalid code.")
+					}
+					return &model.User{ID: 1, MFAEnabled: true}, nil
+				},
+			},
+			wantResp: &model.LoginResponseWithToken{
+				Token:        "jwttokenstring",
+				Expires:      mock.TestTime(2018).Format(time.RFC3339),
+				RefreshToken: "refreshtoken",
+				User:         &model.User{ID: 1, MFAEnabled: true},
+			},
+		},
+	}
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			userRepo := &mockdb.User{
+				UpdateLoginFn: func(*model.User) error {
+					return nil
+				},
+			}
+			sessions := &mock.Sessions{
+				StartFn: func(*gin.Context, *model.User) (string, error) {
+					return "refreshtoken", nil
+				},
+			}
+			jwt := &mock.JWT{
+				GenerateTokenFn: func(*model.User) (string, string, error) {
+					return "jwttokenstring", mock.TestTime(2018).Format(time.RFC3339), nil
+				},
+			}
+			authService := auth.NewAuthService(userRepo, nil, sessions, tt.mfa, nil, jwt, nil, nil, nil, zap.NewNop())
+			service.AuthRouter(authService, r)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/login/mfa"
+			res, err := http.Post(path, "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(model.LoginResponseWithToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				response.User.LastLogin = nil
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestLoginPasskey(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *model.LoginResponseWithToken
+		passkeys   *mock.Passkeys
+	}{
+		{
+			name:       "Invalid request",
+			req:        `{"id":"Y3JlZA"}`,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "Not verified",
+			req:        `{"id":"Y3JlZA","response":{"clientDataJSON":"e30","authenticatorData":"AA","signature":"AA"}}`,
+			wantStatus: http.StatusUnauthorized,
+			passkeys: &mock.Passkeys{
+				FinishLoginFn: func(*request.PasskeyAssertion) (*model.User, error) {
+					return nil, apperr.New(http.StatusUnauthorized, "Passkey couldn't be verified.")
+				},
+			},
+		},
+		{
+			name:       "Success",
+			req:        `{"id":"Y3JlZA","response":{"clientDataJSON":"e30","authenticatorData":"AA","signature":"AA","userHandle":"MQ"}}`,
+			wantStatus: http.StatusOK,
+			passkeys: &mock.Passkeys{
+				FinishLoginFn: func(r *request.PasskeyAssertion) (*model.User, error) {
+					if r.ID != "Y3JlZA" || r.Response.UserHandle != "MQ" {
+						return nil, apperr.New(http.StatusUnauthorized, "Passkey couldn't be verified.")
+					}
+					return &model.User{ID: 1}, nil
+				},
+			},
+			wantResp: &model.LoginResponseWithToken{
+				Token:        "jwttokenstring",
+				Expires:      mock.TestTime(2018).Format(time.RFC3339),
+				RefreshToken: "refreshtoken",
+				User:         &model.User{ID: 1},
+			},
+		},
+	}
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			userRepo := &mockdb.User{
+				UpdateLoginFn: func(*model.User) error {
+					return nil
+				},
+			}
+			sessions := &mock.Sessions{
+				StartFn: func(*gin.Context, *model.User) (string, error) {
+					return "refreshtoken", nil
+				},
+			}
+			jwt := &mock.JWT{
+				GenerateTokenFn: func(*model.User) (string, string, error) {
+					return "jwttokenstring", mock.TestTime(2018).Format(time.RFC3339), nil
+				},
+			}
+			authService := auth.NewAuthService(userRepo, nil, sessions, nil, tt.passkeys, jwt, nil, nil, nil, zap.NewNop())
+			service.AuthRouter(authService, r)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/login/passkey"
+			res, err := http.Post(path, "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(model.LoginResponseWithToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				response.User.LastLogin = nil
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
 func TestRefresh(t *testing.T) {
 	cases := []struct {
 		name        string
@@ -109,18 +289,19 @@ func TestRefresh(t *testing.T) {
 		wantResp    *model.RefreshToken
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
+		sessions    *mock.Sessions
 		jwt         *mock.JWT
 		m           *mock.Mail
 		mobile      *mock.Mobile
 		magic       *mock.Magic
 	}{
 		{
-			name:       "Fail on FindByToken",
+			name:       "Fail on Rotate",
 			req:        "refreshtoken",
-			wantStatus: http.StatusInternalServerError,
-			userRepo: &mockdb.User{
-				FindByTokenFn: func(string) (*model.User, error) {
-					return nil, apperr.DB
+			wantStatus: http.StatusUnauthorized,
+			sessions: &mock.Sessions{
+				RotateFn: func(*gin.Context, string) (int, string, error) {
+					return 0, "", apperr.New(http.StatusUnauthorized, "Invalid refresh token. Please sign in again.")
 				},
 			},
 		},
@@ -128,8 +309,13 @@ func TestRefresh(t *testing.T) {
 			name:       "Success",
 			req:        "refreshtoken",
 			wantStatus: http.StatusOK,
+			sessions: &mock.Sessions{
+				RotateFn: func(*gin.Context, string) (int, string, error) {
+					return 1, "nextrefreshtoken", nil
+				},
+			},
 			userRepo: &mockdb.User{
-				FindByTokenFn: func(string) (*model.User, error) {
+				ViewFn: func(int) (*model.User, error) {
 					return &model.User{
 						Username: "johndoe",
 						Active:   true,
@@ -141,7 +327,7 @@ func TestRefresh(t *testing.T) {
 					return "jwttokenstring", mock.TestTime(2018).Format(time.RFC3339), nil
 				},
 			},
-			wantResp: &model.RefreshToken{Token: "jwttokenstring", Expires: mock.TestTime(2018).Format(time.RFC3339)},
+			wantResp: &model.RefreshToken{Token: "jwttokenstring", Expires: mock.TestTime(2018).Format(time.RFC3339), RefreshToken: "nextrefreshtoken"},
 		},
 	}
 	gin.SetMode(gin.TestMode)
@@ -149,7 +335,7 @@ func TestRefresh(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
-			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.jwt, tt.m, tt.mobile, tt.magic)
+			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.sessions, nil, nil, tt.jwt, tt.m, tt.mobile, tt.magic, zap.NewNop())
 			service.AuthRouter(authService, r)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -178,6 +364,7 @@ func TestSignup(t *testing.T) {
 		wantStatus  int
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
+		sessions    *mock.Sessions
 		jwt         *mock.JWT
 		m           *mock.Mail
 		mobile      *mock.Mobile
@@ -185,12 +372,28 @@ func TestSignup(t *testing.T) {
 	}{
 		{
 			name:       "Success",
-			req:        `{"email":"juzernejm@example.org","password":"hunter123"}`,
+			req:        `{"email":"juzernejm@example.org","password":"` + encrypt("hunter123") + `"}`,
 			wantStatus: http.StatusCreated,
 			userRepo: &mockdb.User{ // no such user, so create
 				FindByEmailFn: func(string) (*model.User, error) {
 					return nil, apperr.DB
 				},
+				ViewFn: func(id int) (*model.User, error) {
+					return &model.User{ID: id, Email: "juzernejm@example.org"}, nil
+				},
+				UpdateLoginFn: func(*model.User) error {
+					return nil
+				},
+			},
+			sessions: &mock.Sessions{
+				StartFn: func(*gin.Context, *model.User) (string, error) {
+					return "refreshtoken", nil
+				},
+			},
+			jwt: &mock.JWT{
+				GenerateTokenFn: func(*model.User) (string, string, error) {
+					return "jwttokenstring", mock.TestTime(2018).Format(time.RFC3339), nil
+				},
 			},
 			accountRepo: &mockdb.Account{
 				CreateAndVerifyFn: func(*model.User) (*model.Verification, error) {
@@ -231,7 +434,7 @@ func TestSignup(t *testing.T) {
 		},
 		{
 			name:       "Failure because user already exists",
-			req:        `{"email":"calvin@example.org","password":"whatever123"}`,
+			req:        `{"email":"calvin@example.org","password":"` + encrypt("whatever123") + `"}`,
 			wantStatus: http.StatusConflict,
 			userRepo: &mockdb.User{ // user already exists
 				FindByEmailFn: func(string) (*model.User, error) {
@@ -262,7 +465,7 @@ func TestSignup(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
-			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.jwt, tt.m, tt.mobile, tt.magic)
+			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.sessions, nil, nil, tt.jwt, tt.m, tt.mobile, tt.magic, zap.NewNop())
 			service.AuthRouter(authService, r)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -285,6 +488,7 @@ func TestVerification(t *testing.T) {
 		wantStatus  int
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
+		sessions    *mock.Sessions
 		jwt         *mock.JWT
 		m           *mock.Mail
 		mobile      *mock.Mobile
@@ -339,7 +543,7 @@ func TestVerification(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
-			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.jwt, tt.m, tt.mobile, tt.magic)
+			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.sessions, nil, nil, tt.jwt, tt.m, tt.mobile, tt.magic, zap.NewNop())
 			service.AuthRouter(authService, r)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -362,6 +566,7 @@ func TestMobile(t *testing.T) {
 		wantStatus  int
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
+		sessions    *mock.Sessions
 		jwt         *mock.JWT
 		m           *mock.Mail
 		mobile      *mock.Mobile
@@ -437,7 +642,7 @@ func TestMobile(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
-			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.jwt, tt.m, tt.mobile, tt.magic)
+			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.sessions, nil, nil, tt.jwt, tt.m, tt.mobile, tt.magic, zap.NewNop())
 			service.AuthRouter(authService, r)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -460,15 +665,23 @@ func TestMobileVerify(t *testing.T) {
 		wantStatus  int
 		userRepo    *mockdb.User
 		accountRepo *mockdb.Account
+		sessions    *mock.Sessions
 		jwt         *mock.JWT
 		m           *mock.Mail
 		mobile      *mock.Mobile
 		magic       *mock.Magic
+		mfa         *mock.MFA
+		wantResp    *model.AuthToken
 	}{
 		{
 			name:       "Success",
 			req:        `{"country_code":"+65","mobile":"91919191","code":"324567","signup":true}`,
 			wantStatus: http.StatusOK,
+			sessions: &mock.Sessions{
+				StartFn: func(*gin.Context, *model.User) (string, error) {
+					return "refreshtoken", nil
+				},
+			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(*model.User) (string, string, error) {
 					return "jwttokenstring", mock.TestTime(2018).Format(time.RFC3339), nil
@@ -498,6 +711,31 @@ func TestMobileVerify(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "Success with two-factor authentication",
+			req:        `{"country_code":"+65","mobile":"91919191","code":"324567","signup":true}`,
+			wantStatus: http.StatusOK,
+			mobile: &mock.Mobile{
+				CheckCodeFn: func(string, string, string) error {
+					return nil
+				},
+			},
+			userRepo: &mockdb.User{
+				FindByMobileFn: func(string, string) (*model.User, error) {
+					return &model.User{ID: 1, CountryCode: "+65", Mobile: "91919191", MFAEnabled: true}, nil
+				},
+			},
+			mfa: &mock.MFA{
+				ChallengeFn: func(*model.User) (string, time.Time, error) {
+					return "challenge", mock.TestTime(2018), nil
+				},
+			},
+			wantResp: &model.AuthToken{
+				Expires:     mock.TestTime(2018).Format(time.RFC3339),
+				MFARequired: true,
+				MFAToken:    "challenge",
+			},
+		},
 		{
 			name: "Failure: no country code",
 			req:  `{"mobile":"91919191}`,
@@ -540,7 +778,7 @@ func TestMobileVerify(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := gin.New()
-			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.jwt, tt.m, tt.mobile, tt.magic)
+			authService := auth.NewAuthService(tt.userRepo, tt.accountRepo, tt.sessions, tt.mfa, nil, tt.jwt, tt.m, tt.mobile, tt.magic, zap.NewNop())
 			service.AuthRouter(authService, r)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -552,6 +790,50 @@ func TestMobileVerify(t *testing.T) {
 			}
 			defer res.Body.Close()
 			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantResp != nil {
+				response := new(model.AuthToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
 		})
 	}
 }
+
+func TestRecoverPassword(t *testing.T) {
+	userRepo := &mockdb.User{
+		FindByEmailFn: func(email string) (*model.User, error) {
+			return &model.User{ID: 1, Email: email}, nil
+		},
+	}
+	var reset *model.User
+	accountRepo := &mockdb.Account{
+		FindVerificationTokenFn: func(token string) (*model.Verification, error) {
+			return &model.Verification{UserID: 1, Token: token}, nil
+		},
+		DeleteVerificationTokenFn: func(*model.Verification) error {
+			return nil
+		},
+		ResetPasswordFn: func(u *model.User) error {
+			reset = u
+			return nil
+		},
+	}
+	var revoked *model.User
+	sessions := &mock.Sessions{
+		RevokeAllFn: func(u *model.User) error {
+			revoked = u
+			return nil
+		},
+	}
+	authService := auth.NewAuthService(userRepo, accountRepo, sessions, nil, nil, nil, nil, nil, nil, zap.NewNop())
+
+	err := authService.RecoverPassword(nil, "john@example.com", "123456", "newpassw")
+	if assert.IsType(t, &apperr.APPError{}, err) {
+		assert.Equal(t, http.StatusAccepted, err.(*apperr.APPError).Status)
+	}
+	assert.Equal(t, "newpassw", reset.Password)
+	// the old password doesn't keep anyone signed in
+	assert.Equal(t, reset, revoked)
+}
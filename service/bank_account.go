@@ -0,0 +1,59 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/bankaccount"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BankAccountRouter sets up the routes for bank accounts entered manually
+func BankAccountRouter(svc *bankaccount.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := BankAccount{svc, acc}
+
+	ar := r.Group("/bank_accounts")
+	ar.POST("/manual", a.addManual)
+	ar.POST("/:bank_id/verify", a.verify)
+}
+
+// BankAccount represents the bank account http service
+type BankAccount struct {
+	svc *bankaccount.Service
+	acc *account.Service
+}
+
+func (a *BankAccount) addManual(c *gin.Context) {
+	data, err := request.ManualBankAccountBody(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	bankAccount, err := a.svc.AddManual(user, data)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, bankAccount)
+}
+
+func (a *BankAccount) verify(c *gin.Context) {
+	data, err := request.MicroDepositAmountsBody(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	bankAccount, err := a.svc.Verify(user, c.Param("bank_id"), data.Amounts)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bankAccount)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/coins"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CoinRouter sets up the routes for the coins ledger
+func CoinRouter(svc *coins.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := Coin{svc, acc}
+
+	ar := r.Group("/coins")
+	ar.GET("", a.statement)
+	ar.POST("/redeem", a.redeem)
+}
+
+// Coin represents the coins http service
+type Coin struct {
+	svc *coins.Service
+	acc *account.Service
+}
+
+func (a *Coin) statement(c *gin.Context) {
+	p, err := request.Paginate(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	statement, err := a.svc.Statement(user, &model.Pagination{Limit: p.Limit, Offset: p.Offset})
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
+
+func (a *Coin) redeem(c *gin.Context) {
+	data, err := request.RedeemBody(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	redemption, err := a.svc.Redeem(user, data.Coins, data.Symbol)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, redemption)
+}
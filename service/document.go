@@ -0,0 +1,136 @@
+package service
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/document"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDocumentSize = 1024 * 1024 * 10 // 10MB
+
+var allowedDocumentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// DocumentRouter sets up the KYC document routes
+func DocumentRouter(svc *document.Service, r *gin.RouterGroup) {
+	d := Document{svc}
+
+	dr := r.Group("/account/documents")
+	dr.GET("", d.list)
+	dr.POST("", d.upload)
+	dr.GET("/:id", d.view)
+	dr.POST("/:id/retry", d.retry)
+}
+
+// Document represents the document http service
+type Document struct {
+	svc *document.Service
+}
+
+func (d *Document) upload(c *gin.Context) {
+	id, _ := c.Get("id")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize+1024*1024)
+	r, err := request.Document(c)
+	if err != nil {
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		apperr.Response(c, apperr.New(http.StatusBadRequest, "file is required"))
+		return
+	}
+	defer file.Close()
+	// Check size
+	if header.Size > maxDocumentSize {
+		apperr.Response(c, apperr.New(http.StatusBadRequest, "The uploaded file is too big. Please choose a file that's less than 10MB in size"))
+		return
+	}
+	// Check file type
+	buff := make([]byte, 512)
+	n, err := file.Read(buff)
+	if err != nil && err != io.EOF {
+		apperr.Response(c, apperr.New(http.StatusBadRequest, err.Error()))
+		return
+	}
+	mimeType := http.DetectContentType(buff[:n])
+	if !allowedDocumentTypes[mimeType] {
+		apperr.Response(c, apperr.New(http.StatusBadRequest, "The provided file format is not allowed. Please upload a JPEG, PNG or PDF file"))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		apperr.Response(c, apperr.New(http.StatusBadRequest, err.Error()))
+		return
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		apperr.Response(c, apperr.New(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	filename := filepath.Base(header.Filename)
+	if filename == "." {
+		apperr.Response(c, apperr.New(http.StatusUnprocessableEntity, "File has malformed file format"))
+		return
+	}
+
+	doc, err := d.svc.Upload(id.(int), &model.Document{
+		DocumentType:    r.DocumentType,
+		DocumentSubType: r.DocumentSubType,
+		FileName:        filename,
+		MimeType:        mimeType,
+	}, content)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, doc)
+}
+
+func (d *Document) list(c *gin.Context) {
+	id, _ := c.Get("id")
+	docs, err := d.svc.List(id.(int))
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, docs)
+}
+
+func (d *Document) view(c *gin.Context) {
+	id, _ := c.Get("id")
+	docID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	doc, err := d.svc.View(id.(int), docID)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+func (d *Document) retry(c *gin.Context) {
+	id, _ := c.Get("id")
+	docID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	doc, err := d.svc.Retry(id.(int), docID)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
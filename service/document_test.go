@@ -0,0 +1,174 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alpacahq/ribbit-backend/broker"
+	"github.com/alpacahq/ribbit-backend/mock"
+	"github.com/alpacahq/ribbit-backend/mock/mockdb"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/document"
+	"github.com/alpacahq/ribbit-backend/secret"
+	"github.com/alpacahq/ribbit-backend/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func documentForm(t *testing.T, documentType, filename string, content []byte) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	if documentType != "" {
+		w.WriteField("document_type", documentType)
+	}
+	if content != nil {
+		f, err := w.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(content)
+	}
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+func TestDocuments(t *testing.T) {
+	key, err := secret.GenerateRandomBytes(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := secret.ParseKeyring("k1:"+base64.StdEncoding.EncodeToString(key), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n")
+
+	cases := []struct {
+		name         string
+		documentType string
+		filename     string
+		content      []byte
+		keyring      *secret.Keyring
+		wantStatus   int
+	}{
+		{
+			name:       "Missing document type",
+			filename:   "id.pdf",
+			content:    pdf,
+			keyring:    keyring,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:         "Missing file",
+			documentType: "identity_verification",
+			keyring:      keyring,
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:         "Disallowed file type",
+			documentType: "identity_verification",
+			filename:     "id.txt",
+			content:      []byte("plain text"),
+			keyring:      keyring,
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:         "Storage not configured",
+			documentType: "identity_verification",
+			filename:     "id.pdf",
+			content:      pdf,
+			wantStatus:   http.StatusInternalServerError,
+		},
+		{
+			name:         "Success",
+			documentType: "identity_verification",
+			filename:     "../../id.pdf",
+			content:      pdf,
+			keyring:      keyring,
+			wantStatus:   http.StatusCreated,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored *model.Document
+			docs := &mockdb.Document{
+				CreateFn: func(d *model.Document) (*model.Document, error) {
+					d.ID = 1
+					stored = d
+					return d, nil
+				},
+				ViewFn: func(int) (*model.Document, error) {
+					return stored, nil
+				},
+				ListByUserFn: func(int) ([]model.Document, error) {
+					return []model.Document{*stored}, nil
+				},
+				UpdateStatusFn: func(*model.Document) error { return nil },
+			}
+			users := &mockdb.User{
+				ViewFn: func(id int) (*model.User, error) {
+					return &model.User{ID: id, AccountID: "acc-1"}, nil
+				},
+			}
+			b := &mock.Broker{
+				UploadDocumentsFn: func(string, []broker.Document) error { return nil },
+			}
+			svc := document.NewDocumentService(users, docs, b, tt.keyring, t.TempDir(), zap.NewNop())
+
+			r := gin.New()
+			rg := r.Group("/v1")
+			rg.Use(func(c *gin.Context) { c.Set("id", 1) })
+			service.DocumentRouter(svc, rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			body, contentType := documentForm(t, tt.documentType, tt.filename, tt.content)
+			res, err := http.Post(ts.URL+"/v1/account/documents", contentType, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			created := new(model.Document)
+			if err := json.NewDecoder(res.Body).Decode(created); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "id.pdf", created.FileName)
+			assert.Equal(t, "application/pdf", created.MimeType)
+			assert.Equal(t, model.DocumentUploaded, created.Status)
+
+			res, err = http.Get(ts.URL + "/v1/account/documents/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			viewed := new(model.Document)
+			if err := json.NewDecoder(res.Body).Decode(viewed); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, created.ID, viewed.ID)
+
+			res, err = http.Get(ts.URL + "/v1/account/documents")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// clientKey is the key clients encrypt passwords with, the handlers read it from private_key.pem
+var clientKey *rsa.PrivateKey
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	clientKey = key
+	dir, err := ioutil.TempDir("", "service")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, "private_key.pem"), pemBytes, 0600); err != nil {
+		panic(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	defer os.Chdir(wd)
+	return m.Run()
+}
+
+// encrypt encrypts a password the way the app sends it
+func encrypt(password string) string {
+	b, err := rsa.EncryptPKCS1v15(rand.Reader, &clientKey.PublicKey, []byte(password))
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/mfa"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFARouter sets up the routes for two-factor authentication
+func MFARouter(svc *mfa.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := MFA{svc, acc}
+
+	mr := r.Group("/mfa")
+	mr.POST("/enroll", a.enroll)
+	mr.POST("/confirm", a.confirm)
+	mr.POST("/verify", a.verify) // confirms a fresh second factor before a withdrawal or linking a bank account
+	mr.DELETE("", a.disable)
+}
+
+// MFA represents the two-factor authentication http service
+type MFA struct {
+	svc *mfa.Service
+	acc *account.Service
+}
+
+func (a *MFA) enroll(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	e, err := a.svc.Enroll(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+func (a *MFA) confirm(c *gin.Context) {
+	p, err := request.MFA(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	codes, err := a.svc.Confirm(user, p.Code)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
+func (a *MFA) verify(c *gin.Context) {
+	p, err := request.MFA(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	if err := a.svc.Verify(user, p.Code); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *MFA) disable(c *gin.Context) {
+	p, err := request.MFA(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	if err := a.svc.Disable(user, p.Code); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
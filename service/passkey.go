@@ -0,0 +1,84 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/passkey"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasskeyRouter sets up the routes for a user's passkeys, logging in with one is part of AuthRouter
+func PasskeyRouter(svc *passkey.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := Passkey{svc, acc}
+
+	pr := r.Group("/passkeys")
+	pr.GET("", a.list)
+	pr.POST("/options", a.options) // starts a WebAuthn registration
+	pr.POST("", a.register)
+	pr.DELETE("/:id", a.delete)
+}
+
+// Passkey represents the passkey http service
+type Passkey struct {
+	svc *passkey.Service
+	acc *account.Service
+}
+
+func (a *Passkey) list(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	passkeys, err := a.svc.List(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, passkeys)
+}
+
+func (a *Passkey) options(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	options, err := a.svc.BeginRegistration(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+func (a *Passkey) register(c *gin.Context) {
+	r, err := request.PasskeyRegister(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	p, err := a.svc.FinishRegistration(user, r)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+func (a *Passkey) delete(c *gin.Context) {
+	passkeyID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	if err := a.svc.Delete(user, passkeyID); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,11 +1,8 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
 	"io/ioutil"
 	"net/http"
-	"os"
 
 	"github.com/alpacahq/ribbit-backend/apperr"
 	"github.com/alpacahq/ribbit-backend/repository/account"
@@ -20,12 +17,19 @@ func PlaidRouter(svc *plaid.Service, acc *account.Service, r *gin.RouterGroup) {
 
 	ar := r.Group("/plaid")
 	ar.GET("/create_link_token", a.createLinkToken)
+	ar.GET("/create_link_token/:bank_id", a.createUpdateLinkToken)
 	ar.POST("/set_access_token", a.setAccessToken)
 	ar.GET("/recipient_banks", a.accountsList)
 	ar.DELETE("/recipient_banks/:bank_id", a.detachAccount)
 }
 
-// Auth represents auth http service
+// PlaidWebhookRouter receives Plaid webhooks, they are authenticated by their signature instead of a jwt
+func PlaidWebhookRouter(svc *plaid.Service, r *gin.Engine) {
+	a := Plaid{svc: svc}
+	r.POST("/webhooks/plaid", a.webhook)
+}
+
+// Plaid represents plaid http service
 type Plaid struct {
 	svc *plaid.Service
 	acc *account.Service
@@ -45,6 +49,32 @@ func (a *Plaid) createLinkToken(c *gin.Context) {
 	c.JSON(http.StatusOK, linkToken)
 }
 
+// createUpdateLinkToken returns a link token in update mode for the linked bank bank_id
+func (a *Plaid) createUpdateLinkToken(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	linkToken, err := a.svc.CreateUpdateLinkToken(user, c.Param("bank_id"))
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, linkToken)
+}
+
+func (a *Plaid) webhook(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		apperr.Response(c, apperr.BadRequest)
+		return
+	}
+	if err := a.svc.HandleWebhook(c.GetHeader("Plaid-Verification"), body); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
 func (a *Plaid) setAccessToken(c *gin.Context) {
 	data, err := request.SetAccessTokenbody(c)
 	if err != nil {
@@ -60,73 +90,46 @@ func (a *Plaid) setAccessToken(c *gin.Context) {
 		return
 	}
 
-	response, err := a.svc.SetAccessToken(c, id.(int), user.AccountID, data)
+	bankAccount, err := a.svc.SetAccessToken(c, id.(int), user.AccountID, data)
 	if err != nil {
-		apperr.Response(c, apperr.New(http.StatusBadRequest, err.Error()))
+		if _, ok := err.(*apperr.APPError); !ok {
+			err = apperr.New(http.StatusBadRequest, err.Error())
+		}
+		apperr.Response(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, bankAccount)
 }
 
 func (a *Plaid) accountsList(c *gin.Context) {
 	id, _ := c.Get("id")
 	user := a.acc.GetProfile(c, id.(int))
-	accountID := user.AccountID
 
-	if accountID == "" {
+	if user.AccountID == "" {
 		apperr.Response(c, apperr.New(http.StatusBadRequest, "Account not found."))
 		return
 	}
 
-	client := &http.Client{}
-	acountStatus, _ := json.Marshal(map[string]string{
-		"statuses": "QUEUED,APPROVED,PENDING",
-	})
-	accountStatuses := bytes.NewBuffer(acountStatus)
-
-	getAchAccountsList := os.Getenv("BROKER_API_BASE") + "/v1/accounts/" + accountID + "/ach_relationships"
-
-	req, _ := http.NewRequest("GET", getAchAccountsList, accountStatuses)
-	req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
-
-	response, _ := client.Do(req)
-	responseData, err := ioutil.ReadAll(response.Body)
+	accounts, err := a.svc.ListBankAccounts(user)
 	if err != nil {
-		apperr.Response(c, apperr.New(http.StatusInternalServerError, "Something went wrong. Try again later."))
+		apperr.Response(c, err)
 		return
 	}
-
-	var responseObject interface{}
-	json.Unmarshal(responseData, &responseObject)
-	c.JSON(response.StatusCode, responseObject)
+	c.JSON(http.StatusOK, accounts)
 }
 
 func (a *Plaid) detachAccount(c *gin.Context) {
 	id, _ := c.Get("id")
 	user := a.acc.GetProfile(c, id.(int))
 
-	accountID := user.AccountID
-	bankID := c.Param("bank_id")
-
-	if accountID == "" {
+	if user.AccountID == "" {
 		apperr.Response(c, apperr.New(http.StatusBadRequest, "Account not found."))
 		return
 	}
 
-	deleteAccountAPIURL := os.Getenv("BROKER_API_BASE") + "/v1/accounts/" + accountID + "/ach_relationships/" + bankID
-
-	client := &http.Client{}
-	req, _ := http.NewRequest("DELETE", deleteAccountAPIURL, nil)
-	req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
-	response, _ := client.Do(req)
-
-	responseData, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		apperr.Response(c, apperr.New(http.StatusInternalServerError, "Something went wrong. Try again later."))
+	if err := a.svc.DetachBankAccount(user, c.Param("bank_id")); err != nil {
+		apperr.Response(c, err)
 		return
 	}
-
-	var responseObject interface{}
-	json.Unmarshal(responseData, &responseObject)
-	c.JSON(response.StatusCode, responseObject)
+	c.Status(http.StatusNoContent)
 }
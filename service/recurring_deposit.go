@@ -0,0 +1,105 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/recurring"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecurringDepositRouter sets up the routes for scheduled deposits
+func RecurringDepositRouter(svc *recurring.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := RecurringDeposit{svc, acc}
+
+	ar := r.Group("/transfers/recurring")
+	ar.GET("", a.list)
+	ar.POST("", a.create)
+	ar.GET("/:id/runs", a.runs)
+	ar.POST("/:id/resume", a.resume)
+	ar.DELETE("/:id", a.cancel)
+}
+
+// RecurringDeposit represents the recurring deposit http service
+type RecurringDeposit struct {
+	svc *recurring.Service
+	acc *account.Service
+}
+
+func (a *RecurringDeposit) list(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	deposits, err := a.svc.List(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deposits)
+}
+
+func (a *RecurringDeposit) create(c *gin.Context) {
+	data, err := request.RecurringDepositBody(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	deposit, err := a.svc.Create(user, data)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, deposit)
+}
+
+func (a *RecurringDeposit) runs(c *gin.Context) {
+	depositID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	runs, err := a.svc.Runs(user, depositID)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+func (a *RecurringDeposit) resume(c *gin.Context) {
+	depositID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	deposit, err := a.svc.Resume(user, depositID)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deposit)
+}
+
+func (a *RecurringDeposit) cancel(c *gin.Context) {
+	depositID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	if err := a.svc.Cancel(user, depositID); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/referral"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReferralRouter sets up the routes for a user's referrals
+func ReferralRouter(svc *referral.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := Referral{svc, acc}
+
+	r.GET("/referral", a.link)
+	r.GET("/referrals", a.summary)
+}
+
+// ReferralLeaderboardRouter sets up the public referral leaderboard, it needs no jwt
+func ReferralLeaderboardRouter(svc *referral.Service, r *gin.Engine) {
+	a := Referral{svc: svc}
+	r.GET("/referrals/leaderboard", a.leaderboard)
+}
+
+// Referral represents the referral http service
+type Referral struct {
+	svc *referral.Service
+	acc *account.Service
+}
+
+func (a *Referral) link(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	link, err := a.svc.Link(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+func (a *Referral) summary(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	summary, err := a.svc.Summary(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+func (a *Referral) leaderboard(c *gin.Context) {
+	entries, err := a.svc.Leaderboard()
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
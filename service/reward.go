@@ -0,0 +1,157 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/reward"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RewardRouter sets up the admin routes for referral programs, rewards that failed to pay out and rewards held for review
+func RewardRouter(svc *reward.Service, r *gin.RouterGroup) {
+	a := Reward{svc}
+
+	ar := r.Group("/rewards")
+	ar.GET("/outstanding", a.outstanding)
+	ar.GET("/review", a.review)
+	ar.POST("/:id/approve", a.approve)
+	ar.POST("/:id/retry", a.retry)
+	ar.POST("/:id/void", a.void)
+
+	pr := ar.Group("/programs")
+	pr.GET("", a.listPrograms)
+	pr.POST("", a.createProgram)
+	pr.GET("/:id", a.viewProgram)
+	pr.PUT("/:id", a.updateProgram)
+	pr.DELETE("/:id", a.endProgram)
+}
+
+// Reward represents the referral reward http service
+type Reward struct {
+	svc *reward.Service
+}
+
+func (a *Reward) outstanding(c *gin.Context) {
+	rewards, err := a.svc.ListOutstanding(c)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rewards)
+}
+
+func (a *Reward) review(c *gin.Context) {
+	rewards, err := a.svc.ListReview(c)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rewards)
+}
+
+func (a *Reward) approve(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	reward, err := a.svc.Approve(c, id)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reward)
+}
+
+func (a *Reward) retry(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	reward, err := a.svc.Retry(c, id)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reward)
+}
+
+func (a *Reward) void(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	reward, err := a.svc.Void(c, id)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reward)
+}
+
+func (a *Reward) listPrograms(c *gin.Context) {
+	programs, err := a.svc.ListPrograms(c)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, programs)
+}
+
+func (a *Reward) createProgram(c *gin.Context) {
+	data, err := request.RewardProgramBody(c)
+	if err != nil {
+		return
+	}
+	program, err := a.svc.CreateProgram(c, data)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, program)
+}
+
+func (a *Reward) viewProgram(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	program, err := a.svc.ViewProgram(c, id)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, program)
+}
+
+func (a *Reward) updateProgram(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	data, err := request.RewardProgramBody(c)
+	if err != nil {
+		return
+	}
+	program, err := a.svc.UpdateProgram(c, id, data)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, program)
+}
+
+func (a *Reward) endProgram(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	program, err := a.svc.EndProgram(c, id)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, program)
+}
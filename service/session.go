@@ -0,0 +1,66 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/repository/account"
+	"github.com/alpacahq/ribbit-backend/repository/session"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionRouter sets up the routes for the devices a user is signed in on
+func SessionRouter(svc *session.Service, acc *account.Service, r *gin.RouterGroup) {
+	a := Session{svc, acc}
+
+	sr := r.Group("/sessions")
+	sr.GET("", a.list)
+	sr.DELETE("", a.revokeAll)
+	sr.DELETE("/:id", a.revoke)
+}
+
+// Session represents the session http service
+type Session struct {
+	svc *session.Service
+	acc *account.Service
+}
+
+func (a *Session) list(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	sessions, err := a.svc.List(user)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (a *Session) revoke(c *gin.Context) {
+	sessionID, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	if err := a.svc.Revoke(user, sessionID); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *Session) revokeAll(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	if err := a.svc.RevokeAll(user); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
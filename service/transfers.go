@@ -1,7 +1,6 @@
 package service
 
 import (
-	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -9,8 +8,10 @@ import (
 	"strconv"
 
 	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
 	"github.com/alpacahq/ribbit-backend/repository/account"
 	"github.com/alpacahq/ribbit-backend/repository/transfer"
+	"github.com/alpacahq/ribbit-backend/request"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +29,14 @@ func TransferRouter(svc *transfer.Service, acc *account.Service, r *gin.RouterGr
 	ar.GET("/history", a.transfer)
 	ar.POST("/bank/:bank_id/deposit", a.createNewTransfer)
 	ar.DELETE("/:transfer_id/delete", a.deleteTransfer)
+
+	tr := r.Group("/transfers")
+	tr.POST("/bank/:bank_id/withdraw/otp", a.withdrawOTP)
+	tr.POST("/bank/:bank_id/withdraw", a.withdraw)
+	tr.GET("/limits", a.limits)
+	tr.GET("/limits/users/:id", a.userLimits)
+	tr.PUT("/limits/users/:id", a.updateUserLimits)
+	tr.GET("/:id", a.timeline)
 }
 
 // Auth represents auth http service
@@ -85,81 +94,121 @@ func (a *Transfer) createNewTransfer(c *gin.Context) {
 		return
 	}
 
-	if amount <= 0 {
-		apperr.Response(c, apperr.New(http.StatusBadRequest, "Amount must be greater than 0."))
+	t, err := a.svc.Deposit(user, bankID, amount)
+	if err != nil {
+		apperr.Response(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, t)
+}
 
-	if user.AccountID == "" {
-		apperr.Response(c, apperr.New(http.StatusBadRequest, "Account not found."))
+func (a *Transfer) deleteTransfer(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
+
+	resp, err := a.svc.Cancel(user, c.Param("transfer_id"))
+	if err != nil {
+		apperr.Response(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	createNewTransactionURL := os.Getenv("BROKER_API_BASE") + "/v1/accounts/" + user.AccountID + "/transfers"
-
-	client := &http.Client{}
-	createNewTransfer, _ := json.Marshal(map[string]interface{}{
-		"transfer_type":   "ach",
-		"relationship_id": bankID,
-		"amount":          amount,
-		"direction":       "INCOMING",
-	})
-	tranferBody := bytes.NewBuffer(createNewTransfer)
-
-	req, err := http.NewRequest("POST", createNewTransactionURL, tranferBody)
-	req.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
-
-	createTransfer, err := client.Do(req)
-	responseData, err := ioutil.ReadAll(createTransfer.Body)
+// timeline returns a transfer from our ledger with its status changes
+func (a *Transfer) timeline(c *gin.Context) {
+	transferID, err := request.ID(c)
 	if err != nil {
-		apperr.Response(c, apperr.New(createTransfer.StatusCode, "Something went wrong. Try again later."))
 		return
 	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
 
-	if createTransfer.StatusCode != 200 {
-		errorBody := ErrorBody{}
-		json.Unmarshal(responseData, &errorBody)
-		apperr.Response(c, apperr.New(createTransfer.StatusCode, errorBody.Message))
+	timeline, err := a.svc.Timeline(user, transferID)
+	if err != nil {
+		apperr.Response(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, timeline)
+}
 
-	var responseObject map[string]interface{}
-	json.Unmarshal(responseData, &responseObject)
+func (a *Transfer) withdrawOTP(c *gin.Context) {
+	data, err := request.WithdrawalOTPBody(c)
+	if err != nil {
+		return
+	}
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
 
-	c.JSON(createTransfer.StatusCode, responseObject)
+	if err := a.svc.RequestWithdrawalOTP(user, c.Param("bank_id"), data.Amount); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OTP sent.",
+	})
 }
 
-func (a *Transfer) deleteTransfer(c *gin.Context) {
+func (a *Transfer) withdraw(c *gin.Context) {
+	data, err := request.WithdrawBody(c)
+	if err != nil {
+		return
+	}
 	id, _ := c.Get("id")
 	user := a.acc.GetProfile(c, id.(int))
 
-	if user.AccountID == "" {
-		apperr.Response(c, apperr.New(http.StatusBadRequest, "Account not found."))
+	t, err := a.svc.Withdraw(user, c.Param("bank_id"), data.Amount, data.OTP)
+	if err != nil {
+		apperr.Response(c, err)
 		return
 	}
-	transferID := c.Param("transfer_id")
-
-	deleteTransfersListURL := os.Getenv("BROKER_API_BASE") + "/v1/accounts/" + user.AccountID + "/transfers/" + transferID
+	c.JSON(http.StatusOK, t)
+}
 
-	client := &http.Client{}
-	transferDeleteRequest, _ := http.NewRequest("DELETE", deleteTransfersListURL, nil)
-	transferDeleteRequest.Header.Add("Authorization", os.Getenv("BROKER_TOKEN"))
+func (a *Transfer) limits(c *gin.Context) {
+	id, _ := c.Get("id")
+	user := a.acc.GetProfile(c, id.(int))
 
-	transferDeleteResponse, _ := client.Do(transferDeleteRequest)
-	transferDeleteBody, err := ioutil.ReadAll(transferDeleteResponse.Body)
+	limits, err := a.svc.Limits(user)
 	if err != nil {
-		apperr.Response(c, apperr.New(transferDeleteResponse.StatusCode, "Something went wrong. Try again later."))
+		apperr.Response(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, limits)
+}
 
-	if transferDeleteResponse.StatusCode != 200 {
-		errorBody := ErrorBody{}
-		json.Unmarshal(transferDeleteBody, &errorBody)
-		apperr.Response(c, apperr.New(transferDeleteResponse.StatusCode, errorBody.Message))
+func (a *Transfer) userLimits(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	limits, err := a.svc.ViewUserLimits(c, id)
+	if err != nil {
+		apperr.Response(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, limits)
+}
 
-	var responseObject interface{}
-	json.Unmarshal(transferDeleteBody, &responseObject)
-	c.JSON(transferDeleteResponse.StatusCode, responseObject)
+func (a *Transfer) updateUserLimits(c *gin.Context) {
+	id, err := request.ID(c)
+	if err != nil {
+		return
+	}
+	data, err := request.TransferLimitsBody(c)
+	if err != nil {
+		return
+	}
+	limits, err := a.svc.UpdateUserLimits(c, id, &model.TransferLimit{
+		DepositDaily:      data.DepositDaily,
+		DepositWeekly:     data.DepositWeekly,
+		DepositMonthly:    data.DepositMonthly,
+		WithdrawalDaily:   data.WithdrawalDaily,
+		WithdrawalWeekly:  data.WithdrawalWeekly,
+		WithdrawalMonthly: data.WithdrawalMonthly,
+	}, data.PerTransfer)
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, limits)
 }
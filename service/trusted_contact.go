@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/alpacahq/ribbit-backend/apperr"
+	"github.com/alpacahq/ribbit-backend/model"
+	"github.com/alpacahq/ribbit-backend/repository/trustedcontact"
+	"github.com/alpacahq/ribbit-backend/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedContactRouter sets up the trusted contact routes
+func TrustedContactRouter(svc *trustedcontact.Service, r *gin.RouterGroup) {
+	t := TrustedContact{svc}
+
+	tr := r.Group("/account/trusted-contact")
+	tr.GET("", t.view)
+	tr.POST("", t.add)
+	tr.PATCH("", t.update)
+	tr.DELETE("", t.remove)
+}
+
+// TrustedContact represents the trusted contact http service
+type TrustedContact struct {
+	svc *trustedcontact.Service
+}
+
+func (t *TrustedContact) view(c *gin.Context) {
+	id, _ := c.Get("id")
+	contact, err := t.svc.View(id.(int))
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+func (t *TrustedContact) add(c *gin.Context) {
+	id, _ := c.Get("id")
+	r, err := request.TrustedContactSave(c)
+	if err != nil {
+		return
+	}
+	contact, err := t.svc.Add(id.(int), toTrustedContact(r))
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, contact)
+}
+
+func (t *TrustedContact) update(c *gin.Context) {
+	id, _ := c.Get("id")
+	r, err := request.TrustedContactSave(c)
+	if err != nil {
+		return
+	}
+	contact, err := t.svc.Update(id.(int), toTrustedContact(r))
+	if err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+func (t *TrustedContact) remove(c *gin.Context) {
+	id, _ := c.Get("id")
+	if err := t.svc.Remove(id.(int)); err != nil {
+		apperr.Response(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func toTrustedContact(r *request.TrustedContact) *model.TrustedContact {
+	return &model.TrustedContact{
+		GivenName:     r.GivenName,
+		FamilyName:    r.FamilyName,
+		Email:         r.Email,
+		Phone:         r.Phone,
+		StreetAddress: r.StreetAddress,
+		City:          r.City,
+		State:         r.State,
+		PostalCode:    r.PostalCode,
+		Country:       r.Country,
+	}
+}
@@ -0,0 +1,56 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the SHA1 test vectors of RFC 6238, truncated to 6 digits
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step, ok := totp.Validate(rfcSecret, "050471", now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// the previous period is still accepted for clock drift, older ones aren't
+	_, ok = totp.Validate(rfcSecret, "050471", now.Add(totp.Period))
+	assert.True(t, ok)
+	_, ok = totp.Validate(rfcSecret, "050471", now.Add(2*totp.Period))
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(rfcSecret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := totp.URI("Ribbit", "jane@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Ribbit:jane@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=Ribbit")
+}
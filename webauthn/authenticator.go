@@ -0,0 +1,160 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// NewSoftAuthenticator creates a software passkey authenticator with ES256 keys, for tests
+func NewSoftAuthenticator(rpID, origin string) *SoftAuthenticator {
+	return &SoftAuthenticator{RPID: rpID, Origin: origin, UserVerified: true, credentials: map[string]*softCredential{}}
+}
+
+// SoftAuthenticator is a software passkey authenticator for tests, it keeps one signature counter
+// per credential like a hardware security key
+type SoftAuthenticator struct {
+	RPID   string
+	Origin string
+	// UserVerified sets whether the authenticator reports it verified the user
+	UserVerified bool
+
+	mu          sync.Mutex
+	credentials map[string]*softCredential
+}
+
+type softCredential struct {
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// Attestation is the response of navigator.credentials.create()
+type Attestation struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// Assertion is the response of navigator.credentials.get()
+type Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+var errUnknownCredential = errors.New("webauthn: unknown credential")
+
+// Create makes a new credential for the user handle from the options of a registration ceremony
+func (a *SoftAuthenticator) Create(options *CreationOptions) (*Attestation, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	userHandle, err := Decode(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.credentials[string(id)] = &softCredential{key: key, userHandle: userHandle}
+	a.mu.Unlock()
+
+	cose := encodeCBOR(cborMap{
+		coseKty: ktyEC2,
+		coseAlg: AlgES256,
+		coseCrv: crvP256,
+		coseX:   pad32(key.X.Bytes()),
+		coseY:   pad32(key.Y.Bytes()),
+	})
+	attested := make([]byte, 18, 18+len(id)+len(cose))
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(id)))
+	attested = append(append(attested, id...), cose...)
+	authData := append(a.authData(flagAttestedData, 0), attested...)
+
+	clientData, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	return &Attestation{
+		CredentialID:      id,
+		ClientDataJSON:    clientData,
+		AttestationObject: encodeCBOR(cborMap{"fmt": "none", "attStmt": cborMap{}, "authData": authData}),
+	}, nil
+}
+
+// Get signs the challenge of a login ceremony with a credential, incrementing its signature counter
+func (a *SoftAuthenticator) Get(credentialID []byte, challenge string) (*Assertion, error) {
+	a.mu.Lock()
+	c, ok := a.credentials[string(credentialID)]
+	if ok {
+		c.signCount++
+	}
+	a.mu.Unlock()
+	if !ok {
+		return nil, errUnknownCredential
+	}
+
+	authData := a.authData(0, c.signCount)
+	clientData, err := a.clientData("webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	r, ss, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, ss})
+	if err != nil {
+		return nil, err
+	}
+	return &Assertion{
+		CredentialID:      credentialID,
+		ClientDataJSON:    clientData,
+		AuthenticatorData: authData,
+		Signature:         sig,
+		UserHandle:        c.userHandle,
+	}, nil
+}
+
+// SetSignCount sets the signature counter of a credential, setting it back makes the authenticator look cloned
+func (a *SoftAuthenticator) SetSignCount(credentialID []byte, count uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.credentials[string(credentialID)]; ok {
+		c.signCount = count
+	}
+}
+
+func (a *SoftAuthenticator) authData(flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	b := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], signCount)
+	return b
+}
+
+func (a *SoftAuthenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(ClientData{Type: ceremony, Challenge: challenge, Origin: a.Origin})
+}
+
+func pad32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
@@ -0,0 +1,182 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// Authenticators encode attestation objects and public keys in CBOR (RFC 7049). Only the subset they
+// use is supported: integers, byte and text strings, arrays, maps, tags and simple values, all of
+// definite length.
+
+var errCBOR = errors.New("webauthn: malformed CBOR")
+
+// maxDepth bounds how deeply arrays and maps may nest
+const maxDepth = 16
+
+// decodeCBOR decodes the first item of b and returns it with the bytes which follow it. Integers decode
+// to int64, byte strings to []byte, text strings to string, arrays to []interface{} and maps to
+// map[interface{}]interface{} keyed by int64 or string
+func decodeCBOR(b []byte) (interface{}, []byte, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (interface{}, []byte, error) {
+	if len(b) == 0 || depth > maxDepth {
+		return nil, nil, errCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info == 24 && len(b) >= 1:
+		n, b = uint64(b[0]), b[1:]
+	case info == 25 && len(b) >= 2:
+		n, b = uint64(binary.BigEndian.Uint16(b)), b[2:]
+	case info == 26 && len(b) >= 4:
+		n, b = uint64(binary.BigEndian.Uint32(b)), b[4:]
+	case info == 27 && len(b) >= 8:
+		n, b = binary.BigEndian.Uint64(b), b[8:]
+	default:
+		// indefinite lengths and truncated arguments
+		return nil, nil, errCBOR
+	}
+
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(n), b, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if n > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		if major == 3 {
+			return string(b[:n]), b[n:], nil
+		}
+		return append([]byte{}, b[:n]...), b[n:], nil
+	case 4:
+		if n > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			var err error
+			if items[i], b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, b, nil
+	case 5:
+		if n > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, rest, err := decodeItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if m[k], b, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return m, b, nil
+	case 6:
+		// tags only annotate the item which follows
+		return decodeItem(b, depth+1)
+	default:
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22, 23:
+			return nil, b, nil
+		}
+		return nil, nil, errCBOR
+	}
+}
+
+// cborMap is a map encoded in CTAP2 canonical order, see encodeCBOR
+type cborMap map[interface{}]interface{}
+
+// encodeCBOR encodes int, int64, []byte, string, bool and cborMap values
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case cborMap:
+		keys := make([][]byte, 0, len(v))
+		values := make(map[string][]byte, len(v))
+		for k, item := range v {
+			key := encodeCBOR(k)
+			keys = append(keys, key)
+			values[string(key)] = encodeCBOR(item)
+		}
+		// shorter keys first, then bytewise
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return string(keys[i]) < string(keys[j])
+		})
+		b := cborHead(5, uint64(len(v)))
+		for _, k := range keys {
+			b = append(append(b, k...), values[string(k)]...)
+		}
+		return b
+	}
+	panic("webauthn: can't encode CBOR value")
+}
+
+func cborHead(major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return []byte{major | byte(n)}
+	case n <= math.MaxUint8:
+		return []byte{major | 24, byte(n)}
+	case n <= math.MaxUint16:
+		b := []byte{major | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		return b
+	case n <= math.MaxUint32:
+		b := []byte{major | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		return b
+	}
+	b := []byte{major | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(b[1:], n)
+	return b
+}
@@ -0,0 +1,117 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 8152) of the credential keys we accept
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE key parameters
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1 // n for RSA
+	coseX   = -2 // e for RSA
+	coseY   = -3
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+var (
+	errUnsupportedKey = errors.New("webauthn: unsupported credential public key")
+	errSignature      = errors.New("webauthn: invalid signature")
+)
+
+// publicKey is a parsed COSE credential public key
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey parses a COSE_Key as stored with a credential
+func parsePublicKey(b []byte) (*publicKey, error) {
+	v, rest, err := decodeCBOR(b)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, errUnsupportedKey
+	}
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errUnsupportedKey
+		}
+		k := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !k.Curve.IsOnCurve(k.X, k.Y) {
+			return nil, errUnsupportedKey
+		}
+		return &publicKey{alg, k}, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, errUnsupportedKey
+		}
+		return &publicKey{alg, ed25519.PublicKey(x)}, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseCrv)].([]byte)
+		e, _ := m[int64(coseX)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, errUnsupportedKey
+		}
+		return &publicKey{alg, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}}, nil
+	}
+	return nil, errUnsupportedKey
+}
+
+// verify checks sig is the key's signature of data
+func (k *publicKey) verify(data, sig []byte) error {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		var s struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &s); err != nil || len(rest) != 0 {
+			return errSignature
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.Verify(key, digest[:], s.R, s.S) {
+			return errSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return errSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return errSignature
+		}
+	default:
+		return errUnsupportedKey
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package webauthn
+
+import (
+	"bytes"
+	"testing"
+)
+
+// attestationObject returns the attestation object and authenticator data of a registration with the soft authenticator
+func attestationObject(f *testing.F) ([]byte, []byte) {
+	a := NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+	att, err := a.Create(&CreationOptions{Challenge: "challenge", User: UserEntity{ID: Encode([]byte("1"))}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	v, _, err := decodeCBOR(att.AttestationObject)
+	if err != nil {
+		f.Fatal(err)
+	}
+	return att.AttestationObject, v.(map[interface{}]interface{})["authData"].([]byte)
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	object, authData := attestationObject(f)
+	f.Add(object)
+	f.Add(authData[minAuthDataLength+18+16:])
+	f.Add(encodeCBOR(cborMap{1: int64(-7), "a": []byte{1, 2}, "b": true, -1: cborMap{}}))
+	f.Add([]byte{0x9f, 0xff})                                           // indefinite length array
+	f.Add([]byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) // byte string longer than the input
+	f.Add([]byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) // integer beyond int64
+	f.Add(bytes.Repeat([]byte{0x81}, maxDepth+2))                       // nested too deep
+	f.Add([]byte{0xc6, 0xc6, 0xc6, 0x01})                               // tags
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		v, rest, err := decodeCBOR(b)
+		if err != nil {
+			return
+		}
+		if len(rest) >= len(b) || !bytes.HasSuffix(b, rest) {
+			t.Fatalf("rest %x isn't what follows the decoded item of %x", rest, b)
+		}
+		if m, ok := v.(map[interface{}]interface{}); ok {
+			for k := range m {
+				switch k.(type) {
+				case int64, string:
+				default:
+					t.Fatalf("map key %v of type %T", k, k)
+				}
+			}
+		}
+		// the decoded item can be fed to the key parser without panicking
+		parsePublicKey(b[:len(b)-len(rest)])
+	})
+}
+
+func FuzzAuthData(f *testing.F) {
+	_, authData := attestationObject(f)
+	rp := &RelyingParty{ID: "alpa.ca", Origins: []string{"https://alpa.ca"}, UserVerification: true}
+	f.Add(authData)
+	f.Add(authData[:minAuthDataLength])
+	f.Add(authData[:minAuthDataLength+18])
+	f.Add(append(authData[:minAuthDataLength+16:minAuthDataLength+16], 0xff, 0xff))
+	f.Add(append(append([]byte{}, authData...), 0xa1, 0x01, 0x02)) // extensions after the key
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		flags, _, err := rp.checkAuthData(b)
+		if err != nil {
+			return
+		}
+		if len(b) < minAuthDataLength || flags != b[32] {
+			t.Fatalf("accepted authenticator data %x", b)
+		}
+		aaguid, id, key, err := parseAttestedData(b[minAuthDataLength:])
+		if err != nil {
+			return
+		}
+		if len(aaguid) != 16 || len(id) == 0 || len(id) > maxCredentialIDLength || len(key) == 0 {
+			t.Fatalf("attested data of %x split into %x, %x and %x", b, aaguid, id, key)
+		}
+		if !bytes.Contains(b, key) {
+			t.Fatalf("key %x isn't part of %x", key, b)
+		}
+		parsePublicKey(key)
+	})
+}
@@ -0,0 +1,323 @@
+// Package webauthn implements the relying party side of the WebAuthn registration and assertion ceremonies
+// (https://www.w3.org/TR/webauthn-2/) as needed for passkeys: "none" attestation and ES256, EdDSA or
+// RS256 credential keys
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent       = 0x01
+	flagUserVerified      = 0x04
+	flagAttestedData      = 0x40
+	minAuthDataLength     = 37 // rpIdHash, flags and signCount
+	maxCredentialIDLength = 1023
+)
+
+var (
+	errClientData       = errors.New("webauthn: invalid client data")
+	errCeremony         = errors.New("webauthn: client data is for another ceremony")
+	errChallenge        = errors.New("webauthn: challenge doesn't match")
+	errOrigin           = errors.New("webauthn: origin isn't allowed")
+	errAuthData         = errors.New("webauthn: malformed authenticator data")
+	errRPID             = errors.New("webauthn: credential is scoped to another relying party")
+	errUserPresence     = errors.New("webauthn: user wasn't present")
+	errUserVerification = errors.New("webauthn: user wasn't verified")
+	errAttestation      = errors.New("webauthn: unsupported attestation")
+)
+
+// RelyingParty is the site credentials are scoped to
+type RelyingParty struct {
+	// ID is the domain credentials are scoped to, e.g. alpa.ca
+	ID string
+	// Name is shown by the authenticator
+	Name string
+	// Origins are where ceremonies may run, web origins like https://alpa.ca or native apps like android:apk-key-hash:...
+	Origins []string
+	// UserVerification requires the authenticator to verify the user with a PIN or biometrics, not just their presence
+	UserVerification bool
+	// Timeout is how long the client should wait for the user
+	Timeout time.Duration
+}
+
+// Credential is a newly registered public key credential
+type Credential struct {
+	// ID is the base64url encoded credential id
+	ID string
+	// PublicKey is the credential public key in COSE format
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// CreationOptions are the PublicKeyCredentialCreationOptions for navigator.credentials.create(), binary values
+// base64url encoded
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the PublicKeyCredentialRequestOptions for navigator.credentials.get(), binary values
+// base64url encoded. Without AllowCredentials the user picks any of their passkeys for the relying party
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RPEntity describes the relying party to the authenticator
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity describes the account a credential is created for, ID is the base64url encoded user handle
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is a key type the relying party accepts
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// AuthenticatorSelection asks for a discoverable credential, i.e. a passkey
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	RequireResident  bool   `json:"requireResidentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// ClientData is the part of the client data the relying party checks
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// NewChallenge returns a random base64url encoded challenge
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Encode(b), nil
+}
+
+// Encode base64url encodes b without padding, as WebAuthn serializes binary values
+func Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode decodes a base64url value with or without padding
+func Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(string(bytes.TrimRight([]byte(s), "=")))
+}
+
+// ParseClientData parses the client data JSON of a ceremony, its challenge tells which one it belongs to
+func ParseClientData(clientDataJSON []byte) (*ClientData, error) {
+	c := new(ClientData)
+	if err := json.Unmarshal(clientDataJSON, c); err != nil {
+		return nil, errClientData
+	}
+	return c, nil
+}
+
+// CreationOptions returns the options to register a passkey for user, exclude lists the ids of the
+// credentials they already have so the same authenticator isn't registered twice
+func (rp *RelyingParty) CreationOptions(challenge string, user UserEntity, exclude []string) *CreationOptions {
+	descriptors := make([]CredentialDescriptor, len(exclude))
+	for i, id := range exclude {
+		descriptors[i] = CredentialDescriptor{Type: "public-key", ID: id}
+	}
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        RPEntity{ID: rp.ID, Name: rp.Name},
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			RequireResident:  true,
+			UserVerification: rp.userVerification(),
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options to sign in with any passkey of the relying party
+func (rp *RelyingParty) RequestOptions(challenge string) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: rp.userVerification(),
+	}
+}
+
+func (rp *RelyingParty) userVerification() string {
+	if rp.UserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create() to the challenge and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	v, rest, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	att, ok := v.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, errAttestation
+	}
+	// we ask for no attestation, so the client strips it
+	format, _ := att["fmt"].(string)
+	stmt, _ := att["attStmt"].(map[interface{}]interface{})
+	authData, _ := att["authData"].([]byte)
+	if format != "none" || stmt == nil || len(stmt) != 0 {
+		return nil, errAttestation
+	}
+
+	flags, signCount, err := rp.checkAuthData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagAttestedData == 0 {
+		return nil, errAuthData
+	}
+	aaguid, id, key, err := parseAttestedData(authData[minAuthDataLength:])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parsePublicKey(key); err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:        Encode(id),
+		PublicKey: append([]byte{}, key...),
+		SignCount: signCount,
+		AAGUID:    append([]byte{}, aaguid...),
+	}, nil
+}
+
+// parseAttestedData splits the attested credential data which follows the fixed part of the authenticator
+// data into the authenticator's AAGUID, the credential id and its COSE public key. Extensions may follow the key
+func parseAttestedData(data []byte) ([]byte, []byte, []byte, error) {
+	if len(data) < 18 {
+		return nil, nil, nil, errAuthData
+	}
+	aaguid := data[:16]
+	n := int(binary.BigEndian.Uint16(data[16:18]))
+	data = data[18:]
+	if n == 0 || n > maxCredentialIDLength || len(data) < n {
+		return nil, nil, nil, errAuthData
+	}
+	id := data[:n]
+	_, rest, err := decodeCBOR(data[n:])
+	if err != nil {
+		return nil, nil, nil, errAuthData
+	}
+	return aaguid, id, data[n : len(data)-len(rest)], nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get() to the challenge was signed by the
+// credential with publicKey and returns its signature counter
+func (rp *RelyingParty) VerifyAssertion(challenge string, publicKey, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	_, signCount, err := rp.checkAuthData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if err := key.verify(signed, signature); err != nil {
+		return 0, err
+	}
+	return signCount, nil
+}
+
+func (rp *RelyingParty) checkClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	c, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if c.Type != ceremony {
+		return errCeremony
+	}
+	if c.Challenge != challenge {
+		return errChallenge
+	}
+	if c.CrossOrigin {
+		return errOrigin
+	}
+	for _, o := range rp.Origins {
+		if c.Origin == o {
+			return nil
+		}
+	}
+	return errOrigin
+}
+
+// checkAuthData checks the authenticator data is for the relying party and returns its flags and signature counter
+func (rp *RelyingParty) checkAuthData(authData []byte) (byte, uint32, error) {
+	if len(authData) < minAuthDataLength {
+		return 0, 0, errAuthData
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return 0, 0, errRPID
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 {
+		return 0, 0, errUserPresence
+	}
+	if rp.UserVerification && flags&flagUserVerified == 0 {
+		return 0, 0, errUserVerification
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
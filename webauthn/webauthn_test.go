@@ -0,0 +1,108 @@
+package webauthn_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/ribbit-backend/webauthn"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func relyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{ID: "alpa.ca", Name: "Ribbit", Origins: []string{"https://alpa.ca"}, UserVerification: true, Timeout: 5 * time.Minute}
+}
+
+func register(t *testing.T, rp *webauthn.RelyingParty, a *webauthn.SoftAuthenticator) (*webauthn.Credential, []byte) {
+	options := rp.CreationOptions("register-challenge", webauthn.UserEntity{ID: webauthn.Encode([]byte("1")), Name: "alice@example.com"}, nil)
+	att, err := a.Create(options)
+	assert.NoError(t, err)
+	c, err := rp.VerifyRegistration("register-challenge", att.ClientDataJSON, att.AttestationObject)
+	assert.NoError(t, err)
+	return c, att.CredentialID
+}
+
+func TestRegistration(t *testing.T) {
+	rp := relyingParty()
+	options := rp.CreationOptions("challenge", webauthn.UserEntity{ID: webauthn.Encode([]byte("1")), Name: "alice@example.com"}, []string{"old"})
+	assert.Equal(t, int64(300000), options.Timeout)
+	assert.Equal(t, "required", options.AuthenticatorSelection.UserVerification)
+	assert.Equal(t, []webauthn.CredentialDescriptor{{Type: "public-key", ID: "old"}}, options.ExcludeCredentials)
+
+	att, err := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca").Create(options)
+	assert.NoError(t, err)
+	c, err := rp.VerifyRegistration("challenge", att.ClientDataJSON, att.AttestationObject)
+	assert.NoError(t, err)
+	assert.Equal(t, webauthn.Encode(att.CredentialID), c.ID)
+	assert.NotEmpty(t, c.PublicKey)
+	assert.Equal(t, uint32(0), c.SignCount)
+
+	_, err = rp.VerifyRegistration("another", att.ClientDataJSON, att.AttestationObject)
+	assert.Error(t, err)
+	_, err = rp.VerifyRegistration("challenge", att.ClientDataJSON, att.AttestationObject[:len(att.AttestationObject)-1])
+	assert.Error(t, err)
+
+	unverified := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+	unverified.UserVerified = false
+	cases := map[string]*webauthn.SoftAuthenticator{
+		"other origin":         webauthn.NewSoftAuthenticator("alpa.ca", "https://evil.example"),
+		"other relying party":  webauthn.NewSoftAuthenticator("evil.example", "https://alpa.ca"),
+		"user wasn't verified": unverified,
+	}
+	for name, a := range cases {
+		t.Run(name, func(t *testing.T) {
+			att, err := a.Create(options)
+			assert.NoError(t, err)
+			_, err = rp.VerifyRegistration("challenge", att.ClientDataJSON, att.AttestationObject)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	rp := relyingParty()
+	a := webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca")
+	c, id := register(t, rp, a)
+
+	for want := uint32(1); want <= 2; want++ {
+		as, err := a.Get(id, "login-challenge")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("1"), as.UserHandle)
+		count, err := rp.VerifyAssertion("login-challenge", c.PublicKey, as.ClientDataJSON, as.AuthenticatorData, as.Signature)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count)
+	}
+
+	as, err := a.Get(id, "login-challenge")
+	assert.NoError(t, err)
+	_, err = rp.VerifyAssertion("another", c.PublicKey, as.ClientDataJSON, as.AuthenticatorData, as.Signature)
+	assert.Error(t, err)
+
+	// the signature covers the authenticator data
+	tampered := append([]byte{}, as.AuthenticatorData...)
+	tampered[36]++
+	_, err = rp.VerifyAssertion("login-challenge", c.PublicKey, as.ClientDataJSON, tampered, as.Signature)
+	assert.Error(t, err)
+
+	// and only the credential's own key verifies it
+	other, _ := register(t, rp, webauthn.NewSoftAuthenticator("alpa.ca", "https://alpa.ca"))
+	_, err = rp.VerifyAssertion("login-challenge", other.PublicKey, as.ClientDataJSON, as.AuthenticatorData, as.Signature)
+	assert.Error(t, err)
+
+	// a registration response can't stand in for a login
+	options := rp.CreationOptions("login-challenge", webauthn.UserEntity{ID: webauthn.Encode([]byte("1"))}, nil)
+	att, err := a.Create(options)
+	assert.NoError(t, err)
+	_, err = rp.VerifyAssertion("login-challenge", c.PublicKey, att.ClientDataJSON, as.AuthenticatorData, as.Signature)
+	assert.Error(t, err)
+}
+
+func TestDecode(t *testing.T) {
+	for _, s := range []string{"AQID", "AQID=", "AQID=="} {
+		b, err := webauthn.Decode(s)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{1, 2, 3}, b)
+	}
+	_, err := webauthn.Decode("AQ+D")
+	assert.Error(t, err)
+}